@@ -0,0 +1,17 @@
+package agent
+
+import "context"
+
+// channelKey is the context key of the channel a loop run answers in
+type channelKey struct{}
+
+// WithChannel returns a context that tells tools which channel they answer in
+func WithChannel(ctx context.Context, channel string) context.Context {
+	return context.WithValue(ctx, channelKey{}, channel)
+}
+
+// ChannelFromContext returns the channel set by WithChannel, or ""
+func ChannelFromContext(ctx context.Context) string {
+	channel, _ := ctx.Value(channelKey{}).(string)
+	return channel
+}
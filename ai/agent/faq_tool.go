@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/faq"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// faqLookupLimit is how many FAQ entries are given back to the LLM
+const faqLookupLimit = 3
+
+// FAQSearcher finds FAQ entries for a question. *faq.Service satisfies this interface.
+type FAQSearcher interface {
+	Lookup(ctx context.Context, question string, limit int, categories []string) ([]faq.Match, error)
+}
+
+// FAQTool looks up the streamer's curated FAQ answers
+type FAQTool struct {
+	searcher FAQSearcher
+	// categories limits each channel's lookups to its FAQ categories. Channels without
+	// categories search every FAQ entry.
+	categories map[string][]string
+}
+
+// NewFAQTool creates a new FAQTool. categories maps a channel to the FAQ categories it
+// may use and may be nil.
+func NewFAQTool(searcher FAQSearcher, categories map[string][]string) *FAQTool {
+	return &FAQTool{searcher: searcher, categories: categories}
+}
+
+// Name returns the name of the tool
+func (f *FAQTool) Name() string {
+	return "faq_lookup"
+}
+
+// Description returns a description of the tool
+func (f *FAQTool) Description() string {
+	return "Look up answers to frequently asked questions about the stream and the streamer"
+}
+
+// faqArgs are the arguments of a faq_lookup tool call
+type faqArgs struct {
+	Question string `json:"question"`
+}
+
+// Call looks up the question. input is the JSON arguments of the tool call.
+func (f *FAQTool) Call(ctx context.Context, input string) (string, error) {
+	var args faqArgs
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", fmt.Errorf("failed to parse tool call arguments: %w", err)
+	}
+	if args.Question == "" {
+		return "", fmt.Errorf("question cannot be empty")
+	}
+
+	// Only the asking channel's categories, the same as its FAQ auto-responder
+	matches, err := f.searcher.Lookup(ctx, args.Question, faqLookupLimit, f.categories[ChannelFromContext(ctx)])
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "No FAQ entry matches that question.", nil
+	}
+
+	var b strings.Builder
+	for _, m := range matches {
+		fmt.Fprintf(&b, "Q: %s\nA: %s\n", m.Question, m.Response)
+	}
+	return b.String(), nil
+}
+
+// GetFAQToolDefinition returns the LLM tool definition for FAQ lookup
+func GetFAQToolDefinition() llms.Tool {
+	return llms.Tool{
+		Type: "function",
+		Function: &llms.FunctionDefinition{
+			Name:        "faq_lookup",
+			Description: "Look up the streamer's own answers to common questions, like their setup, schedule, editor or job",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"question": map[string]any{
+						"type":        "string",
+						"description": "The question to look up",
+					},
+				},
+				"required": []string{"question"},
+			},
+		},
+	}
+}
+
+// NewFAQRegistryTool returns the FAQ lookup tool ready to register
+func NewFAQRegistryTool(searcher FAQSearcher, categories map[string][]string) Tool {
+	return Tool{Definition: GetFAQToolDefinition(), Impl: NewFAQTool(searcher, categories)}
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Soypete/twitch-llm-bot/ai/webfetch"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// PageSummarizer fetches a page and summarizes it. *webfetch.Fetcher satisfies this interface.
+type PageSummarizer interface {
+	FetchSummary(ctx context.Context, url, question string) (string, error)
+}
+
+// FetchPageTool reads a web page, such as a link from web search results
+type FetchPageTool struct {
+	summarizer PageSummarizer
+}
+
+// NewFetchPageTool creates a new FetchPageTool
+func NewFetchPageTool(summarizer PageSummarizer) *FetchPageTool {
+	return &FetchPageTool{summarizer: summarizer}
+}
+
+// Name returns the name of the tool
+func (f *FetchPageTool) Name() string {
+	return "fetch_page"
+}
+
+// Description returns a description of the tool
+func (f *FetchPageTool) Description() string {
+	return "Read a web page and summarize it"
+}
+
+// fetchPageArgs are the arguments of a fetch_page tool call
+type fetchPageArgs struct {
+	URL      string `json:"url"`
+	Question string `json:"question"`
+}
+
+// Call fetches and summarizes the page. input is the JSON arguments of the tool call.
+func (f *FetchPageTool) Call(ctx context.Context, input string) (string, error) {
+	var args fetchPageArgs
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", fmt.Errorf("failed to parse tool call arguments: %w", err)
+	}
+	if args.URL == "" {
+		return "", fmt.Errorf("url cannot be empty")
+	}
+
+	summary, err := f.summarizer.FetchSummary(ctx, args.URL, args.Question)
+	if errors.Is(err, webfetch.ErrDomainNotAllowed) {
+		return fmt.Sprintf("%s cannot be read, its site is not on the allowed list. Use the search results instead.", args.URL), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	RecordSources(ctx, types.Source{URL: args.URL, Tool: f.Name()})
+	return fmt.Sprintf("Summary of %s: %s", args.URL, summary), nil
+}
+
+// GetFetchPageToolDefinition returns the LLM tool definition for reading a page
+func GetFetchPageToolDefinition() llms.Tool {
+	return llms.Tool{
+		Type: "function",
+		Function: &llms.FunctionDefinition{
+			Name:        "fetch_page",
+			Description: "Read a web page, such as a link from web_search results, when its snippet is too thin to answer the question. Returns a short summary of the page",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"url": map[string]any{
+						"type":        "string",
+						"description": "The http or https URL of the page",
+					},
+					"question": map[string]any{
+						"type":        "string",
+						"description": "What to look for on the page",
+					},
+				},
+				"required": []string{"url"},
+			},
+		},
+	}
+}
+
+// NewFetchPageRegistryTool returns the page reading tool ready to register
+func NewFetchPageRegistryTool(summarizer PageSummarizer) Tool {
+	return Tool{Definition: GetFetchPageToolDefinition(), Impl: NewFetchPageTool(summarizer)}
+}
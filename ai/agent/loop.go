@@ -0,0 +1,176 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// DefaultMaxSteps is how many times the loop asks the LLM before it forces a final answer
+const DefaultMaxSteps = 4
+
+// maxToolResultLength keeps one tool result from filling the model's context
+const maxToolResultLength = 4000
+
+// ToolStep records one tool call made by the loop
+type ToolStep struct {
+	Name      string
+	Arguments string
+	Result    string
+	Error     string
+	Duration  time.Duration
+}
+
+// LoopResult is the final answer of the loop and the tool calls that led to it
+type LoopResult struct {
+	Content string
+	Steps   []ToolStep
+	// Sources are the pages the tools used, such as web search results
+	Sources []types.Source
+	// BudgetExhausted is true when the LLM still wanted tools after the last step and
+	// had to answer without them
+	BudgetExhausted bool
+}
+
+// Loop lets the LLM call tools. It runs every tool call, feeds the results back and asks
+// again until the LLM answers without calling a tool or the step budget runs out.
+type Loop struct {
+	llm      llms.Model
+	registry *Registry
+	maxSteps int
+	logger   *logging.Logger
+}
+
+// NewLoop creates a tool-calling loop. maxSteps <= 0 uses DefaultMaxSteps.
+func NewLoop(llm llms.Model, registry *Registry, maxSteps int, logger *logging.Logger) *Loop {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	return &Loop{llm: llm, registry: registry, maxSteps: maxSteps, logger: logger}
+}
+
+// Run sends messages to the LLM with the registry's tools and runs the tool calls it asks
+// for. opts are passed to every LLM call.
+func (l *Loop) Run(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*LoopResult, error) {
+	result := &LoopResult{}
+	messages = append([]llms.MessageContent(nil), messages...)
+	ctx, sources := withSources(ctx)
+
+	for step := 0; step < l.maxSteps; step++ {
+		callOpts := opts
+		if defs := l.registry.Definitions(); len(defs) > 0 {
+			callOpts = append(append([]llms.CallOption(nil), opts...), llms.WithTools(defs))
+		}
+
+		choice, err := l.generate(ctx, messages, callOpts)
+		if err != nil {
+			return nil, err
+		}
+		if len(choice.ToolCalls) == 0 {
+			result.Content = choice.Content
+			result.Sources = sources.list()
+			return result, nil
+		}
+
+		messages = append(messages, toolCallMessage(choice))
+		for _, call := range choice.ToolCalls {
+			toolStep := l.runTool(ctx, call)
+			result.Steps = append(result.Steps, toolStep)
+			messages = append(messages, llms.MessageContent{
+				Role: llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{
+					ToolCallID: call.ID,
+					Name:       toolStep.Name,
+					Content:    toolResultContent(toolStep),
+				}},
+			})
+		}
+	}
+
+	// Out of steps: ask for an answer with what the tools returned so far
+	l.logger.Warn("tool loop step budget exhausted", "maxSteps", l.maxSteps, "toolCalls", len(result.Steps))
+	metrics.AgentStepBudgetExhaustedTotal.Inc()
+	result.BudgetExhausted = true
+	messages = append(messages, llms.TextParts(llms.ChatMessageTypeSystem,
+		"You cannot call any more tools. Answer the user now with the information you have."))
+	choice, err := l.generate(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Content = choice.Content
+	result.Sources = sources.list()
+	return result, nil
+}
+
+func (l *Loop) generate(ctx context.Context, messages []llms.MessageContent, opts []llms.CallOption) (*llms.ContentChoice, error) {
+	resp, err := l.llm.GenerateContent(ctx, messages, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get llm response: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("llm returned no choices")
+	}
+	return resp.Choices[0], nil
+}
+
+// runTool runs one tool call. Errors are recorded and given back to the LLM instead of
+// ending the loop, so it can try something else.
+func (l *Loop) runTool(ctx context.Context, call llms.ToolCall) ToolStep {
+	step := ToolStep{}
+	if call.FunctionCall != nil {
+		step.Name = call.FunctionCall.Name
+		step.Arguments = call.FunctionCall.Arguments
+	}
+
+	start := time.Now()
+	out, err := l.registry.Call(ctx, call)
+	step.Duration = time.Since(start)
+
+	status := "success"
+	if err != nil {
+		status = "error"
+		step.Error = err.Error()
+		l.logger.Warn("tool call failed", "tool", step.Name, "error", err.Error(), "duration", step.Duration)
+	} else {
+		step.Result = out
+		l.logger.Debug("tool call finished", "tool", step.Name, "resultLength", len(out), "duration", step.Duration)
+	}
+	metrics.AgentToolCallsTotal.WithLabelValues(step.Name, status).Inc()
+	return step
+}
+
+// toolCallMessage is the assistant message that asked for the tool calls
+func toolCallMessage(choice *llms.ContentChoice) llms.MessageContent {
+	msg := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+	if choice.Content != "" {
+		msg.Parts = append(msg.Parts, llms.TextContent{Text: choice.Content})
+	}
+	for _, call := range choice.ToolCalls {
+		msg.Parts = append(msg.Parts, call)
+	}
+	return msg
+}
+
+func toolResultContent(step ToolStep) string {
+	if step.Error != "" {
+		return "Tool error: " + step.Error
+	}
+	if len(step.Result) > maxToolResultLength {
+		// Cut at a rune boundary so the model never gets invalid UTF-8
+		cut := maxToolResultLength
+		for cut > 0 && !utf8.RuneStart(step.Result[cut]) {
+			cut--
+		}
+		return step.Result[:cut] + "..."
+	}
+	return step.Result
+}
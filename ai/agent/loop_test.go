@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Soypete/twitch-llm-bot/ai/websearch"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// scriptedLLM returns one canned choice per call and records what it was sent
+type scriptedLLM struct {
+	choices  []*llms.ContentChoice
+	calls    [][]llms.MessageContent
+	withTool []bool
+}
+
+func (s *scriptedLLM) GenerateContent(_ context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	callOpts := llms.CallOptions{}
+	for _, opt := range opts {
+		opt(&callOpts)
+	}
+	s.calls = append(s.calls, messages)
+	s.withTool = append(s.withTool, len(callOpts.Tools) > 0)
+	if len(s.calls) > len(s.choices) {
+		return nil, errors.New("no more scripted responses")
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{s.choices[len(s.calls)-1]}}, nil
+}
+
+func (s *scriptedLLM) Call(context.Context, string, ...llms.CallOption) (string, error) {
+	return "", nil
+}
+
+func TestLoop_Run(t *testing.T) {
+	tests := []struct {
+		name          string
+		choices       []*llms.ContentChoice
+		maxSteps      int
+		wantContent   string
+		wantSteps     []string
+		wantExhausted bool
+		wantErr       bool
+	}{
+		{
+			name:        "answers without tools",
+			choices:     []*llms.ContentChoice{{Content: "hi chat"}},
+			wantContent: "hi chat",
+		},
+		{
+			name: "calls two tools then answers",
+			choices: []*llms.ContentChoice{
+				{ToolCalls: []llms.ToolCall{toolCall("a", "web_search", `{"query":"go 1.26"}`)}},
+				{ToolCalls: []llms.ToolCall{toolCall("b", "faq_lookup", `{"question":"editor"}`)}},
+				{Content: "Go 1.26 is out and Pete uses neovim"},
+			},
+			wantContent: "Go 1.26 is out and Pete uses neovim",
+			wantSteps:   []string{"web_search", "faq_lookup"},
+		},
+		{
+			name: "unknown tool error is fed back",
+			choices: []*llms.ContentChoice{
+				{ToolCalls: []llms.ToolCall{toolCall("a", "delete_everything", `{}`)}},
+				{Content: "I can't do that"},
+			},
+			wantContent: "I can't do that",
+			wantSteps:   []string{"delete_everything"},
+		},
+		{
+			name: "step budget forces an answer",
+			choices: []*llms.ContentChoice{
+				{ToolCalls: []llms.ToolCall{toolCall("a", "web_search", `{"query":"1"}`)}},
+				{ToolCalls: []llms.ToolCall{toolCall("b", "web_search", `{"query":"2"}`)}},
+				{Content: "here is what I found"},
+			},
+			maxSteps:      2,
+			wantContent:   "here is what I found",
+			wantSteps:     []string{"web_search", "web_search"},
+			wantExhausted: true,
+		},
+		{
+			name:    "llm error",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			require.NoError(t, registry.Register(echoTool("web_search")))
+			require.NoError(t, registry.Register(echoTool("faq_lookup")))
+			llm := &scriptedLLM{choices: tt.choices}
+
+			result, err := NewLoop(llm, registry, tt.maxSteps, nil).Run(context.Background(),
+				[]llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hey pedro")})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantContent, result.Content)
+			assert.Equal(t, tt.wantExhausted, result.BudgetExhausted)
+			var steps []string
+			for _, step := range result.Steps {
+				steps = append(steps, step.Name)
+			}
+			assert.Equal(t, tt.wantSteps, steps)
+			if tt.wantExhausted {
+				assert.False(t, llm.withTool[len(llm.withTool)-1], "final call after the budget should not offer tools")
+			}
+		})
+	}
+}
+
+func TestLoop_FeedsToolResultsBack(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(echoTool("web_search")))
+	llm := &scriptedLLM{choices: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{toolCall("call-1", "web_search", `{"query":"go"}`)}},
+		{Content: "done"},
+	}}
+
+	_, err := NewLoop(llm, registry, 0, nil).Run(context.Background(),
+		[]llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "what's new in go")})
+	require.NoError(t, err)
+	require.Len(t, llm.calls, 2)
+
+	second := llm.calls[1]
+	require.Len(t, second, 3)
+	assert.Equal(t, llms.ChatMessageTypeAI, second[1].Role)
+	assert.IsType(t, llms.ToolCall{}, second[1].Parts[0])
+	assert.Equal(t, llms.ChatMessageTypeTool, second[2].Role)
+	resp, ok := second[2].Parts[0].(llms.ToolCallResponse)
+	require.True(t, ok)
+	assert.Equal(t, "call-1", resp.ToolCallID)
+	assert.Equal(t, `web_search:{"query":"go"}`, resp.Content)
+}
+
+func TestLoop_CollectsSources(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(NewWebSearchRegistryTool(websearch.NewFixture("", map[string][]websearch.Result{
+		"go release": {
+			{Title: "Go 1.26 is released", URL: "https://go.dev/blog/go1.26", Snippet: "Go 1.26 is out now."},
+			{Title: "Release history", URL: "https://go.dev/doc/devel/release"},
+		},
+	}))))
+	require.NoError(t, registry.Register(NewFetchPageRegistryTool(&stubSummarizer{})))
+	llm := &scriptedLLM{choices: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{toolCall("call-1", "web_search", `{"query":"go release"}`)}},
+		{ToolCalls: []llms.ToolCall{
+			toolCall("call-2", "fetch_page", `{"url":"https://go.dev/blog/go1.26"}`),
+			toolCall("call-3", "fetch_page", `{"url":"https://tip.golang.org/doc/go1.26"}`),
+		}},
+		{Content: "Go 1.26 is out"},
+	}}
+
+	result, err := NewLoop(llm, registry, 0, nil).Run(context.Background(),
+		[]llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "what's new in go")})
+	require.NoError(t, err)
+	assert.Equal(t, []types.Source{
+		{Title: "Go 1.26 is released", URL: "https://go.dev/blog/go1.26", Tool: "web_search"},
+		{Title: "Release history", URL: "https://go.dev/doc/devel/release", Tool: "web_search"},
+		{URL: "https://tip.golang.org/doc/go1.26", Tool: "fetch_page"},
+	}, result.Sources, "pages are recorded once, in the order they were first used")
+
+	// Runs do not share sources
+	llm = &scriptedLLM{choices: []*llms.ContentChoice{{Content: "hi"}}}
+	result, err = NewLoop(llm, registry, 0, nil).Run(context.Background(),
+		[]llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi pedro")})
+	require.NoError(t, err)
+	assert.Empty(t, result.Sources)
+}
+
+func TestToolResultContent(t *testing.T) {
+	tests := []struct {
+		name string
+		step ToolStep
+		want string
+	}{
+		{name: "result", step: ToolStep{Result: "Go 1.26"}, want: "Go 1.26"},
+		{name: "error", step: ToolStep{Error: "timeout"}, want: "Tool error: timeout"},
+		{name: "long result", step: ToolStep{Result: strings.Repeat("a", maxToolResultLength+10)}, want: strings.Repeat("a", maxToolResultLength) + "..."},
+		{
+			name: "long result cut inside a rune",
+			step: ToolStep{Result: strings.Repeat("a", maxToolResultLength-1) + "é and more"},
+			want: strings.Repeat("a", maxToolResultLength-1) + "...",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toolResultContent(tt.step)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, utf8.ValidString(got))
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// Tool is a tool Pedro can call: the schema offered to the LLM and the Go code that runs it.
+// Impl.Call receives the JSON arguments from the tool call.
+type Tool struct {
+	Definition llms.Tool
+	Impl       tools.Tool
+	// Available reports whether the tool is offered right now. Nil means always.
+	Available func() bool
+}
+
+// Registry holds the tools offered to the LLM
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string
+}
+
+// NewRegistry creates an empty tool registry
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]Tool)}
+}
+
+// Register adds a tool. Registering a name twice replaces the earlier tool.
+func (r *Registry) Register(tool Tool) error {
+	if tool.Definition.Function == nil || tool.Definition.Function.Name == "" {
+		return fmt.Errorf("tool definition needs a function name")
+	}
+	if tool.Impl == nil {
+		return fmt.Errorf("tool %s has no implementation", tool.Definition.Function.Name)
+	}
+	name := tool.Definition.Function.Name
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	r.tools[name] = tool
+	return nil
+}
+
+// Unregister removes a tool
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[name]; !ok {
+		return
+	}
+	delete(r.tools, name)
+	for i, n := range r.order {
+		if n == name {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Definitions returns the definitions of the tools available right now, in registration order
+func (r *Registry) Definitions() []llms.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var defs []llms.Tool
+	for _, name := range r.order {
+		tool := r.tools[name]
+		if tool.Available != nil && !tool.Available() {
+			continue
+		}
+		defs = append(defs, tool.Definition)
+	}
+	return defs
+}
+
+// Has reports whether the named tool is registered and available right now
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	tool, ok := r.tools[name]
+	r.mu.RUnlock()
+	return ok && (tool.Available == nil || tool.Available())
+}
+
+// Call runs the tool named in a tool call with its arguments
+func (r *Registry) Call(ctx context.Context, call llms.ToolCall) (string, error) {
+	if call.FunctionCall == nil {
+		return "", fmt.Errorf("tool call %s has no function", call.ID)
+	}
+	name := call.FunctionCall.Name
+
+	r.mu.RLock()
+	tool, ok := r.tools[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+	if tool.Available != nil && !tool.Available() {
+		return "", fmt.Errorf("tool %s is not available right now", name)
+	}
+	return tool.Impl.Call(ctx, call.FunctionCall.Arguments)
+}
+
+// FuncTool adapts a Go function to tools.Tool
+type FuncTool struct {
+	name        string
+	description string
+	fn          func(ctx context.Context, input string) (string, error)
+}
+
+// NewFuncTool creates a tool that runs fn
+func NewFuncTool(name, description string, fn func(ctx context.Context, input string) (string, error)) *FuncTool {
+	return &FuncTool{name: name, description: description, fn: fn}
+}
+
+// Name returns the name of the tool
+func (f *FuncTool) Name() string {
+	return f.name
+}
+
+// Description returns a description of the tool
+func (f *FuncTool) Description() string {
+	return f.description
+}
+
+// Call runs the function
+func (f *FuncTool) Call(ctx context.Context, input string) (string, error) {
+	return f.fn(ctx, input)
+}
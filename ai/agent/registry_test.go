@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Soypete/twitch-llm-bot/faq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func echoTool(name string) Tool {
+	return Tool{
+		Definition: llms.Tool{Type: "function", Function: &llms.FunctionDefinition{Name: name}},
+		Impl: NewFuncTool(name, "echo", func(_ context.Context, input string) (string, error) {
+			return name + ":" + input, nil
+		}),
+	}
+}
+
+func toolCall(id, name, args string) llms.ToolCall {
+	return llms.ToolCall{ID: id, Type: "function", FunctionCall: &llms.FunctionCall{Name: name, Arguments: args}}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(echoTool("web_search")))
+	require.NoError(t, r.Register(echoTool("faq_lookup")))
+
+	available := false
+	history := echoTool("query_chat_history")
+	history.Available = func() bool { return available }
+	require.NoError(t, r.Register(history))
+
+	names := func() []string {
+		var out []string
+		for _, def := range r.Definitions() {
+			out = append(out, def.Function.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"web_search", "faq_lookup"}, names())
+
+	_, err := r.Call(context.Background(), toolCall("1", "query_chat_history", "{}"))
+	assert.Error(t, err, "unavailable tools cannot be called")
+
+	available = true
+	assert.Equal(t, []string{"web_search", "faq_lookup", "query_chat_history"}, names())
+	out, err := r.Call(context.Background(), toolCall("1", "query_chat_history", `{"query_text":"generics"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `query_chat_history:{"query_text":"generics"}`, out)
+
+	r.Unregister("web_search")
+	assert.Equal(t, []string{"faq_lookup", "query_chat_history"}, names())
+
+	_, err = r.Call(context.Background(), toolCall("2", "web_search", "{}"))
+	assert.ErrorContains(t, err, "unknown tool")
+}
+
+func TestRegistry_RegisterErrors(t *testing.T) {
+	r := NewRegistry()
+	assert.Error(t, r.Register(Tool{Impl: NewFuncTool("x", "", nil)}), "missing definition")
+	assert.Error(t, r.Register(Tool{Definition: GetWebSearchToolDefinition()}), "missing implementation")
+}
+
+type fakeFAQ struct {
+	matches    []faq.Match
+	categories []string
+}
+
+func (f *fakeFAQ) Lookup(_ context.Context, _ string, _ int, categories []string) ([]faq.Match, error) {
+	f.categories = categories
+	return f.matches, nil
+}
+
+func TestFAQTool_Call(t *testing.T) {
+	tool := NewFAQTool(&fakeFAQ{matches: []faq.Match{{Question: "What editor?", Response: "Neovim"}}}, nil)
+
+	out, err := tool.Call(context.Background(), `{"question":"which editor does pete use"}`)
+	require.NoError(t, err)
+	assert.Contains(t, out, "A: Neovim")
+
+	_, err = tool.Call(context.Background(), `{"question":""}`)
+	assert.ErrorContains(t, err, "question cannot be empty")
+
+	out, err = NewFAQTool(&fakeFAQ{}, nil).Call(context.Background(), `{"question":"anything"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "No FAQ entry matches that question.", out)
+}
+
+func TestFAQTool_ChannelCategories(t *testing.T) {
+	searcher := &fakeFAQ{}
+	tool := NewFAQTool(searcher, map[string][]string{"soypetetech": {"setup", "schedule"}})
+
+	_, err := tool.Call(WithChannel(context.Background(), "soypetetech"), `{"question":"what keyboard?"}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"setup", "schedule"}, searcher.categories)
+
+	_, err = tool.Call(WithChannel(context.Background(), "forgeutah"), `{"question":"what keyboard?"}`)
+	require.NoError(t, err)
+	assert.Empty(t, searcher.categories, "channels without categories search every entry")
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+// sourcesKey is the context key of the sources collected during a loop run
+type sourcesKey struct{}
+
+// sourceCollector collects the pages tools used, in the order they were first used
+type sourceCollector struct {
+	mu      sync.Mutex
+	sources []types.Source
+	seen    map[string]bool
+}
+
+// withSources returns a context tools can record sources to
+func withSources(ctx context.Context) (context.Context, *sourceCollector) {
+	collector := &sourceCollector{seen: make(map[string]bool)}
+	return context.WithValue(ctx, sourcesKey{}, collector), collector
+}
+
+// CollectSources returns a context tools can record sources to outside a loop run, and
+// a function listing the sources recorded so far
+func CollectSources(ctx context.Context) (context.Context, func() []types.Source) {
+	ctx, collector := withSources(ctx)
+	return ctx, collector.list
+}
+
+// RecordSources records pages a tool used to answer, so they can be stored with the
+// answer. Sources without a URL and pages already recorded are skipped. It does nothing
+// outside a loop run or CollectSources.
+func RecordSources(ctx context.Context, sources ...types.Source) {
+	collector, ok := ctx.Value(sourcesKey{}).(*sourceCollector)
+	if !ok {
+		return
+	}
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	for _, source := range sources {
+		if source.URL == "" || collector.seen[source.URL] {
+			continue
+		}
+		collector.seen[source.URL] = true
+		collector.sources = append(collector.sources, source)
+	}
+}
+
+// list returns the recorded sources
+func (c *sourceCollector) list() []types.Source {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]types.Source(nil), c.sources...)
+}
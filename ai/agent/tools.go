@@ -5,19 +5,21 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/Soypete/twitch-llm-bot/duckduckgo"
+	"github.com/Soypete/twitch-llm-bot/ai/websearch"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/types"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/tools"
 )
 
-// WebSearchTool implements the tools.Tool interface for DuckDuckGo search
+// WebSearchTool implements the tools.Tool interface for web search
 type WebSearchTool struct {
-	ddgClient *duckduckgo.Client
+	provider websearch.SearchProvider
 }
 
-// NewWebSearchTool creates a new WebSearchTool
-func NewWebSearchTool(ddgClient *duckduckgo.Client) *WebSearchTool {
-	return &WebSearchTool{ddgClient: ddgClient}
+// NewWebSearchTool creates a new WebSearchTool that searches with provider
+func NewWebSearchTool(provider websearch.SearchProvider) *WebSearchTool {
+	return &WebSearchTool{provider: provider}
 }
 
 // Name returns the name of the tool
@@ -30,33 +32,45 @@ func (w *WebSearchTool) Description() string {
 	return "Perform a web search to find current information"
 }
 
-// Call performs the web search
+// maxSearchSnippets is how many results are given back to the LLM
+const maxSearchSnippets = 5
+
+// Results given back to the LLM when a search comes up empty, so it answers honestly from
+// what it already knows instead of giving up
+const (
+	noResultsFound    = "No results found for %q. Answer from what you already know and say that you could not confirm it online. If you do not know, say so."
+	searchUnavailable = "Web search is not working right now, so nothing could be looked up for %q. Answer from what you already know and say that you could not check online. If you do not know, say so."
+)
+
+// Call performs the web search. input is either the JSON arguments of a web_search tool
+// call or a plain query.
 func (w *WebSearchTool) Call(ctx context.Context, input string) (string, error) {
-	// Perform the search
-	searchResult, err := w.ddgClient.Search(input)
-	if err != nil {
-		return "", err
+	query := input
+	var args ToolCallArgs
+	if err := json.Unmarshal([]byte(input), &args); err == nil && args.Query != "" {
+		query = args.Query
 	}
 
-	// Parse the search result
-	var ddgResponse duckduckgo.Response
-	err = json.Unmarshal(searchResult, &ddgResponse)
+	results, err := w.provider.Search(ctx, query)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse search results: %w", err)
+		metrics.WebSearchFailCount.Add(1)
+		if ctx.Err() != nil {
+			return "", err
+		}
+		return fmt.Sprintf(searchUnavailable, query), nil
 	}
+	metrics.WebSearchSuccessCount.Add(1)
 
-	// Extract the first result or abstract
-	if len(ddgResponse.Results) > 0 {
-		return ddgResponse.Results[0].Text, nil
+	if len(results) == 0 {
+		return fmt.Sprintf(noResultsFound, query), nil
 	}
-	if ddgResponse.Abstract != "" {
-		return ddgResponse.Abstract, nil
+	if len(results) > maxSearchSnippets {
+		results = results[:maxSearchSnippets]
 	}
-	if len(ddgResponse.RelatedTopics) > 0 {
-		return ddgResponse.RelatedTopics[0].Text, nil
+	for _, result := range results {
+		RecordSources(ctx, types.Source{Title: result.Title, URL: result.URL, Tool: w.Name()})
 	}
-
-	return "No results found", nil
+	return websearch.Format(results), nil
 }
 
 // GetWebSearchToolDefinition returns the LLM tool definition for web search
@@ -104,8 +118,13 @@ func ParseWebSearchToolCall(toolCall llms.ToolCall) (string, error) {
 	return args.Query, nil
 }
 
+// NewWebSearchRegistryTool returns the web search tool ready to register
+func NewWebSearchRegistryTool(provider websearch.SearchProvider) Tool {
+	return Tool{Definition: GetWebSearchToolDefinition(), Impl: NewWebSearchTool(provider)}
+}
+
 // CreateWebSearchAgent creates an agent with web search capabilities
 // This is a compatibility function that returns the web search tool
-func CreateWebSearchAgent(ddgClient *duckduckgo.Client) tools.Tool {
-	return NewWebSearchTool(ddgClient)
+func CreateWebSearchAgent(provider websearch.SearchProvider) tools.Tool {
+	return NewWebSearchTool(provider)
 }
@@ -2,8 +2,13 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/Soypete/twitch-llm-bot/ai/webfetch"
+	"github.com/Soypete/twitch-llm-bot/ai/websearch"
 	"github.com/Soypete/twitch-llm-bot/duckduckgo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -11,8 +16,7 @@ import (
 )
 
 func TestNewWebSearchTool(t *testing.T) {
-	ddgClient := duckduckgo.NewClient()
-	tool := NewWebSearchTool(ddgClient)
+	tool := NewWebSearchTool(websearch.NewDuckDuckGo(duckduckgo.NewClient()))
 
 	assert.NotNil(t, tool)
 	assert.Equal(t, "web_search", tool.Name())
@@ -96,15 +100,15 @@ func TestParseWebSearchToolCall_EmptyQuery(t *testing.T) {
 }
 
 func TestCreateWebSearchAgent(t *testing.T) {
-	ddgClient := duckduckgo.NewClient()
-	agent := CreateWebSearchAgent(ddgClient)
+	provider := websearch.NewDuckDuckGo(duckduckgo.NewClient())
+	agent := CreateWebSearchAgent(provider)
 
 	assert.NotNil(t, agent)
 	assert.Equal(t, "web_search", agent.Name())
 	assert.NotEmpty(t, agent.Description())
 
 	// Verify it's the same type as NewWebSearchTool
-	tool := NewWebSearchTool(ddgClient)
+	tool := NewWebSearchTool(provider)
 	assert.IsType(t, tool, agent)
 }
 
@@ -114,8 +118,7 @@ func TestWebSearchTool_Call(t *testing.T) {
 
 	// Note: This test requires a real DuckDuckGo client
 	// In a real test suite, you might want to mock this
-	ddgClient := duckduckgo.NewClient()
-	tool := NewWebSearchTool(ddgClient)
+	tool := NewWebSearchTool(websearch.NewDuckDuckGo(duckduckgo.NewClient()))
 
 	// For now, just verify the tool can be called without panicking
 	// A full integration test would verify the actual search results
@@ -125,3 +128,91 @@ func TestWebSearchTool_Call(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, result)
 }
+
+func TestWebSearchTool_CallFixture(t *testing.T) {
+	tool := NewWebSearchTool(websearch.NewFixture("", map[string][]websearch.Result{
+		"latest go release": {
+			{Title: "Go 1.26 is released", URL: "https://go.dev/blog/go1.26", Snippet: "Go 1.26 is out now."},
+		},
+	}))
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "tool call arguments", input: `{"query":"Latest Go release"}`, want: "- Go 1.26 is released: Go 1.26 is out now. (https://go.dev/blog/go1.26)"},
+		{name: "plain query", input: "latest go release", want: "- Go 1.26 is released: Go 1.26 is out now. (https://go.dev/blog/go1.26)"},
+		{name: "no results", input: `{"query":"something else"}`, want: fmt.Sprintf(noResultsFound, "something else")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tool.Call(context.Background(), tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	down := NewWebSearchTool(downProvider{})
+	got, err := down.Call(context.Background(), "golang")
+	require.NoError(t, err, "a failed search is reported to the LLM, not returned")
+	assert.Equal(t, fmt.Sprintf(searchUnavailable, "golang"), got)
+}
+
+// downProvider fails every search
+type downProvider struct{}
+
+func (downProvider) Name() string {
+	return "down"
+}
+
+func (downProvider) Search(_ context.Context, _ string) ([]websearch.Result, error) {
+	return nil, errors.New("connection refused")
+}
+
+// stubSummarizer summarizes allowed pages and records what it was asked
+type stubSummarizer struct {
+	url, question string
+}
+
+func (s *stubSummarizer) FetchSummary(_ context.Context, url, question string) (string, error) {
+	s.url, s.question = url, question
+	if strings.Contains(url, "evil") {
+		return "", fmt.Errorf("%w: evil.example", webfetch.ErrDomainNotAllowed)
+	}
+	return "Go 1.26 brings faster builds.", nil
+}
+
+func TestFetchPageTool_Call(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "summarizes the page",
+			input: `{"url":"https://go.dev/blog/go1.26","question":"what is new?"}`,
+			want:  "Summary of https://go.dev/blog/go1.26: Go 1.26 brings faster builds.",
+		},
+		{
+			name:  "domain not allowed is told to the LLM",
+			input: `{"url":"https://evil.example/page"}`,
+			want:  "https://evil.example/page cannot be read, its site is not on the allowed list. Use the search results instead.",
+		},
+		{name: "missing url", input: `{"question":"what is new?"}`, wantErr: true},
+		{name: "invalid arguments", input: `not json`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summarizer := &stubSummarizer{}
+			got, err := NewFetchPageTool(summarizer).Call(context.Background(), tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
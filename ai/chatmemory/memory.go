@@ -0,0 +1,263 @@
+// Package chatmemory gives Pedro memory across streams. Every persisted chat message is
+// embedded in the background and stored with pgvector, and before Pedro answers, the most
+// similar messages from earlier streams and his answers to them are recalled into the prompt.
+package chatmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/database"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+)
+
+// Embedder turns chat messages into vectors. ai.EmbeddingGenerator implements it.
+type Embedder interface {
+	GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
+	GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Config holds configuration for chat memory
+type Config struct {
+	// Model is the embedding model. It is stored with every embedding and only
+	// embeddings from the same model are compared.
+	Model string
+
+	// QueueSize is how many messages can wait to be embedded. More are dropped.
+	QueueSize int
+
+	// BatchSize is how many messages are embedded in one call
+	BatchSize int
+
+	// FlushInterval is the longest a message waits for its batch to fill
+	FlushInterval time.Duration
+
+	// Limit is how many past messages are recalled for one reply
+	Limit int
+
+	// MinSimilarity is the cosine similarity a past message needs to be recalled
+	MinSimilarity float64
+
+	// MinAge keeps recent messages out of recall. They are already in the conversation.
+	MinAge time.Duration
+
+	// AllChannels recalls messages from every channel, not just the one being answered
+	AllChannels bool
+}
+
+// DefaultConfig returns the default chat memory configuration
+func DefaultConfig() Config {
+	return Config{
+		Model:         "text-embedding-3-small",
+		QueueSize:     1000,
+		BatchSize:     16,
+		FlushInterval: 2 * time.Second,
+		Limit:         3,
+		MinSimilarity: 0.8,
+		MinAge:        time.Hour,
+	}
+}
+
+// pending is a message waiting to be embedded
+type pending struct {
+	id   uuid.UUID
+	text string
+}
+
+// Memory embeds chat messages in the background and recalls similar ones
+type Memory struct {
+	embedder Embedder
+	store    database.ChatMemoryStore
+	config   Config
+	logger   *logging.Logger
+	now      func() time.Time
+
+	queue chan pending
+	// unstored is the number of queued messages whose embedding is not stored yet
+	unstored atomic.Int64
+}
+
+// New creates chat memory
+func New(embedder Embedder, store database.ChatMemoryStore, config Config, logger *logging.Logger) (*Memory, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if embedder == nil || store == nil {
+		return nil, errors.New("chat memory needs an embedder and a store")
+	}
+	defaults := DefaultConfig()
+	if config.Model == "" {
+		config.Model = defaults.Model
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.Limit <= 0 {
+		config.Limit = defaults.Limit
+	}
+	if config.MinSimilarity <= 0 {
+		config.MinSimilarity = defaults.MinSimilarity
+	}
+
+	return &Memory{
+		embedder: embedder,
+		store:    store,
+		config:   config,
+		logger:   logger,
+		now:      time.Now,
+		queue:    make(chan pending, config.QueueSize),
+	}, nil
+}
+
+// Start embeds queued messages in batches until ctx is done
+func (m *Memory) Start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.logger.Info("chat memory started", "model", m.config.Model)
+
+		ticker := time.NewTicker(m.config.FlushInterval)
+		defer ticker.Stop()
+
+		batch := make([]pending, 0, m.config.BatchSize)
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			m.embedBatch(ctx, batch)
+			m.unstored.Add(-int64(len(batch)))
+			batch = batch[:0]
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				m.logger.Info("chat memory shutting down", "unembedded", len(batch)+len(m.queue))
+				return
+			case item := <-m.queue:
+				batch = append(batch, item)
+				if len(batch) >= m.config.BatchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+}
+
+// Pending returns the number of messages that have not been stored yet
+func (m *Memory) Pending() int {
+	return int(m.unstored.Load())
+}
+
+// Enqueue queues a persisted message to be embedded. It never blocks, and returns false
+// when the message was dropped.
+func (m *Memory) Enqueue(msg types.TwitchMessage) bool {
+	item := pending{id: msg.UUID, text: msg.Text}
+	if item.id == uuid.Nil || strings.TrimSpace(item.text) == "" {
+		return false
+	}
+	m.unstored.Add(1)
+	select {
+	case m.queue <- item:
+		return true
+	default:
+		m.unstored.Add(-1)
+		metrics.ChatEmbeddingsTotal.WithLabelValues("dropped").Inc()
+		m.logger.Warn("chat embedding queue full, dropping message", "messageID", item.id)
+		return false
+	}
+}
+
+// embedBatch embeds a batch of messages and stores every embedding
+func (m *Memory) embedBatch(ctx context.Context, batch []pending) {
+	texts := make([]string, len(batch))
+	for i, item := range batch {
+		texts[i] = item.text
+	}
+
+	vectors, err := m.embedder.GenerateEmbeddings(ctx, texts)
+	if err == nil && len(vectors) != len(texts) {
+		err = fmt.Errorf("got %d embeddings for %d messages", len(vectors), len(texts))
+	}
+	if err != nil {
+		metrics.ChatEmbeddingsTotal.WithLabelValues("failed").Add(float64(len(texts)))
+		m.logger.Error("failed to embed chat messages", "error", err.Error(), "messages", len(texts))
+		return
+	}
+
+	for i, item := range batch {
+		if err := m.store.StoreMessageEmbedding(ctx, item.id, m.config.Model, vectors[i]); err != nil {
+			metrics.ChatEmbeddingsTotal.WithLabelValues("failed").Inc()
+			m.logger.Error("failed to store chat embedding", "error", err.Error(), "messageID", item.id)
+			continue
+		}
+		metrics.ChatEmbeddingsTotal.WithLabelValues("stored").Inc()
+	}
+}
+
+// Recall returns the messages from earlier streams most similar to msg. It only searches;
+// storing the message's own embedding is left to Enqueue.
+func (m *Memory) Recall(ctx context.Context, msg types.TwitchMessage) ([]types.ChatMemory, error) {
+	embedding, err := m.embedder.GenerateEmbedding(ctx, msg.Text)
+	if err != nil {
+		metrics.ChatMemoryRecallsTotal.WithLabelValues("error").Inc()
+		return nil, fmt.Errorf("failed to embed message: %w", err)
+	}
+
+	query := types.ChatMemoryQuery{
+		Embedding:     embedding,
+		Model:         m.config.Model,
+		Before:        m.now().Add(-m.config.MinAge),
+		Limit:         m.config.Limit,
+		MinSimilarity: m.config.MinSimilarity,
+	}
+	if !m.config.AllChannels {
+		query.Channel = msg.Channel
+	}
+	memories, err := m.store.FindChatMemories(ctx, query)
+	if err != nil {
+		metrics.ChatMemoryRecallsTotal.WithLabelValues("error").Inc()
+		return nil, fmt.Errorf("failed to find similar messages: %w", err)
+	}
+
+	result := "miss"
+	if len(memories) > 0 {
+		result = "hit"
+	}
+	metrics.ChatMemoryRecallsTotal.WithLabelValues(result).Inc()
+	m.logger.Debug("recalled chat memories", "messageID", msg.UUID, "memories", len(memories))
+	return memories, nil
+}
+
+// Format writes memories as context for Pedro's prompt. It returns "" when there are none.
+func Format(memories []types.ChatMemory) string {
+	if len(memories) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Similar messages from earlier streams, with your answers:")
+	for _, memory := range memories {
+		fmt.Fprintf(&b, "\n- [%s #%s] %s: %s", memory.Time.Format("2006-01-02"), memory.Channel, memory.Username, memory.Text)
+		if memory.Response != "" {
+			fmt.Fprintf(&b, "\n  You answered: %s", memory.Response)
+		}
+	}
+	return b.String()
+}
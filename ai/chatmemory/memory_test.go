@@ -0,0 +1,164 @@
+package chatmemory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEmbedder embeds a text as its length, or fails
+type fakeEmbedder struct {
+	mu      sync.Mutex
+	err     error
+	batches [][]string
+	single  int
+}
+
+func (f *fakeEmbedder) GenerateEmbedding(_ context.Context, text string) ([]float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.single++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []float32{float32(len(text))}, nil
+}
+
+func (f *fakeEmbedder) GenerateEmbeddings(_ context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, texts)
+	if f.err != nil {
+		return nil, f.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text))}
+	}
+	return vectors, nil
+}
+
+// fakeStore records stored embeddings and returns memories for every query
+type fakeStore struct {
+	mu       sync.Mutex
+	stored   map[uuid.UUID][]float32
+	queries  []types.ChatMemoryQuery
+	memories []types.ChatMemory
+}
+
+func (f *fakeStore) StoreMessageEmbedding(_ context.Context, messageID uuid.UUID, _ string, embedding []float32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stored == nil {
+		f.stored = make(map[uuid.UUID][]float32)
+	}
+	f.stored[messageID] = embedding
+	return nil
+}
+
+func (f *fakeStore) FindChatMemories(_ context.Context, query types.ChatMemoryQuery) ([]types.ChatMemory, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, query)
+	return f.memories, nil
+}
+
+func (f *fakeStore) storedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.stored)
+}
+
+func TestMemory_EmbedsInBatches(t *testing.T) {
+	embedder := &fakeEmbedder{}
+	store := &fakeStore{}
+	memory, err := New(embedder, store, Config{BatchSize: 2, FlushInterval: 10 * time.Millisecond}, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	memory.Start(ctx, &wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for _, text := range []string{"hello", "how do goroutines work?", "bye"} {
+		assert.True(t, memory.Enqueue(types.TwitchMessage{UUID: uuid.New(), Text: text}))
+	}
+	assert.False(t, memory.Enqueue(types.TwitchMessage{Text: "never persisted"}), "messages without an ID are not embedded")
+
+	require.Eventually(t, func() bool { return store.storedCount() == 3 && memory.Pending() == 0 }, time.Second, 5*time.Millisecond)
+	embedder.mu.Lock()
+	defer embedder.mu.Unlock()
+	assert.Equal(t, [][]string{{"hello", "how do goroutines work?"}, {"bye"}}, embedder.batches)
+}
+
+func TestMemory_Recall(t *testing.T) {
+	now := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	past := types.ChatMemory{Channel: "soypetetech", Username: "alice", Text: "how do channels work?", Response: "they pass values between goroutines", Time: now.AddDate(0, -1, 0)}
+
+	tests := []struct {
+		name        string
+		allChannels bool
+		embedErr    error
+		wantChannel string
+		wantErr     bool
+	}{
+		{name: "same channel", wantChannel: "soypetetech"},
+		{name: "all channels", allChannels: true, wantChannel: ""},
+		{name: "embedding fails", embedErr: errors.New("backend down"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedder := &fakeEmbedder{err: tt.embedErr}
+			store := &fakeStore{memories: []types.ChatMemory{past}}
+			config := DefaultConfig()
+			config.AllChannels = tt.allChannels
+			memory, err := New(embedder, store, config, nil)
+			require.NoError(t, err)
+			memory.now = func() time.Time { return now }
+
+			msg := types.TwitchMessage{UUID: uuid.New(), Channel: "soypetetech", Text: "what are channels?"}
+			got, err := memory.Recall(context.Background(), msg)
+			// Recall only searches, the message is embedded and stored through Enqueue
+			assert.Empty(t, memory.queue)
+			assert.Empty(t, store.stored)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Empty(t, store.queries)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []types.ChatMemory{past}, got)
+
+			require.Len(t, store.queries, 1)
+			query := store.queries[0]
+			assert.Equal(t, tt.wantChannel, query.Channel)
+			assert.Equal(t, config.Model, query.Model)
+			assert.Equal(t, now.Add(-time.Hour), query.Before)
+			assert.Equal(t, 1, embedder.single, "recall embeds the query once")
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	assert.Empty(t, Format(nil))
+
+	memories := []types.ChatMemory{
+		{Channel: "soypetetech", Username: "alice", Text: "how do channels work?", Response: "they pass values between goroutines", Time: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{Channel: "forgeutah", Username: "bob", Text: "channels are cool", Time: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	want := "Similar messages from earlier streams, with your answers:\n" +
+		"- [2026-02-01 #soypetetech] alice: how do channels work?\n" +
+		"  You answered: they pass values between goroutines\n" +
+		"- [2026-01-15 #forgeutah] bob: channels are cool"
+	assert.Equal(t, want, Format(memories))
+}
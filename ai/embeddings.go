@@ -38,7 +38,13 @@ func NewEmbeddingGenerator(llmPath string, embeddingModel string) (*EmbeddingGen
 		return nil, fmt.Errorf("failed to create OpenAI client for embeddings: %w", err)
 	}
 
-	embedder, err := embeddings.NewEmbedder(llm)
+	return NewEmbeddingGeneratorFromClient(llm)
+}
+
+// NewEmbeddingGeneratorFromClient creates an embedding generator that uses an existing
+// embeddings client, such as the shared LLM provider's
+func NewEmbeddingGeneratorFromClient(client embeddings.EmbedderClient) (*EmbeddingGenerator, error) {
+	embedder, err := embeddings.NewEmbedder(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedder: %w", err)
 	}
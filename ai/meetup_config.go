@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
 	"gopkg.in/yaml.v3"
 )
 
@@ -165,100 +165,12 @@ func validateConfig(config *MeetupConfig) error {
 	return nil
 }
 
-// GenerateMeetupAddendum creates a formatted prompt addendum from the config
+// GenerateMeetupAddendum creates a formatted prompt addendum from the config using the
+// stream_context prompt template. It returns an empty addendum if the template fails.
 func GenerateMeetupAddendum(config *MeetupConfig) string {
-	var sb strings.Builder
-
-	// Format the date nicely
-	dateStr := config.Metadata.Date.Format("Monday, January 2, 2006 at 3:04 PM MST")
-
-	sb.WriteString("\n\n")
-	fmt.Fprintf(&sb, "SPECIAL EVENT - %s (%s):\n", config.EventInfo.Title, dateStr)
-	fmt.Fprintf(&sb, "We're streaming/discussing the %s meetup!\n\n", config.Metadata.Name)
-
-	// Event details
-	fmt.Fprintf(&sb, "Event: %s\n", config.EventInfo.Title)
-	if len(config.Speakers) > 0 {
-		speaker := config.Speakers[0]
-		fmt.Fprintf(&sb, "Speaker: %s - %s\n", speaker.Name, speaker.TalkTitle)
-	}
-	fmt.Fprintf(&sb, "When: %s\n", dateStr)
-	fmt.Fprintf(&sb, "Where: %s", config.Location.Venue)
-	if config.Location.Type == "virtual" {
-		sb.WriteString(" (Virtual Event)")
-	}
-	sb.WriteString("\n")
-
-	// Links
-	if config.Links.VideoCall != "" {
-		fmt.Fprintf(&sb, "Join: %s\n", config.Links.VideoCall)
-	}
-	if config.Links.Registration != "" {
-		fmt.Fprintf(&sb, "Register: %s\n", config.Links.Registration)
-	}
-
-	// Schedule
-	if len(config.Schedule) > 0 {
-		sb.WriteString("\nSchedule:\n")
-		for _, item := range config.Schedule {
-			if item.Speaker != "" {
-				fmt.Fprintf(&sb, "%s - %s (Speaker: %s)\n", item.Time, item.Event, item.Speaker)
-			} else {
-				fmt.Fprintf(&sb, "%s - %s\n", item.Time, item.Event)
-			}
-		}
-	}
-
-	// Speaker bio (first speaker)
-	if len(config.Speakers) > 0 {
-		speaker := config.Speakers[0]
-		sb.WriteString("\nAbout the Speaker:\n")
-		fmt.Fprintf(&sb, "%s\n", speaker.Bio)
-	}
-
-	// Forge Utah info
-	if config.ForgeInfo.IsForgeEvent {
-		sb.WriteString("\nForge Utah Foundation:\n")
-		fmt.Fprintf(&sb, "%s\n", config.ForgeInfo.About)
-		if config.ForgeInfo.Mission != "" {
-			fmt.Fprintf(&sb, "Mission: %s\n", config.ForgeInfo.Mission)
-		}
-		if len(config.ForgeInfo.OtherMeetups) > 0 {
-			fmt.Fprintf(&sb, "We also run: %s\n", strings.Join(config.ForgeInfo.OtherMeetups, ", "))
-		}
-
-		sb.WriteString("\nHow to Get Involved:\n")
-		if config.ForgeInfo.HowToJoin != "" {
-			fmt.Fprintf(&sb, "- Participate: %s\n", config.ForgeInfo.HowToJoin)
-		}
-		if config.ForgeInfo.HowToSpeak != "" {
-			fmt.Fprintf(&sb, "- Speak: %s\n", config.ForgeInfo.HowToSpeak)
-		}
-		if config.ForgeInfo.Sponsorship != "" {
-			fmt.Fprintf(&sb, "- Sponsor: %s\n", config.ForgeInfo.Sponsorship)
-		}
-	}
-
-	// Bot instructions
-	sb.WriteString("\nWhen viewers ask about:\n")
-	sb.WriteString("- The event: Share title, speaker, time, and registration link enthusiastically\n")
-	if config.ForgeInfo.IsForgeEvent {
-		sb.WriteString("- Forge Utah: Explain our mission and other meetups\n")
-	}
-	sb.WriteString("- Speaking opportunities: Encourage them to reach out to organizers\n")
-	if len(config.Speakers) > 0 {
-		sb.WriteString("- The speaker: Share bio and talk topic\n")
-	}
-
-	// Encouragement messages
-	if len(config.BotInstructions.Encourage) > 0 {
-		sb.WriteString("\nBe encouraging:\n")
-		for _, msg := range config.BotInstructions.Encourage {
-			fmt.Fprintf(&sb, "- %s\n", msg)
-		}
+	rendered, err := prompts.Default().Render(prompts.StreamContext, config)
+	if err != nil {
+		return ""
 	}
-
-	sb.WriteString("\nBe welcoming to newcomers and encourage participation!")
-
-	return sb.String()
+	return rendered.Text
 }
@@ -3,7 +3,6 @@ package ai
 import (
 	"os"
 	"path/filepath"
-	"strings"
 	"testing"
 )
 
@@ -216,14 +215,3 @@ func TestLoadModerationConfig_InvalidYAML(t *testing.T) {
 		t.Error("expected error for invalid YAML")
 	}
 }
-
-func TestModerationPrompt(t *testing.T) {
-	if ModerationPrompt == "" {
-		t.Error("ModerationPrompt should not be empty")
-	}
-
-	// Check that the prompt contains placeholder markers
-	if !strings.Contains(ModerationPrompt, "%s") {
-		t.Error("ModerationPrompt should contain format placeholders")
-	}
-}
@@ -0,0 +1,87 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+// StepStatus is where a step is in its execution
+type StepStatus string
+
+const (
+	StatusPending   StepStatus = "pending"
+	StatusRunning   StepStatus = "running"
+	StatusSucceeded StepStatus = "succeeded"
+	StatusFailed    StepStatus = "failed"
+)
+
+// Emoji is how the status is shown in chat
+func (s StepStatus) Emoji() string {
+	switch s {
+	case StatusSucceeded:
+		return "✔️"
+	case StatusFailed:
+		return "❌"
+	case StatusRunning:
+		return "⏳"
+	default:
+		return "▫️"
+	}
+}
+
+// EventType is what happened in an orchestrator run
+type EventType string
+
+const (
+	EventPlanCreated   EventType = "plan_created"
+	EventPlanRejected  EventType = "plan_rejected"
+	EventStepStarted   EventType = "step_started"
+	EventStepRetrying  EventType = "step_retrying"
+	EventStepSucceeded EventType = "step_succeeded"
+	EventStepFailed    EventType = "step_failed"
+	EventCompleted     EventType = "completed"
+	EventFailed        EventType = "failed"
+)
+
+// Event is published as a run progresses. Step and Attempt are set for step events,
+// starting at 1.
+type Event struct {
+	Type     EventType
+	Question string
+	Plan     *Plan
+	Step     int
+	Action   Action
+	Attempt  int
+	Error    string
+	Answer   string
+}
+
+// StepResult is the outcome of one step
+type StepResult struct {
+	Step     Step
+	Status   StepStatus
+	Attempts int
+	Output   string
+	Error    string
+}
+
+// Result is the outcome of a run
+type Result struct {
+	Question string
+	Plan     *Plan
+	Steps    []StepResult
+	Answer   string
+	// Sources are the pages the steps' tools used, such as web search results
+	Sources []types.Source
+}
+
+// Progress shows each step's status, like "1 ✔️ web_search · 2 ❌ db_query"
+func (r *Result) Progress() string {
+	parts := make([]string, len(r.Steps))
+	for i, step := range r.Steps {
+		parts[i] = fmt.Sprintf("%d %s %s", i+1, step.Status.Emoji(), step.Step.Action)
+	}
+	return strings.Join(parts, " · ")
+}
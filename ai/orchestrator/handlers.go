@@ -0,0 +1,114 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/database"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// HandleTools registers handlers for the actions Pedro's tools can run: web_search uses the
+// web_search tool, reference_check uses faq_lookup and query_chat_history, and api_call runs
+// any tool by name. Actions whose tools are not registered are left out of plans.
+func (o *Orchestrator) HandleTools(registry *agent.Registry) {
+	if registry.Has("web_search") {
+		o.Handle(ActionWebSearch, func(ctx context.Context, step Step, _ []StepResult) (string, error) {
+			return callTool(ctx, registry, "web_search", map[string]string{"query": step.Params["query"]})
+		})
+	}
+	if registry.Has("faq_lookup") || registry.Has("query_chat_history") {
+		o.Handle(ActionReferenceCheck, referenceCheck(registry))
+	}
+	o.Handle(ActionAPICall, func(ctx context.Context, step Step, _ []StepResult) (string, error) {
+		name := step.Params["tool"]
+		if !registry.Has(name) {
+			return "", fmt.Errorf("tool %s is not available", name)
+		}
+		args := make(map[string]string, len(step.Params))
+		for k, v := range step.Params {
+			if k != "tool" {
+				args[k] = v
+			}
+		}
+		return callTool(ctx, registry, name, args)
+	})
+}
+
+// referenceCheck looks the query up in the FAQ and in chat history. It fails only when
+// every source fails.
+func referenceCheck(registry *agent.Registry) StepHandler {
+	return func(ctx context.Context, step Step, _ []StepResult) (string, error) {
+		query := step.Params["query"]
+		sources := []struct {
+			tool string
+			args map[string]string
+		}{
+			{"faq_lookup", map[string]string{"question": query}},
+			{"query_chat_history", map[string]string{"query_text": query}},
+		}
+
+		var found []string
+		var errs []string
+		for _, source := range sources {
+			if !registry.Has(source.tool) {
+				continue
+			}
+			out, err := callTool(ctx, registry, source.tool, source.args)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", source.tool, err.Error()))
+				continue
+			}
+			found = append(found, fmt.Sprintf("%s:\n%s", source.tool, out))
+		}
+		if len(found) == 0 {
+			if len(errs) == 0 {
+				return "", fmt.Errorf("no reference sources are available")
+			}
+			return "", fmt.Errorf("reference check failed: %s", strings.Join(errs, "; "))
+		}
+		return strings.Join(found, "\n\n"), nil
+	}
+}
+
+// maxDBQueryResults is how many past answers a db_query step returns
+const maxDBQueryResults = 5
+
+// HandleDBQuery registers the db_query handler, which searches Pedro's past answers in the
+// channel set with agent.WithChannel
+func (o *Orchestrator) HandleDBQuery(searcher database.AnswerSearcher) {
+	o.Handle(ActionDBQuery, func(ctx context.Context, step Step, _ []StepResult) (string, error) {
+		channel := agent.ChannelFromContext(ctx)
+		if channel == "" {
+			return "", fmt.Errorf("no channel to search")
+		}
+		answers, err := searcher.SearchAnswers(ctx, channel, step.Params["query"], maxDBQueryResults)
+		if err != nil {
+			return "", err
+		}
+		if len(answers) == 0 {
+			return "Nothing in Pedro's past answers matches that.", nil
+		}
+		var b strings.Builder
+		for _, answer := range answers {
+			fmt.Fprintf(&b, "%s %s asked: %s\nPedro answered: %s\n",
+				answer.CreatedAt.Format("2006-01-02"), answer.Username, answer.Message, answer.Response)
+		}
+		return b.String(), nil
+	})
+}
+
+func callTool(ctx context.Context, registry *agent.Registry, name string, args map[string]string) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s arguments: %w", name, err)
+	}
+	return registry.Call(ctx, llms.ToolCall{
+		ID:           "plan-" + name,
+		Type:         "function",
+		FunctionCall: &llms.FunctionCall{Name: name, Arguments: string(data)},
+	})
+}
@@ -0,0 +1,318 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// StepHandler runs one step. prior holds the results of the steps before it.
+type StepHandler func(ctx context.Context, step Step, prior []StepResult) (string, error)
+
+// Config controls planning and execution
+type Config struct {
+	// MaxSteps is the longest plan accepted
+	MaxSteps int
+	// MaxRetries is how many times a failed step is retried
+	MaxRetries int
+	// RetryDelay is the wait before the first retry; it doubles for each retry after
+	RetryDelay time.Duration
+	// StepTimeout bounds one attempt of a step
+	StepTimeout time.Duration
+	// PlanAttempts is how many times the LLM may try to write a valid plan
+	PlanAttempts int
+	// SystemPrompt is Pedro's persona, used when writing the final answer
+	SystemPrompt string
+}
+
+// DefaultConfig returns the orchestrator settings used in production
+func DefaultConfig() Config {
+	return Config{
+		MaxSteps:     6,
+		MaxRetries:   2,
+		RetryDelay:   500 * time.Millisecond,
+		StepTimeout:  30 * time.Second,
+		PlanAttempts: 2,
+	}
+}
+
+// Orchestrator asks the LLM for a plan and executes it step by step
+type Orchestrator struct {
+	llm       llms.Model
+	modelName string
+	config    Config
+	logger    *logging.Logger
+
+	mu        sync.RWMutex
+	handlers  map[Action]StepHandler
+	listeners []func(Event)
+}
+
+// New creates an orchestrator. Zero config values use the defaults. Only return_response
+// is available until handlers are registered for the other actions.
+func New(llm llms.Model, modelName string, config Config, logger *logging.Logger) *Orchestrator {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	defaults := DefaultConfig()
+	if config.MaxSteps <= 0 {
+		config.MaxSteps = defaults.MaxSteps
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaults.RetryDelay
+	}
+	if config.StepTimeout <= 0 {
+		config.StepTimeout = defaults.StepTimeout
+	}
+	if config.PlanAttempts <= 0 {
+		config.PlanAttempts = defaults.PlanAttempts
+	}
+
+	return &Orchestrator{
+		llm:       llm,
+		modelName: modelName,
+		config:    config,
+		logger:    logger,
+		handlers:  make(map[Action]StepHandler),
+	}
+}
+
+// Handle registers the handler for an action. return_response is built in.
+func (o *Orchestrator) Handle(action Action, handler StepHandler) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.handlers[action] = handler
+}
+
+// OnEvent registers a listener for progress events. Listeners are called synchronously.
+func (o *Orchestrator) OnEvent(fn func(Event)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.listeners = append(o.listeners, fn)
+}
+
+// Actions returns the actions a plan may use
+func (o *Orchestrator) Actions() []Action {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	actions := []Action{ActionReturnResponse}
+	for action := range o.handlers {
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i] < actions[j] })
+	return actions
+}
+
+// Run plans and executes an answer to question
+func (o *Orchestrator) Run(ctx context.Context, question string) (*Result, error) {
+	plan, err := o.Plan(ctx, question)
+	if err != nil {
+		o.publish(Event{Type: EventFailed, Question: question, Error: err.Error()})
+		return nil, err
+	}
+	return o.Execute(ctx, question, plan)
+}
+
+// Plan asks the LLM for a plan and validates it. An invalid plan is sent back with the
+// validation error so the LLM can fix it.
+func (o *Orchestrator) Plan(ctx context.Context, question string) (*Plan, error) {
+	actions := o.Actions()
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, o.planPrompt(actions)),
+		llms.TextParts(llms.ChatMessageTypeHuman, question),
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= o.config.PlanAttempts; attempt++ {
+		text, err := o.generate(ctx, messages, llms.WithJSONMode(), llms.WithTemperature(0.2))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get plan: %w", err)
+		}
+
+		plan, err := ParsePlan(text)
+		if err == nil {
+			err = plan.Validate(actions, o.config.MaxSteps)
+		}
+		if err == nil {
+			o.logger.Info("plan created", "plan", plan.String(), "attempt", attempt)
+			o.publish(Event{Type: EventPlanCreated, Question: question, Plan: plan})
+			return plan, nil
+		}
+
+		lastErr = err
+		o.logger.Warn("plan rejected", "error", err.Error(), "attempt", attempt)
+		metrics.OrchestratorPlansRejectedTotal.Inc()
+		o.publish(Event{Type: EventPlanRejected, Question: question, Attempt: attempt, Error: err.Error()})
+		messages = append(messages,
+			llms.TextParts(llms.ChatMessageTypeAI, text),
+			llms.TextParts(llms.ChatMessageTypeHuman, fmt.Sprintf("That plan is invalid: %s. Reply with a corrected plan.", err.Error())))
+	}
+	return nil, fmt.Errorf("no valid plan after %d attempts: %w", o.config.PlanAttempts, lastErr)
+}
+
+// Execute runs a validated plan. A step that still fails after its retries is marked
+// failed and the plan continues, so the final answer can say what could not be found.
+func (o *Orchestrator) Execute(ctx context.Context, question string, plan *Plan) (*Result, error) {
+	result := &Result{Question: question, Plan: plan, Steps: make([]StepResult, len(plan.Steps))}
+	ctx, sources := agent.CollectSources(ctx)
+	defer func() { result.Sources = sources() }()
+	for i, step := range plan.Steps {
+		result.Steps[i] = StepResult{Step: step, Status: StatusPending}
+	}
+
+	for i := range plan.Steps {
+		if err := ctx.Err(); err != nil {
+			o.publish(Event{Type: EventFailed, Question: question, Plan: plan, Error: err.Error()})
+			return result, err
+		}
+		o.runStep(ctx, result, i)
+	}
+
+	final := result.Steps[len(result.Steps)-1]
+	if final.Status != StatusSucceeded {
+		err := fmt.Errorf("failed to write the final answer: %s", final.Error)
+		o.publish(Event{Type: EventFailed, Question: question, Plan: plan, Error: err.Error()})
+		return result, err
+	}
+	result.Answer = final.Output
+	o.publish(Event{Type: EventCompleted, Question: question, Plan: plan, Answer: result.Answer})
+	return result, nil
+}
+
+// runStep runs step i with retries and records its result
+func (o *Orchestrator) runStep(ctx context.Context, result *Result, i int) {
+	sr := &result.Steps[i]
+	step := sr.Step
+	handler := o.handlerFor(result.Question, step.Action)
+	sr.Status = StatusRunning
+
+	for attempt := 1; attempt <= o.config.MaxRetries+1; attempt++ {
+		sr.Attempts = attempt
+		if attempt == 1 {
+			o.publish(Event{Type: EventStepStarted, Question: result.Question, Plan: result.Plan, Step: i + 1, Action: step.Action, Attempt: attempt})
+		} else {
+			delay := o.config.RetryDelay << (attempt - 2)
+			o.publish(Event{Type: EventStepRetrying, Question: result.Question, Plan: result.Plan, Step: i + 1, Action: step.Action, Attempt: attempt, Error: sr.Error})
+			select {
+			case <-ctx.Done():
+				sr.Status = StatusFailed
+				sr.Error = ctx.Err().Error()
+				o.publish(Event{Type: EventStepFailed, Question: result.Question, Plan: result.Plan, Step: i + 1, Action: step.Action, Attempt: attempt, Error: sr.Error})
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		stepCtx, cancel := context.WithTimeout(ctx, o.config.StepTimeout)
+		output, err := handler(stepCtx, step, result.Steps[:i])
+		cancel()
+		if err == nil {
+			sr.Status = StatusSucceeded
+			sr.Output = output
+			sr.Error = ""
+			metrics.OrchestratorStepsTotal.WithLabelValues(string(step.Action), string(StatusSucceeded)).Inc()
+			o.logger.Debug("plan step succeeded", "step", i+1, "action", step.Action, "attempt", attempt)
+			o.publish(Event{Type: EventStepSucceeded, Question: result.Question, Plan: result.Plan, Step: i + 1, Action: step.Action, Attempt: attempt})
+			return
+		}
+		sr.Error = err.Error()
+		o.logger.Warn("plan step failed", "step", i+1, "action", step.Action, "attempt", attempt, "error", err.Error())
+	}
+
+	sr.Status = StatusFailed
+	metrics.OrchestratorStepsTotal.WithLabelValues(string(step.Action), string(StatusFailed)).Inc()
+	o.publish(Event{Type: EventStepFailed, Question: result.Question, Plan: result.Plan, Step: i + 1, Action: step.Action, Attempt: sr.Attempts, Error: sr.Error})
+}
+
+func (o *Orchestrator) handlerFor(question string, action Action) StepHandler {
+	if action == ActionReturnResponse {
+		return func(ctx context.Context, _ Step, prior []StepResult) (string, error) {
+			return o.returnResponse(ctx, question, prior)
+		}
+	}
+	o.mu.RLock()
+	handler, ok := o.handlers[action]
+	o.mu.RUnlock()
+	if !ok {
+		return func(context.Context, Step, []StepResult) (string, error) {
+			return "", fmt.Errorf("no handler for action %s", action)
+		}
+	}
+	return handler
+}
+
+// returnResponse is the built-in return_response handler. It asks the LLM to answer the
+// question from the outputs of the earlier steps.
+func (o *Orchestrator) returnResponse(ctx context.Context, question string, prior []StepResult) (string, error) {
+	var b strings.Builder
+	for i, sr := range prior {
+		switch sr.Status {
+		case StatusSucceeded:
+			fmt.Fprintf(&b, "Step %d (%s) found:\n%s\n\n", i+1, sr.Step.Action, sr.Output)
+		case StatusFailed:
+			fmt.Fprintf(&b, "Step %d (%s) failed: %s\n\n", i+1, sr.Step.Action, sr.Error)
+		}
+	}
+	if b.Len() == 0 {
+		b.WriteString("No research steps were run.\n")
+	}
+
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, o.config.SystemPrompt),
+		llms.TextParts(llms.ChatMessageTypeSystem, "You planned and ran these steps to answer the user's question:\n\n"+b.String()+
+			"Answer the question using these results. If a step failed or found nothing, say what you could not check."),
+		llms.TextParts(llms.ChatMessageTypeHuman, question),
+	}
+	answer, err := o.generate(ctx, messages, llms.WithTemperature(0.7), llms.WithMaxLength(500))
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(answer) == "" {
+		return "", fmt.Errorf("llm returned an empty answer")
+	}
+	return answer, nil
+}
+
+func (o *Orchestrator) generate(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (string, error) {
+	opts = append([]llms.CallOption{llms.WithModel(o.modelName), llms.WithCandidateCount(1)}, opts...)
+	resp, err := o.llm.GenerateContent(ctx, messages, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get llm response: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("llm returned no choices")
+	}
+	return resp.Choices[0].Content, nil
+}
+
+func (o *Orchestrator) planPrompt(actions []Action) string {
+	var b strings.Builder
+	b.WriteString("You are Pedro's planner. Do not answer the question. Reply only with a JSON plan for answering it, in this format:\n")
+	b.WriteString(`{"goal": "<what the user wants>", "steps": [{"action": "<action>", "params": {"<name>": "<value>"}, "reason": "<why>"}]}`)
+	b.WriteString("\n\nUse only these actions:\n")
+	for _, action := range actions {
+		fmt.Fprintf(&b, "- %s: %s\n", action, actionDescriptions[action])
+	}
+	fmt.Fprintf(&b, "\nUse at most %d steps. Param values are strings. The last step must be return_response and it can only be used once.", o.config.MaxSteps)
+	return b.String()
+}
+
+func (o *Orchestrator) publish(event Event) {
+	o.mu.RLock()
+	listeners := append([]func(Event){}, o.listeners...)
+	o.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
@@ -0,0 +1,324 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeLLM returns one canned response per call and records what it was sent
+type fakeLLM struct {
+	responses []string
+	calls     [][]llms.MessageContent
+	jsonMode  []bool
+}
+
+func (f *fakeLLM) GenerateContent(_ context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	callOpts := llms.CallOptions{}
+	for _, opt := range opts {
+		opt(&callOpts)
+	}
+	f.calls = append(f.calls, messages)
+	f.jsonMode = append(f.jsonMode, callOpts.JSONMode)
+	if len(f.calls) > len(f.responses) {
+		return nil, errors.New("no more scripted responses")
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: f.responses[len(f.calls)-1]}}}, nil
+}
+
+func (f *fakeLLM) Call(context.Context, string, ...llms.CallOption) (string, error) {
+	return "", nil
+}
+
+func testConfig() Config {
+	config := DefaultConfig()
+	config.RetryDelay = time.Millisecond
+	return config
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestParsePlanAndValidate(t *testing.T) {
+	allowed := []Action{ActionWebSearch, ActionReferenceCheck, ActionReturnResponse}
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{
+			name: "valid plan in a code fence",
+			text: "```json\n" + `{"goal":"release date","steps":[{"action":"web_search","params":{"query":"go 1.26 release"}},{"action":"return_response"}]}` + "\n```",
+		},
+		{name: "not json", text: "I would search the web", wantErr: "no JSON object"},
+		{name: "unknown field", text: `{"goal":"x","steps":[],"extra":1}`, wantErr: "does not match schema"},
+		{name: "no steps", text: `{"goal":"x","steps":[]}`, wantErr: "no steps"},
+		{
+			name:    "action not allowed",
+			text:    `{"goal":"x","steps":[{"action":"db_query","params":{"query":"y"}},{"action":"return_response"}]}`,
+			wantErr: `action "db_query" is not allowed`,
+		},
+		{
+			name:    "missing param",
+			text:    `{"goal":"x","steps":[{"action":"web_search"},{"action":"return_response"}]}`,
+			wantErr: `needs the "query" param`,
+		},
+		{
+			name:    "does not end with return_response",
+			text:    `{"goal":"x","steps":[{"action":"web_search","params":{"query":"y"}}]}`,
+			wantErr: "must end with return_response",
+		},
+		{
+			name:    "return_response in the middle",
+			text:    `{"goal":"x","steps":[{"action":"return_response"},{"action":"return_response"}]}`,
+			wantErr: "must be the last step",
+		},
+		{
+			name:    "too many steps",
+			text:    `{"goal":"x","steps":[{"action":"web_search","params":{"query":"a"}},{"action":"web_search","params":{"query":"b"}},{"action":"web_search","params":{"query":"c"}},{"action":"return_response"}]}`,
+			wantErr: "at most 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := ParsePlan(tt.text)
+			if err == nil {
+				err = plan.Validate(allowed, 3)
+			}
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "web_search → return_response", plan.String())
+		})
+	}
+}
+
+func TestOrchestrator_Run(t *testing.T) {
+	llm := &fakeLLM{responses: []string{
+		`{"goal":"find the release","steps":[{"action":"web_search","params":{"query":"go 1.26 release"}},{"action":"return_response"}]}`,
+		"Go 1.26 came out in February",
+	}}
+	o := New(llm, "test-model", testConfig(), nil)
+
+	var gotQuery string
+	source := types.Source{Title: "Go 1.26", URL: "https://go.dev/doc/go1.26", Tool: "web_search"}
+	o.Handle(ActionWebSearch, func(ctx context.Context, step Step, _ []StepResult) (string, error) {
+		gotQuery = step.Params["query"]
+		agent.RecordSources(ctx, source)
+		return "Go 1.26 released February 2026", nil
+	})
+	var events []Event
+	o.OnEvent(func(e Event) { events = append(events, e) })
+
+	result, err := o.Run(context.Background(), "when did go 1.26 come out?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "go 1.26 release", gotQuery)
+	assert.Equal(t, "Go 1.26 came out in February", result.Answer)
+	assert.Equal(t, []types.Source{source}, result.Sources, "sources the steps' tools record are kept with the answer")
+	assert.Equal(t, "1 ✔️ web_search · 2 ✔️ return_response", result.Progress())
+	assert.Equal(t, []EventType{
+		EventPlanCreated,
+		EventStepStarted, EventStepSucceeded,
+		EventStepStarted, EventStepSucceeded,
+		EventCompleted,
+	}, eventTypes(events))
+
+	// The planner is asked for JSON and only offered the actions with handlers
+	require.Len(t, llm.calls, 2)
+	assert.True(t, llm.jsonMode[0])
+	planPrompt := llm.calls[0][0].Parts[0].(llms.TextContent).Text
+	assert.Contains(t, planPrompt, "- web_search:")
+	assert.NotContains(t, planPrompt, "- db_query:")
+
+	// The final answer is written from the step outputs
+	synth := llm.calls[1][1].Parts[0].(llms.TextContent).Text
+	assert.Contains(t, synth, "Go 1.26 released February 2026")
+}
+
+func TestOrchestrator_StepRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		wantProgress string
+		wantAttempts int
+		wantEvents   []EventType
+	}{
+		{
+			name:         "succeeds after a retry",
+			failures:     1,
+			wantProgress: "1 ✔️ web_search · 2 ✔️ return_response",
+			wantAttempts: 2,
+			wantEvents: []EventType{
+				EventPlanCreated,
+				EventStepStarted, EventStepRetrying, EventStepSucceeded,
+				EventStepStarted, EventStepSucceeded,
+				EventCompleted,
+			},
+		},
+		{
+			name:         "fails after every retry",
+			failures:     10,
+			wantProgress: "1 ❌ web_search · 2 ✔️ return_response",
+			wantAttempts: 3,
+			wantEvents: []EventType{
+				EventPlanCreated,
+				EventStepStarted, EventStepRetrying, EventStepRetrying, EventStepFailed,
+				EventStepStarted, EventStepSucceeded,
+				EventCompleted,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &fakeLLM{responses: []string{
+				`{"goal":"x","steps":[{"action":"web_search","params":{"query":"q"}},{"action":"return_response"}]}`,
+				"here is what I found",
+			}}
+			o := New(llm, "test-model", testConfig(), nil)
+
+			calls := 0
+			o.Handle(ActionWebSearch, func(context.Context, Step, []StepResult) (string, error) {
+				calls++
+				if calls <= tt.failures {
+					return "", errors.New("search timed out")
+				}
+				return "results", nil
+			})
+			var events []Event
+			o.OnEvent(func(e Event) { events = append(events, e) })
+
+			result, err := o.Run(context.Background(), "question")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantProgress, result.Progress())
+			assert.Equal(t, tt.wantAttempts, result.Steps[0].Attempts)
+			assert.Equal(t, tt.wantEvents, eventTypes(events))
+
+			if tt.failures > tt.wantAttempts {
+				synth := llm.calls[1][1].Parts[0].(llms.TextContent).Text
+				assert.Contains(t, synth, "failed: search timed out")
+			}
+		})
+	}
+}
+
+func TestOrchestrator_Replan(t *testing.T) {
+	llm := &fakeLLM{responses: []string{
+		`{"goal":"x","steps":[{"action":"db_query","params":{"query":"q"}},{"action":"return_response"}]}`,
+		`{"goal":"x","steps":[{"action":"return_response"}]}`,
+		"hello",
+	}}
+	o := New(llm, "test-model", testConfig(), nil)
+	var events []Event
+	o.OnEvent(func(e Event) { events = append(events, e) })
+
+	result, err := o.Run(context.Background(), "question")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", result.Answer)
+	assert.Equal(t, EventPlanRejected, events[0].Type)
+	assert.Contains(t, events[0].Error, "not allowed")
+
+	// The second planner call carries the validation error
+	replan := llm.calls[1]
+	last := replan[len(replan)-1].Parts[0].(llms.TextContent).Text
+	assert.Contains(t, last, `action "db_query" is not allowed`)
+}
+
+func TestOrchestrator_NoValidPlan(t *testing.T) {
+	llm := &fakeLLM{responses: []string{"not a plan", "still not a plan"}}
+	o := New(llm, "test-model", testConfig(), nil)
+	var events []Event
+	o.OnEvent(func(e Event) { events = append(events, e) })
+
+	_, err := o.Run(context.Background(), "question")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no valid plan after 2 attempts")
+	assert.Equal(t, []EventType{EventPlanRejected, EventPlanRejected, EventFailed}, eventTypes(events))
+}
+
+func TestHandleTools(t *testing.T) {
+	registry := agent.NewRegistry()
+	var faqArgs string
+	require.NoError(t, registry.Register(agent.Tool{
+		Definition: llms.Tool{Type: "function", Function: &llms.FunctionDefinition{Name: "faq_lookup"}},
+		Impl: agent.NewFuncTool("faq_lookup", "", func(_ context.Context, input string) (string, error) {
+			faqArgs = input
+			return "Pete uses neovim", nil
+		}),
+	}))
+	require.NoError(t, registry.Register(agent.Tool{
+		Definition: llms.Tool{Type: "function", Function: &llms.FunctionDefinition{Name: "query_chat_history"}},
+		Impl: agent.NewFuncTool("query_chat_history", "", func(context.Context, string) (string, error) {
+			return "", errors.New("no session")
+		}),
+	}))
+
+	o := New(&fakeLLM{}, "test-model", testConfig(), nil)
+	o.HandleTools(registry)
+
+	// web_search is not registered so plans cannot use it
+	assert.Equal(t, []Action{ActionAPICall, ActionReferenceCheck, ActionReturnResponse}, o.Actions())
+
+	out, err := o.handlerFor("q", ActionReferenceCheck)(context.Background(), Step{Params: map[string]string{"query": "editor"}}, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"question":"editor"}`, faqArgs)
+	assert.True(t, strings.HasPrefix(out, "faq_lookup:\nPete uses neovim"))
+
+	_, err = o.handlerFor("q", ActionAPICall)(context.Background(), Step{Params: map[string]string{"tool": "web_search"}}, nil)
+	assert.ErrorContains(t, err, "tool web_search is not available")
+}
+
+// fakeAnswers returns canned past answers and records the search
+type fakeAnswers struct {
+	answers        []types.PastAnswer
+	channel, query string
+}
+
+func (f *fakeAnswers) SearchAnswers(_ context.Context, channel, query string, _ int) ([]types.PastAnswer, error) {
+	f.channel, f.query = channel, query
+	return f.answers, nil
+}
+
+func TestHandleDBQuery(t *testing.T) {
+	searcher := &fakeAnswers{answers: []types.PastAnswer{{
+		Username:  "scott",
+		Message:   "pedro what editor does pete use?",
+		Response:  "Pete uses neovim",
+		CreatedAt: time.Date(2026, 9, 1, 18, 0, 0, 0, time.UTC),
+	}}}
+	o := New(&fakeLLM{}, "test-model", testConfig(), nil)
+	o.HandleDBQuery(searcher)
+	assert.Equal(t, []Action{ActionDBQuery, ActionReturnResponse}, o.Actions())
+
+	handler := o.handlerFor("q", ActionDBQuery)
+	step := Step{Params: map[string]string{"query": "editor"}}
+	out, err := handler(agent.WithChannel(context.Background(), "soypetetech"), step, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "soypetetech", searcher.channel)
+	assert.Equal(t, "editor", searcher.query)
+	assert.Equal(t, "2026-09-01 scott asked: pedro what editor does pete use?\nPedro answered: Pete uses neovim\n", out)
+
+	searcher.answers = nil
+	out, err = handler(agent.WithChannel(context.Background(), "soypetetech"), step, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Nothing in Pedro's past answers matches that.", out)
+
+	_, err = handler(context.Background(), step, nil)
+	assert.ErrorContains(t, err, "no channel to search")
+}
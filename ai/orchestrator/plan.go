@@ -0,0 +1,110 @@
+// Package orchestrator lets Pedro plan and execute multi-step questions. The LLM writes a
+// JSON plan made of a fixed set of actions, the plan is validated, and every step is run
+// in order with retries. Progress is published as events so chat or Discord can show it.
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Action is a kind of step a plan may use
+type Action string
+
+const (
+	ActionAPICall        Action = "api_call"
+	ActionDBQuery        Action = "db_query"
+	ActionWebSearch      Action = "web_search"
+	ActionReferenceCheck Action = "reference_check"
+	ActionReturnResponse Action = "return_response"
+)
+
+// actionDescriptions explain each action to the planner, including its params
+var actionDescriptions = map[Action]string{
+	ActionAPICall:        `call one of Pedro's tools. params: {"tool": "<tool name>", ...tool arguments}`,
+	ActionDBQuery:        `search Pedro's past answers in this channel. params: {"query": "<words to look for>"}`,
+	ActionWebSearch:      `search the web. params: {"query": "<search query>"}`,
+	ActionReferenceCheck: `check the FAQ and this stream's chat history. params: {"query": "<what to check>"}`,
+	ActionReturnResponse: `write the final answer from the results so far. params: {}`,
+}
+
+// requiredParams are the params each action must have
+var requiredParams = map[Action][]string{
+	ActionAPICall:        {"tool"},
+	ActionDBQuery:        {"query"},
+	ActionWebSearch:      {"query"},
+	ActionReferenceCheck: {"query"},
+}
+
+// Step is one action in a plan
+type Step struct {
+	Action Action            `json:"action"`
+	Params map[string]string `json:"params,omitempty"`
+	Reason string            `json:"reason,omitempty"`
+}
+
+// Plan is the LLM's plan for answering a question
+type Plan struct {
+	Goal  string `json:"goal"`
+	Steps []Step `json:"steps"`
+}
+
+// ParsePlan reads a plan from an LLM response. Markdown code fences and text around the
+// JSON object are ignored, but the object itself must match the plan schema exactly.
+func ParsePlan(text string) (*Plan, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in plan response")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(text[start : end+1])))
+	dec.DisallowUnknownFields()
+	var plan Plan
+	if err := dec.Decode(&plan); err != nil {
+		return nil, fmt.Errorf("plan does not match schema: %w", err)
+	}
+	return &plan, nil
+}
+
+// Validate checks the plan only uses allowed actions with their required params, has at
+// most maxSteps steps, and ends with exactly one return_response.
+func (p *Plan) Validate(allowed []Action, maxSteps int) error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("plan has no steps")
+	}
+	if maxSteps > 0 && len(p.Steps) > maxSteps {
+		return fmt.Errorf("plan has %d steps, at most %d are allowed", len(p.Steps), maxSteps)
+	}
+
+	last := len(p.Steps) - 1
+	for i, step := range p.Steps {
+		if !slices.Contains(allowed, step.Action) {
+			return fmt.Errorf("step %d: action %q is not allowed", i+1, step.Action)
+		}
+		if step.Action == ActionReturnResponse && i != last {
+			return fmt.Errorf("step %d: return_response must be the last step", i+1)
+		}
+		for _, param := range requiredParams[step.Action] {
+			if strings.TrimSpace(step.Params[param]) == "" {
+				return fmt.Errorf("step %d: %s needs the %q param", i+1, step.Action, param)
+			}
+		}
+	}
+	if p.Steps[last].Action != ActionReturnResponse {
+		return fmt.Errorf("plan must end with return_response")
+	}
+	return nil
+}
+
+// String lists the plan's actions, like "web_search → return_response"
+func (p *Plan) String() string {
+	actions := make([]string, len(p.Steps))
+	for i, step := range p.Steps {
+		actions[i] = string(step.Action)
+	}
+	return strings.Join(actions, " → ")
+}
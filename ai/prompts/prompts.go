@@ -0,0 +1,372 @@
+// Package prompts holds Pedro's prompts as versioned Go templates, and the personas that
+// fill them in. The templates in templates/ are built in. A prompts directory can replace
+// any of them, and its personas.yaml replaces the built-in personas.
+//
+// Every template file starts with front matter giving its version:
+//
+//	---
+//	version: 2
+//	---
+//	Your name is {{.Persona.Name}}...
+//
+// The template's name is its file name without .tmpl, and templates can use each other as
+// sections with {{template "name" .}}.
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed templates/*.tmpl templates/personas.yaml
+var builtin embed.FS
+
+// Template names used by Pedro
+const (
+	Pedro             = "pedro"
+	StreamContext     = "stream_context"
+	ModerationSystem  = "moderation_system"
+	ModerationMessage = "moderation_message"
+	FAQSystem         = "faq_system"
+	FAQResponse       = "faq_response"
+	ClassifierSystem  = "classifier_system"
+	ClassifierMessage = "classifier_message"
+	SafetyReview      = "safety_review"
+	SearchRewrite     = "search_rewrite"
+	PageSummary       = "page_summary"
+
+	TwentyQuestionsPick   = "twenty_questions_pick"
+	TwentyQuestionsAnswer = "twenty_questions_answer"
+	TwentyQuestionsGuess  = "twenty_questions_guess"
+)
+
+// personasFile is the personas file in a prompts directory
+const personasFile = "personas.yaml"
+
+// Persona is who Pedro is and how he talks
+type Persona struct {
+	Version string `yaml:"version"`
+	Name    string `yaml:"name"`
+	// Intro is what the bot is, e.g. "You are a chat bot that helps out in ..."
+	Intro string `yaml:"intro"`
+	// About describes the streamer and the stream
+	About   string   `yaml:"about"`
+	Style   string   `yaml:"style"`
+	Rules   []string `yaml:"rules"`
+	Emotes  []string `yaml:"emotes"`
+	SignOff string   `yaml:"sign_off"`
+}
+
+type personasConfig struct {
+	Default  string             `yaml:"default"`
+	Personas map[string]Persona `yaml:"personas"`
+}
+
+// Rendered is a rendered prompt and the version of the template that produced it
+type Rendered struct {
+	Text    string
+	Version string
+}
+
+// Set is a loaded set of templates and personas
+type Set struct {
+	root           *template.Template
+	versions       map[string]string
+	personas       map[string]Persona
+	defaultPersona string
+}
+
+var funcs = template.FuncMap{
+	"join": strings.Join,
+}
+
+var (
+	defaultMu  sync.RWMutex
+	defaultSet *Set
+)
+
+// Default returns the set in use, the built-in templates unless SetDefault was called
+func Default() *Set {
+	defaultMu.RLock()
+	set := defaultSet
+	defaultMu.RUnlock()
+	if set != nil {
+		return set
+	}
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultSet == nil {
+		set, err := Load("")
+		if err != nil {
+			// the built-in templates are checked by the package tests
+			panic(fmt.Sprintf("built-in prompts are invalid: %v", err))
+		}
+		defaultSet = set
+	}
+	return defaultSet
+}
+
+// SetDefault replaces the set returned by Default
+func SetDefault(set *Set) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultSet = set
+}
+
+// Load loads the built-in templates, then the .tmpl files and personas.yaml in dir, which
+// replace built-in templates and personas with the same name. An empty dir loads only the
+// built-in templates.
+func Load(dir string) (*Set, error) {
+	sources := make(map[string][]byte)
+	builtinFS, err := fs.Sub(builtin, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := readTemplates(builtinFS, sources); err != nil {
+		return nil, err
+	}
+	personaData, err := fs.ReadFile(builtinFS, personasFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read built-in personas: %w", err)
+	}
+
+	if dir != "" {
+		dirFS := os.DirFS(dir)
+		if err := readTemplates(dirFS, sources); err != nil {
+			return nil, fmt.Errorf("failed to read prompts from %s: %w", dir, err)
+		}
+		data, err := fs.ReadFile(dirFS, personasFile)
+		switch {
+		case err == nil:
+			personaData = data
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(dir, personasFile), err)
+		}
+	}
+
+	set := &Set{
+		root:     template.New("").Funcs(funcs).Option("missingkey=error"),
+		versions: make(map[string]string),
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		version, body, err := splitFrontMatter(sources[name])
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		if _, err := set.root.New(name).Parse(body); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		set.versions[name] = version
+	}
+
+	if err := set.loadPersonas(personaData); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func readTemplates(fsys fs.FS, sources map[string][]byte) error {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		sources[strings.TrimSuffix(file, ".tmpl")] = data
+	}
+	return nil
+}
+
+// splitFrontMatter reads the version from a template's front matter and returns the body.
+// A single trailing newline is dropped so files can end with one.
+func splitFrontMatter(data []byte) (string, string, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return "", "", fmt.Errorf("missing front matter")
+	}
+	front, body, ok := strings.Cut(text[len("---\n"):], "\n---\n")
+	if !ok {
+		return "", "", fmt.Errorf("front matter is not closed")
+	}
+	var meta struct {
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal([]byte(front), &meta); err != nil {
+		return "", "", fmt.Errorf("invalid front matter: %w", err)
+	}
+	if meta.Version == "" {
+		return "", "", fmt.Errorf("front matter has no version")
+	}
+	return meta.Version, strings.TrimSuffix(body, "\n"), nil
+}
+
+func (s *Set) loadPersonas(data []byte) error {
+	var config personasConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse personas: %w", err)
+	}
+	if len(config.Personas) == 0 {
+		return fmt.Errorf("no personas defined")
+	}
+	for key, p := range config.Personas {
+		if p.Name == "" || p.Version == "" {
+			return fmt.Errorf("persona %s needs a name and a version", key)
+		}
+	}
+	if _, ok := config.Personas[config.Default]; !ok {
+		return fmt.Errorf("default persona %q is not defined", config.Default)
+	}
+	s.personas = config.Personas
+	s.defaultPersona = config.Default
+	return nil
+}
+
+// Render renders the named template with data
+func (s *Set) Render(name string, data any) (Rendered, error) {
+	version, ok := s.versions[name]
+	if !ok {
+		return Rendered{}, fmt.Errorf("unknown prompt template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := s.root.ExecuteTemplate(&buf, name, data); err != nil {
+		return Rendered{}, fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+	return Rendered{Text: buf.String(), Version: name + "@" + version}, nil
+}
+
+// Persona returns a persona by key. An empty key returns the default persona.
+func (s *Set) Persona(key string) (Persona, error) {
+	if key == "" {
+		key = s.defaultPersona
+	}
+	p, ok := s.personas[key]
+	if !ok {
+		return Persona{}, fmt.Errorf("unknown persona %q", key)
+	}
+	return p, nil
+}
+
+// Personas returns the persona keys, sorted
+func (s *Set) Personas() []string {
+	keys := make([]string, 0, len(s.personas))
+	for key := range s.personas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PedroData fills in the pedro template
+type PedroData struct {
+	Persona Persona
+	// Date is today's date, e.g. 2026-03-01
+	Date string
+	// Emotes are the emotes Pedro may use in this channel
+	Emotes []string
+	// Stream is the stream context section's data, usually an *ai.MeetupConfig. Nil leaves
+	// the section out.
+	Stream any
+}
+
+// RenderPedro renders Pedro's system prompt with a persona. Channel emotes replace the
+// persona's emotes when set. The version names the template and the persona, e.g.
+// "pedro@2 persona=pedro@1".
+func (s *Set) RenderPedro(persona string, date string, emotes []string, stream any) (Rendered, error) {
+	p, err := s.Persona(persona)
+	if err != nil {
+		return Rendered{}, err
+	}
+	if persona == "" {
+		persona = s.defaultPersona
+	}
+	if len(emotes) == 0 {
+		emotes = p.Emotes
+	}
+	rendered, err := s.Render(Pedro, PedroData{Persona: p, Date: date, Emotes: emotes, Stream: stream})
+	if err != nil {
+		return Rendered{}, err
+	}
+	rendered.Version = fmt.Sprintf("%s persona=%s@%s", rendered.Version, persona, p.Version)
+	return rendered, nil
+}
+
+// ModerationData fills in the moderation_system template
+type ModerationData struct {
+	Rules       []string
+	Sensitivity string
+}
+
+// ModerationMessageData fills in the moderation_message template
+type ModerationMessageData struct {
+	Recent    []types.TwitchMessage
+	Message   types.TwitchMessage
+	MessageID string
+}
+
+// FAQData fills in the faq_system and faq_response templates
+type FAQData struct {
+	Persona     Persona
+	UserMessage string
+	Question    string
+	Answer      string
+}
+
+// ClassifierData fills in the classifier_message template
+type ClassifierData struct {
+	Classes []string
+	Message string
+}
+
+// SafetyReviewData fills in the safety_review template
+type SafetyReviewData struct {
+	Persona Persona
+	Channel string
+	// Rules are extra reasons to veto a reply
+	Rules []string
+}
+
+// SearchRewriteData fills in the search_rewrite template
+type SearchRewriteData struct {
+	// Tried are the queries that found nothing, oldest first
+	Tried []string
+}
+
+// PageSummaryData fills in the page_summary template
+type PageSummaryData struct {
+	URL      string
+	Title    string
+	Question string
+	// Part and Parts place a chunk of a long page. Notes is set when combining the
+	// summaries of every part.
+	Part     int
+	Parts    int
+	Notes    bool
+	MaxChars int
+}
+
+// TwentyQuestionsData fills in the twenty_questions templates
+type TwentyQuestionsData struct {
+	Category string
+	Answer   string
+	Guess    string
+}
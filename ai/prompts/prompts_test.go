@@ -0,0 +1,128 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinTemplatesRender(t *testing.T) {
+	set, err := Load("")
+	require.NoError(t, err)
+	pedro, err := set.Persona("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		data     any
+		contains string
+	}{
+		{name: ModerationSystem, data: ModerationData{Rules: []string{"be kind"}, Sensitivity: "moderate"}, contains: "Channel Rules:\n- be kind\n"},
+		{
+			name: ModerationMessage,
+			data: ModerationMessageData{
+				Recent:    []types.TwitchMessage{{Username: "a", Text: "hi"}},
+				Message:   types.TwitchMessage{Username: "b", Text: "spam"},
+				MessageID: "42",
+			},
+			contains: "[a]: hi\n\n\nMessage to evaluate:\nUser: b\nMessage ID: 42\nContent: spam",
+		},
+		{name: FAQSystem, data: FAQData{Persona: pedro}, contains: "You are Pedro,"},
+		{name: FAQResponse, data: FAQData{UserMessage: "when?", Question: "schedule", Answer: "Tuesdays"}, contains: "The information to share is: Tuesdays"},
+		{name: ClassifierSystem, data: nil, contains: "Unclassified"},
+		{name: SafetyReview, data: SafetyReviewData{Persona: pedro, Channel: "soypetetech", Rules: []string{"talks about Java"}}, contains: "- tells viewers to run destructive commands\n- talks about Java\n\nJokes"},
+		{name: SearchRewrite, data: SearchRewriteData{Tried: []string{"pedro whats the go release", "go release"}}, contains: "found nothing:\n- pedro whats the go release\n- go release\n\nRespond"},
+		{name: PageSummary, data: PageSummaryData{URL: "https://go.dev/doc", Question: "what is new?", Part: 2, Parts: 3, MaxChars: 500}, contains: "part 2 of 3 of the text of the page https://go.dev/doc.\n\nFocus on what helps answer this question: what is new?"},
+		{name: PageSummary, data: PageSummaryData{URL: "https://go.dev/doc", Title: "Docs", Notes: true, MaxChars: 500}, contains: `notes taken from each part of the page https://go.dev/doc ("Docs"). Combine them into one summary.`},
+		{name: ClassifierMessage, data: ClassifierData{Classes: []string{"Go", "AI"}, Message: "goroutines"}, contains: "categories: Go, AI\n\nMessage to classify:\ngoroutines"},
+		{name: TwentyQuestionsPick, data: TwentyQuestionsData{Category: "animals"}, contains: "from this category: animals."},
+		{name: TwentyQuestionsAnswer, data: TwentyQuestionsData{Answer: "gopher"}, contains: `The secret answer is "gopher".`},
+		{name: TwentyQuestionsGuess, data: TwentyQuestionsData{Answer: "gopher", Guess: "mole"}, contains: `"gopher" and a viewer guessed "mole"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := set.Render(tt.name, tt.data)
+			require.NoError(t, err)
+			assert.Contains(t, got.Text, tt.contains)
+			assert.Equal(t, tt.name+"@1", got.Version)
+		})
+	}
+
+	_, err = set.Render("missing", nil)
+	assert.ErrorContains(t, err, `unknown prompt template "missing"`)
+}
+
+func TestRenderPedro(t *testing.T) {
+	set, err := Load("")
+	require.NoError(t, err)
+
+	got, err := set.RenderPedro("", "2026-03-01", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pedro@1 persona=pedro@1", got.Version)
+	assert.Contains(t, got.Text, "Your name is Pedro. ")
+	assert.Contains(t, got.Text, "Today's date is 2026-03-01.")
+	assert.Contains(t, got.Text, "soypet2Dance")
+	assert.NotContains(t, got.Text, "\n", "Pedro's prompt has no stream section")
+
+	got, err = set.RenderPedro("prof_pedro", "2026-03-01", []string{"forgeuHype"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pedro@1 persona=prof_pedro@1", got.Version)
+	assert.Contains(t, got.Text, "Your name is Professor Pedro. ")
+	assert.Contains(t, got.Text, "approved emotes forgeuHype.")
+	assert.NotContains(t, got.Text, "soypet2Profpedro", "channel emotes replace the persona's")
+
+	_, err = set.RenderPedro("nobody", "2026-03-01", nil, nil)
+	assert.ErrorContains(t, err, `unknown persona "nobody"`)
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	write("pedro.tmpl", "---\nversion: 7\n---\n{{.Persona.Name}} says hi on {{.Date}}{{with .Stream}}{{template \"stream_context\" .}}{{end}}\n")
+	write("stream_context.tmpl", "---\nversion: 2\n---\n at {{.}}\n")
+	write("personas.yaml", "default: bot\npersonas:\n  bot:\n    version: \"3\"\n    name: Bot\n")
+
+	set, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bot"}, set.Personas())
+
+	got, err := set.RenderPedro("", "today", nil, "the meetup")
+	require.NoError(t, err)
+	assert.Equal(t, "Bot says hi on today at the meetup", got.Text)
+	assert.Equal(t, "pedro@7 persona=bot@3", got.Version)
+
+	// Templates the directory leaves out are still built in
+	got, err = set.Render(ClassifierSystem, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "classifier_system@1", got.Version)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{name: "no front matter", file: "pedro.tmpl", content: "hello", wantErr: "missing front matter"},
+		{name: "unclosed front matter", file: "pedro.tmpl", content: "---\nversion: 1\nhello", wantErr: "front matter is not closed"},
+		{name: "no version", file: "pedro.tmpl", content: "---\nowner: pete\n---\nhello", wantErr: "front matter has no version"},
+		{name: "bad template", file: "pedro.tmpl", content: "---\nversion: 1\n---\n{{.Persona", wantErr: "failed to parse template pedro"},
+		{name: "unknown default persona", file: "personas.yaml", content: "default: nobody\npersonas:\n  bot:\n    version: \"1\"\n    name: Bot\n", wantErr: `default persona "nobody" is not defined`},
+		{name: "persona without version", file: "personas.yaml", content: "default: bot\npersonas:\n  bot:\n    name: Bot\n", wantErr: "persona bot needs a name and a version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.content), 0o600))
+			_, err := Load(dir)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
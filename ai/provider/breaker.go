@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a backend's circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed sends calls to the backend
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets one trial call through to see if the backend recovered
+	BreakerHalfOpen
+	// BreakerOpen skips the backend
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half_open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker opens after threshold failures in a row and stays open for openFor. After that
+// one trial call decides whether it closes or opens again.
+type breaker struct {
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	inTrial   bool
+	threshold int
+	openFor   time.Duration
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, openFor time.Duration) *breaker {
+	return &breaker{threshold: threshold, openFor: openFor, now: time.Now}
+}
+
+// allow reports whether a call may go to the backend
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openFor {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.inTrial = false
+	}
+	if b.inTrial {
+		return false
+	}
+	b.inTrial = true
+	return true
+}
+
+// success records a successful call and closes the breaker
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.inTrial = false
+}
+
+// failure records a failed call. A failed trial call opens the breaker again.
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.trip()
+	}
+}
+
+// release ends a call without an outcome, e.g. when the caller cancelled it
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inTrial = false
+}
+
+// open opens the breaker, e.g. when a health check fails
+func (b *breaker) open() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trip()
+}
+
+// recovered lets an open breaker try a call right away, e.g. when a health check passes
+func (b *breaker) recovered() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen {
+		b.state = BreakerHalfOpen
+		b.inTrial = false
+	}
+}
+
+func (b *breaker) trip() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+	b.inTrial = false
+}
+
+func (b *breaker) currentState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,125 @@
+// Package provider is the shared LLM layer. It holds a prioritized list of OpenAI-compatible
+// backends and sends every chat and embedding call to the first healthy one, falling back
+// down the list when a backend errors, times out or has its circuit breaker open.
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BackendConfig is one OpenAI-compatible server
+type BackendConfig struct {
+	Name string `yaml:"name"`
+	// URL is the server's base URL. /v1 is appended when missing.
+	URL string `yaml:"url"`
+	// Model replaces the model callers ask for, for backends that serve a different model
+	Model string `yaml:"model,omitempty"`
+	// EmbeddingModel replaces the embedding model callers ask for
+	EmbeddingModel string `yaml:"embedding_model,omitempty"`
+	// APIKeyEnv names the environment variable holding the backend's API key. Without it
+	// OPENAI_API_KEY is used when set.
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+	// TimeoutSeconds overrides the provider's per-call timeout for this backend
+	TimeoutSeconds int `yaml:"timeout_seconds,omitempty"`
+}
+
+// Config lists the backends in priority order and how failures are handled
+type Config struct {
+	Backends []BackendConfig `yaml:"backends"`
+	// CallTimeoutSeconds bounds one call to one backend
+	CallTimeoutSeconds int `yaml:"call_timeout_seconds,omitempty"`
+	// FailureThreshold is how many failures in a row open a backend's circuit breaker
+	FailureThreshold int `yaml:"failure_threshold,omitempty"`
+	// OpenSeconds is how long an open breaker skips its backend before trying it again
+	OpenSeconds int `yaml:"open_seconds,omitempty"`
+	// HealthCheckSeconds is how often every backend's /models endpoint is checked. Zero uses
+	// the default and a negative value turns health checks off.
+	HealthCheckSeconds int `yaml:"health_check_seconds,omitempty"`
+}
+
+const (
+	defaultCallTimeout      = 60 * time.Second
+	defaultFailureThreshold = 3
+	defaultOpenDuration     = 30 * time.Second
+	defaultHealthInterval   = 30 * time.Second
+)
+
+// LoadConfig loads backends from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LLM config %s: %w", path, err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM config %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid LLM config %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// ConfigFromEnv builds the backends from LLAMA_CPP_PATH and the comma separated
+// LLM_FALLBACK_PATHS
+func ConfigFromEnv() *Config {
+	config := SingleBackend(os.Getenv("LLAMA_CPP_PATH"))
+	for i, url := range strings.Split(os.Getenv("LLM_FALLBACK_PATHS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			config.Backends = append(config.Backends, BackendConfig{Name: fmt.Sprintf("fallback-%d", i+1), URL: url})
+		}
+	}
+	return config
+}
+
+// defaultURL is used when no URL is configured, as the openai client does
+const defaultURL = "https://api.openai.com/v1"
+
+// SingleBackend is a config with one backend at url. An empty url uses the OpenAI API.
+func SingleBackend(url string) *Config {
+	if url == "" {
+		url = defaultURL
+	}
+	return &Config{Backends: []BackendConfig{{Name: "primary", URL: url}}}
+}
+
+// Validate checks every backend has a unique name and a URL
+func (c *Config) Validate() error {
+	if len(c.Backends) == 0 {
+		return fmt.Errorf("at least one backend is required")
+	}
+	seen := make(map[string]bool)
+	for i, b := range c.Backends {
+		if b.Name == "" {
+			return fmt.Errorf("backend %d has no name", i+1)
+		}
+		if seen[b.Name] {
+			return fmt.Errorf("backend name %q is used twice", b.Name)
+		}
+		seen[b.Name] = true
+		if b.URL == "" {
+			return fmt.Errorf("backend %s has no url", b.Name)
+		}
+	}
+	return nil
+}
+
+func seconds(n int, fallback time.Duration) time.Duration {
+	if n <= 0 {
+		return fallback
+	}
+	return time.Duration(n) * time.Second
+}
+
+func apiURL(url string) string {
+	url = strings.TrimSuffix(url, "/")
+	if !strings.HasSuffix(url, "/v1") {
+		url += "/v1"
+	}
+	return url
+}
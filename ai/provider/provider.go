@@ -0,0 +1,297 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// ErrUnavailable is returned when every backend's circuit breaker is open
+var ErrUnavailable = errors.New("no LLM backend is available")
+
+// healthCheckTimeout bounds one health check request
+const healthCheckTimeout = 5 * time.Second
+
+// backend is one configured server with its clients and breaker
+type backend struct {
+	config  BackendConfig
+	url     string
+	token   string
+	timeout time.Duration
+	breaker *breaker
+	chat    *openai.LLM
+
+	embedMu   sync.Mutex
+	embedders map[string]*openai.LLM
+}
+
+// embedder returns the client for an embedding model, creating it on first use
+func (b *backend) embedder(model string) (*openai.LLM, error) {
+	if b.config.EmbeddingModel != "" {
+		model = b.config.EmbeddingModel
+	}
+	b.embedMu.Lock()
+	defer b.embedMu.Unlock()
+	if client, ok := b.embedders[model]; ok {
+		return client, nil
+	}
+	client, err := openai.New(
+		openai.WithBaseURL(b.url),
+		openai.WithToken(b.token),
+		openai.WithEmbeddingModel(model),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding client: %w", err)
+	}
+	b.embedders[model] = client
+	return client, nil
+}
+
+// Provider sends LLM calls to the first available backend and falls back down the list.
+// It implements llms.Model, so it can be used wherever an openai.LLM was.
+type Provider struct {
+	backends       []*backend
+	healthInterval time.Duration
+	httpClient     *http.Client
+	logger         *logging.Logger
+}
+
+// New creates a provider for the configured backends
+func New(config *Config, logger *logging.Logger) (*Provider, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if config == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	callTimeout := seconds(config.CallTimeoutSeconds, defaultCallTimeout)
+	threshold := config.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	openFor := seconds(config.OpenSeconds, defaultOpenDuration)
+
+	p := &Provider{
+		healthInterval: seconds(config.HealthCheckSeconds, defaultHealthInterval),
+		httpClient:     &http.Client{Timeout: healthCheckTimeout},
+		logger:         logger,
+	}
+	if config.HealthCheckSeconds < 0 {
+		p.healthInterval = 0
+	}
+
+	for _, bc := range config.Backends {
+		b := &backend{
+			config:    bc,
+			url:       apiURL(bc.URL),
+			token:     apiKey(bc.APIKeyEnv),
+			timeout:   seconds(bc.TimeoutSeconds, callTimeout),
+			breaker:   newBreaker(threshold, openFor),
+			embedders: make(map[string]*openai.LLM),
+		}
+		opts := []openai.Option{openai.WithBaseURL(b.url), openai.WithToken(b.token)}
+		if bc.Model != "" {
+			opts = append(opts, openai.WithModel(bc.Model))
+		}
+		chat, err := openai.New(opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client for backend %s: %w", bc.Name, err)
+		}
+		b.chat = chat
+		p.backends = append(p.backends, b)
+		metrics.LLMBackendCircuitState.WithLabelValues(bc.Name).Set(float64(BreakerClosed))
+		logger.Info("llm backend configured", "backend", bc.Name, "url", b.url, "timeout", b.timeout)
+	}
+	return p, nil
+}
+
+// GenerateContent implements llms.Model
+func (p *Provider) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	var resp *llms.ContentResponse
+	err := p.do(ctx, "chat", func(ctx context.Context, b *backend) error {
+		opts := options
+		if b.config.Model != "" {
+			opts = append(slices.Clone(options), llms.WithModel(b.config.Model))
+		}
+		r, err := b.chat.GenerateContent(ctx, messages, opts...)
+		if err != nil {
+			return err
+		}
+		resp = r
+		return nil
+	})
+	return resp, err
+}
+
+// Call implements llms.Model
+func (p *Provider) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, p, prompt, options...)
+}
+
+// Embedder returns an embeddings client for model that uses the same backends and breakers
+func (p *Provider) Embedder(model string) embeddings.EmbedderClient {
+	return embeddings.EmbedderClientFunc(func(ctx context.Context, texts []string) ([][]float32, error) {
+		var vectors [][]float32
+		err := p.do(ctx, "embedding", func(ctx context.Context, b *backend) error {
+			client, err := b.embedder(model)
+			if err != nil {
+				return err
+			}
+			v, err := client.CreateEmbedding(ctx, texts)
+			if err != nil {
+				return err
+			}
+			vectors = v
+			return nil
+		})
+		return vectors, err
+	})
+}
+
+// do runs call against each backend in priority order until one succeeds
+func (p *Provider) do(ctx context.Context, kind string, call func(context.Context, *backend) error) error {
+	var errs []error
+	for i, b := range p.backends {
+		name := b.config.Name
+		if !b.breaker.allow() {
+			metrics.LLMBackendCallsTotal.WithLabelValues(name, kind, "skipped").Inc()
+			continue
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, b.timeout)
+		start := time.Now()
+		err := call(callCtx, b)
+		cancel()
+		metrics.LLMBackendCallDuration.WithLabelValues(name, kind).Observe(time.Since(start).Seconds())
+
+		switch {
+		case err == nil:
+			b.breaker.success()
+			p.setStateMetric(b)
+			metrics.LLMBackendCallsTotal.WithLabelValues(name, kind, "success").Inc()
+			if i > 0 {
+				p.logger.Info("llm call served by fallback backend", "backend", name, "kind", kind)
+			}
+			return nil
+		case ctx.Err() != nil:
+			// The caller gave up, which says nothing about the backend
+			b.breaker.release()
+			metrics.LLMBackendCallsTotal.WithLabelValues(name, kind, "cancelled").Inc()
+			return fmt.Errorf("llm call cancelled: %w", ctx.Err())
+		}
+
+		b.breaker.failure()
+		p.setStateMetric(b)
+		metrics.LLMBackendCallsTotal.WithLabelValues(name, kind, "error").Inc()
+		p.logger.Warn("llm backend call failed", "backend", name, "kind", kind, "error", err.Error(), "duration", time.Since(start))
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+
+	metrics.LLMAllBackendsFailedTotal.WithLabelValues(kind).Inc()
+	if len(errs) == 0 {
+		return ErrUnavailable
+	}
+	return fmt.Errorf("all LLM backends failed: %w", errors.Join(errs...))
+}
+
+// Start health checks every backend until ctx is cancelled. A failed check opens the
+// backend's breaker, and a passing check lets an open breaker try a call again.
+func (p *Provider) Start(ctx context.Context, wg *sync.WaitGroup) {
+	if p.healthInterval <= 0 {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(p.healthInterval)
+		defer ticker.Stop()
+		for {
+			p.CheckHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// CheckHealth checks every backend's /models endpoint once
+func (p *Provider) CheckHealth(ctx context.Context) {
+	for _, b := range p.backends {
+		err := p.checkBackend(ctx, b)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if b.breaker.currentState() != BreakerOpen {
+				p.logger.Warn("llm backend health check failed", "backend", b.config.Name, "error", err.Error())
+			}
+			b.breaker.open()
+			metrics.LLMBackendUp.WithLabelValues(b.config.Name).Set(0)
+		} else {
+			b.breaker.recovered()
+			metrics.LLMBackendUp.WithLabelValues(b.config.Name).Set(1)
+		}
+		p.setStateMetric(b)
+	}
+}
+
+func (p *Provider) checkBackend(ctx context.Context, b *backend) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+"/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// States returns each backend's breaker state, in priority order
+func (p *Provider) States() map[string]BreakerState {
+	states := make(map[string]BreakerState, len(p.backends))
+	for _, b := range p.backends {
+		states[b.config.Name] = b.breaker.currentState()
+	}
+	return states
+}
+
+// apiKey reads a backend's API key. Local servers do not check it, but the openai client
+// needs one.
+func apiKey(env string) string {
+	if env != "" {
+		if key := os.Getenv(env); key != "" {
+			return key
+		}
+	}
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		return key
+	}
+	return "none"
+}
+
+func (p *Provider) setStateMetric(b *backend) {
+	metrics.LLMBackendCircuitState.WithLabelValues(b.config.Name).Set(float64(b.breaker.currentState()))
+}
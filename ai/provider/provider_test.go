@@ -0,0 +1,239 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeBackend is an OpenAI-compatible server that answers with its name or a status code
+type fakeBackend struct {
+	*httptest.Server
+	name      string
+	status    atomic.Int64
+	delay     time.Duration
+	hits      atomic.Int64
+	lastModel atomic.Value
+}
+
+func newFakeBackend(t *testing.T, name string) *fakeBackend {
+	t.Helper()
+	f := &fakeBackend{name: name}
+	f.status.Store(http.StatusOK)
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeBackend) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/models" {
+		w.WriteHeader(int(f.status.Load()))
+		_, _ = w.Write([]byte(`{"object":"list","data":[]}`))
+		return
+	}
+
+	f.hits.Add(1)
+	var body struct {
+		Model string `json:"model"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	f.lastModel.Store(body.Model)
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+	if status := int(f.status.Load()); status != http.StatusOK {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"error":{"message":"backend down"}}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/v1/embeddings":
+		_, _ = w.Write([]byte(`{"object":"list","model":"m","data":[{"object":"embedding","index":0,"embedding":[0.5,0.5]}]}`))
+	default:
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "created": 1, "model": body.Model,
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": "from " + f.name},
+				"finish_reason": "stop",
+			}},
+		})
+	}
+}
+
+func generate(t *testing.T, p *Provider) (string, error) {
+	t.Helper()
+	resp, err := p.GenerateContent(context.Background(),
+		[]llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")},
+		llms.WithModel("local-model"))
+	if err != nil {
+		return "", err
+	}
+	return resp.Choices[0].Content, nil
+}
+
+func TestProvider_Fallback(t *testing.T) {
+	primary := newFakeBackend(t, "primary")
+	fallback := newFakeBackend(t, "fallback")
+	p, err := New(&Config{
+		Backends: []BackendConfig{
+			{Name: "primary", URL: primary.URL},
+			{Name: "fallback", URL: fallback.URL, Model: "fallback-model"},
+		},
+		FailureThreshold: 2,
+	}, nil)
+	require.NoError(t, err)
+
+	got, err := generate(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, "from primary", got)
+	assert.Equal(t, "local-model", primary.lastModel.Load())
+
+	// The primary fails, so calls fall back and the fallback's model replaces the caller's
+	primary.status.Store(http.StatusInternalServerError)
+	for i := 0; i < 2; i++ {
+		got, err = generate(t, p)
+		require.NoError(t, err)
+		assert.Equal(t, "from fallback", got)
+	}
+	assert.Equal(t, "fallback-model", fallback.lastModel.Load())
+	assert.Equal(t, BreakerOpen, p.States()["primary"])
+
+	// With its breaker open the primary is skipped
+	hits := primary.hits.Load()
+	_, err = generate(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, hits, primary.hits.Load())
+
+	// Every backend failing returns an error naming each one
+	fallback.status.Store(http.StatusInternalServerError)
+	_, err = generate(t, p)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "fallback:")
+}
+
+func TestProvider_CallTimeout(t *testing.T) {
+	slow := newFakeBackend(t, "slow")
+	slow.delay = 1500 * time.Millisecond
+	fast := newFakeBackend(t, "fast")
+	p, err := New(&Config{
+		Backends: []BackendConfig{
+			{Name: "slow", URL: slow.URL, TimeoutSeconds: 1},
+			{Name: "fast", URL: fast.URL},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	got, err := generate(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, "from fast", got)
+}
+
+func TestProvider_CallerCancelled(t *testing.T) {
+	primary := newFakeBackend(t, "primary")
+	fallback := newFakeBackend(t, "fallback")
+	p, err := New(&Config{Backends: []BackendConfig{
+		{Name: "primary", URL: primary.URL},
+		{Name: "fallback", URL: fallback.URL},
+	}}, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = p.GenerateContent(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, int64(0), fallback.hits.Load())
+	assert.Equal(t, BreakerClosed, p.States()["primary"])
+}
+
+func TestProvider_Embedder(t *testing.T) {
+	primary := newFakeBackend(t, "primary")
+	primary.status.Store(http.StatusServiceUnavailable)
+	fallback := newFakeBackend(t, "fallback")
+	p, err := New(&Config{Backends: []BackendConfig{
+		{Name: "primary", URL: primary.URL},
+		{Name: "fallback", URL: fallback.URL, EmbeddingModel: "text-embedding-3-small"},
+	}}, nil)
+	require.NoError(t, err)
+
+	vectors, err := p.Embedder("nomic-embed").CreateEmbedding(context.Background(), []string{"hello"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{0.5, 0.5}}, vectors)
+	assert.Equal(t, "nomic-embed", primary.lastModel.Load())
+	assert.Equal(t, "text-embedding-3-small", fallback.lastModel.Load())
+}
+
+func TestProvider_CheckHealth(t *testing.T) {
+	backend := newFakeBackend(t, "primary")
+	p, err := New(&Config{Backends: []BackendConfig{{Name: "primary", URL: backend.URL}}}, nil)
+	require.NoError(t, err)
+
+	backend.status.Store(http.StatusServiceUnavailable)
+	p.CheckHealth(context.Background())
+	assert.Equal(t, BreakerOpen, p.States()["primary"])
+	_, err = generate(t, p)
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	backend.status.Store(http.StatusOK)
+	p.CheckHealth(context.Background())
+	assert.Equal(t, BreakerHalfOpen, p.States()["primary"])
+	_, err = generate(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, BreakerClosed, p.States()["primary"])
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := newBreaker(2, 30*time.Second)
+	b.now = func() time.Time { return now }
+
+	assert.True(t, b.allow())
+	b.failure()
+	assert.Equal(t, BreakerClosed, b.currentState(), "one failure is under the threshold")
+	b.failure()
+	assert.Equal(t, BreakerOpen, b.currentState())
+	assert.False(t, b.allow())
+
+	now = now.Add(31 * time.Second)
+	assert.True(t, b.allow(), "trial call after the open period")
+	assert.Equal(t, BreakerHalfOpen, b.currentState())
+	assert.False(t, b.allow(), "only one trial call at a time")
+	b.failure()
+	assert.Equal(t, BreakerOpen, b.currentState(), "failed trial opens again")
+
+	now = now.Add(31 * time.Second)
+	assert.True(t, b.allow())
+	b.success()
+	assert.Equal(t, BreakerClosed, b.currentState())
+	assert.True(t, b.allow())
+}
+
+func TestConfig(t *testing.T) {
+	t.Setenv("LLAMA_CPP_PATH", "http://llama:8080")
+	t.Setenv("LLM_FALLBACK_PATHS", "http://backup:8080, https://api.openai.com/v1")
+	config := ConfigFromEnv()
+	require.NoError(t, config.Validate())
+	require.Len(t, config.Backends, 3)
+	assert.Equal(t, "fallback-2", config.Backends[2].Name)
+	assert.Equal(t, "http://llama:8080/v1", apiURL(config.Backends[0].URL))
+	assert.Equal(t, "https://api.openai.com/v1", apiURL(config.Backends[2].URL))
+
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("backends:\n  - name: a\n    url: http://a\n  - name: a\n    url: http://b\n"), 0o600))
+	_, err := LoadConfig(path)
+	assert.ErrorContains(t, err, `backend name "a" is used twice`)
+}
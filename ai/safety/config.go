@@ -0,0 +1,134 @@
+// Package safety checks Pedro's messages before they are sent to chat. A pipeline of
+// stages strips links, removes banned phrases and unknown emotes, fits the message to
+// Twitch's length limit and can ask the LLM to veto it.
+package safety
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// What a stage does when it finds a banned phrase
+const (
+	ActionBlock  = "block"
+	ActionRedact = "redact"
+)
+
+// Config holds configuration for the output safety pipeline
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Sources are the outbound message sources that are checked (chat, web_search, faq,
+	// command, ...). Empty means every message.
+	Sources []string `yaml:"sources"`
+
+	// URLs controls link stripping
+	URLs URLConfig `yaml:"urls"`
+
+	// MaxLength is the longest message in characters. Longer messages are cut at the last
+	// sentence that fits. Zero turns truncation off, and the outbound sender splits long
+	// messages into several chat lines instead.
+	MaxLength int `yaml:"max_length"`
+
+	// Emotes controls which emotes Pedro may use
+	Emotes EmoteConfig `yaml:"emotes"`
+
+	// BannedPhrases are matched as whole words, ignoring case
+	BannedPhrases []string `yaml:"banned_phrases"`
+
+	// BannedAction is block (drop the message) or redact (replace the phrase)
+	BannedAction string `yaml:"banned_action"`
+
+	// SelfModeration asks the LLM to review each message last
+	SelfModeration SelfModerationConfig `yaml:"self_moderation"`
+}
+
+// URLConfig controls link stripping
+type URLConfig struct {
+	Strip bool `yaml:"strip"`
+
+	// AllowedDomains are kept, including their subdomains
+	AllowedDomains []string `yaml:"allowed_domains"`
+}
+
+// EmoteConfig controls which emotes Pedro may use. Words starting with one of Prefixes
+// are emotes, and emotes not in Allowed are removed.
+type EmoteConfig struct {
+	Prefixes []string `yaml:"prefixes"`
+
+	// Allowed emotes. Empty uses the persona's emotes.
+	Allowed []string `yaml:"allowed"`
+}
+
+// SelfModerationConfig controls the LLM review of each message
+type SelfModerationConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Rules are extra reasons for the LLM to veto a message
+	Rules []string `yaml:"rules"`
+
+	// TimeoutSeconds bounds one review
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+
+	// FailOpen sends the message when the review fails instead of dropping it
+	FailOpen bool `yaml:"fail_open"`
+}
+
+// Timeout returns how long one review may take
+func (c SelfModerationConfig) Timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// DefaultConfig returns the default output safety configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Enabled: true,
+		Sources: []string{"chat", "web_search", "faq"},
+		URLs: URLConfig{
+			Strip: true,
+		},
+		Emotes: EmoteConfig{
+			Prefixes: []string{"soypet2"},
+		},
+		BannedPhrases: []string{"java", "javascript"},
+		BannedAction:  ActionBlock,
+		SelfModeration: SelfModerationConfig{
+			Enabled:        false,
+			TimeoutSeconds: 10,
+			FailOpen:       true,
+		},
+	}
+}
+
+// LoadConfig loads an output safety configuration from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read safety config file: %w", err)
+	}
+
+	config := DefaultConfig()
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse safety config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid safety config: %w", err)
+	}
+	return config, nil
+}
+
+// Validate checks the banned phrase action
+func (c *Config) Validate() error {
+	switch c.BannedAction {
+	case ActionBlock, ActionRedact:
+		return nil
+	default:
+		return fmt.Errorf("banned_action must be %s or %s, got %q", ActionBlock, ActionRedact, c.BannedAction)
+	}
+}
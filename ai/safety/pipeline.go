@@ -0,0 +1,388 @@
+package safety
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrBlocked is returned when a stage drops a message
+var ErrBlocked = errors.New("message blocked")
+
+// Stage names, used in logs and metrics
+const (
+	StageURLs   = "urls"
+	StageBanned = "banned_phrases"
+	StageEmotes = "emotes"
+	StageLength = "length"
+	StageReview = "self_moderation"
+)
+
+// urlPattern matches links with a scheme or www, and bare domains on common TLDs
+var urlPattern = regexp.MustCompile(`(?i)\b(?:(?:https?://|www\.)\S+|[a-z0-9][a-z0-9-]*(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|dev|gg|tv|co|me|ly|app|ai|xyz|info)(?:/\S*)?\b)`)
+
+// spaceBeforePunctuation is left behind when a link before a full stop is removed
+var spaceBeforePunctuation = regexp.MustCompile(`\s+([.,!?:;])`)
+
+// trailingPunctuation is left in place when a link or emote at the end of a sentence is removed
+const trailingPunctuation = ".,!?:;)]}'\""
+
+type stage struct {
+	name string
+	run  func(ctx context.Context, channel, text string) (string, error)
+}
+
+type bannedPhrase struct {
+	phrase  string
+	pattern *regexp.Regexp
+}
+
+// Pipeline runs the configured stages over each outgoing message. It satisfies
+// outbound.Filter.
+type Pipeline struct {
+	config    *Config
+	llm       llms.Model
+	modelName string
+	logger    *logging.Logger
+
+	sources map[string]bool
+	banned  []bannedPhrase
+	stages  []stage
+
+	mu            sync.RWMutex
+	allowed       map[string]bool
+	channelEmotes map[string]map[string]bool
+}
+
+// New creates an output safety pipeline. llm is only needed for self moderation and may
+// be nil otherwise.
+func New(config *Config, llm llms.Model, modelName string, logger *logging.Logger) (*Pipeline, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.SelfModeration.Enabled && llm == nil {
+		return nil, fmt.Errorf("self moderation needs an LLM")
+	}
+
+	p := &Pipeline{
+		config:        config,
+		llm:           llm,
+		modelName:     modelName,
+		logger:        logger,
+		sources:       make(map[string]bool),
+		channelEmotes: make(map[string]map[string]bool),
+	}
+	for _, source := range config.Sources {
+		p.sources[source] = true
+	}
+	for _, phrase := range config.BannedPhrases {
+		pattern, err := phrasePattern(phrase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid banned phrase %q: %w", phrase, err)
+		}
+		p.banned = append(p.banned, bannedPhrase{phrase: phrase, pattern: pattern})
+	}
+
+	allowed := config.Emotes.Allowed
+	if len(allowed) == 0 {
+		persona, err := prompts.Default().Persona("")
+		if err != nil {
+			return nil, err
+		}
+		allowed = persona.Emotes
+	}
+	p.allowed = emoteSet(allowed)
+
+	if config.URLs.Strip {
+		p.stages = append(p.stages, stage{StageURLs, p.stripURLs})
+	}
+	if len(p.banned) > 0 {
+		p.stages = append(p.stages, stage{StageBanned, p.checkBanned})
+	}
+	if len(config.Emotes.Prefixes) > 0 {
+		p.stages = append(p.stages, stage{StageEmotes, p.checkEmotes})
+	}
+	if config.MaxLength > 0 {
+		p.stages = append(p.stages, stage{StageLength, p.truncate})
+	}
+	if config.SelfModeration.Enabled {
+		p.stages = append(p.stages, stage{StageReview, p.review})
+	}
+	return p, nil
+}
+
+// SetChannelEmotes sets the emotes Pedro may use in one channel, replacing the
+// configured ones there
+func (p *Pipeline) SetChannelEmotes(channel string, emotes []string) {
+	if len(emotes) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.channelEmotes[strings.ToLower(channel)] = emoteSet(emotes)
+}
+
+// Check runs every stage over a message and returns the text to send. It returns an
+// error wrapping ErrBlocked when the message must not be sent.
+func (p *Pipeline) Check(ctx context.Context, channel, source, text string) (string, error) {
+	if !p.config.Enabled || (len(p.sources) > 0 && !p.sources[source]) {
+		return text, nil
+	}
+
+	original := text
+	for _, s := range p.stages {
+		out, err := s.run(ctx, channel, text)
+		if err == nil && strings.TrimSpace(out) == "" {
+			err = fmt.Errorf("%w: nothing left to send", ErrBlocked)
+		}
+		if err != nil {
+			metrics.OutputSafetyActionsTotal.WithLabelValues(s.name, "blocked").Inc()
+			metrics.OutputSafetyChecksTotal.WithLabelValues(source, "blocked").Inc()
+			p.logger.Warn("output safety blocked message", "stage", s.name, "channel", channel, "source", source, "reason", err.Error())
+			return "", err
+		}
+		if out != text {
+			metrics.OutputSafetyActionsTotal.WithLabelValues(s.name, "modified").Inc()
+			p.logger.Debug("output safety modified message", "stage", s.name, "channel", channel, "source", source, "before", len(text), "after", len(out))
+		}
+		text = out
+	}
+
+	result := "passed"
+	if text != original {
+		result = "modified"
+	}
+	metrics.OutputSafetyChecksTotal.WithLabelValues(source, result).Inc()
+	return text, nil
+}
+
+// stripURLs removes links unless their domain is allowed
+func (p *Pipeline) stripURLs(_ context.Context, _ string, text string) (string, error) {
+	removed := false
+	text = urlPattern.ReplaceAllStringFunc(text, func(link string) string {
+		trimmed := strings.TrimRight(link, trailingPunctuation)
+		if p.domainAllowed(trimmed) {
+			return link
+		}
+		removed = true
+		return link[len(trimmed):]
+	})
+	if !removed {
+		return text, nil
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	return spaceBeforePunctuation.ReplaceAllString(text, "$1"), nil
+}
+
+func (p *Pipeline) domainAllowed(link string) bool {
+	host := strings.ToLower(link)
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexAny(host, "/:?#"); i >= 0 {
+		host = host[:i]
+	}
+	host = strings.TrimPrefix(host, "www.")
+	for _, domain := range p.config.URLs.AllowedDomains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBanned blocks or redacts banned phrases
+func (p *Pipeline) checkBanned(_ context.Context, _ string, text string) (string, error) {
+	for _, b := range p.banned {
+		if !b.pattern.MatchString(text) {
+			continue
+		}
+		if p.config.BannedAction == ActionBlock {
+			return "", fmt.Errorf("%w: banned phrase %q", ErrBlocked, b.phrase)
+		}
+		// matches share the characters around them, so repeat until every one is gone
+		for b.pattern.MatchString(text) {
+			text = b.pattern.ReplaceAllString(text, "${1}***${2}")
+		}
+	}
+	return text, nil
+}
+
+// checkEmotes removes emotes that are not allowed in the channel
+func (p *Pipeline) checkEmotes(_ context.Context, channel string, text string) (string, error) {
+	p.mu.RLock()
+	allowed, ok := p.channelEmotes[strings.ToLower(channel)]
+	if !ok {
+		allowed = p.allowed
+	}
+	p.mu.RUnlock()
+
+	words := strings.Fields(text)
+	kept := make([]string, 0, len(words))
+	for _, word := range words {
+		emote := strings.TrimRight(word, trailingPunctuation)
+		if p.isEmote(emote) && !allowed[emote] {
+			if rest := word[len(emote):]; rest != "" && len(kept) > 0 {
+				kept[len(kept)-1] += rest
+			}
+			continue
+		}
+		kept = append(kept, word)
+	}
+	if len(kept) == len(words) {
+		return text, nil
+	}
+	return strings.Join(kept, " "), nil
+}
+
+func (p *Pipeline) isEmote(word string) bool {
+	lower := strings.ToLower(word)
+	for _, prefix := range p.config.Emotes.Prefixes {
+		if len(word) > len(prefix) && strings.HasPrefix(lower, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate cuts a message that is too long after the last whole sentence that fits, or
+// at a word boundary with an ellipsis when not even the first sentence fits
+func (p *Pipeline) truncate(_ context.Context, _ string, text string) (string, error) {
+	return Truncate(text, p.config.MaxLength), nil
+}
+
+// Truncate shortens text to at most maxLen characters, keeping whole sentences where it can
+func Truncate(text string, maxLen int) string {
+	runes := []rune(text)
+	if maxLen <= 0 || len(runes) <= maxLen {
+		return text
+	}
+
+	// the last sentence end that fits, unless that would drop most of the message
+	for i := maxLen - 1; i >= maxLen/2; i-- {
+		switch runes[i] {
+		case '.', '!', '?':
+			if i+1 == len(runes) || unicode.IsSpace(runes[i+1]) {
+				return string(runes[:i+1])
+			}
+		}
+	}
+
+	// no sentence fits, so cut at the last space and leave room for the ellipsis
+	cut := maxLen - 1
+	for i := cut; i > 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "…"
+}
+
+// verdict is the LLM's answer to a review
+type verdict struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// review asks the LLM whether the message may be sent
+func (p *Pipeline) review(ctx context.Context, channel, text string) (string, error) {
+	config := p.config.SelfModeration
+	v, err := p.askReviewer(ctx, channel, text, config)
+	if err != nil {
+		metrics.OutputSafetyActionsTotal.WithLabelValues(StageReview, "error").Inc()
+		if config.FailOpen {
+			p.logger.Warn("self moderation failed, sending message", "channel", channel, "error", err.Error())
+			return text, nil
+		}
+		return "", fmt.Errorf("%w: self moderation failed: %v", ErrBlocked, err)
+	}
+	if !v.Allow {
+		return "", fmt.Errorf("%w: vetoed by self moderation: %s", ErrBlocked, v.Reason)
+	}
+	return text, nil
+}
+
+func (p *Pipeline) askReviewer(ctx context.Context, channel, text string, config SelfModerationConfig) (verdict, error) {
+	persona, err := prompts.Default().Persona("")
+	if err != nil {
+		return verdict{}, err
+	}
+	system, err := prompts.Default().Render(prompts.SafetyReview, prompts.SafetyReviewData{
+		Persona: persona,
+		Channel: channel,
+		Rules:   config.Rules,
+	})
+	if err != nil {
+		return verdict{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout())
+	defer cancel()
+	resp, err := p.llm.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, system.Text),
+		llms.TextParts(llms.ChatMessageTypeHuman, text),
+	},
+		llms.WithModel(p.modelName),
+		llms.WithTemperature(0),
+		llms.WithMaxTokens(100),
+		llms.WithJSONMode(),
+	)
+	if err != nil {
+		return verdict{}, fmt.Errorf("review call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return verdict{}, fmt.Errorf("review returned no choices")
+	}
+	return parseVerdict(resp.Choices[0].Content)
+}
+
+// parseVerdict reads the JSON object from a review, ignoring text around it
+func parseVerdict(content string) (verdict, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return verdict{}, fmt.Errorf("review is not JSON: %q", content)
+	}
+	var v verdict
+	if err := json.Unmarshal([]byte(content[start:end+1]), &v); err != nil {
+		return verdict{}, fmt.Errorf("failed to parse review: %w", err)
+	}
+	return v, nil
+}
+
+// phrasePattern matches a phrase as whole words, ignoring case. The groups keep the
+// characters around it so a redaction does not eat them.
+func phrasePattern(phrase string) (*regexp.Regexp, error) {
+	phrase = strings.TrimSpace(phrase)
+	if phrase == "" {
+		return nil, fmt.Errorf("phrase is empty")
+	}
+	quoted := strings.Join(strings.Fields(regexp.QuoteMeta(phrase)), `\s+`)
+	return regexp.Compile(`(?i)(^|[^\p{L}\p{N}_])` + quoted + `($|[^\p{L}\p{N}_])`)
+}
+
+func emoteSet(emotes []string) map[string]bool {
+	set := make(map[string]bool, len(emotes))
+	for _, emote := range emotes {
+		set[emote] = true
+	}
+	return set
+}
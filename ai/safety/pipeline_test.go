@@ -0,0 +1,189 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeLLM answers every review with the same content, or fails
+type fakeLLM struct {
+	content string
+	err     error
+	calls   int
+}
+
+func (f *fakeLLM) GenerateContent(_ context.Context, _ []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: f.content}}}, nil
+}
+
+func (f *fakeLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
+
+func TestPipeline_Check(t *testing.T) {
+	config := DefaultConfig()
+	config.URLs.AllowedDomains = []string{"github.com"}
+	config.Emotes.Allowed = []string{"soypet2Dance", "soypet2Love"}
+	config.MaxLength = 60
+
+	tests := []struct {
+		name    string
+		source  string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "clean message", source: "chat", text: "Go channels are great soypet2Dance", want: "Go channels are great soypet2Dance"},
+		{name: "strips links", source: "chat", text: "Read https://evil.example/x or www.spam.net today.", want: "Read or today."},
+		{name: "strips bare domains before punctuation", source: "chat", text: "Check out example.com.", want: "Check out."},
+		{name: "keeps allowed domains", source: "chat", text: "Code is on https://github.com/soypete/iam_pedro!", want: "Code is on https://github.com/soypete/iam_pedro!"},
+		{name: "keeps file names", source: "chat", text: "Open main.go and go.mod", want: "Open main.go and go.mod"},
+		{name: "blocks banned phrases", source: "chat", text: "Have you tried Java instead?", wantErr: true},
+		{name: "banned phrases are whole words", source: "chat", text: "I love javanese coffee", want: "I love javanese coffee"},
+		{name: "removes unknown emotes", source: "chat", text: "Nice soypet2Fake! soypet2Love", want: "Nice! soypet2Love"},
+		{name: "truncates at a sentence", source: "chat", text: "Goroutines are cheap threads. Channels connect them safely. Use them well.", want: "Goroutines are cheap threads. Channels connect them safely."},
+		{name: "nothing left is blocked", source: "web_search", text: "https://example.com", wantErr: true},
+		{name: "other sources are not checked", source: "command", text: "Join https://discord.gg/soypete to talk Java", want: "Join https://discord.gg/soypete to talk Java"},
+	}
+	pipeline, err := New(config, nil, "", nil)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pipeline.Check(context.Background(), "soypetetech", tt.source, tt.text)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrBlocked)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPipeline_Redact(t *testing.T) {
+	config := DefaultConfig()
+	config.BannedPhrases = []string{"java", "bad word"}
+	config.BannedAction = ActionRedact
+	pipeline, err := New(config, nil, "", nil)
+	require.NoError(t, err)
+
+	got, err := pipeline.Check(context.Background(), "soypetetech", "chat", "Java java, and a BAD  word!")
+	require.NoError(t, err)
+	assert.Equal(t, "*** ***, and a ***!", got)
+}
+
+func TestPipeline_DefaultKeepsLongMessages(t *testing.T) {
+	pipeline, err := New(DefaultConfig(), nil, "", nil)
+	require.NoError(t, err)
+
+	// Length is left to the outbound sender, which splits long messages
+	text := strings.Repeat("Goroutines are cheap threads. ", 30)
+	got, err := pipeline.Check(context.Background(), "soypetetech", "chat", text)
+	require.NoError(t, err)
+	assert.Equal(t, text, got)
+}
+
+func TestPipeline_ChannelEmotes(t *testing.T) {
+	pipeline, err := New(DefaultConfig(), nil, "", nil)
+	require.NoError(t, err)
+	pipeline.SetChannelEmotes("ForgeUtah", []string{"soypet2Hype"})
+
+	got, err := pipeline.Check(context.Background(), "forgeutah", "chat", "Welcome soypet2Hype soypet2Dance")
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome soypet2Hype", got)
+
+	// Other channels use the persona's emotes
+	got, err = pipeline.Check(context.Background(), "soypetetech", "chat", "Welcome soypet2Hype soypet2Dance")
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome soypet2Dance", got)
+}
+
+func TestPipeline_SelfModeration(t *testing.T) {
+	tests := []struct {
+		name     string
+		llm      *fakeLLM
+		failOpen bool
+		wantErr  string
+	}{
+		{name: "allowed", llm: &fakeLLM{content: `{"allow": true}`}},
+		{name: "vetoed", llm: &fakeLLM{content: "```json\n{\"allow\": false, \"reason\": \"personal info\"}\n```"}, wantErr: "vetoed by self moderation: personal info"},
+		{name: "review fails open", llm: &fakeLLM{err: errors.New("backend down")}, failOpen: true},
+		{name: "review fails closed", llm: &fakeLLM{err: errors.New("backend down")}, wantErr: "self moderation failed"},
+		{name: "review is not JSON", llm: &fakeLLM{content: "sure, looks fine"}, wantErr: "review is not JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.SelfModeration.Enabled = true
+			config.SelfModeration.FailOpen = tt.failOpen
+			pipeline, err := New(config, tt.llm, "model", nil)
+			require.NoError(t, err)
+
+			got, err := pipeline.Check(context.Background(), "soypetetech", "chat", "Hello chat!")
+			assert.Equal(t, 1, tt.llm.calls)
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, ErrBlocked)
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Hello chat!", got)
+		})
+	}
+
+	config := DefaultConfig()
+	config.SelfModeration.Enabled = true
+	_, err := New(config, nil, "", nil)
+	assert.ErrorContains(t, err, "self moderation needs an LLM")
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("word ", 30)
+	tests := []struct {
+		name   string
+		text   string
+		maxLen int
+		want   string
+	}{
+		{name: "fits", text: "Short one.", maxLen: 20, want: "Short one."},
+		{name: "last whole sentence", text: "One two three. Four five six. Seven eight.", maxLen: 35, want: "One two three. Four five six."},
+		{name: "decimal is not a sentence end", text: "Go 1.22 is out now and it is good", maxLen: 15, want: "Go 1.22 is out…"},
+		{name: "tiny first sentence cuts words instead", text: "Hi! " + long, maxLen: 40, want: "Hi! word word word word word word word…"},
+		{name: "no spaces", text: strings.Repeat("a", 30), maxLen: 10, want: strings.Repeat("a", 9) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.text, tt.maxLen)
+			assert.Equal(t, tt.want, got)
+			assert.LessOrEqual(t, utf8.RuneCountInString(got), tt.maxLen)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "safety.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("max_length: 300\nbanned_phrases: [rust]\n"), 0o600))
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 300, config.MaxLength)
+	assert.Equal(t, []string{"rust"}, config.BannedPhrases)
+	assert.True(t, config.URLs.Strip, "unset fields keep their defaults")
+
+	require.NoError(t, os.WriteFile(path, []byte("banned_action: shout\n"), 0o600))
+	_, err = LoadConfig(path)
+	assert.ErrorContains(t, err, "banned_action must be block or redact")
+}
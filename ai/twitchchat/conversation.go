@@ -0,0 +1,167 @@
+package twitchchat
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Conversation scopes
+const (
+	// ScopeUser gives every viewer their own conversation with Pedro
+	ScopeUser = "user"
+	// ScopeChannel shares one conversation between everyone in the channel
+	ScopeChannel = "channel"
+)
+
+// ConversationConfig controls how much of a conversation Pedro remembers
+type ConversationConfig struct {
+	// MaxTurns is the number of messages kept per conversation
+	MaxTurns int `yaml:"max_turns,omitempty"`
+	// IdleTimeout is how long a conversation is kept after its last message
+	IdleTimeout time.Duration `yaml:"idle_timeout,omitempty"`
+	// Scope is ScopeUser or ScopeChannel
+	Scope string `yaml:"scope,omitempty"`
+}
+
+// DefaultConversationConfig keeps the last 10 messages per viewer for 15 minutes
+func DefaultConversationConfig() ConversationConfig {
+	return ConversationConfig{
+		MaxTurns:    10,
+		IdleTimeout: 15 * time.Minute,
+		Scope:       ScopeUser,
+	}
+}
+
+// withDefaults fills unset fields from defaults
+func (c ConversationConfig) withDefaults(defaults ConversationConfig) ConversationConfig {
+	if c.MaxTurns <= 0 {
+		c.MaxTurns = defaults.MaxTurns
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = defaults.IdleTimeout
+	}
+	if c.Scope != ScopeUser && c.Scope != ScopeChannel {
+		c.Scope = defaults.Scope
+	}
+	return c
+}
+
+type conversationKey struct {
+	channel string
+	user    string
+}
+
+type conversation struct {
+	turns      []llms.MessageContent
+	lastActive time.Time
+}
+
+// ConversationStore holds conversation history keyed by channel and user.
+// It is safe for concurrent use.
+type ConversationStore struct {
+	mu            sync.Mutex
+	defaults      ConversationConfig
+	channels      map[string]ConversationConfig
+	conversations map[conversationKey]*conversation
+	now           func() time.Time
+}
+
+// NewConversationStore creates a store that uses defaults for channels without their own config
+func NewConversationStore(defaults ConversationConfig) *ConversationStore {
+	return &ConversationStore{
+		defaults:      defaults.withDefaults(DefaultConversationConfig()),
+		channels:      make(map[string]ConversationConfig),
+		conversations: make(map[conversationKey]*conversation),
+		now:           time.Now,
+	}
+}
+
+// SetChannelConfig sets the conversation config for one channel. Unset fields use the defaults.
+func (s *ConversationStore) SetChannelConfig(channel string, config ConversationConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[strings.ToLower(channel)] = config.withDefaults(s.defaults)
+}
+
+// History returns a copy of the conversation for a user in a channel
+func (s *ConversationStore) History(channel, user string) []llms.MessageContent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, config := s.keyLocked(channel, user)
+	conv, ok := s.conversations[key]
+	if !ok {
+		return nil
+	}
+	if s.now().Sub(conv.lastActive) > config.IdleTimeout {
+		delete(s.conversations, key)
+		return nil
+	}
+
+	history := make([]llms.MessageContent, len(conv.turns))
+	copy(history, conv.turns)
+	return history
+}
+
+// Append adds a message to the conversation for a user in a channel, dropping the
+// oldest messages past the channel's MaxTurns. Idle conversations are expired on the way.
+func (s *ConversationStore) Append(channel, user string, chatType llms.ChatMessageType, text string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.expireLocked(now)
+
+	key, config := s.keyLocked(channel, user)
+	conv, ok := s.conversations[key]
+	if !ok {
+		conv = &conversation{}
+		s.conversations[key] = conv
+	}
+
+	conv.turns = append(conv.turns, llms.TextParts(chatType, text))
+	if len(conv.turns) > config.MaxTurns {
+		conv.turns = append([]llms.MessageContent(nil), conv.turns[len(conv.turns)-config.MaxTurns:]...)
+	}
+	conv.lastActive = now
+	return len(conv.turns)
+}
+
+// Len returns the number of active conversations
+func (s *ConversationStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked(s.now())
+	return len(s.conversations)
+}
+
+// keyLocked returns the conversation key and config for a message
+func (s *ConversationStore) keyLocked(channel, user string) (conversationKey, ConversationConfig) {
+	channel = strings.ToLower(channel)
+	config, ok := s.channels[channel]
+	if !ok {
+		config = s.defaults
+	}
+
+	key := conversationKey{channel: channel, user: strings.ToLower(user)}
+	if config.Scope == ScopeChannel {
+		key.user = ""
+	}
+	return key, config
+}
+
+// expireLocked removes conversations idle longer than their channel allows
+func (s *ConversationStore) expireLocked(now time.Time) {
+	for key, conv := range s.conversations {
+		config, ok := s.channels[key.channel]
+		if !ok {
+			config = s.defaults
+		}
+		if now.Sub(conv.lastActive) > config.IdleTimeout {
+			delete(s.conversations, key)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package twitchchat
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestConversationStore_KeyedByChannelAndUser(t *testing.T) {
+	store := NewConversationStore(DefaultConversationConfig())
+	store.SetChannelConfig("ForgeUtah", ConversationConfig{Scope: ScopeChannel})
+
+	store.Append("soypetetech", "alice", llms.ChatMessageTypeHuman, "pedro what is a slice?")
+	store.Append("soypetetech", "bob", llms.ChatMessageTypeHuman, "pedro tell me a joke")
+	store.Append("forgeutah", "alice", llms.ChatMessageTypeHuman, "when is the meetup?")
+	store.Append("forgeutah", "bob", llms.ChatMessageTypeHuman, "where is the meetup?")
+
+	tests := []struct {
+		name    string
+		channel string
+		user    string
+		wantLen int
+	}{
+		{name: "per user", channel: "soypetetech", user: "alice", wantLen: 1},
+		{name: "user name is case insensitive", channel: "soypetetech", user: "Bob", wantLen: 1},
+		{name: "no conversation yet", channel: "soypetetech", user: "carol", wantLen: 0},
+		{name: "channel scope is shared", channel: "forgeutah", user: "carol", wantLen: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := store.History(tt.channel, tt.user); len(got) != tt.wantLen {
+				t.Errorf("History(%s, %s) len = %d, want %d", tt.channel, tt.user, len(got), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestConversationStore_MaxTurnsAndExpiry(t *testing.T) {
+	now := time.Now()
+	store := NewConversationStore(ConversationConfig{MaxTurns: 3, IdleTimeout: time.Minute})
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		store.Append("soypetetech", "alice", llms.ChatMessageTypeHuman, fmt.Sprintf("message %d", i))
+	}
+	history := store.History("soypetetech", "alice")
+	if len(history) != 3 {
+		t.Fatalf("History() len = %d, want 3", len(history))
+	}
+	if got := history[0].Parts[0].(llms.TextContent).Text; got != "message 2" {
+		t.Errorf("oldest kept message = %q, want %q", got, "message 2")
+	}
+
+	now = now.Add(30 * time.Second)
+	store.Append("soypetetech", "bob", llms.ChatMessageTypeHuman, "hi pedro")
+
+	now = now.Add(45 * time.Second)
+	if got := store.History("soypetetech", "alice"); got != nil {
+		t.Errorf("idle conversation should have expired, got %d messages", len(got))
+	}
+	if got := store.Len(); got != 1 {
+		t.Errorf("Len() = %d, want 1", got)
+	}
+}
+
+func TestConversationStore_Concurrent(t *testing.T) {
+	store := NewConversationStore(DefaultConversationConfig())
+
+	var wg sync.WaitGroup
+	for u := 0; u < 10; u++ {
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				store.Append("soypetetech", user, llms.ChatMessageTypeHuman, "hello")
+				_ = store.History("soypetetech", user)
+			}
+		}(fmt.Sprintf("user%d", u))
+	}
+	wg.Wait()
+
+	if got := store.Len(); got != 10 {
+		t.Errorf("Len() = %d, want 10", got)
+	}
+	if got := store.History("soypetetech", "user3"); len(got) != 10 {
+		t.Errorf("History() len = %d, want 10", len(got))
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/Soypete/twitch-llm-bot/ai"
 	"github.com/Soypete/twitch-llm-bot/ai/agent"
@@ -14,190 +13,138 @@ import (
 	"github.com/tmc/langchaingo/llms"
 )
 
-func (c *Client) manageChatHistory(ctx context.Context, injection []string, chatType llms.ChatMessageType) {
-	c.logger.Debug("managing chat history", "type", chatType, "message", strings.Join(injection, " "))
+// manageChatHistory adds a message to the conversation between Pedro and a user in a channel
+func (c *Client) manageChatHistory(ctx context.Context, channel, user string, injection []string, chatType llms.ChatMessageType) {
+	c.logger.Debug("managing chat history", "type", chatType, "channel", channel, "user", user, "message", strings.Join(injection, " "))
 
-	if len(c.chatHistory) >= 10 {
-		c.logger.Debug("pruning chat history", "old_size", len(c.chatHistory))
-		c.chatHistory = c.chatHistory[1:]
-	}
-	c.chatHistory = append(c.chatHistory, llms.TextParts(chatType, strings.Join(injection, " ")))
-	c.logger.Debug("updated chat history", "new_size", len(c.chatHistory))
+	size := c.conversationStore().Append(channel, user, chatType, strings.Join(injection, " "))
+	c.logger.Debug("updated chat history", "channel", channel, "user", user, "new_size", size)
 }
 
-func (c *Client) callLLM(ctx context.Context, injection []string, messageID uuid.UUID) (*llms.ContentResponse, error) {
-	c.logger.Debug("calling LLM", "message", strings.Join(injection, " "), "messageID", messageID)
-
-	now := time.Now().Format(time.DateOnly)
-	c.manageChatHistory(ctx, injection, llms.ChatMessageTypeHuman)
+// callLLM adds the message to the conversation and runs the tool loop until Pedro has an
+// answer. recalled is context for this request only, put before the message in the prompt
+// but not saved in the conversation. It also returns the version of the system prompt it used.
+func (c *Client) callLLM(ctx context.Context, channel, user string, injection []string, recalled string, messageID uuid.UUID) (*agent.LoopResult, string, error) {
+	c.logger.Debug("calling LLM", "channel", channel, "user", user, "message", strings.Join(injection, " "), "messageID", messageID)
 
-	// Build system prompt with optional stream context addendum
-	systemPrompt := fmt.Sprintf(ai.PedroPrompt, now)
-	if c.streamConfig != "" && c.streamAddendum != "" {
-		systemPrompt += c.streamAddendum
-		c.logger.Debug("using stream context enhanced prompt", "streamConfig", c.streamConfig)
+	systemPrompt, err := c.systemPrompt(channel)
+	if err != nil {
+		c.logger.Error("failed to render system prompt", "channel", channel, "error", err.Error())
+		return nil, "", err
 	}
+	c.manageChatHistory(ctx, channel, user, injection, llms.ChatMessageTypeHuman)
 
-	messageHistory := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt)}
-	messageHistory = append(messageHistory, c.chatHistory...)
-
-	// Get web search tool definition from shared agent package
-	toolDefinition := agent.GetWebSearchToolDefinition()
+	messageHistory := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt.Text)}
+	history := c.conversationStore().History(channel, user)
+	if recalled != "" && len(history) > 0 {
+		// History is a copy, so the stored message stays without the recalled context
+		history[len(history)-1] = llms.TextParts(llms.ChatMessageTypeHuman, recalled+"\n\n"+strings.Join(injection, " "))
+	}
+	messageHistory = append(messageHistory, history...)
 
 	c.logger.Debug("generating content", "historyLength", len(messageHistory), "model", c.modelName)
-	resp, err := c.llm.GenerateContent(ctx, messageHistory,
+	loop := agent.NewLoop(c.llm, c.toolRegistry(), c.maxToolSteps, c.logger)
+	result, err := loop.Run(agent.WithChannel(ctx, channel), messageHistory,
 		llms.WithModel(c.modelName),
 		llms.WithCandidateCount(1),
 		llms.WithMaxLength(500),
 		llms.WithTemperature(0.7),
 		llms.WithPresencePenalty(1.0),
-		llms.WithStopWords([]string{"LUL, PogChamp, Kappa, KappaPride, KappaRoss, KappaWealth"}),
-		llms.WithTools([]llms.Tool{toolDefinition}))
+		llms.WithStopWords([]string{"LUL, PogChamp, Kappa, KappaPride, KappaRoss, KappaWealth"}))
 	if err != nil {
 		c.logger.Error("failed to get LLM response", "error", err.Error())
-		return nil, fmt.Errorf("failed to get llm response: %w", err)
+		return nil, "", err
 	}
 
-	c.logger.Debug("successfully generated response", "messageID", messageID)
-	return resp, nil
+	for _, step := range result.Steps {
+		c.logger.Debug("tool call", "tool", step.Name, "error", step.Error, "duration", step.Duration, "messageID", messageID)
+	}
+	c.logger.Debug("successfully generated response", "messageID", messageID, "toolCalls", len(result.Steps), "budgetExhausted", result.BudgetExhausted, "sources", len(result.Sources), "promptVersion", systemPrompt.Version)
+	return result, systemPrompt.Version, nil
 }
 
 // SingleMessageResponse is a response from the LLM model to a single message, but to work it needs to have context of chat history
 func (c *Client) SingleMessageResponse(ctx context.Context, msg types.TwitchMessage, messageID uuid.UUID) (types.TwitchMessage, error) {
 	c.logger.Debug("processing single message response", "messageID", messageID)
 
-	// Build user message with palace context if available
+	// Palace context and memories from earlier streams are only added to this prompt, so
+	// they do not build up in the conversation history
 	userMessage := fmt.Sprintf("%s: %s", msg.Username, msg.Text)
+	var recalled []string
+	if msg.MemoryContext != "" {
+		recalled = append(recalled, msg.MemoryContext)
+		c.logger.Debug("injected chat memories into prompt", "contextLength", len(msg.MemoryContext))
+	}
 	if msg.PalaceContext != "" {
-		userMessage = fmt.Sprintf("Relevant conversation context from this stream:\n%s\n\n%s", msg.PalaceContext, userMessage)
+		recalled = append(recalled, "Relevant conversation context from this stream:\n"+msg.PalaceContext)
 		c.logger.Debug("injected palace context into prompt", "contextLength", len(msg.PalaceContext))
 	}
 
-	resp, err := c.callLLM(ctx, []string{userMessage}, messageID)
+	result, promptVersion, err := c.callLLM(ctx, msg.Channel, msg.Username, []string{userMessage}, strings.Join(recalled, "\n\n"), messageID)
 	if err != nil {
 		c.logger.Error("failed to generate response", "error", err.Error(), "messageID", messageID)
 		metrics.FailedLLMGenCount.Add(1)
 		return types.TwitchMessage{}, err
 	}
 
-	// Check if the LLM wants to call a tool
-	if len(resp.Choices) > 0 && len(resp.Choices[0].ToolCalls) > 0 {
-		toolCall := resp.Choices[0].ToolCalls[0]
-		c.logger.Debug("tool call requested", "function", toolCall.FunctionCall.Name, "messageID", messageID)
-
-		if toolCall.FunctionCall.Name == "web_search" {
-			// Parse the tool call using shared agent package
-			query, err := agent.ParseWebSearchToolCall(toolCall)
-			if err != nil {
-				c.logger.Error("failed to parse tool call arguments", "error", err.Error())
-				return types.TwitchMessage{
-					Text: "Sorry, I had trouble understanding the search request soypet2ConfusedPedro",
-					UUID: messageID,
-				}, nil
-			}
-
-			c.logger.Debug("web search requested via tool call", "query", query, "messageID", messageID)
-			msg.UUID = messageID
-			return types.TwitchMessage{
-				Text: "one second and I will look that up for you soypet2Thinking",
-				UUID: messageID,
-				WebSearch: &types.WebSearchRequest{
-					Query:       query,
-					OriginalMsg: msg,
-					ChatHistory: c.chatHistory,
-				},
-			}, nil
-		}
-	}
-
-	// No tool call, process the text response
-	prompt := ai.CleanResponse(resp.Choices[0].Content)
+	prompt := ai.CleanResponse(result.Content)
 	if prompt == "" {
 		c.logger.Warn("empty response from LLM", "messageID", messageID)
 		metrics.EmptyLLMResponseCount.Add(1)
 		// We are trying to tag the user to get them to try again with a better prompt.
 		return types.TwitchMessage{
-			Text: fmt.Sprintf("sorry, I cannot respond to @%s. Please try again", msg.Username),
-			UUID: messageID,
+			Text:          fmt.Sprintf("sorry, I cannot respond to @%s. Please try again", msg.Username),
+			UUID:          messageID,
+			PromptVersion: promptVersion,
 		}, nil
 	}
 
 	// Add Pedro's response to chat history
-	c.manageChatHistory(ctx, []string{prompt}, llms.ChatMessageTypeAI)
+	c.manageChatHistory(ctx, msg.Channel, msg.Username, []string{prompt}, llms.ChatMessageTypeAI)
 
 	c.logger.Debug("successful response generation", "messageID", messageID, "messageLength", len(prompt))
 	metrics.SuccessfulLLMGenCount.Add(1)
 	return types.TwitchMessage{
-		Text: prompt,
-		UUID: messageID,
+		Text:          prompt,
+		UUID:          messageID,
+		PromptVersion: promptVersion,
+		Sources:       result.Sources,
 	}, nil
 }
 
-// End20Questions is a response from the LLM model to end the game of 20 questions
-func (c *Client) End20Questions() {
-	c.logger.Debug("ending 20 questions game")
-}
-
-// Play20Questions is a response from the LLM model to a game of 20 questions
-func (c *Client) Play20Questions(ctx context.Context, msg types.TwitchMessage, messageID uuid.UUID) (string, error) {
-	c.logger.Debug("play 20 questions called but not implemented for twitch", "messageID", messageID)
-	return "", nil
-}
-
-// ExecuteWebSearch performs a web search and generates a response based on the results
-func (c *Client) ExecuteWebSearch(ctx context.Context, request *types.WebSearchRequest, responseChan chan<- types.TwitchMessage) {
-	c.logger.Debug("executing web search", "query", request.Query, "originalMessageID", request.OriginalMsg.UUID)
+// PromptResponse answers a one-off prompt, such as an LLM backed chat command.
+// It does not use or update the chat history and does not offer any tools.
+func (c *Client) PromptResponse(ctx context.Context, channel string, prompt string) (string, error) {
+	c.logger.Debug("generating prompt response", "channel", channel)
 
-	// Perform the search
-	searchResult, err := c.ddgClient.Search(request.Query)
+	systemPrompt, err := c.systemPrompt(channel)
 	if err != nil {
-		c.logger.Error("web search failed", "error", err.Error(), "query", request.Query, "messageID", request.OriginalMsg.UUID)
-		metrics.WebSearchFailCount.Add(1)
-		responseChan <- types.TwitchMessage{
-			Text: "Sorry, I couldn't search for that information right now soypet2ConfusedPedro",
-			UUID: request.OriginalMsg.UUID,
-		}
-		return
+		return "", fmt.Errorf("failed to render system prompt: %w", err)
 	}
 
-	metrics.WebSearchSuccessCount.Add(1)
-	c.logger.Debug("web search successful", "query", request.Query, "messageID", request.OriginalMsg.UUID)
-
-	now := time.Now().Format(time.DateOnly)
-
-	systemPrompt := fmt.Sprintf(ai.PedroPrompt, now)
-	messageHistory := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt)}
-	// messageHistory = append(messageHistory, request.ChatHistory...)
-	messageHistory = append(messageHistory, llms.TextParts(llms.ChatMessageTypeSystem,
-		fmt.Sprintf("Pedro, we have called the duckduckgo search api and the following is the json formatted response: %s. Please provide a helpful summary to the user's question. If you still cannot answer, apologize and ask them to try again.", searchResult)))
-	messageHistory = append(messageHistory, llms.TextParts(llms.ChatMessageTypeHuman, request.Query))
-
-	resp, err := c.llm.GenerateContent(ctx, messageHistory,
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt.Text),
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	}
+	resp, err := c.llm.GenerateContent(ctx, messages,
 		llms.WithModel(c.modelName),
 		llms.WithCandidateCount(1),
 		llms.WithMaxLength(500),
-		llms.WithTemperature(0.7),
-		llms.WithPresencePenalty(1.0))
+		llms.WithTemperature(0.7))
 	if err != nil {
-		c.logger.Error("failed to generate response with search results", "error", err.Error())
-		responseChan <- types.TwitchMessage{
-			Text: "Sorry, I found the information but couldn't process it soypet2ConfusedPedro",
-			UUID: request.OriginalMsg.UUID,
-		}
-		return
+		metrics.FailedLLMGenCount.Add(1)
+		return "", fmt.Errorf("failed to get llm response: %w", err)
 	}
-
-	cleanedResponse := ai.CleanResponse(resp.Choices[0].Content)
-	if cleanedResponse == "" {
-		c.logger.Error("llm returned empty response", "responselen", len(responseChan))
+	if len(resp.Choices) == 0 {
+		metrics.EmptyLLMResponseCount.Add(1)
+		return "", fmt.Errorf("llm returned no choices")
 	}
 
-	// Update chat history with the search-informed response
-	c.manageChatHistory(ctx, []string{cleanedResponse}, llms.ChatMessageTypeAI)
-
-	c.logger.Debug("sending web search response", "messageID", request.OriginalMsg.UUID, "responseLength", len(cleanedResponse))
-	responseChan <- types.TwitchMessage{
-		Text: cleanedResponse,
-		UUID: request.OriginalMsg.UUID,
+	text := ai.CleanResponse(resp.Choices[0].Content)
+	if text == "" {
+		metrics.EmptyLLMResponseCount.Add(1)
+		return "", fmt.Errorf("llm returned an empty response")
 	}
+	metrics.SuccessfulLLMGenCount.Add(1)
+	return text, nil
 }
@@ -2,11 +2,16 @@ package twitchchat
 
 import (
 	"context"
-	"fmt"
+	"net/http"
 	"testing"
 
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/internal/llmtest"
 	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/types"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/tmc/langchaingo/llms"
 )
 
@@ -33,13 +38,13 @@ func TestClient_callLLM(t *testing.T) {
 	}
 	tests := []struct {
 		name    string
-		c       Client
+		c       *Client
 		args    args
 		wantErr bool
 	}{
 		{
 			name: "make prompt",
-			c: Client{
+			c: &Client{
 				llm:    &mockLLM{},
 				logger: logging.Default(),
 			},
@@ -52,7 +57,7 @@ func TestClient_callLLM(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.c.callLLM(tt.args.ctx, tt.args.injection, uuid.New())
+			got, promptVersion, err := tt.c.callLLM(tt.args.ctx, "", "", tt.args.injection, "", uuid.New())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Client.callLLM() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -61,31 +66,29 @@ func TestClient_callLLM(t *testing.T) {
 				t.Errorf("Client.callLLM() returned nil response")
 				return
 			}
-			if len(got.Choices) == 0 {
-				t.Errorf("Client.callLLM() returned response with no choices")
-				return
+			if got.Content != "Hello World" {
+				t.Errorf("Client.callLLM() content = %v, want %v", got.Content, "Hello World")
 			}
-			if got.Choices[0].Content != "Hello World" {
-				t.Errorf("Client.callLLM() content = %v, want %v", got.Choices[0].Content, "Hello World")
+			if promptVersion != "pedro@1 persona=pedro@1" {
+				t.Errorf("Client.callLLM() promptVersion = %v, want %v", promptVersion, "pedro@1 persona=pedro@1")
 			}
 		})
 	}
 }
 
 func TestClient_manageChatHistory(t *testing.T) {
-	var ch []llms.MessageContent
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeHuman, "Hello World"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeHuman, "my name is Scott"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeAI, "I am a bot"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeHuman, "This is chat history"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeHuman, "I am writing a test"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeHuman, "Please work"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeAI, "I am a bot"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeHuman, "This is chat history"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeHuman, "I am writing a test"))
-	ch = append(ch, llms.TextParts(llms.ChatMessageTypeHuman, "Please work"))
+	texts := []string{
+		"Hello World", "my name is Scott", "I am a bot", "This is chat history", "I am writing a test",
+		"Please work", "I am a bot", "This is chat history", "I am writing a test", "Please work",
+	}
+	seed := func(n int) *ConversationStore {
+		store := NewConversationStore(DefaultConversationConfig())
+		for _, text := range texts[:n] {
+			store.Append("soypetetech", "scott", llms.ChatMessageTypeHuman, text)
+		}
+		return store
+	}
 
-	chat3 := ch[:3]
 	type args struct {
 		ctx       context.Context
 		injection []string
@@ -113,9 +116,9 @@ func TestClient_manageChatHistory(t *testing.T) {
 		{
 			name: "some chat",
 			client: &Client{
-				llm:         &mockLLM{},
-				chatHistory: chat3,
-				logger:      logging.Default(),
+				llm:           &mockLLM{},
+				conversations: seed(3),
+				logger:        logging.Default(),
 			},
 			args: args{
 				ctx:       context.Background(),
@@ -127,9 +130,9 @@ func TestClient_manageChatHistory(t *testing.T) {
 		{
 			name: "full chat",
 			client: &Client{
-				llm:         &mockLLM{},
-				chatHistory: ch,
-				logger:      logging.Default(),
+				llm:           &mockLLM{},
+				conversations: seed(10),
+				logger:        logging.Default(),
 			},
 			args: args{
 				ctx:       context.Background(),
@@ -141,11 +144,93 @@ func TestClient_manageChatHistory(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.client.manageChatHistory(tt.args.ctx, tt.args.injection, tt.args.chatType)
-			if len(tt.client.chatHistory) != tt.wantLen {
-				fmt.Println(tt.client.chatHistory)
-				t.Errorf("Client.manageChatHistory() = %v, want %v", len(tt.client.chatHistory), tt.wantLen)
+			tt.client.manageChatHistory(tt.args.ctx, "soypetetech", "scott", tt.args.injection, tt.args.chatType)
+			history := tt.client.conversationStore().History("soypetetech", "scott")
+			if len(history) != tt.wantLen {
+				t.Errorf("Client.manageChatHistory() = %v, want %v, history %v", len(history), tt.wantLen, history)
 			}
 		})
 	}
 }
+
+func TestClient_SingleMessageResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		failures     int
+		wantText     string
+		wantErr      bool
+		wantRequests int
+		wantSources  []types.Source
+	}{
+		{name: "plain answer", text: "hi pedro", wantText: "hello @scott", wantRequests: 1},
+		{name: "tool call then answer", text: "pedro what is the latest Go release?", wantText: "Go 1.26 is out", wantRequests: 2,
+			wantSources: []types.Source{{Title: "Go 1.26 is released", URL: "https://go.dev/blog/go1.26", Tool: "web_search"}}},
+		{name: "backend error", text: "hi pedro", failures: 1, wantErr: true, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := llmtest.NewServer(t)
+			for i := 0; i < tt.failures; i++ {
+				server.Reply(llmtest.Response{Status: http.StatusInternalServerError})
+			}
+			server.OnMessage("latest go release", llmtest.Response{ToolCalls: []llmtest.ToolCall{{Name: "web_search", Arguments: `{"query":"latest Go release"}`}}})
+			server.OnToolResult(func(result string) llmtest.Response { return llmtest.Response{Content: result + " is out"} })
+			server.Default(llmtest.Response{Content: "hello @scott"})
+
+			client, err := SetupWithLLM(server.Client(t, "test-model"), "test-model", "", nil)
+			require.NoError(t, err)
+			// Replace the DuckDuckGo tool so the test never touches the network
+			require.NoError(t, client.RegisterTool(agent.Tool{
+				Definition: agent.GetWebSearchToolDefinition(),
+				Impl: agent.NewFuncTool("web_search", "test search", func(ctx context.Context, _ string) (string, error) {
+					agent.RecordSources(ctx, types.Source{Title: "Go 1.26 is released", URL: "https://go.dev/blog/go1.26", Tool: "web_search"})
+					return "Go 1.26", nil
+				}),
+			}))
+
+			msg := types.TwitchMessage{Channel: "soypetetech", Username: "scott", Text: tt.text}
+			resp, err := client.SingleMessageResponse(context.Background(), msg, uuid.New())
+			requests := server.Requests()
+			require.Len(t, requests, tt.wantRequests)
+			assert.True(t, requests[0].HasTool("web_search"))
+			assert.Contains(t, requests[0].LastUserMessage(), "scott: "+tt.text)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantText, resp.Text)
+			assert.NotEmpty(t, resp.PromptVersion)
+			assert.Equal(t, tt.wantSources, resp.Sources)
+		})
+	}
+}
+
+func TestClient_SingleMessageResponseRecalledContext(t *testing.T) {
+	server := llmtest.NewServer(t)
+	server.Default(llmtest.Response{Content: "channels pass values"})
+	client, err := SetupWithLLM(server.Client(t, "test-model"), "test-model", "", nil)
+	require.NoError(t, err)
+
+	msg := types.TwitchMessage{
+		Channel:       "soypetetech",
+		Username:      "scott",
+		Text:          "pedro how do channels work?",
+		PalaceContext: "alice: we talked about goroutines",
+		MemoryContext: "Similar messages from earlier streams, with your answers:",
+	}
+	_, err = client.SingleMessageResponse(context.Background(), msg, uuid.New())
+	require.NoError(t, err)
+
+	// The prompt has the recalled context, the saved conversation does not
+	requests := server.Requests()
+	require.Len(t, requests, 1)
+	prompt := requests[0].LastUserMessage()
+	assert.Contains(t, prompt, msg.PalaceContext)
+	assert.Contains(t, prompt, msg.MemoryContext)
+
+	history := client.conversationStore().History("soypetetech", "scott")
+	require.Len(t, history, 2)
+	assert.Equal(t, llms.TextParts(llms.ChatMessageTypeHuman, "scott: pedro how do channels work?"), history[0])
+}
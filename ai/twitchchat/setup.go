@@ -3,23 +3,49 @@ package twitchchat
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Soypete/twitch-llm-bot/ai"
-	"github.com/Soypete/twitch-llm-bot/duckduckgo"
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/ai/orchestrator"
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
+	"github.com/Soypete/twitch-llm-bot/ai/provider"
+	"github.com/Soypete/twitch-llm-bot/ai/websearch"
 	"github.com/Soypete/twitch-llm-bot/logging"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 // Client is a client for interacting with the OpenAI LLM and the database.
 type Client struct {
-	llm            llms.Model
-	chatHistory    []llms.MessageContent
-	modelName      string
-	logger         *logging.Logger
-	ddgClient      *duckduckgo.Client
-	streamConfig   string
-	streamAddendum string
+	llm           llms.Model
+	modelName     string
+	logger        *logging.Logger
+	streamConfig  string
+	streamContext *ai.MeetupConfig
+	persona       string
+
+	// per-channel stream context and persona, keyed by channel name
+	channelMu       sync.RWMutex
+	channelConfigs  map[string]string
+	channelContexts map[string]*ai.MeetupConfig
+	channelPersonas map[string]channelPersona
+
+	// conversation history, keyed by channel and user
+	conversationsOnce sync.Once
+	conversations     *ConversationStore
+
+	// tools Pedro can call while answering, and how many LLM calls one answer may take
+	toolsOnce    sync.Once
+	tools        *agent.Registry
+	maxToolSteps int
+
+	// provider behind the web_search tool, how its searches are retried and cached
+	searchMu       sync.Mutex
+	searchProvider websearch.SearchProvider
+	searchRetry    websearch.RetryConfig
+	searchCache    *websearch.Cache
 }
 
 // Setup creates a new twitch chat bot.
@@ -34,33 +60,31 @@ func SetupWithStreamConfig(llmPath string, modelName string, streamConfigPath st
 	}
 
 	logger.Info("setting up twitch chat LLM client", "path", llmPath, "streamConfig", streamConfigPath)
-
-	// Ensure the path ends with /v1 for OpenAI-compatible API
-	if llmPath != "" && llmPath[len(llmPath)-3:] != "/v1" {
-		llmPath = llmPath + "/v1"
-		logger.Info("appended /v1 to LLM path", "fullPath", llmPath)
-	}
-
-	opts := []openai.Option{
-		openai.WithBaseURL(llmPath),
-		openai.WithModel(modelName),
-	}
-	llm, err := openai.New(opts...)
+	llm, err := provider.New(provider.SingleBackend(llmPath), logger)
 	if err != nil {
-		logger.Error("failed to create OpenAI LLM", "error", err.Error())
-		return nil, fmt.Errorf("failed to create OpenAI LLM: %w", err)
+		logger.Error("failed to create LLM provider", "error", err.Error())
+		return nil, fmt.Errorf("failed to create LLM provider: %w", err)
 	}
+	return SetupWithLLM(llm, modelName, streamConfigPath, logger)
+}
 
-	// Initialize DuckDuckGo client
-	ddgClient := duckduckgo.NewClient()
+// SetupWithLLM creates a new twitch chat bot that uses a shared LLM, such as a
+// *provider.Provider with fallback backends.
+func SetupWithLLM(llm llms.Model, modelName string, streamConfigPath string, logger *logging.Logger) (*Client, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
 
 	client := &Client{
 		llm:          llm,
 		modelName:    modelName,
 		logger:       logger,
-		ddgClient:    ddgClient,
 		streamConfig: streamConfigPath,
 	}
+	// Web search uses DuckDuckGo until SetSearchProvider chooses the configured providers
+	if err := client.SetSearchProvider(websearch.NewDuckDuckGo(nil)); err != nil {
+		return nil, err
+	}
 
 	// Load stream config if path provided
 	if streamConfigPath != "" {
@@ -71,13 +95,218 @@ func SetupWithStreamConfig(llmPath string, modelName string, streamConfigPath st
 			return nil, fmt.Errorf("failed to load stream config from '%s': %w", streamConfigPath, err)
 		}
 
-		client.streamAddendum = ai.GenerateMeetupAddendum(config)
+		client.streamContext = config
 		logger.Info("stream context enabled", "eventTitle", config.EventInfo.Title)
 	}
 
 	return client, nil
 }
 
+// SetChannelStreamConfig loads a stream context config that only applies to one channel.
+// Channels without their own config use the config passed to SetupWithStreamConfig.
+func (c *Client) SetChannelStreamConfig(channel string, streamConfigPath string) error {
+	if streamConfigPath == "" {
+		return nil
+	}
+
+	config, err := ai.LoadStreamConfig(streamConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load stream config from '%s': %w", streamConfigPath, err)
+	}
+
+	channel = strings.ToLower(channel)
+	c.channelMu.Lock()
+	defer c.channelMu.Unlock()
+	if c.channelContexts == nil {
+		c.channelContexts = make(map[string]*ai.MeetupConfig)
+		c.channelConfigs = make(map[string]string)
+	}
+	c.channelContexts[channel] = config
+	c.channelConfigs[channel] = streamConfigPath
+
+	c.logger.Info("channel stream context enabled", "channel", channel, "eventTitle", config.EventInfo.Title)
+	return nil
+}
+
+// streamContextFor returns the stream context and its config path for a channel
+func (c *Client) streamContextFor(channel string) (*ai.MeetupConfig, string) {
+	c.channelMu.RLock()
+	config, ok := c.channelContexts[strings.ToLower(channel)]
+	configPath := c.channelConfigs[strings.ToLower(channel)]
+	c.channelMu.RUnlock()
+	if ok {
+		return config, configPath
+	}
+	return c.streamContext, c.streamConfig
+}
+
+// channelPersona is the persona and emotes one channel uses
+type channelPersona struct {
+	name   string
+	emotes []string
+}
+
+// SetPersona sets the persona Pedro uses in channels without their own. An empty name uses
+// the default persona from the prompts.
+func (c *Client) SetPersona(name string) error {
+	if _, err := prompts.Default().Persona(name); err != nil {
+		return err
+	}
+	c.channelMu.Lock()
+	defer c.channelMu.Unlock()
+	c.persona = name
+	return nil
+}
+
+// SetChannelPersona sets the persona Pedro uses in one channel, and the emotes he may use
+// there. An empty persona uses the client's persona and no emotes use the persona's emotes.
+func (c *Client) SetChannelPersona(channel, persona string, emotes []string) error {
+	if persona == "" && len(emotes) == 0 {
+		return nil
+	}
+	if persona != "" {
+		if _, err := prompts.Default().Persona(persona); err != nil {
+			return err
+		}
+	}
+
+	channel = strings.ToLower(channel)
+	c.channelMu.Lock()
+	defer c.channelMu.Unlock()
+	if c.channelPersonas == nil {
+		c.channelPersonas = make(map[string]channelPersona)
+	}
+	c.channelPersonas[channel] = channelPersona{name: persona, emotes: emotes}
+
+	c.logger.Info("channel persona set", "channel", channel, "persona", persona, "emotes", len(emotes))
+	return nil
+}
+
+// systemPrompt renders Pedro's system prompt for a channel with its persona, emotes and
+// stream context. The rendered version names the templates and persona that produced it.
+func (c *Client) systemPrompt(channel string) (prompts.Rendered, error) {
+	c.channelMu.RLock()
+	persona := c.persona
+	settings := c.channelPersonas[strings.ToLower(channel)]
+	c.channelMu.RUnlock()
+	if settings.name != "" {
+		persona = settings.name
+	}
+
+	// a nil *MeetupConfig in the interface would still render the section
+	var stream any
+	if config, configPath := c.streamContextFor(channel); config != nil {
+		stream = config
+		c.logger.Debug("using stream context enhanced prompt", "channel", channel, "streamConfig", configPath)
+	}
+	return prompts.Default().RenderPedro(persona, time.Now().Format(time.DateOnly), settings.emotes, stream)
+}
+
+// SetChannelConversationConfig sets how much conversation Pedro remembers in one channel.
+// Channels without their own config use DefaultConversationConfig.
+func (c *Client) SetChannelConversationConfig(channel string, config ConversationConfig) {
+	c.conversationStore().SetChannelConfig(channel, config)
+	c.logger.Info("channel conversation config set", "channel", channel, "maxTurns", config.MaxTurns, "idleTimeout", config.IdleTimeout, "scope", config.Scope)
+}
+
+// conversationStore returns the conversation store, creating it on first use
+func (c *Client) conversationStore() *ConversationStore {
+	c.conversationsOnce.Do(func() {
+		if c.conversations == nil {
+			c.conversations = NewConversationStore(DefaultConversationConfig())
+		}
+	})
+	return c.conversations
+}
+
+// RegisterTool offers a tool to Pedro when he answers chat
+func (c *Client) RegisterTool(tool agent.Tool) error {
+	if err := c.toolRegistry().Register(tool); err != nil {
+		return err
+	}
+	c.logger.Info("chat tool registered", "tool", tool.Definition.Function.Name)
+	return nil
+}
+
+// SetSearchProvider sets the provider behind Pedro's web_search tool. Searches that find
+// nothing are retried with a query rewritten by the LLM.
+func (c *Client) SetSearchProvider(provider websearch.SearchProvider) error {
+	c.searchMu.Lock()
+	defer c.searchMu.Unlock()
+	c.searchProvider = provider
+	return c.registerWebSearch()
+}
+
+// SetSearchRetry sets how web searches are retried
+func (c *Client) SetSearchRetry(config websearch.RetryConfig) error {
+	c.searchMu.Lock()
+	defer c.searchMu.Unlock()
+	c.searchRetry = config
+	return c.registerWebSearch()
+}
+
+// SetSearchCache caches web searches. Nil turns the cache off.
+func (c *Client) SetSearchCache(cache *websearch.Cache) error {
+	c.searchMu.Lock()
+	defer c.searchMu.Unlock()
+	c.searchCache = cache
+	return c.registerWebSearch()
+}
+
+// SearchCache returns the web search cache, or nil when searches are not cached
+func (c *Client) SearchCache() *websearch.Cache {
+	c.searchMu.Lock()
+	defer c.searchMu.Unlock()
+	return c.searchCache
+}
+
+// registerWebSearch registers the web_search tool with the current provider, retries and
+// cache. c.searchMu must be held.
+func (c *Client) registerWebSearch() error {
+	if c.searchProvider == nil {
+		return nil
+	}
+	var provider websearch.SearchProvider = websearch.NewRetry(c.searchProvider, websearch.NewLLMRewriter(c.llm, c.modelName), c.searchRetry, c.logger)
+	if c.searchCache != nil {
+		provider = c.searchCache.Wrap(provider)
+	}
+	if err := c.RegisterTool(agent.NewWebSearchRegistryTool(provider)); err != nil {
+		return fmt.Errorf("failed to register web search tool: %w", err)
+	}
+	return nil
+}
+
+// SetMaxToolSteps sets how many LLM calls one answer may take before Pedro has to answer
+// without more tools. Zero or less uses agent.DefaultMaxSteps.
+func (c *Client) SetMaxToolSteps(steps int) {
+	c.maxToolSteps = steps
+}
+
+// NewOrchestrator creates a plan-and-execute orchestrator that uses Pedro's chat tools and
+// persona for channel
+func (c *Client) NewOrchestrator(channel string, config orchestrator.Config) *orchestrator.Orchestrator {
+	if config.SystemPrompt == "" {
+		if prompt, err := c.systemPrompt(channel); err != nil {
+			c.logger.Error("failed to render system prompt for orchestrator", "channel", channel, "error", err.Error())
+		} else {
+			config.SystemPrompt = prompt.Text
+		}
+	}
+	o := orchestrator.New(c.llm, c.modelName, config, c.logger)
+	o.HandleTools(c.toolRegistry())
+	return o
+}
+
+// toolRegistry returns the tool registry, creating it on first use
+func (c *Client) toolRegistry() *agent.Registry {
+	c.toolsOnce.Do(func() {
+		if c.tools == nil {
+			c.tools = agent.NewRegistry()
+		}
+	})
+	return c.tools
+}
+
 // SetupWithMeetupMode is deprecated - use SetupWithStreamConfig instead
 // Kept for backward compatibility
 func SetupWithMeetupMode(llmPath string, modelName string, meetupSlug string, logger *logging.Logger) (*Client, error) {
@@ -0,0 +1,116 @@
+package twitchchat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Start20Questions picks the secret answer for a new game of Twenty Questions. An empty
+// category lets the LLM pick anything.
+func (c *Client) Start20Questions(ctx context.Context, channel, category string) (string, error) {
+	c.logger.Debug("picking 20 questions answer", "channel", channel, "category", category)
+
+	prompt, err := prompts.Default().Render(prompts.TwentyQuestionsPick, prompts.TwentyQuestionsData{Category: category})
+	if err != nil {
+		return "", fmt.Errorf("failed to render 20 questions prompt: %w", err)
+	}
+	answer, err := c.generate(ctx, 1.0, llms.TextParts(llms.ChatMessageTypeHuman, prompt.Text))
+	if err != nil {
+		return "", fmt.Errorf("failed to pick 20 questions answer: %w", err)
+	}
+
+	answer = strings.Trim(strings.TrimSpace(strings.SplitN(answer, "\n", 2)[0]), `."'*`)
+	if answer == "" {
+		return "", fmt.Errorf("llm picked an empty 20 questions answer")
+	}
+	return answer, nil
+}
+
+// Play20Questions answers a yes or no question about the secret answer of a game
+func (c *Client) Play20Questions(ctx context.Context, channel, answer, question string) (string, error) {
+	c.logger.Debug("answering 20 questions question", "channel", channel)
+
+	prompt, err := prompts.Default().Render(prompts.TwentyQuestionsAnswer, prompts.TwentyQuestionsData{Answer: answer})
+	if err != nil {
+		return "", fmt.Errorf("failed to render 20 questions prompt: %w", err)
+	}
+	reply, err := c.generate(ctx, 0.3,
+		llms.TextParts(llms.ChatMessageTypeSystem, prompt.Text),
+		llms.TextParts(llms.ChatMessageTypeHuman, question))
+	if err != nil {
+		return "", fmt.Errorf("failed to answer 20 questions question: %w", err)
+	}
+
+	reply = ai.CleanResponse(reply)
+	if reply == "" {
+		return "", fmt.Errorf("llm gave an empty 20 questions answer")
+	}
+	return reply, nil
+}
+
+// Check20QuestionsGuess reports whether a guess names the secret answer. Guesses that
+// match after normalizing are accepted without asking the LLM.
+func (c *Client) Check20QuestionsGuess(ctx context.Context, answer, guess string) (bool, error) {
+	if normalizeGuess(answer) == normalizeGuess(guess) {
+		return true, nil
+	}
+
+	prompt, err := prompts.Default().Render(prompts.TwentyQuestionsGuess, prompts.TwentyQuestionsData{Answer: answer, Guess: guess})
+	if err != nil {
+		return false, fmt.Errorf("failed to render 20 questions prompt: %w", err)
+	}
+	content, err := c.generate(ctx, 0, llms.TextParts(llms.ChatMessageTypeHuman, prompt.Text))
+	if err != nil {
+		return false, fmt.Errorf("failed to check 20 questions guess: %w", err)
+	}
+
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return false, fmt.Errorf("guess check is not JSON: %q", content)
+	}
+	var verdict struct {
+		Correct bool `json:"correct"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &verdict); err != nil {
+		return false, fmt.Errorf("failed to parse guess check: %w", err)
+	}
+	return verdict.Correct, nil
+}
+
+// generate sends messages to the LLM without tools or chat history
+func (c *Client) generate(ctx context.Context, temperature float64, messages ...llms.MessageContent) (string, error) {
+	resp, err := c.llm.GenerateContent(ctx, messages,
+		llms.WithModel(c.modelName),
+		llms.WithCandidateCount(1),
+		llms.WithMaxLength(200),
+		llms.WithTemperature(temperature))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("llm returned no choices")
+	}
+	return resp.Choices[0].Content, nil
+}
+
+// normalizeGuess lowercases a guess and drops punctuation and a leading article
+func normalizeGuess(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > 1 {
+		switch words[0] {
+		case "a", "an", "the":
+			words = words[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,57 @@
+package twitchchat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// judgeLLM answers every guess check with content
+type judgeLLM struct {
+	content string
+	calls   int
+}
+
+func (j *judgeLLM) GenerateContent(_ context.Context, _ []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	j.calls++
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: j.content}}}, nil
+}
+
+func (j *judgeLLM) Call(ctx context.Context, prompt string, opts ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, j, prompt, opts...)
+}
+
+func TestClient_Check20QuestionsGuess(t *testing.T) {
+	tests := []struct {
+		name      string
+		answer    string
+		guess     string
+		content   string
+		want      bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "exact match", answer: "Golden Gate Bridge", guess: "the golden gate bridge!", want: true},
+		{name: "llm accepts synonym", answer: "couch", guess: "sofa", content: `{"correct": true}`, want: true, wantCalls: 1},
+		{name: "llm rejects", answer: "dog", guess: "animal", content: "```json\n{\"correct\": false}\n```", wantCalls: 1},
+		{name: "llm answer is not JSON", answer: "dog", guess: "cat", content: "nope", wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &judgeLLM{content: tt.content}
+			c := &Client{llm: llm, logger: logging.Default()}
+			got, err := c.Check20QuestionsGuess(context.Background(), tt.answer, tt.guess)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check20QuestionsGuess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Check20QuestionsGuess() = %v, want %v", got, tt.want)
+			}
+			if llm.calls != tt.wantCalls {
+				t.Errorf("llm called %d times, want %d", llm.calls, tt.wantCalls)
+			}
+		})
+	}
+}
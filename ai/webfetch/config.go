@@ -0,0 +1,112 @@
+package webfetch
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config controls which pages Pedro may fetch and how much of them he reads
+type Config struct {
+	// AllowedDomains can be fetched, including their subdomains. Nothing else can.
+	AllowedDomains []string `yaml:"allowed_domains"`
+
+	// MaxBytes is the most of a page that is read. Zero means 1 MiB.
+	MaxBytes int64 `yaml:"max_bytes,omitempty"`
+
+	// TimeoutSeconds bounds fetching one page. Zero means 10.
+	TimeoutSeconds int `yaml:"timeout_seconds,omitempty"`
+
+	// ChunkChars is the size of the parts a page is summarized in. Zero means 4000.
+	ChunkChars int `yaml:"chunk_chars,omitempty"`
+
+	// MaxChunks is how many parts of a page are summarized, the rest is skipped. Zero means 4.
+	MaxChunks int `yaml:"max_chunks,omitempty"`
+
+	// SummaryChars is the longest summary. Zero means 500, the length of a chat reply.
+	SummaryChars int `yaml:"summary_chars,omitempty"`
+}
+
+// DefaultConfig returns the fetch limits with no domains allowed
+func DefaultConfig() *Config {
+	return &Config{
+		MaxBytes:       1 << 20,
+		TimeoutSeconds: 10,
+		ChunkChars:     4000,
+		MaxChunks:      4,
+		SummaryChars:   500,
+	}
+}
+
+// LoadConfig loads the fetch config from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fetch config %s: %w", path, err)
+	}
+	config := DefaultConfig()
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse fetch config %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid fetch config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Validate checks at least one domain is allowed and the limits are not negative
+func (c *Config) Validate() error {
+	if len(c.AllowedDomains) == 0 {
+		return fmt.Errorf("at least one allowed domain is required")
+	}
+	for _, domain := range c.AllowedDomains {
+		if strings.TrimSpace(domain) == "" || strings.Contains(domain, "/") {
+			return fmt.Errorf("allowed domain %q must be a host name like go.dev", domain)
+		}
+	}
+	if c.MaxBytes < 0 || c.TimeoutSeconds < 0 || c.ChunkChars < 0 || c.MaxChunks < 0 || c.SummaryChars < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	return nil
+}
+
+// withDefaults fills in zero limits
+func (c Config) withDefaults() Config {
+	defaults := DefaultConfig()
+	if c.MaxBytes == 0 {
+		c.MaxBytes = defaults.MaxBytes
+	}
+	if c.TimeoutSeconds == 0 {
+		c.TimeoutSeconds = defaults.TimeoutSeconds
+	}
+	if c.ChunkChars == 0 {
+		c.ChunkChars = defaults.ChunkChars
+	}
+	if c.MaxChunks == 0 {
+		c.MaxChunks = defaults.MaxChunks
+	}
+	if c.SummaryChars == 0 {
+		c.SummaryChars = defaults.SummaryChars
+	}
+	return c
+}
+
+// Timeout returns how long fetching one page may take
+func (c Config) Timeout() time.Duration {
+	return time.Duration(c.withDefaults().TimeoutSeconds) * time.Second
+}
+
+// DomainAllowed reports whether host is an allowed domain or a subdomain of one
+func (c Config) DomainAllowed(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, domain := range c.AllowedDomains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
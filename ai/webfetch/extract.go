@@ -0,0 +1,122 @@
+package webfetch
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped elements hold no readable text or only the site's navigation. The title is
+// read on its own.
+var skipped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Title:    true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+}
+
+// blocks start a new paragraph
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Li: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Pre: true, atom.Blockquote: true, atom.Table: true, atom.Tr: true, atom.Br: true,
+	atom.Figcaption: true,
+}
+
+// ExtractText returns the title and readable text of an HTML page. Paragraphs are
+// separated by blank lines and whitespace inside them is collapsed.
+func ExtractText(r io.Reader) (title string, text string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	var paragraphs []string
+	var current strings.Builder
+	flush := func() {
+		if p := strings.Join(strings.Fields(current.String()), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.ElementNode:
+			if n.DataAtom == atom.Title && title == "" && n.FirstChild != nil {
+				title = strings.Join(strings.Fields(n.FirstChild.Data), " ")
+			}
+			if skipped[n.DataAtom] {
+				return
+			}
+		case html.TextNode:
+			current.WriteString(n.Data)
+			current.WriteString(" ")
+			return
+		}
+
+		block := n.Type == html.ElementNode && blocks[n.DataAtom]
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			flush()
+		}
+	}
+	walk(doc)
+	flush()
+
+	return title, strings.Join(paragraphs, "\n\n"), nil
+}
+
+// Chunk splits text into parts of at most size characters, breaking between paragraphs,
+// then sentences, then words where it can
+func Chunk(text string, size int) []string {
+	if size <= 0 {
+		return []string{text}
+	}
+	var chunks []string
+	for len(text) > size {
+		cut := lastBreak(text[:size+1])
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// lastBreak returns where to cut text, preferring the last paragraph break, then sentence
+// end, then space in its second half
+func lastBreak(text string) int {
+	half := len(text) / 2
+	for _, sep := range []string{"\n\n", ". ", " "} {
+		if i := strings.LastIndex(text, sep); i > half {
+			return i + len(sep)
+		}
+	}
+	cut := len(text) - 1
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return cut
+}
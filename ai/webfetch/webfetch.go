@@ -0,0 +1,241 @@
+// Package webfetch lets Pedro read web pages that search snippets only point at. Pages on
+// allowed domains are fetched with size and time limits, reduced to their readable text,
+// and summarized by the LLM in parts short enough for a chat reply.
+package webfetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// userAgent identifies Pedro to the sites he reads
+const userAgent = "PedroBot/1.0"
+
+// ErrDomainNotAllowed is returned for pages outside the allowed domains
+var ErrDomainNotAllowed = errors.New("domain is not allowed")
+
+// Page is the readable text of a fetched page
+type Page struct {
+	URL   string
+	Title string
+	Text  string
+	// Truncated is set when the page was larger than the size limit
+	Truncated bool
+}
+
+// Fetcher fetches pages and summarizes them
+type Fetcher struct {
+	config    Config
+	client    *http.Client
+	llm       llms.Model
+	modelName string
+	logger    *logging.Logger
+}
+
+// New creates a fetcher that summarizes pages with llm
+func New(config *Config, llm llms.Model, modelName string, logger *logging.Logger) (*Fetcher, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if config == nil {
+		return nil, errors.New("fetch config is required")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	f := &Fetcher{
+		config:    config.withDefaults(),
+		llm:       llm,
+		modelName: modelName,
+		logger:    logger,
+	}
+	f.client = &http.Client{
+		Timeout: f.config.Timeout(),
+		// Redirects must stay on allowed domains too
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f, nil
+}
+
+// checkURL allows http and https links to allowed domains
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if !f.config.DomainAllowed(u.Hostname()) {
+		return fmt.Errorf("%w: %s", ErrDomainNotAllowed, u.Hostname())
+	}
+	return nil
+}
+
+// Fetch downloads a page and extracts its readable text
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	page, err := f.fetch(ctx, rawURL)
+	switch {
+	case errors.Is(err, ErrDomainNotAllowed):
+		metrics.FetchPageRequestsTotal.WithLabelValues("blocked").Inc()
+	case err != nil:
+		metrics.FetchPageRequestsTotal.WithLabelValues("error").Inc()
+	default:
+		metrics.FetchPageRequestsTotal.WithLabelValues("success").Inc()
+	}
+	return page, err
+}
+
+func (f *Fetcher) fetch(ctx context.Context, rawURL string) (*Page, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, err)
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned status %d", u, resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "" && mediaType != "text/html" && mediaType != "text/plain" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%s is %s, not a web page", u, mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.config.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+	page := &Page{URL: resp.Request.URL.String()}
+	if int64(len(body)) > f.config.MaxBytes {
+		body = body[:f.config.MaxBytes]
+		page.Truncated = true
+	}
+
+	if mediaType == "text/plain" {
+		page.Text = strings.TrimSpace(string(body))
+	} else {
+		page.Title, page.Text, err = ExtractText(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+	}
+	f.logger.Debug("fetched page", "url", page.URL, "bytes", len(body), "chars", len(page.Text), "truncated", page.Truncated)
+	return page, nil
+}
+
+// Summarize summarizes a page, focused on question when it is set. Long pages are
+// summarized in parts and the parts' summaries combined.
+func (f *Fetcher) Summarize(ctx context.Context, page *Page, question string) (string, error) {
+	chunks := Chunk(page.Text, f.config.ChunkChars)
+	if len(chunks) == 0 {
+		return "", fmt.Errorf("%s has no readable text", page.URL)
+	}
+	if len(chunks) > f.config.MaxChunks {
+		f.logger.Debug("page too long, summarizing the start", "url", page.URL, "chunks", len(chunks), "maxChunks", f.config.MaxChunks)
+		chunks = chunks[:f.config.MaxChunks]
+	}
+
+	data := prompts.PageSummaryData{
+		URL:      page.URL,
+		Title:    page.Title,
+		Question: question,
+		Parts:    len(chunks),
+		MaxChars: f.config.SummaryChars,
+	}
+	var notes []string
+	for i, chunk := range chunks {
+		data.Part = i + 1
+		note, err := f.summarize(ctx, data, chunk)
+		if err != nil {
+			return "", err
+		}
+		notes = append(notes, note)
+	}
+	if len(notes) == 1 {
+		return notes[0], nil
+	}
+
+	data.Part, data.Parts, data.Notes = 0, 0, true
+	return f.summarize(ctx, data, strings.Join(notes, "\n\n"))
+}
+
+// summarize asks the LLM to summarize one text
+func (f *Fetcher) summarize(ctx context.Context, data prompts.PageSummaryData, text string) (string, error) {
+	system, err := prompts.Default().Render(prompts.PageSummary, data)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.llm.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, system.Text),
+		llms.TextParts(llms.ChatMessageTypeHuman, text),
+	},
+		llms.WithModel(f.modelName),
+		llms.WithTemperature(0.2),
+		llms.WithMaxTokens(f.config.SummaryChars/2),
+	)
+	if err != nil {
+		return "", fmt.Errorf("summary call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("summary returned no choices")
+	}
+	summary := strings.Join(strings.Fields(resp.Choices[0].Content), " ")
+	if summary == "" {
+		return "", errors.New("summary is empty")
+	}
+	return fitLength(summary, f.config.SummaryChars), nil
+}
+
+// FetchSummary fetches a page and summarizes it, focused on question when it is set
+func (f *Fetcher) FetchSummary(ctx context.Context, rawURL, question string) (string, error) {
+	page, err := f.Fetch(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	return f.Summarize(ctx, page, question)
+}
+
+// fitLength cuts text to limit characters after the last whole sentence that fits, or the
+// last whole word when no sentence does
+func fitLength(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit])
+	if i := strings.LastIndexAny(cut, ".!?"); i > 0 {
+		return cut[:i+1]
+	}
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		return cut[:i]
+	}
+	return cut
+}
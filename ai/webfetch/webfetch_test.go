@@ -0,0 +1,206 @@
+package webfetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/internal/llmtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const articleHTML = `<!DOCTYPE html>
+<html>
+<head><title>Go 1.26 is released</title><style>body { color: red }</style></head>
+<body>
+<nav><a href="/">Home</a> <a href="/blog">Blog</a></nav>
+<main>
+<h1>Go 1.26 is released</h1>
+<p>Today the Go team is happy to release   Go 1.26.</p>
+<script>track("visit")</script>
+<ul><li>Faster builds</li><li>New <code>iter</code> helpers</li></ul>
+</main>
+<footer>Copyright The Go Authors</footer>
+</body>
+</html>`
+
+func TestExtractText(t *testing.T) {
+	title, text, err := ExtractText(strings.NewReader(articleHTML))
+	require.NoError(t, err)
+	assert.Equal(t, "Go 1.26 is released", title)
+	assert.Equal(t, "Go 1.26 is released\n\nToday the Go team is happy to release Go 1.26.\n\nFaster builds\n\nNew iter helpers", text)
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []string
+	}{
+		{name: "short text is one chunk", text: "one. two.", size: 100, want: []string{"one. two."}},
+		{name: "breaks between paragraphs", text: "first paragraph here\n\nsecond one", size: 25, want: []string{"first paragraph here", "second one"}},
+		{name: "breaks between sentences", text: "One sentence here. Another sentence there.", size: 25, want: []string{"One sentence here.", "Another sentence there."}},
+		{name: "breaks long words", text: "abcdefghij", size: 4, want: []string{"abcd", "efgh", "ij"}},
+		{name: "empty text has no chunks", text: "", size: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Chunk(tt.text, tt.size))
+		})
+	}
+}
+
+// newPageServer serves test pages
+func newPageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(articleHTML))
+	})
+	mux.HandleFunc("/notes.txt", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(strings.Repeat("go ", 100)))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusFound)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://evil.example/article", http.StatusFound)
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	server := newPageServer(t)
+
+	tests := []struct {
+		name          string
+		url           string
+		config        Config
+		timeout       time.Duration
+		wantTitle     string
+		wantText      string
+		wantTruncated bool
+		wantErr       string
+	}{
+		{name: "html page", url: server.URL + "/article", wantTitle: "Go 1.26 is released", wantText: "Faster builds"},
+		{name: "plain text", url: server.URL + "/notes.txt", wantText: "go go go"},
+		{name: "redirect on an allowed domain", url: server.URL + "/moved", wantTitle: "Go 1.26 is released"},
+		{name: "size limit", url: server.URL + "/notes.txt", config: Config{MaxBytes: 10}, wantText: "go go go g", wantTruncated: true},
+		{name: "domain not allowed", url: "https://evil.example/article", wantErr: "domain is not allowed"},
+		{name: "redirect off the allowed domains", url: server.URL + "/elsewhere", wantErr: "domain is not allowed"},
+		{name: "not http", url: "file:///etc/passwd", wantErr: "unsupported scheme"},
+		{name: "not a web page", url: server.URL + "/image.png", wantErr: "not a web page"},
+		{name: "missing page", url: server.URL + "/missing", wantErr: "status 404"},
+		{name: "timeout", url: server.URL + "/slow", timeout: 20 * time.Millisecond, wantErr: "context deadline exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.AllowedDomains = []string{"127.0.0.1"}
+			fetcher, err := New(&config, nil, "", nil)
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			page, err := fetcher.Fetch(ctx, tt.url)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTitle, page.Title)
+			assert.Contains(t, page.Text, tt.wantText)
+			assert.Equal(t, tt.wantTruncated, page.Truncated)
+		})
+	}
+}
+
+func TestFetcher_Summarize(t *testing.T) {
+	server := newPageServer(t)
+	llm := llmtest.NewServer(t)
+	llm.Default(llmtest.Response{Content: "Go 1.26 brings faster builds.\nAnd iter helpers."})
+
+	t.Run("short page is summarized once", func(t *testing.T) {
+		fetcher, err := New(&Config{AllowedDomains: []string{"127.0.0.1"}}, llm.Client(t, "test-model"), "test-model", nil)
+		require.NoError(t, err)
+
+		before := len(llm.Requests())
+		got, err := fetcher.FetchSummary(context.Background(), server.URL+"/article", "what is new?")
+		require.NoError(t, err)
+		assert.Equal(t, "Go 1.26 brings faster builds. And iter helpers.", got)
+
+		requests := llm.Requests()[before:]
+		require.Len(t, requests, 1)
+		assert.Contains(t, requests[0].Messages[0].Content, "Focus on what helps answer this question: what is new?")
+		assert.Contains(t, requests[0].LastUserMessage(), "Today the Go team is happy to release Go 1.26.")
+	})
+
+	t.Run("long page is summarized in parts", func(t *testing.T) {
+		config := &Config{AllowedDomains: []string{"127.0.0.1"}, ChunkChars: 100, MaxChunks: 2, SummaryChars: 30}
+		fetcher, err := New(config, llm.Client(t, "test-model"), "test-model", nil)
+		require.NoError(t, err)
+
+		before := len(llm.Requests())
+		got, err := fetcher.FetchSummary(context.Background(), server.URL+"/notes.txt", "")
+		require.NoError(t, err)
+		assert.Equal(t, "Go 1.26 brings faster builds.", got, "cut to the last sentence that fits")
+
+		requests := llm.Requests()[before:]
+		require.Len(t, requests, 3, "two parts and the combined summary")
+		assert.Contains(t, requests[0].Messages[0].Content, "part 1 of 2")
+		assert.Contains(t, requests[2].Messages[0].Content, "Combine them into one summary.")
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "allowed domains", config: Config{AllowedDomains: []string{"go.dev", "pkg.go.dev"}}},
+		{name: "no domains", config: Config{}, wantErr: true},
+		{name: "url instead of domain", config: Config{AllowedDomains: []string{"https://go.dev/"}}, wantErr: true},
+		{name: "negative limit", config: Config{AllowedDomains: []string{"go.dev"}, MaxBytes: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	config := Config{AllowedDomains: []string{"go.dev"}}
+	assert.True(t, config.DomainAllowed("go.dev"))
+	assert.True(t, config.DomainAllowed("www.go.dev"))
+	assert.True(t, config.DomainAllowed("pkg.go.dev"))
+	assert.False(t, config.DomainAllowed("notgo.dev"))
+}
@@ -0,0 +1,375 @@
+package websearch
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/database"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+// CacheConfig controls the search cache
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TTLSeconds is how long results are reused. Zero means an hour.
+	TTLSeconds int `yaml:"ttl_seconds,omitempty"`
+	// Size is how many searches are kept in memory. Zero means 256.
+	Size int `yaml:"size,omitempty"`
+	// Postgres also keeps searches in the web_search_cache table, so they outlive restarts
+	Postgres bool `yaml:"postgres,omitempty"`
+	// Semantic reuses the results of a cached query worded differently. Queries are
+	// embedded with EmbeddingModel and match at MinSimilarity (default 0.92).
+	Semantic       bool    `yaml:"semantic,omitempty"`
+	EmbeddingModel string  `yaml:"embedding_model,omitempty"`
+	MinSimilarity  float64 `yaml:"min_similarity,omitempty"`
+}
+
+// TTL returns how long results are reused
+func (c CacheConfig) TTL() time.Duration {
+	if c.TTLSeconds <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.TTLSeconds) * time.Second
+}
+
+func (c CacheConfig) size() int {
+	if c.Size <= 0 {
+		return 256
+	}
+	return c.Size
+}
+
+func (c CacheConfig) minSimilarity() float64 {
+	if c.MinSimilarity <= 0 {
+		return 0.92
+	}
+	return c.MinSimilarity
+}
+
+// Embedder turns queries into vectors. ai.EmbeddingGenerator implements it.
+type Embedder interface {
+	GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
+}
+
+// cacheEntry is a search kept in memory
+type cacheEntry struct {
+	key       string
+	results   []Result
+	embedding []float32
+	stored    time.Time
+}
+
+// Cache keeps search results by normalized query in an in-memory LRU, and optionally in
+// Postgres. With an embedder, queries worded differently find each other by similarity.
+type Cache struct {
+	config   CacheConfig
+	store    database.SearchCacheStore
+	embedder Embedder
+	logger   *logging.Logger
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+// NewCache creates a search cache. store and embedder are optional.
+func NewCache(config CacheConfig, store database.SearchCacheStore, embedder Embedder, logger *logging.Logger) *Cache {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &Cache{
+		config:   config,
+		store:    store,
+		embedder: embedder,
+		logger:   logger,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Wrap returns provider with its searches cached
+func (c *Cache) Wrap(provider SearchProvider) SearchProvider {
+	return &cached{provider: provider, cache: c}
+}
+
+// Len returns how many searches are kept in memory, expired ones included
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Purge forgets the cached search for query, or every cached search when query is empty.
+// With an embedder, searches similar enough to be served for query are forgotten too. It
+// returns how many were forgotten.
+func (c *Cache) Purge(ctx context.Context, query string) (int, error) {
+	key := normalizeQuery(query)
+
+	var embedding []float32
+	if key != "" && c.embedder != nil {
+		var err error
+		embedding, err = c.embedder.GenerateEmbedding(ctx, query)
+		if err != nil {
+			return 0, fmt.Errorf("failed to embed query to purge: %w", err)
+		}
+	}
+	minSimilarity := c.config.minSimilarity()
+
+	c.mu.Lock()
+	purged := 0
+	if key == "" {
+		purged = c.order.Len()
+		c.entries = make(map[string]*list.Element)
+		c.order.Init()
+	} else {
+		for el := c.order.Front(); el != nil; {
+			next := el.Next()
+			entry := el.Value.(*cacheEntry)
+			if entry.key == key || (len(embedding) > 0 && cosineSimilarity(embedding, entry.embedding) >= minSimilarity) {
+				c.remove(el)
+				purged++
+			}
+			el = next
+		}
+	}
+	c.mu.Unlock()
+
+	if c.store != nil {
+		deleted, err := c.store.PurgeSearchCache(ctx, key)
+		if err != nil {
+			return purged, err
+		}
+		if len(embedding) > 0 {
+			similar, err := c.store.PurgeSimilarSearchCache(ctx, types.SearchCacheQuery{
+				Embedding:     embedding,
+				Model:         c.config.EmbeddingModel,
+				MinSimilarity: minSimilarity,
+			})
+			if err != nil {
+				return purged, err
+			}
+			deleted += similar
+		}
+		purged = max(purged, int(deleted))
+	}
+	c.logger.Info("search cache purged", "query", key, "purged", purged)
+	return purged, nil
+}
+
+// get returns the cached results for key from memory, then Postgres
+func (c *Cache) get(ctx context.Context, key string) ([]Result, bool) {
+	if results, ok := c.getMemory(key); ok {
+		metrics.WebSearchCacheLookupsTotal.WithLabelValues("memory_hit").Inc()
+		return results, true
+	}
+	if c.store == nil {
+		return nil, false
+	}
+	entry, err := c.store.GetSearchCache(ctx, key, c.now().Add(-c.config.TTL()))
+	if err != nil {
+		c.logger.Warn("failed to read search cache", "query", key, "error", err.Error())
+		return nil, false
+	}
+	if entry == nil {
+		return nil, false
+	}
+	results, ok := c.fromStore(entry)
+	if ok {
+		metrics.WebSearchCacheLookupsTotal.WithLabelValues("postgres_hit").Inc()
+	}
+	return results, ok
+}
+
+// getSimilar returns the cached results of the query most similar to embedding
+func (c *Cache) getSimilar(ctx context.Context, embedding []float32) ([]Result, bool) {
+	minSimilarity := c.config.minSimilarity()
+
+	c.mu.Lock()
+	var best *cacheEntry
+	bestSimilarity := minSimilarity
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*cacheEntry)
+		if c.expired(entry) || len(entry.embedding) == 0 {
+			continue
+		}
+		if s := cosineSimilarity(embedding, entry.embedding); s >= bestSimilarity {
+			best, bestSimilarity = entry, s
+		}
+	}
+	if best != nil {
+		c.order.MoveToFront(c.entries[best.key])
+	}
+	c.mu.Unlock()
+	if best != nil {
+		metrics.WebSearchCacheLookupsTotal.WithLabelValues("semantic_hit").Inc()
+		c.logger.Debug("search cache similar hit", "cached", best.key, "similarity", bestSimilarity)
+		return best.results, true
+	}
+
+	if c.store == nil {
+		return nil, false
+	}
+	entry, err := c.store.FindSimilarSearchCache(ctx, types.SearchCacheQuery{
+		Embedding:     embedding,
+		Model:         c.config.EmbeddingModel,
+		Since:         c.now().Add(-c.config.TTL()),
+		MinSimilarity: minSimilarity,
+	})
+	if err != nil {
+		c.logger.Warn("failed to find similar cached search", "error", err.Error())
+		return nil, false
+	}
+	if entry == nil {
+		return nil, false
+	}
+	results, ok := c.fromStore(entry)
+	if ok {
+		metrics.WebSearchCacheLookupsTotal.WithLabelValues("semantic_hit").Inc()
+		c.logger.Debug("search cache similar hit", "cached", entry.Key, "similarity", entry.Similarity)
+	}
+	return results, ok
+}
+
+// fromStore decodes a stored entry and keeps it in memory for the next lookup
+func (c *Cache) fromStore(entry *types.SearchCacheEntry) ([]Result, bool) {
+	var results []Result
+	if err := json.Unmarshal(entry.Results, &results); err != nil {
+		c.logger.Warn("failed to decode cached search", "query", entry.Key, "error", err.Error())
+		return nil, false
+	}
+	c.putMemory(&cacheEntry{key: entry.Key, results: results, stored: entry.CreatedAt})
+	return results, true
+}
+
+// put caches results in memory and Postgres
+func (c *Cache) put(ctx context.Context, key, query string, results []Result, embedding []float32) {
+	c.putMemory(&cacheEntry{key: key, results: results, embedding: embedding, stored: c.now()})
+	if c.store == nil {
+		return
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		c.logger.Warn("failed to encode search results", "query", key, "error", err.Error())
+		return
+	}
+	err = c.store.SaveSearchCache(ctx, types.SearchCacheEntry{
+		Key:            key,
+		Query:          query,
+		Results:        data,
+		Embedding:      embedding,
+		EmbeddingModel: c.config.EmbeddingModel,
+	})
+	if err != nil {
+		c.logger.Warn("failed to save search cache", "query", key, "error", err.Error())
+	}
+}
+
+func (c *Cache) getMemory(key string) ([]Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if c.expired(entry) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.results, true
+}
+
+func (c *Cache) putMemory(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[entry.key]; ok {
+		if entry.embedding == nil {
+			entry.embedding = el.Value.(*cacheEntry).embedding
+		}
+		c.remove(el)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.config.size() {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry. c.mu must be held.
+func (c *Cache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+func (c *Cache) expired(entry *cacheEntry) bool {
+	return c.now().Sub(entry.stored) > c.config.TTL()
+}
+
+// cached is a provider whose searches go through a Cache
+type cached struct {
+	provider SearchProvider
+	cache    *Cache
+}
+
+// Name returns the name of the wrapped provider
+func (p *cached) Name() string {
+	return p.provider.Name()
+}
+
+// Search returns cached results when there are any, and searches and caches otherwise.
+// Searches that fail or find nothing are not cached.
+func (p *cached) Search(ctx context.Context, query string) ([]Result, error) {
+	c := p.cache
+	key := normalizeQuery(query)
+	if results, ok := c.get(ctx, key); ok {
+		c.logger.Debug("search cache hit", "query", key)
+		return results, nil
+	}
+
+	var embedding []float32
+	if c.embedder != nil {
+		var err error
+		embedding, err = c.embedder.GenerateEmbedding(ctx, query)
+		if err != nil {
+			c.logger.Warn("failed to embed search query", "query", key, "error", err.Error())
+		}
+	}
+	if len(embedding) > 0 {
+		if results, ok := c.getSimilar(ctx, embedding); ok {
+			return results, nil
+		}
+	}
+
+	metrics.WebSearchCacheLookupsTotal.WithLabelValues("miss").Inc()
+	results, err := p.provider.Search(ctx, query)
+	if err != nil || len(results) == 0 {
+		return results, err
+	}
+	c.put(ctx, key, query, results, embedding)
+	return results, nil
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
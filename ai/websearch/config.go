@@ -0,0 +1,163 @@
+package websearch
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Provider types
+const (
+	TypeDuckDuckGo = "duckduckgo"
+	TypeSearXNG    = "searxng"
+	TypeFixture    = "fixture"
+)
+
+// ProviderConfig is one search provider
+type ProviderConfig struct {
+	// Name identifies the provider in logs and metrics. It defaults to the type.
+	Name string `yaml:"name,omitempty"`
+	// Type is duckduckgo, searxng or fixture
+	Type string `yaml:"type"`
+	// URL is the SearXNG instance
+	URL string `yaml:"url,omitempty"`
+	// Categories and Language narrow SearXNG searches, e.g. "general" and "en"
+	Categories string `yaml:"categories,omitempty"`
+	Language   string `yaml:"language,omitempty"`
+	// Path is the fixture file
+	Path string `yaml:"path,omitempty"`
+	// TimeoutSeconds bounds one search request
+	TimeoutSeconds int `yaml:"timeout_seconds,omitempty"`
+}
+
+// Config lists the search providers in the order they are tried
+type Config struct {
+	Providers []ProviderConfig `yaml:"providers"`
+	// Retry controls retries of searches that fail or find nothing
+	Retry RetryConfig `yaml:"retry,omitempty"`
+	// Cache reuses results of repeated searches
+	Cache CacheConfig `yaml:"cache,omitempty"`
+}
+
+// DefaultConfig searches DuckDuckGo only
+func DefaultConfig() *Config {
+	return &Config{Providers: []ProviderConfig{{Type: TypeDuckDuckGo}}}
+}
+
+// LoadConfig loads search providers from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search config %s: %w", path, err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse search config %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid search config %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// Validate checks every provider has a known type, the settings it needs and a unique name
+func (c *Config) Validate() error {
+	if len(c.Providers) == 0 {
+		return fmt.Errorf("at least one provider is required")
+	}
+	seen := make(map[string]bool)
+	for i, p := range c.Providers {
+		switch p.Type {
+		case TypeDuckDuckGo:
+		case TypeSearXNG:
+			if p.URL == "" {
+				return fmt.Errorf("provider %d (searxng) needs a url", i+1)
+			}
+		case TypeFixture:
+			if p.Path == "" {
+				return fmt.Errorf("provider %d (fixture) needs a path", i+1)
+			}
+		default:
+			return fmt.Errorf("provider %d has unknown type %q", i+1, p.Type)
+		}
+		name := p.name()
+		if seen[name] {
+			return fmt.Errorf("provider name %q is used twice", name)
+		}
+		seen[name] = true
+	}
+	if c.Cache.Enabled && c.Cache.Semantic && c.Cache.EmbeddingModel == "" {
+		return fmt.Errorf("semantic cache needs an embedding_model")
+	}
+	return nil
+}
+
+func (p ProviderConfig) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Type
+}
+
+// New creates the configured providers, tried in order
+func New(config *Config, logger *logging.Logger) (*Fallback, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var providers []SearchProvider
+	for _, pc := range config.Providers {
+		timeout := time.Duration(pc.TimeoutSeconds) * time.Second
+		var provider SearchProvider
+		switch pc.Type {
+		case TypeDuckDuckGo:
+			ddg := NewDuckDuckGo(nil)
+			if timeout > 0 {
+				ddg.client.HTTPClient.Timeout = timeout
+			}
+			provider = named(pc.name(), ddg)
+		case TypeSearXNG:
+			searxng, err := NewSearXNG(pc.name(), pc.URL, pc.Categories, pc.Language, timeout)
+			if err != nil {
+				return nil, err
+			}
+			provider = searxng
+		case TypeFixture:
+			fixture, err := LoadFixture(pc.name(), pc.Path)
+			if err != nil {
+				return nil, err
+			}
+			provider = fixture
+		}
+		providers = append(providers, provider)
+		logger.Info("search provider configured", "provider", provider.Name(), "type", pc.Type)
+	}
+	return NewFallback(providers, logger)
+}
+
+// namedProvider renames a provider for logs and metrics
+type namedProvider struct {
+	SearchProvider
+	name string
+}
+
+func (n namedProvider) Name() string {
+	return n.name
+}
+
+// named returns provider under name, or provider itself when the name already matches
+func named(name string, provider SearchProvider) SearchProvider {
+	if provider.Name() == name {
+		return provider
+	}
+	return namedProvider{SearchProvider: provider, name: name}
+}
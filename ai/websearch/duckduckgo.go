@@ -0,0 +1,74 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/duckduckgo"
+)
+
+// DuckDuckGo searches the DuckDuckGo Instant Answer API. It only answers questions with
+// an encyclopedia style abstract, so it is best used as a fallback.
+type DuckDuckGo struct {
+	client *duckduckgo.Client
+}
+
+// NewDuckDuckGo creates a DuckDuckGo provider. A nil client uses duckduckgo.NewClient.
+func NewDuckDuckGo(client *duckduckgo.Client) *DuckDuckGo {
+	if client == nil {
+		client = duckduckgo.NewClient()
+	}
+	return &DuckDuckGo{client: client}
+}
+
+// Name returns "duckduckgo"
+func (d *DuckDuckGo) Name() string {
+	return "duckduckgo"
+}
+
+// linkText is the title in the HTML of a DuckDuckGo result or related topic
+var linkText = regexp.MustCompile(`<a [^>]*>([^<]*)</a>`)
+
+// Search returns the abstract followed by the results and related topics
+func (d *DuckDuckGo) Search(ctx context.Context, query string) ([]Result, error) {
+	body, err := d.client.SearchContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	var resp duckduckgo.Response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse DuckDuckGo response: %w", err)
+	}
+
+	var results []Result
+	if resp.Abstract != "" {
+		results = append(results, Result{Title: resp.Heading, URL: resp.AbstractURL, Snippet: resp.Abstract})
+	}
+	for _, r := range resp.Results {
+		results = appendTopic(results, r.Result, r.Text, r.FirstURL)
+	}
+	for _, topic := range resp.RelatedTopics {
+		results = appendTopic(results, topic.Result, topic.Text, topic.FirstURL)
+	}
+	return results, nil
+}
+
+// appendTopic normalizes a result or related topic. Its title is the link text in the
+// result HTML, and its text starts with the title.
+func appendTopic(results []Result, resultHTML, text, url string) []Result {
+	if text == "" {
+		return results
+	}
+	result := Result{URL: url, Snippet: text}
+	if m := linkText.FindStringSubmatch(resultHTML); m != nil {
+		result.Title = html.UnescapeString(m[1])
+		if snippet := strings.TrimSpace(strings.TrimPrefix(text, result.Title)); snippet != "" {
+			result.Snippet = strings.TrimLeft(snippet, "-–, ")
+		}
+	}
+	return append(results, result)
+}
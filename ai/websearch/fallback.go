@@ -0,0 +1,67 @@
+package websearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+)
+
+// Fallback tries its providers in order and returns the first results found. A provider
+// that fails or finds nothing falls back to the next.
+type Fallback struct {
+	providers []SearchProvider
+	logger    *logging.Logger
+}
+
+// NewFallback creates a provider that tries providers in order
+func NewFallback(providers []SearchProvider, logger *logging.Logger) (*Fallback, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("at least one search provider is required")
+	}
+	return &Fallback{providers: providers, logger: logger}, nil
+}
+
+// Name returns "fallback"
+func (f *Fallback) Name() string {
+	return "fallback"
+}
+
+// Providers returns the providers in the order they are tried
+func (f *Fallback) Providers() []SearchProvider {
+	return f.providers
+}
+
+// Search returns the results of the first provider that finds any. It only fails when
+// every provider failed.
+func (f *Fallback) Search(ctx context.Context, query string) ([]Result, error) {
+	var errs []error
+	for _, provider := range f.providers {
+		results, err := provider.Search(ctx, query)
+		switch {
+		case err != nil:
+			metrics.WebSearchRequestsTotal.WithLabelValues(provider.Name(), "error").Inc()
+			f.logger.Warn("search provider failed", "provider", provider.Name(), "error", err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			if ctx.Err() != nil {
+				return nil, errors.Join(errs...)
+			}
+		case len(results) == 0:
+			metrics.WebSearchRequestsTotal.WithLabelValues(provider.Name(), "empty").Inc()
+			f.logger.Debug("search provider found nothing", "provider", provider.Name())
+		default:
+			metrics.WebSearchRequestsTotal.WithLabelValues(provider.Name(), "success").Inc()
+			f.logger.Debug("search provider found results", "provider", provider.Name(), "results", len(results))
+			return results, nil
+		}
+	}
+	if len(errs) == len(f.providers) {
+		return nil, errors.Join(errs...)
+	}
+	return nil, nil
+}
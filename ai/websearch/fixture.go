@@ -0,0 +1,65 @@
+package websearch
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AnyQuery is the fixture key whose results answer queries without their own
+const AnyQuery = "*"
+
+// Fixture answers searches from a fixed set of results, for tests, replays and working
+// offline. Queries are matched ignoring case and spacing.
+type Fixture struct {
+	name    string
+	results map[string][]Result
+}
+
+// NewFixture creates a fixture provider from query to results
+func NewFixture(name string, results map[string][]Result) *Fixture {
+	if name == "" {
+		name = "fixture"
+	}
+	normalized := make(map[string][]Result, len(results))
+	for query, r := range results {
+		if query != AnyQuery {
+			query = normalizeQuery(query)
+		}
+		normalized[query] = r
+	}
+	return &Fixture{name: name, results: normalized}
+}
+
+// LoadFixture reads a YAML (or JSON) file mapping each query to its results:
+//
+//	"latest go release":
+//	  - title: Go 1.26 is released
+//	    url: https://go.dev/blog/go1.26
+//	    snippet: Go 1.26 is out now.
+func LoadFixture(name, path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search fixture %s: %w", path, err)
+	}
+	var results map[string][]Result
+	if err := yaml.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse search fixture %s: %w", path, err)
+	}
+	return NewFixture(name, results), nil
+}
+
+// Name returns the provider's configured name
+func (f *Fixture) Name() string {
+	return f.name
+}
+
+// Search returns the results recorded for query
+func (f *Fixture) Search(_ context.Context, query string) ([]Result, error) {
+	if results, ok := f.results[normalizeQuery(query)]; ok {
+		return results, nil
+	}
+	return f.results[AnyQuery], nil
+}
@@ -0,0 +1,177 @@
+package websearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// RetryConfig controls how often a search is retried before giving up
+type RetryConfig struct {
+	// MaxAttempts is how many searches one query may take, the first included. Zero means
+	// 3, and 1 turns retries off.
+	MaxAttempts int `yaml:"max_attempts,omitempty"`
+	// BackoffMillis is the wait before the first retry. It doubles after every retry.
+	// Zero means 500.
+	BackoffMillis int `yaml:"backoff_ms,omitempty"`
+}
+
+// Attempts returns how many searches one query may take
+func (c RetryConfig) Attempts() int {
+	if c.MaxAttempts <= 0 {
+		return 3
+	}
+	return c.MaxAttempts
+}
+
+// Backoff returns the wait before the first retry
+func (c RetryConfig) Backoff() time.Duration {
+	if c.BackoffMillis <= 0 {
+		return 500 * time.Millisecond
+	}
+	return time.Duration(c.BackoffMillis) * time.Millisecond
+}
+
+// QueryRewriter rewrites a query that found nothing
+type QueryRewriter interface {
+	// Rewrite returns a new query to try. tried holds every query that found nothing,
+	// oldest first.
+	Rewrite(ctx context.Context, tried []string) (string, error)
+}
+
+// LLMRewriter asks the LLM for a better query
+type LLMRewriter struct {
+	llm       llms.Model
+	modelName string
+}
+
+// NewLLMRewriter creates a rewriter that uses the search_rewrite prompt
+func NewLLMRewriter(llm llms.Model, modelName string) *LLMRewriter {
+	return &LLMRewriter{llm: llm, modelName: modelName}
+}
+
+// Rewrite asks the LLM for a query that is more likely to find results than the ones tried
+func (r *LLMRewriter) Rewrite(ctx context.Context, tried []string) (string, error) {
+	system, err := prompts.Default().Render(prompts.SearchRewrite, prompts.SearchRewriteData{Tried: tried})
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.llm.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, system.Text),
+		llms.TextParts(llms.ChatMessageTypeHuman, tried[len(tried)-1]),
+	},
+		llms.WithModel(r.modelName),
+		llms.WithTemperature(0.3),
+		llms.WithMaxTokens(50),
+	)
+	if err != nil {
+		return "", fmt.Errorf("rewrite call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("rewrite returned no choices")
+	}
+	query := strings.TrimSpace(strings.SplitN(strings.TrimSpace(resp.Choices[0].Content), "\n", 2)[0])
+	query = strings.Trim(query, "\"'` ")
+	if query == "" {
+		return "", errors.New("rewrite returned an empty query")
+	}
+	return query, nil
+}
+
+// Retry retries searches that fail or find nothing. Failed searches are retried with the
+// same query; searches that find nothing are retried with a query from the rewriter.
+// Every attempt is logged.
+type Retry struct {
+	provider SearchProvider
+	rewriter QueryRewriter
+	config   RetryConfig
+	logger   *logging.Logger
+}
+
+// NewRetry wraps provider with retries. A nil rewriter only retries failed searches.
+func NewRetry(provider SearchProvider, rewriter QueryRewriter, config RetryConfig, logger *logging.Logger) *Retry {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &Retry{provider: provider, rewriter: rewriter, config: config, logger: logger}
+}
+
+// Name returns the name of the wrapped provider
+func (r *Retry) Name() string {
+	return r.provider.Name()
+}
+
+// Search searches until results are found or the attempts run out. It fails only when the
+// last attempt failed; finding nothing returns no results and no error.
+func (r *Retry) Search(ctx context.Context, query string) ([]Result, error) {
+	backoff := r.config.Backoff()
+	tried := []string{query}
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		results, err := r.provider.Search(ctx, query)
+		attrs := []any{
+			"provider", r.provider.Name(),
+			"query", query,
+			"attempt", attempt,
+			"results", len(results),
+			"duration", time.Since(start),
+		}
+		switch {
+		case err != nil:
+			metrics.WebSearchAttemptsTotal.WithLabelValues("error").Inc()
+			r.logger.Warn("web search attempt", append(attrs, "status", "error", "error", err.Error())...)
+		case len(results) == 0:
+			metrics.WebSearchAttemptsTotal.WithLabelValues("empty").Inc()
+			r.logger.Info("web search attempt", append(attrs, "status", "empty")...)
+		default:
+			metrics.WebSearchAttemptsTotal.WithLabelValues("success").Inc()
+			r.logger.Info("web search attempt", append(attrs, "status", "success")...)
+			return results, nil
+		}
+		if attempt >= r.config.Attempts() || ctx.Err() != nil {
+			return nil, err
+		}
+
+		if err == nil {
+			if r.rewriter == nil {
+				return nil, nil
+			}
+			next, rerr := r.rewriter.Rewrite(ctx, tried)
+			if rerr != nil {
+				metrics.WebSearchAttemptsTotal.WithLabelValues("rewrite_failed").Inc()
+				r.logger.Warn("failed to rewrite search query", "query", query, "error", rerr.Error())
+				return nil, nil
+			}
+			if containsQuery(tried, next) {
+				r.logger.Info("rewritten search query was already tried", "query", next)
+				return nil, nil
+			}
+			r.logger.Info("rewrote search query", "from", query, "to", next)
+			query = next
+			tried = append(tried, next)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func containsQuery(queries []string, query string) bool {
+	for _, q := range queries {
+		if normalizeQuery(q) == normalizeQuery(query) {
+			return true
+		}
+	}
+	return false
+}
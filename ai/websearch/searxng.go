@@ -0,0 +1,116 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultSearchTimeout bounds one search request
+const defaultSearchTimeout = 10 * time.Second
+
+// SearXNG searches a self-hosted SearXNG instance through its JSON API. The instance
+// must allow the json format in search.formats of its settings.yml.
+type SearXNG struct {
+	name       string
+	baseURL    string
+	categories string
+	language   string
+	httpClient *http.Client
+}
+
+// NewSearXNG creates a SearXNG provider for the instance at baseURL. Categories and
+// language are optional, e.g. "general" and "en".
+func NewSearXNG(name, baseURL, categories, language string, timeout time.Duration) (*SearXNG, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("searxng needs a url")
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid searxng url: %w", err)
+	}
+	if name == "" {
+		name = "searxng"
+	}
+	if timeout <= 0 {
+		timeout = defaultSearchTimeout
+	}
+	return &SearXNG{
+		name:       name,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		categories: categories,
+		language:   language,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name returns the provider's configured name
+func (s *SearXNG) Name() string {
+	return s.name
+}
+
+// searxngResponse is the part of a SearXNG JSON response the provider reads
+type searxngResponse struct {
+	Results []struct {
+		Title   string `json:"title"`
+		URL     string `json:"url"`
+		Content string `json:"content"`
+	} `json:"results"`
+	Answers []string `json:"answers"`
+}
+
+// Search returns SearXNG's direct answers followed by its results
+func (s *SearXNG) Search(ctx context.Context, query string) ([]Result, error) {
+	if query == "" {
+		return nil, fmt.Errorf("query cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "json")
+	if s.categories != "" {
+		params.Set("categories", s.categories)
+	}
+	if s.language != "" {
+		params.Set("language", s.language)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "twitch-llm-bot/1.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("searxng returned status %d", resp.StatusCode)
+	}
+
+	var body searxngResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse searxng response: %w", err)
+	}
+
+	var results []Result
+	for _, answer := range body.Answers {
+		if answer != "" {
+			results = append(results, Result{Snippet: answer})
+		}
+	}
+	for _, r := range body.Results {
+		if r.URL == "" {
+			continue
+		}
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return results, nil
+}
@@ -0,0 +1,74 @@
+// Package websearch is Pedro's web search. Search engines sit behind the SearchProvider
+// interface and their results are normalized to a title, URL and snippet. Providers are
+// chosen by config and tried in order, falling back to the next one when a provider
+// fails or finds nothing.
+package websearch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Result is one normalized search result
+type Result struct {
+	Title   string `json:"title" yaml:"title"`
+	URL     string `json:"url" yaml:"url"`
+	Snippet string `json:"snippet" yaml:"snippet"`
+}
+
+// SearchProvider is a search engine
+type SearchProvider interface {
+	// Name identifies the provider in logs and metrics
+	Name() string
+	// Search returns the results for query, best first. No results is not an error.
+	Search(ctx context.Context, query string) ([]Result, error)
+}
+
+// maxSnippetLength keeps one result from crowding out the rest of the prompt
+const maxSnippetLength = 300
+
+// Format writes results as a list for the LLM. It returns "" when there are none.
+func Format(results []Result) string {
+	var b strings.Builder
+	for _, result := range results {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("- ")
+		if result.Title != "" {
+			b.WriteString(result.Title)
+			if result.Snippet != "" {
+				b.WriteString(": ")
+			}
+		}
+		b.WriteString(truncate(result.Snippet, maxSnippetLength))
+		if result.URL != "" {
+			fmt.Fprintf(&b, " (%s)", result.URL)
+		}
+	}
+	return b.String()
+}
+
+// truncate cuts text to at most n bytes at a word boundary
+func truncate(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= n {
+		return text
+	}
+	cut := strings.LastIndex(text[:n], " ")
+	if cut <= 0 {
+		// No space to cut at, so back off to the start of a rune
+		cut = n
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+	}
+	return text[:cut] + "..."
+}
+
+// normalizeQuery makes queries that differ only in case and spacing equal
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
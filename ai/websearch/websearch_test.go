@@ -0,0 +1,607 @@
+package websearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Soypete/twitch-llm-bot/database"
+	"github.com/Soypete/twitch-llm-bot/duckduckgo"
+	"github.com/Soypete/twitch-llm-bot/internal/llmtest"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProvider returns fixed results or an error and counts its searches
+type stubProvider struct {
+	name    string
+	results []Result
+	err     error
+	calls   int
+}
+
+func (s *stubProvider) Name() string {
+	return s.name
+}
+
+func (s *stubProvider) Search(_ context.Context, _ string) ([]Result, error) {
+	s.calls++
+	return s.results, s.err
+}
+
+func TestFallback_Search(t *testing.T) {
+	found := []Result{{Title: "Go", URL: "https://go.dev", Snippet: "The Go programming language"}}
+	failed := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		providers []*stubProvider
+		want      []Result
+		wantErr   bool
+		wantCalls []int
+	}{
+		{
+			name:      "first provider answers",
+			providers: []*stubProvider{{name: "a", results: found}, {name: "b"}},
+			want:      found,
+			wantCalls: []int{1, 0},
+		},
+		{
+			name:      "empty results fall back",
+			providers: []*stubProvider{{name: "a"}, {name: "b", results: found}},
+			want:      found,
+			wantCalls: []int{1, 1},
+		},
+		{
+			name:      "errors fall back",
+			providers: []*stubProvider{{name: "a", err: failed}, {name: "b", results: found}},
+			want:      found,
+			wantCalls: []int{1, 1},
+		},
+		{
+			name:      "nothing found anywhere is not an error",
+			providers: []*stubProvider{{name: "a", err: failed}, {name: "b"}},
+			wantCalls: []int{1, 1},
+		},
+		{
+			name:      "every provider failing is an error",
+			providers: []*stubProvider{{name: "a", err: failed}, {name: "b", err: failed}},
+			wantErr:   true,
+			wantCalls: []int{1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var providers []SearchProvider
+			for _, p := range tt.providers {
+				providers = append(providers, p)
+			}
+			fallback, err := NewFallback(providers, nil)
+			require.NoError(t, err)
+
+			got, err := fallback.Search(context.Background(), "golang")
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "b: connection refused")
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+			for i, p := range tt.providers {
+				assert.Equal(t, tt.wantCalls[i], p.calls, "calls to %s", p.name)
+			}
+		})
+	}
+}
+
+func TestSearXNG_Search(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/search", r.URL.Path)
+		assert.Equal(t, "golang generics", r.URL.Query().Get("q"))
+		assert.Equal(t, "json", r.URL.Query().Get("format"))
+		assert.Equal(t, "general", r.URL.Query().Get("categories"))
+		if r.URL.Query().Get("q") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"query":"golang generics","answers":["Generics arrived in Go 1.18"],"results":[
+			{"title":"Tutorial: Getting started with generics","url":"https://go.dev/doc/tutorial/generics","content":"This tutorial introduces the basics of generics in Go.","engine":"google"},
+			{"title":"no url","url":"","content":"dropped"}]}`))
+	}))
+	defer server.Close()
+
+	searxng, err := NewSearXNG("", server.URL+"/", "general", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "searxng", searxng.Name())
+
+	got, err := searxng.Search(context.Background(), "golang generics")
+	require.NoError(t, err)
+	assert.Equal(t, []Result{
+		{Snippet: "Generics arrived in Go 1.18"},
+		{Title: "Tutorial: Getting started with generics", URL: "https://go.dev/doc/tutorial/generics", Snippet: "This tutorial introduces the basics of generics in Go."},
+	}, got)
+
+	_, err = NewSearXNG("", "", "", "", 0)
+	assert.Error(t, err)
+}
+
+func TestDuckDuckGo_Search(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"Heading": "Go (programming language)",
+			"Abstract": "Go is a statically typed, compiled language.",
+			"AbstractURL": "https://en.wikipedia.org/wiki/Go_(programming_language)",
+			"RelatedTopics": [
+				{"FirstURL": "https://duckduckgo.com/Gopher", "Result": "<a href=\"https://duckduckgo.com/Gopher\">Gopher</a> - The Go mascot.", "Text": "Gopher - The Go mascot."},
+				{"FirstURL": "https://duckduckgo.com/x", "Result": "", "Text": ""}
+			]}`))
+	}))
+	defer server.Close()
+
+	ddg := NewDuckDuckGo(&duckduckgo.Client{BaseURL: server.URL, HTTPClient: server.Client()})
+	got, err := ddg.Search(context.Background(), "golang")
+	require.NoError(t, err)
+	assert.Equal(t, []Result{
+		{Title: "Go (programming language)", URL: "https://en.wikipedia.org/wiki/Go_(programming_language)", Snippet: "Go is a statically typed, compiled language."},
+		{Title: "Gopher", URL: "https://duckduckgo.com/Gopher", Snippet: "The Go mascot."},
+	}, got)
+}
+
+func TestFixture_Search(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+"latest go release":
+  - title: Go 1.26 is released
+    url: https://go.dev/blog/go1.26
+    snippet: Go 1.26 is out now.
+"*":
+  - snippet: Nothing interesting happened.
+`), 0o600))
+
+	fixture, err := LoadFixture("recorded", path)
+	require.NoError(t, err)
+	assert.Equal(t, "recorded", fixture.Name())
+
+	got, err := fixture.Search(context.Background(), "  Latest   Go release ")
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{Title: "Go 1.26 is released", URL: "https://go.dev/blog/go1.26", Snippet: "Go 1.26 is out now."}}, got)
+
+	got, err = fixture.Search(context.Background(), "anything else")
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{Snippet: "Nothing interesting happened."}}, got)
+}
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		yaml      string
+		wantNames []string
+		wantErr   string
+	}{
+		{
+			name: "providers in order",
+			yaml: `
+providers:
+  - type: searxng
+    url: http://searxng:8080
+    categories: general
+  - name: ddg
+    type: duckduckgo
+    timeout_seconds: 5
+`,
+			wantNames: []string{"searxng", "ddg"},
+		},
+		{name: "no providers", yaml: "providers: []", wantErr: "at least one provider"},
+		{name: "unknown type", yaml: "providers: [{type: bing}]", wantErr: `unknown type "bing"`},
+		{name: "searxng without url", yaml: "providers: [{type: searxng}]", wantErr: "needs a url"},
+		{name: "fixture without path", yaml: "providers: [{type: fixture}]", wantErr: "needs a path"},
+		{name: "duplicate names", yaml: "providers: [{type: duckduckgo}, {type: duckduckgo}]", wantErr: "used twice"},
+		{name: "semantic cache without model", yaml: "providers: [{type: duckduckgo}]\ncache: {enabled: true, semantic: true}", wantErr: "embedding_model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "search.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.yaml), 0o600))
+
+			config, err := LoadConfig(path)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			fallback, err := New(config, nil)
+			require.NoError(t, err)
+			var names []string
+			for _, p := range fallback.Providers() {
+				names = append(names, p.Name())
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	assert.Empty(t, Format(nil))
+	got := Format([]Result{
+		{Title: "Go", URL: "https://go.dev", Snippet: "The Go\nprogramming   language"},
+		{Snippet: "Generics arrived in Go 1.18"},
+	})
+	assert.Equal(t, "- Go: The Go programming language (https://go.dev)\n- Generics arrived in Go 1.18", got)
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		n    int
+		want string
+	}{
+		{name: "short", text: "hello world", n: 20, want: "hello world"},
+		{name: "word boundary", text: "hello wide world", n: 12, want: "hello wide..."},
+		{name: "no space", text: "abcdefgh", n: 4, want: "abcd..."},
+		{name: "no space mid rune", text: "ab日本語", n: 4, want: "ab..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.text, tt.n)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, utf8.ValidString(got))
+		})
+	}
+}
+
+// queryProvider answers known queries, failing its first `fails` searches
+type queryProvider struct {
+	results map[string][]Result
+	fails   int
+	queries []string
+}
+
+func (q *queryProvider) Name() string {
+	return "query"
+}
+
+func (q *queryProvider) Search(_ context.Context, query string) ([]Result, error) {
+	q.queries = append(q.queries, query)
+	if len(q.queries) <= q.fails {
+		return nil, errors.New("connection refused")
+	}
+	return q.results[query], nil
+}
+
+// stubRewriter rewrites queries from a map and records what it was asked
+type stubRewriter struct {
+	rewrites map[string]string
+	tried    [][]string
+}
+
+func (s *stubRewriter) Rewrite(_ context.Context, tried []string) (string, error) {
+	s.tried = append(s.tried, tried)
+	next, ok := s.rewrites[tried[len(tried)-1]]
+	if !ok {
+		return "", errors.New("no rewrite")
+	}
+	return next, nil
+}
+
+func TestRetry_Search(t *testing.T) {
+	found := []Result{{Title: "Go 1.26", URL: "https://go.dev/blog/go1.26"}}
+	config := RetryConfig{MaxAttempts: 3, BackoffMillis: 1}
+
+	tests := []struct {
+		name        string
+		provider    *queryProvider
+		rewrites    map[string]string
+		want        []Result
+		wantErr     bool
+		wantQueries []string
+	}{
+		{
+			name:        "found first time",
+			provider:    &queryProvider{results: map[string][]Result{"go release": found}},
+			want:        found,
+			wantQueries: []string{"go release"},
+		},
+		{
+			name:        "empty results are retried with a rewritten query",
+			provider:    &queryProvider{results: map[string][]Result{"go 1.26 release": found}},
+			rewrites:    map[string]string{"pedro whats the new go": "go release", "go release": "go 1.26 release"},
+			want:        found,
+			wantQueries: []string{"pedro whats the new go", "go release", "go 1.26 release"},
+		},
+		{
+			name:        "failures are retried with the same query",
+			provider:    &queryProvider{results: map[string][]Result{"go release": found}, fails: 2},
+			want:        found,
+			wantQueries: []string{"go release", "go release", "go release"},
+		},
+		{
+			name:        "failing every attempt is an error",
+			provider:    &queryProvider{fails: 3},
+			wantErr:     true,
+			wantQueries: []string{"go release", "go release", "go release"},
+		},
+		{
+			name:        "attempts run out",
+			provider:    &queryProvider{},
+			rewrites:    map[string]string{"go release": "golang release", "golang release": "go new version"},
+			wantQueries: []string{"go release", "golang release", "go new version"},
+		},
+		{
+			name:        "a query already tried is not searched again",
+			provider:    &queryProvider{},
+			rewrites:    map[string]string{"go release": "Go  Release"},
+			wantQueries: []string{"go release"},
+		},
+		{
+			name:        "a failed rewrite gives up",
+			provider:    &queryProvider{},
+			wantQueries: []string{"go release"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := "go release"
+			if len(tt.wantQueries) > 0 {
+				query = tt.wantQueries[0]
+			}
+			retry := NewRetry(tt.provider, &stubRewriter{rewrites: tt.rewrites}, config, nil)
+			assert.Equal(t, "query", retry.Name())
+
+			got, err := retry.Search(context.Background(), query)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantQueries, tt.provider.queries)
+		})
+	}
+}
+
+func TestRetry_SearchRewriterSeesTriedQueries(t *testing.T) {
+	provider := &queryProvider{}
+	rewriter := &stubRewriter{rewrites: map[string]string{"a": "b", "b": "c"}}
+	_, err := NewRetry(provider, rewriter, RetryConfig{MaxAttempts: 3, BackoffMillis: 1}, nil).Search(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a"}, {"a", "b"}}, rewriter.tried)
+}
+
+func TestLLMRewriter_Rewrite(t *testing.T) {
+	server := llmtest.NewServer(t)
+	server.Reply(llmtest.Response{Content: "\"go 1.26 release notes\"\n"})
+	rewriter := NewLLMRewriter(server.Client(t, "test-model"), "test-model")
+
+	got, err := rewriter.Rewrite(context.Background(), []string{"pedro whats new in go"})
+	require.NoError(t, err)
+	assert.Equal(t, "go 1.26 release notes", got)
+	require.Len(t, server.Requests(), 1)
+	assert.Equal(t, "pedro whats new in go", server.Requests()[0].LastUserMessage())
+
+	server.Reply(llmtest.Response{Content: "  "})
+	_, err = rewriter.Rewrite(context.Background(), []string{"pedro whats new in go"})
+	assert.Error(t, err)
+}
+
+// memoryCacheStore keeps cached searches in a map
+type memoryCacheStore struct {
+	entries map[string]types.SearchCacheEntry
+}
+
+func (m *memoryCacheStore) GetSearchCache(_ context.Context, key string, since time.Time) (*types.SearchCacheEntry, error) {
+	entry, ok := m.entries[key]
+	if !ok || entry.CreatedAt.Before(since) {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (m *memoryCacheStore) FindSimilarSearchCache(_ context.Context, query types.SearchCacheQuery) (*types.SearchCacheEntry, error) {
+	for _, entry := range m.entries {
+		if entry.EmbeddingModel == query.Model && !entry.CreatedAt.Before(query.Since) && cosineSimilarity(query.Embedding, entry.Embedding) >= query.MinSimilarity {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryCacheStore) SaveSearchCache(_ context.Context, entry types.SearchCacheEntry) error {
+	entry.CreatedAt = time.Now()
+	m.entries[entry.Key] = entry
+	return nil
+}
+
+func (m *memoryCacheStore) PurgeSearchCache(_ context.Context, key string) (int64, error) {
+	if key == "" {
+		n := len(m.entries)
+		m.entries = map[string]types.SearchCacheEntry{}
+		return int64(n), nil
+	}
+	if _, ok := m.entries[key]; !ok {
+		return 0, nil
+	}
+	delete(m.entries, key)
+	return 1, nil
+}
+
+func (m *memoryCacheStore) PurgeSimilarSearchCache(_ context.Context, query types.SearchCacheQuery) (int64, error) {
+	var n int64
+	for key, entry := range m.entries {
+		if entry.EmbeddingModel == query.Model && cosineSimilarity(query.Embedding, entry.Embedding) >= query.MinSimilarity {
+			delete(m.entries, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// topicEmbedder embeds queries about go releases close together and everything else apart
+type topicEmbedder struct{}
+
+func (topicEmbedder) GenerateEmbedding(_ context.Context, text string) ([]float32, error) {
+	if strings.Contains(strings.ToLower(text), "release") {
+		return []float32{1, 0.05, 0}, nil
+	}
+	return []float32{0, 0, 1}, nil
+}
+
+func TestCache_Search(t *testing.T) {
+	release := []Result{{Title: "Go 1.26", URL: "https://go.dev/blog/go1.26"}}
+	weather := []Result{{Snippet: "sunny"}}
+	newProvider := func() *queryProvider {
+		return &queryProvider{results: map[string][]Result{
+			"latest go release": release,
+			"weather in provo":  weather,
+		}}
+	}
+
+	t.Run("repeated queries are served from memory", func(t *testing.T) {
+		provider := newProvider()
+		search := NewCache(CacheConfig{Enabled: true}, nil, nil, nil).Wrap(provider)
+		for _, query := range []string{"latest go release", "  Latest Go  release"} {
+			got, err := search.Search(context.Background(), query)
+			require.NoError(t, err)
+			assert.Equal(t, release, got)
+		}
+		assert.Equal(t, []string{"latest go release"}, provider.queries)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		provider := newProvider()
+		cache := NewCache(CacheConfig{Enabled: true, TTLSeconds: 60}, nil, nil, nil)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+		search := cache.Wrap(provider)
+
+		_, _ = search.Search(context.Background(), "latest go release")
+		now = now.Add(2 * time.Minute)
+		_, _ = search.Search(context.Background(), "latest go release")
+		assert.Len(t, provider.queries, 2)
+	})
+
+	t.Run("least recently used entries are evicted", func(t *testing.T) {
+		provider := newProvider()
+		cache := NewCache(CacheConfig{Enabled: true, Size: 1}, nil, nil, nil)
+		search := cache.Wrap(provider)
+		for _, query := range []string{"latest go release", "weather in provo", "latest go release"} {
+			_, _ = search.Search(context.Background(), query)
+		}
+		assert.Len(t, provider.queries, 3)
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("empty results and errors are not cached", func(t *testing.T) {
+		provider := &queryProvider{fails: 1}
+		cache := NewCache(CacheConfig{Enabled: true}, nil, nil, nil)
+		search := cache.Wrap(provider)
+		_, err := search.Search(context.Background(), "nothing")
+		require.Error(t, err)
+		_, err = search.Search(context.Background(), "nothing")
+		require.NoError(t, err)
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("similar queries share results", func(t *testing.T) {
+		provider := newProvider()
+		search := NewCache(CacheConfig{Enabled: true, Semantic: true}, nil, topicEmbedder{}, nil).Wrap(provider)
+		_, _ = search.Search(context.Background(), "latest go release")
+		got, err := search.Search(context.Background(), "what is the newest go release")
+		require.NoError(t, err)
+		assert.Equal(t, release, got)
+		_, _ = search.Search(context.Background(), "weather in provo")
+		assert.Equal(t, []string{"latest go release", "weather in provo"}, provider.queries)
+	})
+
+	t.Run("postgres outlives the process", func(t *testing.T) {
+		store := &memoryCacheStore{entries: map[string]types.SearchCacheEntry{}}
+		config := CacheConfig{Enabled: true, Postgres: true, Semantic: true, EmbeddingModel: "test-embed"}
+		_, _ = NewCache(config, store, topicEmbedder{}, nil).Wrap(newProvider()).Search(context.Background(), "latest go release")
+		require.Contains(t, store.entries, "latest go release")
+		assert.Equal(t, "test-embed", store.entries["latest go release"].EmbeddingModel)
+
+		provider := newProvider()
+		search := NewCache(config, store, topicEmbedder{}, nil).Wrap(provider)
+		for _, query := range []string{"latest go release", "go release notes"} {
+			got, err := search.Search(context.Background(), query)
+			require.NoError(t, err)
+			assert.Equal(t, release, got)
+		}
+		assert.Empty(t, provider.queries)
+	})
+}
+
+func TestCache_Purge(t *testing.T) {
+	store := &memoryCacheStore{entries: map[string]types.SearchCacheEntry{}}
+	provider := &queryProvider{results: map[string][]Result{
+		"latest go release": {{Snippet: "Go 1.26"}},
+		"weather in provo":  {{Snippet: "sunny"}},
+	}}
+	cache := NewCache(CacheConfig{Enabled: true, Postgres: true}, store, nil, nil)
+	search := cache.Wrap(provider)
+	_, _ = search.Search(context.Background(), "latest go release")
+	_, _ = search.Search(context.Background(), "weather in provo")
+
+	purged, err := cache.Purge(context.Background(), "Latest Go Release")
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Equal(t, 1, cache.Len())
+	assert.NotContains(t, store.entries, "latest go release")
+
+	purged, err = cache.Purge(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Equal(t, 0, cache.Len())
+	assert.Empty(t, store.entries)
+}
+
+func TestCache_PurgeSimilar(t *testing.T) {
+	release := []Result{{Snippet: "Go 1.26"}}
+	tests := []struct {
+		name     string
+		postgres bool
+	}{
+		{name: "memory"},
+		{name: "postgres", postgres: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryCacheStore{entries: map[string]types.SearchCacheEntry{}}
+			config := CacheConfig{Enabled: true, Semantic: true, EmbeddingModel: "test-embed", Postgres: tt.postgres}
+			var cacheStore database.SearchCacheStore
+			if tt.postgres {
+				cacheStore = store
+			}
+			provider := &queryProvider{results: map[string][]Result{"latest go release": release, "newest go release": release}}
+			search := NewCache(config, cacheStore, topicEmbedder{}, nil).Wrap(provider)
+			_, _ = search.Search(context.Background(), "latest go release")
+
+			// A new cache only has Postgres, where the purge must also find similar rows
+			cache := NewCache(config, cacheStore, topicEmbedder{}, nil)
+			if !tt.postgres {
+				cache = search.(*cached).cache
+			}
+			purged, err := cache.Purge(context.Background(), "newest go release")
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+
+			// The purged query and queries worded like it miss the cache
+			search = cache.Wrap(provider)
+			_, err = search.Search(context.Background(), "newest go release")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"latest go release", "newest go release"}, provider.queries)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
+	"github.com/Soypete/twitch-llm-bot/ai/provider"
+	"github.com/Soypete/twitch-llm-bot/ai/safety"
+	"github.com/Soypete/twitch-llm-bot/ai/twitchchat"
+	"github.com/Soypete/twitch-llm-bot/ai/websearch"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	twitchirc "github.com/Soypete/twitch-llm-bot/twitch"
+	"github.com/Soypete/twitch-llm-bot/twitch/replay"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// replay feeds a recorded chat transcript through Pedro without Twitch or Postgres and
+// writes a JSON report of his replies, tool calls and moderation decisions.
+func main() {
+	var transcriptPath string
+	var outPath string
+	var model string
+	var logLevel string
+	var llmConfig string
+	var channelsConfig string
+	var channel string
+	var streamConfig string
+	var modConfigPath string
+	var commandsConfig string
+	var promptsDir string
+	var persona string
+	var safetyConfig string
+	var searchResults string
+	var maxToolSteps int
+
+	flag.StringVar(&transcriptPath, "transcript", "", "Path to the chat transcript to replay (.jsonl, or .csv exported from twitch_chat)")
+	flag.StringVar(&outPath, "out", "", "Path to write the JSON report to. Defaults to stdout")
+	flag.StringVar(&model, "model", os.Getenv("MODEL"), "The model to use for the LLM")
+	flag.StringVar(&logLevel, "errorLevel", "warn", "Log level (debug, info, warn, error)")
+	flag.StringVar(&llmConfig, "llmConfig", "", "Path to LLM backends config file. Defaults to LLAMA_CPP_PATH with LLM_FALLBACK_PATHS")
+	flag.StringVar(&channelsConfig, "channelsConfig", "", "Path to channels config file, for per-channel stream configs, personas and commands")
+	flag.StringVar(&channel, "channel", "soypetetech", "Channel to replay into when there is no channels config")
+	flag.StringVar(&streamConfig, "streamConfig", "", "Path to stream context config file")
+	flag.StringVar(&modConfigPath, "modConfig", "", "Path to moderation config file. Moderation is replayed in dry-run mode when set")
+	flag.StringVar(&commandsConfig, "commandsConfig", "", "Path to chat commands config file")
+	flag.StringVar(&promptsDir, "promptsDir", "", "Directory of prompt templates and personas.yaml that replace the built-in ones")
+	flag.StringVar(&persona, "persona", "", "Prompt persona Pedro uses in channels without their own")
+	flag.StringVar(&safetyConfig, "safetyConfig", "", "Path to output safety config file. Defaults to the built-in safety config")
+	flag.StringVar(&searchResults, "searchResults", "", "Path to a web search fixture (YAML of query to results). Other queries find nothing")
+	flag.IntVar(&maxToolSteps, "maxToolSteps", agent.DefaultMaxSteps, "How many LLM calls Pedro may make (calling tools in between) before he has to answer")
+	flag.Parse()
+
+	logger := logging.NewLogger(logging.LogLevel(logLevel), os.Stderr)
+	if transcriptPath == "" {
+		logger.Error("-transcript is required")
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	wg := &sync.WaitGroup{}
+
+	transcript, err := replay.LoadTranscript(transcriptPath)
+	if err != nil {
+		logger.Error("failed to load transcript", "error", err.Error())
+		os.Exit(1)
+	}
+
+	_ = os.Setenv("OPENAI_API_KEY", "test")
+	llmBackends := provider.ConfigFromEnv()
+	if llmConfig != "" {
+		llmBackends, err = provider.LoadConfig(llmConfig)
+		if err != nil {
+			logger.Error("failed to load LLM config", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+	llm, err := provider.New(llmBackends, logger)
+	if err != nil {
+		logger.Error("failed to setup LLM provider", "error", err.Error())
+		os.Exit(1)
+	}
+	llm.Start(ctx, wg)
+
+	if promptsDir != "" {
+		promptSet, err := prompts.Load(promptsDir)
+		if err != nil {
+			logger.Error("failed to load prompts", "error", err.Error())
+			os.Exit(1)
+		}
+		prompts.SetDefault(promptSet)
+	}
+
+	// The chat client records every tool call and never searches the real web
+	recorder := replay.NewRecordingLLM(llm)
+	twitchllm, err := twitchchat.SetupWithLLM(recorder, model, streamConfig, logger)
+	if err != nil {
+		logger.Error("failed to setup twitch LLM", "error", err.Error())
+		os.Exit(1)
+	}
+	twitchllm.SetMaxToolSteps(maxToolSteps)
+	if err := twitchllm.SetPersona(persona); err != nil {
+		logger.Error("failed to set persona", "error", err.Error())
+		os.Exit(1)
+	}
+	search := websearch.NewFixture("offline", nil)
+	if searchResults != "" {
+		search, err = websearch.LoadFixture("offline", searchResults)
+		if err != nil {
+			logger.Error("failed to load search results", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+	if err := twitchllm.SetSearchProvider(search); err != nil {
+		logger.Error("failed to set offline web search", "error", err.Error())
+		os.Exit(1)
+	}
+
+	channels := []twitchirc.ChannelConfig{{Name: channel}}
+	if channelsConfig != "" {
+		chConfig, err := twitchirc.LoadChannelsConfig(channelsConfig)
+		if err != nil {
+			logger.Error("failed to load channels config", "error", err.Error())
+			os.Exit(1)
+		}
+		channels = chConfig.Channels
+		for _, ch := range channels {
+			if err := twitchllm.SetChannelStreamConfig(ch.Name, ch.StreamConfig); err != nil {
+				logger.Error("failed to load channel stream config", "channel", ch.Name, "error", err.Error())
+				os.Exit(1)
+			}
+			if err := twitchllm.SetChannelPersona(ch.Name, ch.Persona, ch.Emotes); err != nil {
+				logger.Error("failed to set channel persona", "channel", ch.Name, "error", err.Error())
+				os.Exit(1)
+			}
+			if ch.Conversation != nil {
+				twitchllm.SetChannelConversationConfig(ch.Name, *ch.Conversation)
+			}
+		}
+	}
+
+	config := replay.Config{
+		Channels:  channels,
+		ModelName: model,
+		ModLLM:    llm,
+	}
+	if modConfigPath != "" {
+		config.ModConfig, err = ai.LoadModerationConfig(modConfigPath)
+		if err != nil {
+			logger.Error("failed to load moderation config", "error", err.Error())
+			os.Exit(1)
+		}
+		config.ModConfig.Enabled = true
+	}
+	if commandsConfig != "" {
+		cmdConfig, err := twitchirc.LoadCommandsConfig(commandsConfig)
+		if err != nil {
+			logger.Error("failed to load commands config", "error", err.Error())
+			os.Exit(1)
+		}
+		config.Commands = cmdConfig.Commands
+	}
+	config.Filter, err = setupOutputSafety(safetyConfig, persona, channels, llm, model, logger)
+	if err != nil {
+		logger.Error("failed to setup output safety", "error", err.Error())
+		os.Exit(1)
+	}
+
+	runner, err := replay.New(twitchllm, recorder, config, logger)
+	if err != nil {
+		logger.Error("failed to setup replay", "error", err.Error())
+		os.Exit(1)
+	}
+	report, err := runner.Run(ctx, transcript)
+	if err != nil {
+		logger.Error("replay stopped early", "error", err.Error(), "replayed", len(report.Messages))
+	}
+	report.Transcript = transcriptPath
+
+	out := os.Stdout
+	if outPath != "" {
+		out, err = os.Create(outPath)
+		if err != nil {
+			logger.Error("failed to create report", "error", err.Error())
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+	if err := report.WriteJSON(out); err != nil {
+		logger.Error("failed to write report", "error", err.Error())
+		os.Exit(1)
+	}
+	logger.Info("replay complete",
+		"messages", report.Summary.Messages,
+		"answered", report.Summary.Answered,
+		"toolCalls", report.Summary.ToolCalls,
+		"moderation", report.Summary.ModerationActions,
+	)
+
+	cancel()
+	wg.Wait()
+}
+
+// setupOutputSafety creates the output safety pipeline with each channel's emotes, the
+// same way the twitch bot does
+func setupOutputSafety(configPath, persona string, channels []twitchirc.ChannelConfig, llm llms.Model, model string, logger *logging.Logger) (*safety.Pipeline, error) {
+	config := safety.DefaultConfig()
+	if configPath != "" {
+		var err error
+		config, err = safety.LoadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(config.Emotes.Allowed) == 0 && persona != "" {
+		p, err := prompts.Default().Persona(persona)
+		if err != nil {
+			return nil, err
+		}
+		config.Emotes.Allowed = p.Emotes
+	}
+
+	pipeline, err := safety.New(config, llm, model, logger)
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range channels {
+		emotes := ch.Emotes
+		if len(emotes) == 0 && ch.Persona != "" {
+			p, err := prompts.Default().Persona(ch.Persona)
+			if err != nil {
+				return nil, err
+			}
+			emotes = p.Emotes
+		}
+		pipeline.SetChannelEmotes(ch.Name, emotes)
+	}
+	return pipeline, nil
+}
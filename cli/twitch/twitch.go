@@ -5,17 +5,26 @@ import (
 	"flag"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/ai/chatmemory"
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
+	"github.com/Soypete/twitch-llm-bot/ai/provider"
+	"github.com/Soypete/twitch-llm-bot/ai/safety"
 	"github.com/Soypete/twitch-llm-bot/ai/twitchchat"
+	"github.com/Soypete/twitch-llm-bot/ai/webfetch"
+	"github.com/Soypete/twitch-llm-bot/ai/websearch"
 	database "github.com/Soypete/twitch-llm-bot/database"
 	"github.com/Soypete/twitch-llm-bot/faq"
 	"github.com/Soypete/twitch-llm-bot/internal/mempalace"
 	"github.com/Soypete/twitch-llm-bot/logging"
 	"github.com/Soypete/twitch-llm-bot/metrics"
 	twitchirc "github.com/Soypete/twitch-llm-bot/twitch"
+	"github.com/tmc/langchaingo/llms"
 )
 
 func main() {
@@ -29,6 +38,21 @@ func main() {
 	var enableMemPalace bool
 	var memPalaceActiveDir string
 	var memPalaceArchiveDir string
+	var channelsConfig string
+	var enableEventSub bool
+	var commandsConfig string
+	var commandsFromDB bool
+	var addressThreshold float64
+	var shutdownTimeout time.Duration
+	var maxToolSteps int
+	var llmConfig string
+	var promptsDir string
+	var persona string
+	var safetyConfig string
+	var searchConfig string
+	var fetchConfig string
+	var enableChatMemory bool
+	var chatEmbeddingModel string
 
 	flag.StringVar(&model, "model", os.Getenv("MODEL"), "The model to use for the LLM")
 	flag.StringVar(&logLevel, "errorLevel", "info", "Log level (debug, info, warn, error)")
@@ -40,12 +64,29 @@ func main() {
 	flag.BoolVar(&enableMemPalace, "enableMemPalace", false, "Enable Mem Palace chat history system")
 	flag.StringVar(&memPalaceActiveDir, "memPalaceActiveDir", "/data/palaces/active", "Directory for active Mem Palace sessions")
 	flag.StringVar(&memPalaceArchiveDir, "memPalaceArchiveDir", "/data/palaces/archive", "Directory for archived Mem Palace sessions")
+	flag.StringVar(&channelsConfig, "channelsConfig", "", "Path to channels config file for joining multiple channels (e.g., 'configs/channels/channels.yaml')")
+	flag.BoolVar(&enableEventSub, "enableEventSub", false, "Enable EventSub for follows, subs, raids, cheers and channel point redemptions")
+	flag.StringVar(&commandsConfig, "commandsConfig", "", "Path to chat commands config file (e.g., 'configs/commands/commands.yaml')")
+	flag.BoolVar(&commandsFromDB, "commandsFromDB", false, "Load chat commands from the chat_commands table")
+	flag.DurationVar(&shutdownTimeout, "shutdownTimeout", 30*time.Second, "How long to wait for in-flight replies and queued chat messages on shutdown")
+	flag.IntVar(&maxToolSteps, "maxToolSteps", agent.DefaultMaxSteps, "How many LLM calls Pedro may make (calling tools in between) before he has to answer")
+	flag.StringVar(&llmConfig, "llmConfig", "", "Path to LLM backends config file (e.g., 'configs/llm/backends.yaml'). Defaults to LLAMA_CPP_PATH with LLM_FALLBACK_PATHS")
+	flag.StringVar(&promptsDir, "promptsDir", "", "Directory of prompt templates and personas.yaml that replace the built-in ones")
+	flag.StringVar(&persona, "persona", "", "Prompt persona Pedro uses in channels without their own. Defaults to the personas file's default")
+	flag.StringVar(&safetyConfig, "safetyConfig", "", "Path to output safety config file (e.g., 'configs/safety/default.yaml'). Defaults to the built-in safety config")
+	flag.StringVar(&searchConfig, "searchConfig", "", "Path to web search providers config file (e.g., 'configs/search/providers.yaml'). Defaults to DuckDuckGo")
+	flag.StringVar(&fetchConfig, "fetchConfig", "", "Path to page fetch config file (e.g., 'configs/fetch/default.yaml'). Enables the fetch_page tool for its allowed domains")
+	flag.BoolVar(&enableChatMemory, "enableChatMemory", false, "Embed persisted chat messages and recall similar ones from earlier streams into Pedro's prompt")
+	flag.StringVar(&chatEmbeddingModel, "chatEmbeddingModel", chatmemory.DefaultConfig().Model, "Embedding model used for chat memory (used with -enableChatMemory)")
+	flag.Float64Var(&addressThreshold, "addressThreshold", float64(twitchirc.DefaultAddressThreshold), "Address detector score a message needs before Pedro replies (used with -enableMemPalace)")
 	flag.Parse()
 
 	// Initialize logger
 	logger := logging.NewLogger(logging.LogLevel(logLevel), os.Stdout)
 
-	ctx := context.Background()
+	// Root context for every subsystem, cancelled during shutdown once chat has drained
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	stop := make(chan os.Signal, 1)
 	wg := &sync.WaitGroup{}
 
@@ -66,11 +107,65 @@ func main() {
 	//  we are not actually connecting to openai, but we are using their api spec to connect to our own model via llama.cpp
 	_ = os.Setenv("OPENAI_API_KEY", "test")
 	llmPath := os.Getenv("LLAMA_CPP_PATH")
-	twitchllm, err := twitchchat.SetupWithStreamConfig(llmPath, model, streamConfig, logger)
+	llmBackends := provider.ConfigFromEnv()
+	if llmConfig != "" {
+		llmBackends, err = provider.LoadConfig(llmConfig)
+		if err != nil {
+			logger.Error("failed to load LLM config", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+	// Every LLM and embedding call shares these backends, their breakers and fallback
+	llm, err := provider.New(llmBackends, logger)
+	if err != nil {
+		logger.Error("failed to setup LLM provider", "error", err.Error())
+		os.Exit(1)
+	}
+	llm.Start(ctx, wg)
+
+	if promptsDir != "" {
+		promptSet, err := prompts.Load(promptsDir)
+		if err != nil {
+			logger.Error("failed to load prompts", "error", err.Error())
+			os.Exit(1)
+		}
+		prompts.SetDefault(promptSet)
+		logger.Info("loaded prompts", "path", promptsDir, "personas", promptSet.Personas())
+	}
+
+	twitchllm, err := twitchchat.SetupWithLLM(llm, model, streamConfig, logger)
 	if err != nil {
 		logger.Error("failed to setup twitch LLM", "error", err.Error())
 		os.Exit(1)
 	}
+	twitchllm.SetMaxToolSteps(maxToolSteps)
+	if err := twitchllm.SetPersona(persona); err != nil {
+		logger.Error("failed to set persona", "error", err.Error())
+		os.Exit(1)
+	}
+	if searchConfig != "" {
+		if err := setupWebSearch(twitchllm, searchConfig, db, llm, logger); err != nil {
+			logger.Error("failed to setup web search", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+	if fetchConfig != "" {
+		config, err := webfetch.LoadConfig(fetchConfig)
+		if err != nil {
+			logger.Error("failed to load fetch config", "error", err.Error())
+			os.Exit(1)
+		}
+		fetcher, err := webfetch.New(config, llm, model, logger)
+		if err != nil {
+			logger.Error("failed to setup page fetching", "error", err.Error())
+			os.Exit(1)
+		}
+		if err := twitchllm.RegisterTool(agent.NewFetchPageRegistryTool(fetcher)); err != nil {
+			logger.Error("failed to register fetch_page tool", "error", err.Error())
+			os.Exit(1)
+		}
+		logger.Info("fetch_page tool enabled", "allowedDomains", config.AllowedDomains)
+	}
 
 	// Load moderation config if enabled
 	var modConfig *ai.ModerationConfig
@@ -103,43 +198,107 @@ func main() {
 		)
 	}
 
+	// Load per-channel configuration if provided
+	var channels []twitchirc.ChannelConfig
+	if channelsConfig != "" {
+		chConfig, err := twitchirc.LoadChannelsConfig(channelsConfig)
+		if err != nil {
+			logger.Error("failed to load channels config", "error", err.Error())
+			os.Exit(1)
+		}
+		channels = chConfig.Channels
+		for _, ch := range channels {
+			if err := twitchllm.SetChannelStreamConfig(ch.Name, ch.StreamConfig); err != nil {
+				logger.Error("failed to load channel stream config", "channel", ch.Name, "error", err.Error())
+				os.Exit(1)
+			}
+			if err := twitchllm.SetChannelPersona(ch.Name, ch.Persona, ch.Emotes); err != nil {
+				logger.Error("failed to set channel persona", "channel", ch.Name, "error", err.Error())
+				os.Exit(1)
+			}
+			if ch.Conversation != nil {
+				twitchllm.SetChannelConversationConfig(ch.Name, *ch.Conversation)
+			}
+		}
+		logger.Info("loaded channels config", "path", channelsConfig, "channels", len(channels))
+	}
+
 	var irc *twitchirc.IRC
 	// setup twitch IRC with optional moderation
-	if modConfig != nil && modConfig.Enabled {
+	if len(channels) > 0 {
+		irc, err = twitchirc.SetupTwitchIRCWithChannels(wg, twitchllm, model, db, db, modConfig, channels, logger, "data")
+	} else if modConfig != nil && modConfig.Enabled {
 		irc, err = twitchirc.SetupTwitchIRCWithModeration(wg, twitchllm, model, db, db, modConfig, logger, "data")
 	} else {
 		irc, err = twitchirc.SetupTwitchIRC(wg, twitchllm, model, db, logger, "data")
 	}
 	if err != nil {
 		logger.Error("failed to setup twitch IRC", "error", err.Error())
-		stop <- os.Interrupt
+		os.Exit(1)
+	}
+	irc.SetModerationLLM(llm)
+
+	// Every message Pedro sends passes the output safety pipeline first
+	outputSafety, err := setupOutputSafety(safetyConfig, persona, channels, llm, model, logger)
+	if err != nil {
+		logger.Error("failed to setup output safety", "error", err.Error())
+		os.Exit(1)
 	}
+	irc.GetSender().SetFilter(outputSafety)
 
 	// Setup FAQ service if config is provided
 	if faqConfig != "" {
 		logger.Info("setting up FAQ service", "config", faqConfig)
-		faqService, err := setupFAQService(db, llmPath, model, faqConfig, logger)
+		faqService, err := setupFAQService(db, llm, llmPath, model, faqConfig, logger)
 		if err != nil {
 			logger.Error("failed to setup FAQ service", "error", err.Error())
 			// Continue without FAQ - it's optional
 		} else {
-			// Create FAQ processor and attach to IRC
-			faqProcessor := twitchirc.NewFAQProcessor(faqService, irc.GetAsyncResponseChannel(), logger)
-			irc.SetFAQProcessor(faqProcessor)
+			// Create a FAQ processor for each channel and attach to IRC
+			irc.SetFAQService(faqService)
+			if err := twitchllm.RegisterTool(agent.NewFAQRegistryTool(faqService, irc.FAQCategories())); err != nil {
+				logger.Error("failed to register FAQ lookup tool", "error", err.Error())
+			}
 			logger.Info("FAQ service enabled and attached to Twitch IRC")
 		}
 	}
 
+	// Load `!` chat commands from YAML and/or Postgres
+	if commandsConfig != "" {
+		cmdConfig, err := twitchirc.LoadCommandsConfig(commandsConfig)
+		if err != nil {
+			logger.Error("failed to load commands config", "error", err.Error())
+			os.Exit(1)
+		}
+		if err := irc.LoadCommands(cmdConfig.Commands); err != nil {
+			logger.Error("failed to register commands", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+	if commandsFromDB {
+		dbCommands, err := db.ListChatCommands(ctx)
+		if err != nil {
+			logger.Error("failed to load commands from database", "error", err.Error())
+		} else if err := irc.LoadCommands(dbCommands); err != nil {
+			logger.Error("failed to register commands from database", "error", err.Error())
+		}
+	}
+
 	// Register auth health endpoint
 	server.RegisterAuthHealthHandler(irc.AuthHealthHandler())
 	logger.Debug("auth health endpoint registered at /healthz/auth")
 
+	// Sources behind Pedro's answers, since he cannot link them in chat
+	server.RegisterSourcesHandler(irc.SourcesHandler())
+
 	// Setup Mem Palace if enabled
 	if enableMemPalace {
 		logger.Info("setting up Mem Palace", "activeDir", memPalaceActiveDir, "archiveDir", memPalaceArchiveDir)
 		mpConfig := &mempalace.Config{
 			LLMPath:      llmPath,
 			ModelName:    model,
+			LLM:          llm,
+			Embedder:     llm.Embedder(model),
 			HelixClient:  irc.GetHelixClient(),
 			Logger:       logger,
 			PollInterval: 30,
@@ -154,48 +313,102 @@ func main() {
 				logger.Error("failed to start Mem Palace", "error", err.Error())
 			} else {
 				irc.SetMemPalace(mp)
+				irc.SetAddressThreshold(float32(addressThreshold))
 				logger.Info("Mem Palace enabled and attached to Twitch IRC")
 			}
 		}
 	}
 
-	signal.Notify(stop, os.Interrupt)
+	if enableChatMemory {
+		memory, err := setupChatMemory(ctx, wg, db, llm, chatEmbeddingModel, logger)
+		if err != nil {
+			logger.Error("failed to setup chat memory", "error", err.Error())
+		} else {
+			irc.SetChatMemory(memory)
+			logger.Info("chat memory enabled", "embeddingModel", chatEmbeddingModel)
+		}
+	}
+
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	logger.Info("Press Ctrl+C to exit")
 
 	logger.Info("starting twitch IRC connection")
-	go func() {
-		for {
-			if err := irc.ConnectIRC(ctx, wg); err != nil {
-				logger.Error("failed to connect to twitch IRC", "error", err.Error())
-				if authErr := irc.AuthTwitch(ctx); authErr != nil {
-					logger.Error("re-auth failed", "error", authErr)
-					stop <- os.Interrupt
-					return
-				}
-				continue
+	supervisor := twitchirc.NewSupervisor(irc, wg, twitchirc.DefaultSupervisorConfig(), logger)
+	if enableEventSub {
+		supervisor.OnStarted(func() {
+			if err := irc.StartEventSub(ctx, wg); err != nil {
+				logger.Error("failed to start eventsub", "error", err.Error())
 			}
-			if err := irc.Client.Connect(); err != nil {
-				if strings.Contains(err.Error(), "login authentication failed") {
-					logger.Warn("auth failed, attempting refresh/re-auth")
-					if authErr := irc.AuthTwitch(ctx); authErr != nil {
-						logger.Error("re-auth failed", "error", authErr)
-						stop <- os.Interrupt
-						return
-					}
-					continue
-				}
-				logger.Error("twitch client connection failed", "error", err.Error())
-				stop <- os.Interrupt
-				return
+		})
+	}
+	server.RegisterIRCHealthHandler(supervisor.HealthHandler())
+	go func() {
+		if err := supervisor.Run(ctx); err != nil {
+			logger.Error("twitch IRC supervisor gave up", "error", err.Error())
+			select {
+			case stop <- os.Interrupt:
+			default:
 			}
-			return
 		}
 	}()
-	Shutdown(ctx, wg, irc, stop, logger)
+	<-stop
+	Shutdown(cancel, wg, irc, db, server, shutdownTimeout, logger)
+}
+
+// setupChatMemory starts embedding persisted chat messages through the shared provider
+func setupChatMemory(ctx context.Context, wg *sync.WaitGroup, db *database.Postgres, llm *provider.Provider, model string, logger *logging.Logger) (*chatmemory.Memory, error) {
+	embedder, err := ai.NewEmbeddingGeneratorFromClient(llm.Embedder(model))
+	if err != nil {
+		return nil, err
+	}
+
+	config := chatmemory.DefaultConfig()
+	config.Model = model
+	memory, err := chatmemory.New(embedder, db, config, logger)
+	if err != nil {
+		return nil, err
+	}
+	memory.Start(ctx, wg)
+	return memory, nil
+}
+
+// setupWebSearch gives Pedro's web_search tool the configured providers, retries and cache
+func setupWebSearch(twitchllm *twitchchat.Client, configPath string, db *database.Postgres, llm *provider.Provider, logger *logging.Logger) error {
+	config, err := websearch.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	search, err := websearch.New(config, logger)
+	if err != nil {
+		return err
+	}
+	if err := twitchllm.SetSearchProvider(search); err != nil {
+		return err
+	}
+	if err := twitchllm.SetSearchRetry(config.Retry); err != nil {
+		return err
+	}
+	if !config.Cache.Enabled {
+		return nil
+	}
+
+	var store database.SearchCacheStore
+	if config.Cache.Postgres {
+		store = db
+	}
+	var embedder websearch.Embedder
+	if config.Cache.Semantic {
+		embedder, err = ai.NewEmbeddingGeneratorFromClient(llm.Embedder(config.Cache.EmbeddingModel))
+		if err != nil {
+			return err
+		}
+	}
+	logger.Info("web search cache enabled", "ttl", config.Cache.TTL(), "postgres", config.Cache.Postgres, "semantic", config.Cache.Semantic)
+	return twitchllm.SetSearchCache(websearch.NewCache(config.Cache, store, embedder, logger))
 }
 
 // setupFAQService initializes the FAQ service from a config file
-func setupFAQService(db *database.Postgres, llmPath, chatModel, configPath string, logger *logging.Logger) (*faq.Service, error) {
+func setupFAQService(db *database.Postgres, llm *provider.Provider, llmPath, chatModel, configPath string, logger *logging.Logger) (*faq.Service, error) {
 	// Load FAQ config
 	config, err := faq.LoadConfig(configPath)
 	if err != nil {
@@ -211,6 +424,8 @@ func setupFAQService(db *database.Postgres, llmPath, chatModel, configPath strin
 	// Create FAQ service
 	serviceConfig := faq.ServiceConfig{
 		LLMPath:             llmPath,
+		LLM:                 llm,
+		Embedder:            llm.Embedder(config.EmbeddingModel),
 		EmbeddingModel:      config.EmbeddingModel,
 		ChatModel:           chatModel,
 		SimilarityThreshold: config.SimilarityThreshold,
@@ -221,28 +436,100 @@ func setupFAQService(db *database.Postgres, llmPath, chatModel, configPath strin
 	return faq.NewService(db.DB(), serviceConfig)
 }
 
-// Shutdown cancels the context and logs a message.
-// TODO: this needs to be handled with an os signal
-func Shutdown(ctx context.Context, wg *sync.WaitGroup, irc *twitchirc.IRC, stop chan os.Signal, logger *logging.Logger,
-) {
-	<-stop
-	ctx.Done()
+// Shutdown tears the bot down in order: stop taking new chat messages, let in-flight
+// replies and queued chat messages drain within timeout, flush palace sessions, stop the
+// background subsystems, and finally disconnect from Twitch and Postgres.
+func Shutdown(cancel context.CancelFunc, wg *sync.WaitGroup, irc *twitchirc.IRC, db *database.Postgres, server *metrics.Server, timeout time.Duration, logger *logging.Logger) {
+	logger.Info("shutting down", "timeout", timeout)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), timeout)
+	defer shutdownCancel()
 
 	if irc != nil {
-		err := irc.Client.Disconnect()
+		irc.StopIntake()
+		if err := irc.Drain(shutdownCtx); err != nil {
+			logger.Warn("chat pipeline did not drain", "error", err.Error())
+		}
+
+		irc.EndPalaceSessions()
+		if mp := irc.GetMemPalace(); mp != nil {
+			if err := mp.Stop(); err != nil {
+				logger.Error("error stopping Mem Palace", "error", err.Error())
+			}
+		}
+	}
+
+	// Stop the broker, moderation monitors, outbound sender, EventSub and Mem Palace loops
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Info("background subsystems stopped")
+	case <-shutdownCtx.Done():
+		logger.Warn("shutdown deadline reached before background subsystems stopped")
+	}
+
+	if irc != nil {
+		irc.Disconnect()
+	}
+	if db != nil {
+		db.Close()
+	}
+	if server != nil {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("error shutting down metrics server", "error", err.Error())
+		}
+	}
+	logger.Info("shutdown complete")
+}
+
+// setupOutputSafety creates the output safety pipeline with each channel's emotes
+func setupOutputSafety(configPath, persona string, channels []twitchirc.ChannelConfig, llm llms.Model, model string, logger *logging.Logger) (*safety.Pipeline, error) {
+	config := safety.DefaultConfig()
+	if configPath != "" {
+		var err error
+		config, err = safety.LoadConfig(configPath)
 		if err != nil {
-			logger.Error("error disconnecting twitch client", "error", err.Error())
+			return nil, err
 		}
+		logger.Info("loaded output safety config", "path", configPath)
+	}
+
+	// Without an emote list Pedro may use his persona's emotes
+	if len(config.Emotes.Allowed) == 0 && persona != "" {
+		p, err := prompts.Default().Persona(persona)
+		if err != nil {
+			return nil, err
+		}
+		config.Emotes.Allowed = p.Emotes
+	}
 
-		// Cleanup palace sessions
-		if irc.GetSessionRegistry() != nil {
-			if err := irc.GetSessionRegistry().EndAll(); err != nil {
-				logger.Error("error ending palace sessions", "error", err.Error())
+	pipeline, err := safety.New(config, llm, model, logger)
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range channels {
+		emotes := ch.Emotes
+		if len(emotes) == 0 && ch.Persona != "" {
+			p, err := prompts.Default().Persona(ch.Persona)
+			if err != nil {
+				return nil, err
 			}
-			logger.Info("palace sessions cleaned up")
+			emotes = p.Emotes
 		}
+		pipeline.SetChannelEmotes(ch.Name, emotes)
 	}
-	// wg.Done()
-	logger.Info("Shutting down")
-	os.Exit(0)
+
+	logger.Info("output safety configured",
+		"enabled", config.Enabled,
+		"sources", config.Sources,
+		"stripURLs", config.URLs.Strip,
+		"maxLength", config.MaxLength,
+		"bannedPhrases", len(config.BannedPhrases),
+		"selfModeration", config.SelfModeration.Enabled,
+	)
+	return pipeline, nil
 }
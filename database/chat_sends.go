@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+)
+
+// ChatSendWriter is the interface for recording messages sent to Twitch chat
+type ChatSendWriter interface {
+	InsertChatSend(ctx context.Context, send types.ChatSend) error
+}
+
+// InsertChatSend records a message sent to Twitch chat
+func (p *Postgres) InsertChatSend(ctx context.Context, send types.ChatSend) error {
+	if send.ID == uuid.Nil {
+		send.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO chat_sends (
+			id, channel, message, source, priority, part, parts, chat_id, reply_to, queued_at, sent_at
+		) VALUES (
+			:id, :channel, :message, :source, :priority, :part, :parts, :chat_id, :reply_to, :queued_at, :sent_at
+		)
+	`
+
+	_, err := p.connections.NamedExecContext(ctx, query, send)
+	if err != nil {
+		p.logger.Error("failed to insert chat send", "error", err.Error(), "channel", send.Channel, "source", send.Source)
+		return fmt.Errorf("failed to insert chat send: %w", err)
+	}
+
+	return nil
+}
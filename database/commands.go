@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+// ChatCommandReader is the interface for loading chat command definitions
+type ChatCommandReader interface {
+	ListChatCommands(ctx context.Context) ([]types.ChatCommand, error)
+}
+
+// ListChatCommands returns every active chat command
+func (p *Postgres) ListChatCommands(ctx context.Context) ([]types.ChatCommand, error) {
+	query := `
+		SELECT
+			name, aliases, description, args, response, llm_prompt, handler,
+			cooldown_seconds, user_cooldown_seconds, badge, channels
+		FROM chat_commands
+		WHERE is_active = true
+		ORDER BY name
+	`
+
+	var commands []types.ChatCommand
+	if err := p.connections.SelectContext(ctx, &commands, query); err != nil {
+		p.logger.Error("failed to list chat commands", "error", err.Error())
+		return nil, fmt.Errorf("failed to list chat commands: %w", err)
+	}
+
+	return commands, nil
+}
@@ -30,7 +30,7 @@ func (p *Postgres) InsertMessage(ctx context.Context, msg types.TwitchMessage) (
 	}
 	msg.UUID = ID
 
-	query := "INSERT INTO twitch_chat (username, message, isCommand, created_at, uuid) VALUES (:username, :message, :isCommand, :created_at, :uuid)"
+	query := "INSERT INTO twitch_chat (username, message, channel, isCommand, created_at, uuid, twitch_message_id) VALUES (:username, :message, :channel, :isCommand, :created_at, :uuid, :twitch_message_id)"
 	p.logger.Debug("inserting message into database", "messageID", ID)
 
 	_, err = p.connections.NamedExecContext(ctx, query, msg)
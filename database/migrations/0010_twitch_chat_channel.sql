@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE twitch_chat ADD COLUMN IF NOT EXISTS channel text;
+CREATE INDEX IF NOT EXISTS idx_twitch_chat_channel ON twitch_chat(channel);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_twitch_chat_channel;
+ALTER TABLE twitch_chat DROP COLUMN IF EXISTS channel;
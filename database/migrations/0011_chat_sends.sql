@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chat_sends (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    channel text NOT NULL,
+    message text NOT NULL,
+    source text NOT NULL,
+    priority text NOT NULL,
+    part integer NOT NULL DEFAULT 1,
+    parts integer NOT NULL DEFAULT 1,
+    chat_id uuid,
+    queued_at timestamptz NOT NULL,
+    sent_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_sends_sent_at ON chat_sends(sent_at);
+CREATE INDEX IF NOT EXISTS idx_chat_sends_channel ON chat_sends(channel);
+CREATE INDEX IF NOT EXISTS idx_chat_sends_chat_id ON chat_sends(chat_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_chat_sends_chat_id;
+DROP INDEX IF EXISTS idx_chat_sends_channel;
+DROP INDEX IF EXISTS idx_chat_sends_sent_at;
+DROP TABLE IF EXISTS chat_sends;
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chat_commands (
+    name text PRIMARY KEY,
+    aliases text[] NOT NULL DEFAULT '{}',
+    description text NOT NULL DEFAULT '',
+    args text NOT NULL DEFAULT '',
+
+    -- Exactly one of response, llm_prompt or handler is set
+    response text NOT NULL DEFAULT '',
+    llm_prompt text NOT NULL DEFAULT '',
+    handler text NOT NULL DEFAULT '',
+
+    cooldown_seconds integer NOT NULL DEFAULT 0,
+    user_cooldown_seconds integer NOT NULL DEFAULT 0,
+    badge text NOT NULL DEFAULT 'everyone',
+    channels text[] NOT NULL DEFAULT '{}',
+
+    is_active boolean NOT NULL DEFAULT true,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS chat_commands;
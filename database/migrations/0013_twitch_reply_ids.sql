@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE twitch_chat ADD COLUMN IF NOT EXISTS twitch_message_id text;
+ALTER TABLE chat_sends ADD COLUMN IF NOT EXISTS reply_to text;
+
+-- +goose Down
+ALTER TABLE chat_sends DROP COLUMN IF EXISTS reply_to;
+ALTER TABLE twitch_chat DROP COLUMN IF EXISTS twitch_message_id;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Encrypted Twitch OAuth tokens, so the bot survives restarts without a new OAuth flow
+CREATE TABLE IF NOT EXISTS twitch_tokens (
+    name text PRIMARY KEY,
+    data bytea NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS twitch_tokens;
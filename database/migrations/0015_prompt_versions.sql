@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE bot_response ADD COLUMN IF NOT EXISTS prompt_version text;
+
+-- +goose Down
+ALTER TABLE bot_response DROP COLUMN IF EXISTS prompt_version;
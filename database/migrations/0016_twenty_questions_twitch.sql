@@ -0,0 +1,22 @@
+-- +goose Up
+-- Twenty Questions games are played on Twitch as well as Discord
+ALTER TABLE discord_twenty_questions_games ADD COLUMN IF NOT EXISTS platform text NOT NULL DEFAULT 'discord';
+ALTER TABLE discord_twenty_questions_games ADD COLUMN IF NOT EXISTS channel text;
+ALTER TABLE discord_twenty_questions_games ADD COLUMN IF NOT EXISTS winner text;
+ALTER TABLE discord_twenty_questions_games ADD COLUMN IF NOT EXISTS last_activity_at timestamptz DEFAULT NOW();
+ALTER TABLE discord_twenty_questions_games ADD COLUMN IF NOT EXISTS ended_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_twenty_questions_games_channel_status ON discord_twenty_questions_games (platform, channel, status);
+
+ALTER TABLE discord_twenty_questions ADD COLUMN IF NOT EXISTS username text;
+ALTER TABLE discord_twenty_questions ADD COLUMN IF NOT EXISTS is_guess boolean NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE discord_twenty_questions DROP COLUMN IF EXISTS is_guess;
+ALTER TABLE discord_twenty_questions DROP COLUMN IF EXISTS username;
+
+DROP INDEX IF EXISTS idx_twenty_questions_games_channel_status;
+ALTER TABLE discord_twenty_questions_games DROP COLUMN IF EXISTS ended_at;
+ALTER TABLE discord_twenty_questions_games DROP COLUMN IF EXISTS last_activity_at;
+ALTER TABLE discord_twenty_questions_games DROP COLUMN IF EXISTS winner;
+ALTER TABLE discord_twenty_questions_games DROP COLUMN IF EXISTS channel;
+ALTER TABLE discord_twenty_questions_games DROP COLUMN IF EXISTS platform;
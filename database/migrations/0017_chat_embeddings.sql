@@ -0,0 +1,12 @@
+-- +goose Up
+-- Chat embeddings can come from any embedding model, so the column takes any dimension
+-- and the model is stored with each embedding. Only embeddings from the same model are compared.
+ALTER TABLE twitch_chat ALTER COLUMN embedding TYPE vector;
+ALTER TABLE twitch_chat ADD COLUMN IF NOT EXISTS embedding_model text;
+CREATE INDEX IF NOT EXISTS idx_twitch_chat_embedding_model ON twitch_chat(embedding_model) WHERE embedding IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_twitch_chat_embedding_model;
+ALTER TABLE twitch_chat DROP COLUMN IF EXISTS embedding_model;
+UPDATE twitch_chat SET embedding = NULL WHERE vector_dims(embedding) <> 512;
+ALTER TABLE twitch_chat ALTER COLUMN embedding TYPE vector(512);
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Web search results cached by normalized query, so repeated questions during a stream
+-- skip the search. The embedding finds cached queries worded differently; only embeddings
+-- from the same model are compared.
+CREATE TABLE IF NOT EXISTS web_search_cache (
+    query_key text PRIMARY KEY,
+    query text NOT NULL,
+    results jsonb NOT NULL,
+    embedding vector,
+    embedding_model text,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_web_search_cache_created_at ON web_search_cache(created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_web_search_cache_created_at;
+DROP TABLE IF EXISTS web_search_cache;
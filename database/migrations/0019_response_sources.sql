@@ -0,0 +1,9 @@
+-- +goose Up
+-- Pages behind a response, such as web search results, so viewers can check an answer
+-- Pedro could not link in chat
+ALTER TABLE bot_response ADD COLUMN IF NOT EXISTS sources jsonb;
+CREATE INDEX IF NOT EXISTS idx_bot_response_chat_id ON bot_response(chat_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_bot_response_chat_id;
+ALTER TABLE bot_response DROP COLUMN IF EXISTS sources;
@@ -2,20 +2,45 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
 )
 
 type ResponseWriter interface {
 	InsertResponse(ctx context.Context, resp types.TwitchMessage, modelName string) error
 }
 
+// AnswerSearcher is the interface for searching Pedro's past answers
+type AnswerSearcher interface {
+	SearchAnswers(ctx context.Context, channel, query string, limit int) ([]types.PastAnswer, error)
+}
+
+// AnswerSourcesStore is the interface for looking up the sources behind Pedro's answers
+type AnswerSourcesStore interface {
+	GetLastAnswerSources(ctx context.Context, channel, username string) (*types.AnswerSources, error)
+}
+
 func (p *Postgres) InsertResponse(ctx context.Context, resp types.TwitchMessage, modelName string) error {
-	p.logger.Debug("inserting LLM response into database", "messageID", resp.UUID, "model", modelName)
+	p.logger.Debug("inserting LLM response into database", "messageID", resp.UUID, "model", modelName, "promptVersion", resp.PromptVersion, "sources", len(resp.Sources))
 
-	query := "INSERT INTO bot_response (model_name, response, stop_reason, was_successful, chat_id) VALUES ($1, $2, $3, $4, $5)"
-	_, err := p.connections.ExecContext(ctx, query, modelName, resp.Text, resp.StopReason, true, resp.UUID)
+	// Responses without sources store NULL
+	var sources any
+	if len(resp.Sources) > 0 {
+		data, err := json.Marshal(resp.Sources)
+		if err != nil {
+			return fmt.Errorf("error encoding response sources: %w", err)
+		}
+		sources = string(data)
+	}
+
+	query := "INSERT INTO bot_response (model_name, response, stop_reason, was_successful, chat_id, prompt_version, sources) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)"
+	_, err := p.connections.ExecContext(ctx, query, modelName, resp.Text, resp.StopReason, true, resp.UUID, resp.PromptVersion, sources)
 	if err != nil {
 		p.logger.Error("error inserting response into database", "error", err.Error(), "messageID", resp.UUID)
 		return fmt.Errorf("error upserting response: %w", err)
@@ -24,3 +49,69 @@ func (p *Postgres) InsertResponse(ctx context.Context, resp types.TwitchMessage,
 	p.logger.Debug("all response choices inserted successfully", "messageID", resp.UUID)
 	return nil
 }
+
+// GetLastAnswerSources returns Pedro's last answer to a viewer in a channel and the sources
+// behind it, or nil if he has not answered them
+func (p *Postgres) GetLastAnswerSources(ctx context.Context, channel, username string) (*types.AnswerSources, error) {
+	query := `
+		SELECT r.chat_id, c.channel, c.username, r.response, r.sources, r.created_at
+		FROM bot_response r
+		JOIN twitch_chat c ON c.uuid = r.chat_id
+		WHERE c.channel = $1 AND lower(c.username) = lower($2)
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT 1
+	`
+	var row struct {
+		ChatID    uuid.UUID      `db:"chat_id"`
+		Channel   string         `db:"channel"`
+		Username  string         `db:"username"`
+		Response  sql.NullString `db:"response"`
+		Sources   []byte         `db:"sources"`
+		CreatedAt time.Time      `db:"created_at"`
+	}
+	err := p.connections.GetContext(ctx, &row, query, channel, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last answer sources: %w", err)
+	}
+
+	answer := &types.AnswerSources{
+		ChatID:    row.ChatID,
+		Channel:   row.Channel,
+		Username:  row.Username,
+		Response:  row.Response.String,
+		CreatedAt: row.CreatedAt,
+	}
+	if len(row.Sources) > 0 {
+		if err := json.Unmarshal(row.Sources, &answer.Sources); err != nil {
+			return nil, fmt.Errorf("failed to decode answer sources: %w", err)
+		}
+	}
+	return answer, nil
+}
+
+// SearchAnswers returns Pedro's past answers in a channel whose question or answer shares
+// words with query, best match first
+func (p *Postgres) SearchAnswers(ctx context.Context, channel, query string, limit int) ([]types.PastAnswer, error) {
+	// Any word may match, plainto_tsquery alone would need all of them
+	sqlQuery := `
+		WITH q AS (
+			SELECT NULLIF(replace(plainto_tsquery('english', $2)::text, '&', '|'), '')::tsquery AS terms
+		)
+		SELECT c.username, c.message, COALESCE(r.response, '') AS response, r.created_at
+		FROM bot_response r
+		JOIN twitch_chat c ON c.uuid = r.chat_id
+		CROSS JOIN q
+		WHERE c.channel = $1
+			AND to_tsvector('english', c.message || ' ' || COALESCE(r.response, '')) @@ q.terms
+		ORDER BY ts_rank(to_tsvector('english', c.message || ' ' || COALESCE(r.response, '')), q.terms) DESC, r.created_at DESC
+		LIMIT $3
+	`
+	var answers []types.PastAnswer
+	if err := p.connections.SelectContext(ctx, &answers, sqlQuery, channel, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to search answers: %w", err)
+	}
+	return answers, nil
+}
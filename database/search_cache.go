@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+// SearchCacheStore is the interface for caching web search results
+type SearchCacheStore interface {
+	GetSearchCache(ctx context.Context, key string, since time.Time) (*types.SearchCacheEntry, error)
+	FindSimilarSearchCache(ctx context.Context, query types.SearchCacheQuery) (*types.SearchCacheEntry, error)
+	SaveSearchCache(ctx context.Context, entry types.SearchCacheEntry) error
+	PurgeSearchCache(ctx context.Context, key string) (int64, error)
+	PurgeSimilarSearchCache(ctx context.Context, query types.SearchCacheQuery) (int64, error)
+}
+
+// GetSearchCache returns the search cached under key since a time, or nil if there is none
+func (p *Postgres) GetSearchCache(ctx context.Context, key string, since time.Time) (*types.SearchCacheEntry, error) {
+	query := `
+		SELECT query_key, query, results, COALESCE(embedding_model, '') AS embedding_model, created_at
+		FROM web_search_cache
+		WHERE query_key = $1 AND created_at >= $2
+	`
+	var entry types.SearchCacheEntry
+	err := p.connections.GetContext(ctx, &entry, query, key, since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search cache: %w", err)
+	}
+	return &entry, nil
+}
+
+// FindSimilarSearchCache returns the cached search whose query is most similar to an
+// embedding, or nil if none is similar enough
+func (p *Postgres) FindSimilarSearchCache(ctx context.Context, query types.SearchCacheQuery) (*types.SearchCacheEntry, error) {
+	sqlQuery := `
+		SELECT
+			query_key,
+			query,
+			results,
+			embedding_model,
+			created_at,
+			1 - (embedding <=> $1) AS similarity
+		FROM web_search_cache
+		WHERE embedding IS NOT NULL
+			AND embedding_model = $2
+			AND created_at >= $3
+			AND 1 - (embedding <=> $1) >= $4
+		ORDER BY embedding <=> $1
+		LIMIT 1
+	`
+	var entry types.SearchCacheEntry
+	err := p.connections.GetContext(ctx, &entry, sqlQuery,
+		arrayToString(query.Embedding), query.Model, query.Since, query.MinSimilarity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query similar searches: %w", err)
+	}
+	return &entry, nil
+}
+
+// SaveSearchCache caches a search, replacing any earlier one for the same key
+func (p *Postgres) SaveSearchCache(ctx context.Context, entry types.SearchCacheEntry) error {
+	var embedding, model any
+	if len(entry.Embedding) > 0 {
+		embedding = arrayToString(entry.Embedding)
+		model = entry.EmbeddingModel
+	}
+	query := `
+		INSERT INTO web_search_cache (query_key, query, results, embedding, embedding_model, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (query_key) DO UPDATE SET
+			query = EXCLUDED.query,
+			results = EXCLUDED.results,
+			embedding = EXCLUDED.embedding,
+			embedding_model = EXCLUDED.embedding_model,
+			created_at = NOW()
+	`
+	if _, err := p.connections.ExecContext(ctx, query, entry.Key, entry.Query, entry.Results, embedding, model); err != nil {
+		return fmt.Errorf("failed to save search cache: %w", err)
+	}
+	return nil
+}
+
+// PurgeSearchCache deletes the search cached under key, or every cached search when key is
+// empty, and returns how many were deleted
+func (p *Postgres) PurgeSearchCache(ctx context.Context, key string) (int64, error) {
+	result, err := p.connections.ExecContext(ctx, `DELETE FROM web_search_cache WHERE $1 = '' OR query_key = $1`, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge search cache: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// PurgeSimilarSearchCache deletes the cached searches whose query is at least
+// query.MinSimilarity similar to an embedding, and returns how many were deleted
+func (p *Postgres) PurgeSimilarSearchCache(ctx context.Context, query types.SearchCacheQuery) (int64, error) {
+	sqlQuery := `
+		DELETE FROM web_search_cache
+		WHERE embedding IS NOT NULL
+			AND embedding_model = $2
+			AND 1 - (embedding <=> $1) >= $3
+	`
+	result, err := p.connections.ExecContext(ctx, sqlQuery, arrayToString(query.Embedding), query.Model, query.MinSimilarity)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge similar searches: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+// TwentyQuestionsStore is the interface for storing Twenty Questions games
+type TwentyQuestionsStore interface {
+	InsertTwentyQuestionsGame(ctx context.Context, game types.TwentyQuestionsGame) error
+	UpdateTwentyQuestionsGame(ctx context.Context, game types.TwentyQuestionsGame) error
+	InsertTwentyQuestion(ctx context.Context, question types.TwentyQuestion) error
+	ListActiveTwentyQuestionsGames(ctx context.Context, platform string) ([]types.TwentyQuestionsGame, error)
+	TwentyQuestionsLeaderboard(ctx context.Context, platform, channel string, limit int) ([]types.TwentyQuestionsScore, error)
+}
+
+// InsertTwentyQuestionsGame records a new game
+func (p *Postgres) InsertTwentyQuestionsGame(ctx context.Context, game types.TwentyQuestionsGame) error {
+	query := `
+		INSERT INTO discord_twenty_questions_games (
+			game_id, platform, channel, username, answer, question_count, status, created_at, last_activity_at
+		) VALUES (
+			:game_id, :platform, :channel, :username, :answer, :question_count, :status, :created_at, :last_activity_at
+		)
+	`
+
+	if _, err := p.connections.NamedExecContext(ctx, query, game); err != nil {
+		p.logger.Error("failed to insert twenty questions game", "error", err.Error(), "gameID", game.ID)
+		return fmt.Errorf("failed to insert twenty questions game: %w", err)
+	}
+	return nil
+}
+
+// UpdateTwentyQuestionsGame saves a game's question count, status and winner
+func (p *Postgres) UpdateTwentyQuestionsGame(ctx context.Context, game types.TwentyQuestionsGame) error {
+	query := `
+		UPDATE discord_twenty_questions_games
+		SET question_count = :question_count,
+			status = :status,
+			winner = :winner,
+			last_activity_at = :last_activity_at,
+			ended_at = :ended_at
+		WHERE game_id = :game_id
+	`
+
+	if _, err := p.connections.NamedExecContext(ctx, query, game); err != nil {
+		p.logger.Error("failed to update twenty questions game", "error", err.Error(), "gameID", game.ID)
+		return fmt.Errorf("failed to update twenty questions game: %w", err)
+	}
+	return nil
+}
+
+// InsertTwentyQuestion records a question or guess and Pedro's response
+func (p *Postgres) InsertTwentyQuestion(ctx context.Context, question types.TwentyQuestion) error {
+	query := `
+		INSERT INTO discord_twenty_questions (game_id, username, question, response, is_guess)
+		VALUES (:game_id, :username, :question, :response, :is_guess)
+	`
+
+	if _, err := p.connections.NamedExecContext(ctx, query, question); err != nil {
+		p.logger.Error("failed to insert twenty question", "error", err.Error(), "gameID", question.GameID)
+		return fmt.Errorf("failed to insert twenty question: %w", err)
+	}
+	return nil
+}
+
+// ListActiveTwentyQuestionsGames returns the games on a platform that have not ended
+func (p *Postgres) ListActiveTwentyQuestionsGames(ctx context.Context, platform string) ([]types.TwentyQuestionsGame, error) {
+	query := `
+		SELECT
+			game_id, platform, COALESCE(channel, '') AS channel, COALESCE(username, '') AS username,
+			COALESCE(answer, '') AS answer, COALESCE(question_count, 0) AS question_count, status, winner,
+			created_at, COALESCE(last_activity_at, created_at) AS last_activity_at, ended_at
+		FROM discord_twenty_questions_games
+		WHERE platform = $1 AND status = 'started'
+		ORDER BY created_at
+	`
+
+	var games []types.TwentyQuestionsGame
+	if err := p.connections.SelectContext(ctx, &games, query, platform); err != nil {
+		p.logger.Error("failed to list active twenty questions games", "error", err.Error(), "platform", platform)
+		return nil, fmt.Errorf("failed to list active twenty questions games: %w", err)
+	}
+	return games, nil
+}
+
+// TwentyQuestionsLeaderboard returns the viewers with the most wins in a channel
+func (p *Postgres) TwentyQuestionsLeaderboard(ctx context.Context, platform, channel string, limit int) ([]types.TwentyQuestionsScore, error) {
+	query := `
+		SELECT winner AS username, COUNT(*) AS wins
+		FROM discord_twenty_questions_games
+		WHERE platform = $1 AND channel = $2 AND winner IS NOT NULL
+		GROUP BY winner
+		ORDER BY wins DESC, MIN(ended_at)
+		LIMIT $3
+	`
+
+	var scores []types.TwentyQuestionsScore
+	if err := p.connections.SelectContext(ctx, &scores, query, platform, channel, limit); err != nil {
+		p.logger.Error("failed to load twenty questions leaderboard", "error", err.Error(), "channel", channel)
+		return nil, fmt.Errorf("failed to load twenty questions leaderboard: %w", err)
+	}
+	return scores, nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// GetTwitchToken returns the encrypted token saved under name, or nil if there is none
+func (p *Postgres) GetTwitchToken(ctx context.Context, name string) ([]byte, error) {
+	var data []byte
+	err := p.connections.GetContext(ctx, &data, `SELECT data FROM twitch_tokens WHERE name = $1`, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		p.logger.Error("failed to get twitch token", "error", err.Error(), "name", name)
+		return nil, fmt.Errorf("failed to get twitch token: %w", err)
+	}
+	return data, nil
+}
+
+// SaveTwitchToken stores the encrypted token under name, replacing any previous one
+func (p *Postgres) SaveTwitchToken(ctx context.Context, name string, data []byte) error {
+	query := `
+		INSERT INTO twitch_tokens (name, data, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data, updated_at = NOW()
+	`
+	if _, err := p.connections.ExecContext(ctx, query, name, data); err != nil {
+		p.logger.Error("failed to save twitch token", "error", err.Error(), "name", name)
+		return fmt.Errorf("failed to save twitch token: %w", err)
+	}
+	return nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
 )
 
 // FindSimilarMessages finds similar messages from chat history using vector similarity
@@ -55,17 +57,23 @@ func (p *Postgres) FindSimilarMessages(ctx context.Context, embedding []float32,
 	return messages, nil
 }
 
-// StoreMessageEmbedding stores an embedding for a chat message
-func (p *Postgres) StoreMessageEmbedding(ctx context.Context, messageUUID string, embedding []float32) error {
+// ChatMemoryStore is the interface for storing chat embeddings and searching them
+type ChatMemoryStore interface {
+	StoreMessageEmbedding(ctx context.Context, messageID uuid.UUID, model string, embedding []float32) error
+	FindChatMemories(ctx context.Context, query types.ChatMemoryQuery) ([]types.ChatMemory, error)
+}
+
+// StoreMessageEmbedding stores an embedding for a chat message and the model that made it
+func (p *Postgres) StoreMessageEmbedding(ctx context.Context, messageID uuid.UUID, model string, embedding []float32) error {
 	vec := arrayToString(embedding)
 
 	query := `
 		UPDATE twitch_chat
-		SET embedding = $1
-		WHERE uuid = $2
+		SET embedding = $1, embedding_model = $2
+		WHERE uuid = $3
 	`
 
-	_, err := p.connections.ExecContext(ctx, query, vec, messageUUID)
+	_, err := p.connections.ExecContext(ctx, query, vec, model, messageID)
 	if err != nil {
 		return fmt.Errorf("failed to store embedding: %w", err)
 	}
@@ -73,6 +81,55 @@ func (p *Postgres) StoreMessageEmbedding(ctx context.Context, messageUUID string
 	return nil
 }
 
+// FindChatMemories finds the chat messages most similar to an embedding, with Pedro's
+// latest answer to each
+func (p *Postgres) FindChatMemories(ctx context.Context, query types.ChatMemoryQuery) ([]types.ChatMemory, error) {
+	if query.Limit <= 0 {
+		query.Limit = 5
+	}
+	if query.MinSimilarity <= 0 {
+		query.MinSimilarity = 0.7
+	}
+	if query.Before.IsZero() {
+		query.Before = time.Now()
+	}
+
+	sqlQuery := `
+		SELECT
+			c.uuid,
+			COALESCE(c.channel, '') AS channel,
+			COALESCE(c.username, '') AS username,
+			COALESCE(c.message, '') AS message,
+			COALESCE(r.response, '') AS response,
+			c.created_at,
+			1 - (c.embedding <=> $1) AS similarity
+		FROM twitch_chat c
+		LEFT JOIN LATERAL (
+			SELECT response
+			FROM bot_response
+			WHERE chat_id = c.uuid AND was_successful
+			ORDER BY id DESC
+			LIMIT 1
+		) r ON true
+		WHERE c.embedding IS NOT NULL
+			AND c.embedding_model = $2
+			AND c.created_at < $3
+			AND ($4 = '' OR c.channel = $4)
+			AND 1 - (c.embedding <=> $1) >= $5
+		ORDER BY c.embedding <=> $1
+		LIMIT $6
+	`
+
+	var memories []types.ChatMemory
+	err := p.connections.SelectContext(ctx, &memories, sqlQuery,
+		arrayToString(query.Embedding), query.Model, query.Before, query.Channel, query.MinSimilarity, query.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat memories: %w", err)
+	}
+
+	return memories, nil
+}
+
 // arrayToString converts a float32 slice to PostgreSQL vector format
 func arrayToString(arr []float32) string {
 	if len(arr) == 0 {
@@ -1,6 +1,7 @@
 package duckduckgo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -80,6 +81,11 @@ func NewClient() *Client {
 
 // Search calls duckduckgo api and return the json as a an unmasharlled []byte.
 func (c *Client) Search(query string) ([]byte, error) {
+	return c.SearchContext(context.Background(), query)
+}
+
+// SearchContext is Search with a context that cancels the request
+func (c *Client) SearchContext(ctx context.Context, query string) ([]byte, error) {
 	if query == "" {
 		return nil, fmt.Errorf("query cannot be empty")
 	}
@@ -97,7 +103,7 @@ func (c *Client) Search(query string) ([]byte, error) {
 
 	u.RawQuery = params.Encode()
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
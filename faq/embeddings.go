@@ -44,7 +44,17 @@ func NewEmbeddingService(llmPath string, modelName string) (*EmbeddingService, e
 		return nil, fmt.Errorf("failed to create OpenAI client for embeddings: %w", err)
 	}
 
-	embedder, err := embeddings.NewEmbedder(llm)
+	return NewEmbeddingServiceWithClient(llm, modelName)
+}
+
+// NewEmbeddingServiceWithClient creates an embedding service on an existing client, such
+// as the shared provider's Embedder(modelName)
+func NewEmbeddingServiceWithClient(client embeddings.EmbedderClient, modelName string) (*EmbeddingService, error) {
+	if modelName == "" {
+		return nil, fmt.Errorf("modelName cannot be empty")
+	}
+
+	embedder, err := embeddings.NewEmbedder(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedder: %w", err)
 	}
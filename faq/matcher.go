@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Match represents a matched FAQ entry with its similarity score
@@ -32,8 +33,9 @@ func NewMatcher(db *sqlx.DB) *Matcher {
 
 // FindMatch searches for a matching FAQ entry using cosine similarity
 // Returns the best match if similarity >= threshold and cooldown has passed
+// categories limits the search to those categories; an empty list searches all entries
 // Returns nil if no match is found
-func (m *Matcher) FindMatch(ctx context.Context, embedding []float32, threshold float64) (*Match, error) {
+func (m *Matcher) FindMatch(ctx context.Context, embedding []float32, threshold float64, categories []string) (*Match, error) {
 	if len(embedding) == 0 {
 		return nil, fmt.Errorf("embedding cannot be empty")
 	}
@@ -57,12 +59,13 @@ func (m *Matcher) FindMatch(ctx context.Context, embedding []float32, threshold
 		  AND embedding IS NOT NULL
 		  AND 1 - (embedding <=> $1::vector) >= $2
 		  AND (last_triggered_at IS NULL OR last_triggered_at < NOW() - INTERVAL '1 second' * cooldown_seconds)
+		  AND (COALESCE(cardinality($3::text[]), 0) = 0 OR category = ANY($3::text[]))
 		ORDER BY embedding <=> $1::vector
 		LIMIT 1
 	`
 
 	var match Match
-	err := m.db.QueryRowContext(ctx, query, vectorStr, threshold).Scan(
+	err := m.db.QueryRowContext(ctx, query, vectorStr, threshold, pq.Array(categories)).Scan(
 		&match.ID,
 		&match.Question,
 		&match.Response,
@@ -82,7 +85,8 @@ func (m *Matcher) FindMatch(ctx context.Context, embedding []float32, threshold
 }
 
 // FindMatchForUser searches for a matching FAQ entry, also checking per-user cooldowns
-func (m *Matcher) FindMatchForUser(ctx context.Context, embedding []float32, threshold float64, userID string) (*Match, error) {
+// categories limits the search to those categories; an empty list searches all entries
+func (m *Matcher) FindMatchForUser(ctx context.Context, embedding []float32, threshold float64, userID string, categories []string) (*Match, error) {
 	if len(embedding) == 0 {
 		return nil, fmt.Errorf("embedding cannot be empty")
 	}
@@ -108,12 +112,13 @@ func (m *Matcher) FindMatchForUser(ctx context.Context, embedding []float32, thr
 		  AND 1 - (f.embedding <=> $1::vector) >= $2
 		  AND (f.last_triggered_at IS NULL OR f.last_triggered_at < NOW() - INTERVAL '1 second' * f.cooldown_seconds)
 		  AND (uc.triggered_at IS NULL OR uc.triggered_at < NOW() - INTERVAL '1 second' * f.cooldown_seconds)
+		  AND (COALESCE(cardinality($4::text[]), 0) = 0 OR f.category = ANY($4::text[]))
 		ORDER BY f.embedding <=> $1::vector
 		LIMIT 1
 	`
 
 	var match Match
-	err := m.db.QueryRowContext(ctx, query, vectorStr, threshold, userID).Scan(
+	err := m.db.QueryRowContext(ctx, query, vectorStr, threshold, userID, pq.Array(categories)).Scan(
 		&match.ID,
 		&match.Question,
 		&match.Response,
@@ -132,6 +137,57 @@ func (m *Matcher) FindMatchForUser(ctx context.Context, embedding []float32, thr
 	return &match, nil
 }
 
+// Search returns up to limit FAQ entries similar to the embedding, ignoring cooldowns.
+// It is used when Pedro looks up an answer himself rather than auto-responding.
+func (m *Matcher) Search(ctx context.Context, embedding []float32, threshold float64, limit int, categories []string) ([]Match, error) {
+	if len(embedding) == 0 {
+		return nil, fmt.Errorf("embedding cannot be empty")
+	}
+
+	query := `
+		SELECT
+			id,
+			question,
+			response,
+			category,
+			cooldown_seconds,
+			1 - (embedding <=> $1::vector) AS similarity
+		FROM faq_entries
+		WHERE is_active = true
+		  AND embedding IS NOT NULL
+		  AND 1 - (embedding <=> $1::vector) >= $2
+		  AND (COALESCE(cardinality($4::text[]), 0) = 0 OR category = ANY($4::text[]))
+		ORDER BY embedding <=> $1::vector
+		LIMIT $3
+	`
+
+	rows, err := m.db.QueryContext(ctx, query, VectorToString(embedding), threshold, limit, pq.Array(categories))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search FAQ entries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var matches []Match
+	for rows.Next() {
+		var match Match
+		if err := rows.Scan(
+			&match.ID,
+			&match.Question,
+			&match.Response,
+			&match.Category,
+			&match.CooldownSeconds,
+			&match.SimilarityScore,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan FAQ entry: %w", err)
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read FAQ entries: %w", err)
+	}
+	return matches, nil
+}
+
 // RecordTrigger updates the last_triggered_at timestamp for global cooldown
 func (m *Matcher) RecordTrigger(ctx context.Context, faqID uuid.UUID) error {
 	query := `UPDATE faq_entries SET last_triggered_at = NOW() WHERE id = $1`
@@ -6,11 +6,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
+	"github.com/Soypete/twitch-llm-bot/ai/provider"
 	"github.com/Soypete/twitch-llm-bot/logging"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/tmc/langchaingo/embeddings"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 // Service orchestrates FAQ semantic matching and response generation
@@ -18,6 +20,7 @@ type Service struct {
 	embeddingService   *EmbeddingService
 	matcher            *Matcher
 	llm                llms.Model
+	chatModel          string
 	threshold          float64
 	logger             *logging.Logger
 	db                 *sqlx.DB
@@ -26,9 +29,16 @@ type Service struct {
 
 // ServiceConfig configures the FAQ service
 type ServiceConfig struct {
-	// LLMPath is the base URL for the LLM/embedding API
+	// LLMPath is the base URL for the LLM/embedding API. It is only used when LLM or
+	// Embedder is nil.
 	LLMPath string
 
+	// LLM generates responses, usually the shared provider
+	LLM llms.Model
+
+	// Embedder generates embeddings with EmbeddingModel, usually the shared provider
+	Embedder embeddings.EmbedderClient
+
 	// EmbeddingModel is the model name for generating embeddings
 	EmbeddingModel string
 
@@ -50,7 +60,7 @@ func NewService(db *sqlx.DB, config ServiceConfig) (*Service, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection cannot be nil")
 	}
-	if config.LLMPath == "" {
+	if config.LLMPath == "" && (config.LLM == nil || config.Embedder == nil) {
 		return nil, fmt.Errorf("LLMPath cannot be empty")
 	}
 
@@ -60,23 +70,24 @@ func NewService(db *sqlx.DB, config ServiceConfig) (*Service, error) {
 	}
 
 	// Create embedding service
-	embeddingService, err := NewEmbeddingService(config.LLMPath, config.EmbeddingModel)
+	var embeddingService *EmbeddingService
+	var err error
+	if config.Embedder != nil {
+		embeddingService, err = NewEmbeddingServiceWithClient(config.Embedder, config.EmbeddingModel)
+	} else {
+		embeddingService, err = NewEmbeddingService(config.LLMPath, config.EmbeddingModel)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding service: %w", err)
 	}
 
 	// Create LLM for response generation
-	llmPath := config.LLMPath
-	if !strings.HasSuffix(llmPath, "/v1") {
-		llmPath = llmPath + "/v1"
-	}
-
-	llm, err := openai.New(
-		openai.WithBaseURL(llmPath),
-		openai.WithModel(config.ChatModel),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create LLM client: %w", err)
+	llm := config.LLM
+	if llm == nil {
+		llm, err = provider.New(provider.SingleBackend(config.LLMPath), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LLM client: %w", err)
+		}
 	}
 
 	// Set default threshold if not specified
@@ -89,6 +100,7 @@ func NewService(db *sqlx.DB, config ServiceConfig) (*Service, error) {
 		embeddingService:   embeddingService,
 		matcher:            NewMatcher(db),
 		llm:                llm,
+		chatModel:          config.ChatModel,
 		threshold:          threshold,
 		logger:             logger,
 		db:                 db,
@@ -123,6 +135,12 @@ type MatchResult struct {
 // CheckMessage checks if a message matches any FAQ entries and generates a response
 // Returns nil if no match is found
 func (s *Service) CheckMessage(ctx context.Context, userMessage string, userID string) (*MatchResult, error) {
+	return s.CheckMessageInCategories(ctx, userMessage, userID, nil)
+}
+
+// CheckMessageInCategories checks a message against the FAQ entries in the given categories.
+// An empty category list matches against all FAQ entries.
+func (s *Service) CheckMessageInCategories(ctx context.Context, userMessage string, userID string, categories []string) (*MatchResult, error) {
 	if userMessage == "" {
 		return nil, nil
 	}
@@ -142,9 +160,9 @@ func (s *Service) CheckMessage(ctx context.Context, userMessage string, userID s
 	// Find matching FAQ entry
 	var match *Match
 	if s.usePerUserCooldown {
-		match, err = s.matcher.FindMatchForUser(ctx, embedding, s.threshold, userID)
+		match, err = s.matcher.FindMatchForUser(ctx, embedding, s.threshold, userID, categories)
 	} else {
-		match, err = s.matcher.FindMatch(ctx, embedding, s.threshold)
+		match, err = s.matcher.FindMatch(ctx, embedding, s.threshold, categories)
 	}
 
 	if err != nil {
@@ -205,6 +223,26 @@ func (s *Service) CheckMessage(ctx context.Context, userMessage string, userID s
 	return result, nil
 }
 
+// Lookup returns the FAQ entries in the given categories closest to a question, without
+// generating a response or touching cooldowns. An empty category list searches all FAQ
+// entries. It backs Pedro's faq_lookup tool.
+func (s *Service) Lookup(ctx context.Context, question string, limit int, categories []string) ([]Match, error) {
+	if question == "" {
+		return nil, fmt.Errorf("question cannot be empty")
+	}
+	embedding, err := s.embeddingService.Generate(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+	// Pedro judges relevance himself, so accept looser matches than the auto-responder
+	matches, err := s.matcher.Search(ctx, embedding, s.threshold*0.8, limit, categories)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debug("FAQ lookup", "matches", len(matches), "categories", categories)
+	return matches, nil
+}
+
 // generateResponse uses the LLM to generate a natural response based on the FAQ match
 func (s *Service) generateResponse(ctx context.Context, userMessage string, match *Match) (string, error) {
 	// Check for special tokens that require dynamic handling
@@ -215,20 +253,32 @@ func (s *Service) generateResponse(ctx context.Context, userMessage string, matc
 		s.logger.Debug("special token detected in FAQ response", "token", match.Response)
 	}
 
-	prompt := fmt.Sprintf(`A viewer asked: "%s"
-
-This matches our FAQ about: "%s"
-The information to share is: %s
-
-Generate a brief, friendly chat response (under 400 characters) that naturally answers their question with this information. Be conversational and on-brand for a tech streamer. Do not use newlines.`,
-		userMessage, match.Question, match.Response)
+	persona, err := prompts.Default().Persona("")
+	if err != nil {
+		return "", err
+	}
+	data := prompts.FAQData{
+		Persona:     persona,
+		UserMessage: userMessage,
+		Question:    match.Question,
+		Answer:      match.Response,
+	}
+	systemPrompt, err := prompts.Default().Render(prompts.FAQSystem, data)
+	if err != nil {
+		return "", err
+	}
+	prompt, err := prompts.Default().Render(prompts.FAQResponse, data)
+	if err != nil {
+		return "", err
+	}
 
 	messages := []llms.MessageContent{
-		llms.TextParts(llms.ChatMessageTypeSystem, "You are Pedro, a friendly chatbot assistant for SoyPeteTech's Twitch stream. You help viewers with quick, helpful responses. Keep responses under 400 characters with no newlines."),
-		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+		llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt.Text),
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt.Text),
 	}
 
 	response, err := s.llm.GenerateContent(ctx, messages,
+		llms.WithModel(s.chatModel),
 		llms.WithTemperature(0.7),
 		llms.WithMaxTokens(100),
 	)
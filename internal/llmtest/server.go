@@ -0,0 +1,436 @@
+// Package llmtest is a fake OpenAI-compatible LLM server for tests. It implements
+// /v1/chat/completions (with tool calls), /v1/embeddings and /v1/models, so the real
+// openai clients, the provider and everything built on them can be tested without a
+// llama.cpp endpoint.
+//
+// Chat answers are scripted per test: queued replies are used first, then the first
+// matching rule, then the default answer. Embeddings are deterministic, and latency and
+// errors can be injected.
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// DefaultAnswer is the chat answer when nothing else matches
+const DefaultAnswer = "ok"
+
+// DefaultDimensions is the size of the default embeddings
+const DefaultDimensions = 8
+
+// ToolCall is a tool call the fake model makes
+type ToolCall struct {
+	Name      string
+	Arguments string
+}
+
+// Response is how the server answers one chat completion
+type Response struct {
+	Content   string
+	ToolCalls []ToolCall
+
+	// Status other than 0 or 200 fails the request with that status
+	Status int
+
+	// Delay is added before answering
+	Delay time.Duration
+}
+
+// Message is one message of a chat completion request
+type Message struct {
+	Role       string
+	Content    string
+	ToolCallID string
+	ToolCalls  []ToolCall
+}
+
+// Request is a chat completion the server received
+type Request struct {
+	Model    string
+	Messages []Message
+	Tools    []string
+}
+
+// LastUserMessage returns the text of the last user message
+func (r Request) LastUserMessage() string {
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == "user" {
+			return r.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// ToolResults returns the contents of the tool messages, in order
+func (r Request) ToolResults() []string {
+	var results []string
+	for _, m := range r.Messages {
+		if m.Role == "tool" {
+			results = append(results, m.Content)
+		}
+	}
+	return results
+}
+
+// HasTool reports whether the request offered the named tool
+func (r Request) HasTool(name string) bool {
+	for _, tool := range r.Tools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rule answers the requests it matches
+type rule struct {
+	match   func(Request) bool
+	respond func(Request) Response
+}
+
+// Server is a fake OpenAI-compatible server
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	queue       []Response
+	rules       []rule
+	fallback    Response
+	embed       func(text string) []float32
+	embedStatus int
+	latency     time.Duration
+	requests    []Request
+	embedded    []string
+}
+
+// NewServer starts a fake server that is closed when the test ends
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{
+		fallback: Response{Content: DefaultAnswer},
+		embed:    func(text string) []float32 { return Embed(text, DefaultDimensions) },
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// BaseURL is the server's OpenAI API base URL, ending in /v1
+func (s *Server) BaseURL() string {
+	return s.URL + "/v1"
+}
+
+// Client returns an openai client for the server. It implements llms.Model and
+// embeddings.EmbedderClient.
+func (s *Server) Client(t testing.TB, model string) *openai.LLM {
+	t.Helper()
+	client, err := openai.New(
+		openai.WithBaseURL(s.BaseURL()),
+		openai.WithToken("test"),
+		openai.WithModel(model),
+		openai.WithEmbeddingModel(model),
+	)
+	if err != nil {
+		t.Fatalf("failed to create openai client: %v", err)
+	}
+	return client
+}
+
+// Reply queues responses that answer the next chat completions in order, before any rule
+func (s *Server) Reply(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, responses...)
+}
+
+// On answers requests that match with respond. Rules are checked in the order they were added.
+func (s *Server) On(match func(Request) bool, respond func(Request) Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{match: match, respond: respond})
+}
+
+// OnMessage answers with resp when the request ends with a user message containing
+// substr, ignoring case. Later steps of the same turn, after a tool call, do not match.
+func (s *Server) OnMessage(substr string, resp Response) {
+	substr = strings.ToLower(substr)
+	s.On(func(r Request) bool {
+		if len(r.Messages) == 0 || r.Messages[len(r.Messages)-1].Role != "user" {
+			return false
+		}
+		return strings.Contains(strings.ToLower(r.LastUserMessage()), substr)
+	}, func(Request) Response { return resp })
+}
+
+// OnToolResult answers requests that carry a tool result, usually the model's second
+// step after a tool call. respond gets the latest tool result.
+func (s *Server) OnToolResult(respond func(result string) Response) {
+	s.On(func(r Request) bool {
+		return len(r.ToolResults()) > 0
+	}, func(r Request) Response {
+		results := r.ToolResults()
+		return respond(results[len(results)-1])
+	})
+}
+
+// Default sets the answer used when no queued reply or rule applies
+func (s *Server) Default(resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = resp
+}
+
+// SetEmbedder replaces the default embeddings
+func (s *Server) SetEmbedder(embed func(text string) []float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.embed = embed
+}
+
+// FailEmbeddings makes embedding requests fail with status. 0 makes them succeed again.
+func (s *Server) FailEmbeddings(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.embedStatus = status
+}
+
+// SetLatency delays every response
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// Requests returns the chat completions received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Embedded returns every text embedded so far
+func (s *Server) Embedded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.embedded...)
+}
+
+// Embed returns a deterministic unit vector for text. Equal texts get equal vectors.
+func Embed(text string, dimensions int) []float32 {
+	vector := make([]float32, dimensions)
+	var norm float64
+	for i := range vector {
+		h := fnv.New32a()
+		fmt.Fprintf(h, "%d:%s", i, text)
+		v := float64(h.Sum32())/math.MaxUint32*2 - 1
+		vector[i] = float32(v)
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/v1/chat/completions":
+		s.serveChat(w, r)
+	case "/v1/embeddings":
+		s.serveEmbeddings(w, r)
+	case "/v1/models":
+		writeJSON(w, map[string]any{"object": "list", "data": []any{}})
+	default:
+		writeError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+	}
+}
+
+// chatRequest is the part of an OpenAI chat completion request the server reads
+type chatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role       string          `json:"role"`
+		Content    json.RawMessage `json:"content"`
+		ToolCallID string          `json:"tool_call_id"`
+		ToolCalls  []struct {
+			Function struct {
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			} `json:"function"`
+		} `json:"tool_calls"`
+	} `json:"messages"`
+	Tools []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools"`
+}
+
+func (s *Server) serveChat(w http.ResponseWriter, r *http.Request) {
+	var body chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req := Request{Model: body.Model}
+	for _, m := range body.Messages {
+		msg := Message{Role: m.Role, Content: content(m.Content), ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{Name: call.Function.Name, Arguments: call.Function.Arguments})
+		}
+		req.Messages = append(req.Messages, msg)
+	}
+	for _, tool := range body.Tools {
+		req.Tools = append(req.Tools, tool.Function.Name)
+	}
+
+	resp := s.respond(req)
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if resp.Status != 0 && resp.Status != http.StatusOK {
+		writeError(w, resp.Status, "injected failure")
+		return
+	}
+
+	message := map[string]any{"role": "assistant", "content": resp.Content}
+	finishReason := "stop"
+	if len(resp.ToolCalls) > 0 {
+		var calls []map[string]any
+		for i, call := range resp.ToolCalls {
+			calls = append(calls, map[string]any{
+				"id":       fmt.Sprintf("call_%d_%d", len(req.Messages), i),
+				"type":     "function",
+				"function": map[string]any{"name": call.Name, "arguments": call.Arguments},
+			})
+		}
+		message["tool_calls"] = calls
+		finishReason = "tool_calls"
+	}
+	writeJSON(w, map[string]any{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"created": 1,
+		"model":   body.Model,
+		"choices": []map[string]any{{"index": 0, "message": message, "finish_reason": finishReason}},
+		"usage":   map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+	})
+}
+
+// respond records req and picks its response
+func (s *Server) respond(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+
+	if len(s.queue) > 0 {
+		resp := s.queue[0]
+		s.queue = s.queue[1:]
+		return resp
+	}
+	for _, rule := range s.rules {
+		if rule.match(req) {
+			return rule.respond(req)
+		}
+	}
+	return s.fallback
+}
+
+func (s *Server) serveEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var inputs []string
+	if err := json.Unmarshal(body.Input, &inputs); err != nil {
+		var input string
+		if err := json.Unmarshal(body.Input, &input); err != nil {
+			writeError(w, http.StatusBadRequest, "input must be a string or a list of strings")
+			return
+		}
+		inputs = []string{input}
+	}
+
+	s.mu.Lock()
+	status, embed := s.embedStatus, s.embed
+	s.embedded = append(s.embedded, inputs...)
+	s.mu.Unlock()
+	if status != 0 && status != http.StatusOK {
+		writeError(w, status, "injected failure")
+		return
+	}
+
+	data := make([]map[string]any, len(inputs))
+	for i, input := range inputs {
+		data[i] = map[string]any{"object": "embedding", "index": i, "embedding": embed(input)}
+	}
+	writeJSON(w, map[string]any{
+		"object": "list",
+		"model":  body.Model,
+		"data":   data,
+		"usage":  map[string]int{"prompt_tokens": len(inputs), "total_tokens": len(inputs)},
+	})
+}
+
+// content reads a message's content, which is either a string or a list of parts
+func content(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"message": message, "type": "test_error"}})
+}
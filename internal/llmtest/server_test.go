@@ -0,0 +1,114 @@
+package llmtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestServer_Chat(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t, "test-model")
+	ctx := context.Background()
+
+	server.OnMessage("weather", Response{ToolCalls: []ToolCall{{Name: "web_search", Arguments: `{"query":"weather"}`}}})
+	server.OnToolResult(func(result string) Response { return Response{Content: "it is " + result} })
+
+	tests := []struct {
+		name        string
+		messages    []llms.MessageContent
+		wantContent string
+		wantTool    string
+	}{
+		{
+			name:        "default answer",
+			messages:    []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hello")},
+			wantContent: DefaultAnswer,
+		},
+		{
+			name:     "rule makes a tool call",
+			messages: []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "what is the Weather?")},
+			wantTool: "web_search",
+		},
+		{
+			name: "tool result is answered",
+			messages: []llms.MessageContent{
+				llms.TextParts(llms.ChatMessageTypeHuman, "what is the weather?"),
+				{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{llms.ToolCall{ID: "call_1", Type: "function", FunctionCall: &llms.FunctionCall{Name: "web_search", Arguments: `{"query":"weather"}`}}}},
+				{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "call_1", Name: "web_search", Content: "sunny"}}},
+			},
+			wantContent: "it is sunny",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.GenerateContent(ctx, tt.messages, llms.WithTools([]llms.Tool{{Type: "function", Function: &llms.FunctionDefinition{Name: "web_search"}}}))
+			require.NoError(t, err)
+			require.Len(t, resp.Choices, 1)
+			assert.Equal(t, tt.wantContent, resp.Choices[0].Content)
+			if tt.wantTool != "" {
+				require.Len(t, resp.Choices[0].ToolCalls, 1)
+				assert.Equal(t, tt.wantTool, resp.Choices[0].ToolCalls[0].FunctionCall.Name)
+			}
+		})
+	}
+
+	requests := server.Requests()
+	require.Len(t, requests, len(tests))
+	assert.Equal(t, "test-model", requests[0].Model)
+	assert.True(t, requests[0].HasTool("web_search"))
+	assert.Equal(t, []string{"sunny"}, requests[2].ToolResults())
+}
+
+func TestServer_QueuedRepliesAndFailures(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t, "test-model")
+	ctx := context.Background()
+
+	server.Reply(Response{Content: "first"}, Response{Status: http.StatusServiceUnavailable})
+	got, err := client.Call(ctx, "hi")
+	require.NoError(t, err)
+	assert.Equal(t, "first", got)
+
+	_, err = client.Call(ctx, "hi")
+	assert.Error(t, err)
+
+	server.Default(Response{Content: "after"})
+	got, err = client.Call(ctx, "hi")
+	require.NoError(t, err)
+	assert.Equal(t, "after", got)
+
+	server.SetLatency(200 * time.Millisecond)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = client.Call(timeoutCtx, "hi")
+	assert.Error(t, err)
+}
+
+func TestServer_Embeddings(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t, "embed-model")
+	ctx := context.Background()
+
+	vectors, err := client.CreateEmbedding(ctx, []string{"hello", "world", "hello"})
+	require.NoError(t, err)
+	require.Len(t, vectors, 3)
+	assert.Equal(t, Embed("hello", DefaultDimensions), vectors[0])
+	assert.Equal(t, vectors[0], vectors[2], "equal texts embed the same")
+	assert.NotEqual(t, vectors[0], vectors[1])
+	assert.Equal(t, []string{"hello", "world", "hello"}, server.Embedded())
+
+	server.SetEmbedder(func(text string) []float32 { return []float32{float32(len(text))} })
+	vectors, err = client.CreateEmbedding(ctx, []string{"four"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{4}}, vectors)
+
+	server.FailEmbeddings(http.StatusInternalServerError)
+	_, err = client.CreateEmbedding(ctx, []string{"hello"})
+	assert.Error(t, err)
+}
@@ -16,6 +16,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
 	"github.com/Soypete/twitch-llm-bot/internal/mempalace/ontology"
 	"github.com/Soypete/twitch-llm-bot/metrics"
 	"github.com/tmc/langchaingo/llms"
@@ -51,20 +52,21 @@ func (c *Classifier) Classify(ctx context.Context, msg string) (string, error) {
 
 	toolDef := getClassificationToolDefinition(c.classNames)
 
-	systemPrompt := `You are a chat message classifier. Given a chat message, classify it into exactly one topic category.
-If the message doesn't clearly relate to any topic, return "Unclassified".
-Be conservative - only classify if there's a clear topic match.`
-
-	userMessage := fmt.Sprintf(`Classify this chat message into one of these categories: %s
-
-Message to classify:
-%s
-
-Respond with exactly one tool call.`, strings.Join(c.classNames, ", "), msg)
+	systemPrompt, err := prompts.Default().Render(prompts.ClassifierSystem, nil)
+	if err != nil {
+		return "", err
+	}
+	userMessage, err := prompts.Default().Render(prompts.ClassifierMessage, prompts.ClassifierData{
+		Classes: c.classNames,
+		Message: msg,
+	})
+	if err != nil {
+		return "", err
+	}
 
 	messageHistory := []llms.MessageContent{
-		llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt),
-		llms.TextParts(llms.ChatMessageTypeHuman, userMessage),
+		llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt.Text),
+		llms.TextParts(llms.ChatMessageTypeHuman, userMessage.Text),
 	}
 
 	resp, err := c.llm.GenerateContent(ctx, messageHistory,
@@ -55,6 +55,13 @@ func NewController(helixClient *helix.Client, pollInterval time.Duration) *Contr
 	}
 }
 
+// UpdateToken passes a refreshed Twitch access token to the Helix client used for polling
+func (c *Controller) UpdateToken(accessToken string) {
+	if c.helixClient != nil {
+		c.helixClient.UpdateToken(accessToken)
+	}
+}
+
 func (c *Controller) Events() <-chan SessionEvent {
 	return c.events
 }
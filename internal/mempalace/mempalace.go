@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Soypete/twitch-llm-bot/ai/provider"
 	"github.com/Soypete/twitch-llm-bot/faq"
 	"github.com/Soypete/twitch-llm-bot/internal/mempalace/address"
 	"github.com/Soypete/twitch-llm-bot/internal/mempalace/archive"
@@ -19,8 +20,8 @@ import (
 	"github.com/Soypete/twitch-llm-bot/logging"
 	"github.com/Soypete/twitch-llm-bot/twitch/helix"
 	v2 "github.com/gempir/go-twitch-irc/v2"
+	"github.com/tmc/langchaingo/embeddings"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 type MemPalace struct {
@@ -30,6 +31,8 @@ type MemPalace struct {
 	writer        *writer.Writer
 	lifecycle     *lifecycle.Controller
 	store         *store.Store
+	index         *ontology.Index
+	classes       []ontology.Class
 	addressDetect *address.Detector
 	archiver      *archive.Archiver
 
@@ -41,8 +44,12 @@ type MemPalace struct {
 }
 
 type Config struct {
-	LLMPath      string
-	ModelName    string
+	LLMPath   string
+	ModelName string
+	// LLM classifies messages and Embedder embeds them, usually both from the shared
+	// provider. LLMPath is only used when they are nil.
+	LLM          llms.Model
+	Embedder     embeddings.EmbedderClient
 	HelixClient  *helix.Client
 	Logger       *logging.Logger
 	PollInterval int
@@ -66,7 +73,13 @@ func New(config *Config) (*MemPalace, error) {
 		ontologyPath = "/app/internal/mempalace/ontology/testdata/twitch_topics.ttl"
 	}
 
-	embedder, err := faq.NewEmbeddingService(config.LLMPath, config.ModelName)
+	var embedder *faq.EmbeddingService
+	var err error
+	if config.Embedder != nil {
+		embedder, err = faq.NewEmbeddingServiceWithClient(config.Embedder, config.ModelName)
+	} else {
+		embedder, err = faq.NewEmbeddingService(config.LLMPath, config.ModelName)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedder: %w", err)
 	}
@@ -82,12 +95,12 @@ func New(config *Config) (*MemPalace, error) {
 
 	classes := index.GetClasses()
 
-	llm, err := openai.New([]openai.Option{
-		openai.WithBaseURL(config.LLMPath),
-		openai.WithModel(config.ModelName),
-	}...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create classifier LLM: %w", err)
+	llm := config.LLM
+	if llm == nil {
+		llm, err = provider.New(provider.SingleBackend(config.LLMPath), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create classifier LLM: %w", err)
+		}
 	}
 
 	classifr := classifier.NewClassifier(llm, config.ModelName, classes)
@@ -123,6 +136,8 @@ func New(config *Config) (*MemPalace, error) {
 		classifier:    classifr,
 		writer:        wr,
 		lifecycle:     lc,
+		index:         index,
+		classes:       classes,
 		addressDetect: addrDetect,
 		archiver:      archiver,
 		llm:           llm,
@@ -166,16 +181,20 @@ func (m *MemPalace) handleSessionEvent(ctx context.Context, event lifecycle.Sess
 			return
 		}
 
+		m.mu.Lock()
 		m.store = s
+		m.mu.Unlock()
 		m.writer.SetStore(s)
 
 	case lifecycle.EventSessionEnd:
 		m.logger.Info("ending mempalace session", "streamID", event.StreamID)
 
+		m.mu.Lock()
 		if m.store != nil {
 			_ = m.store.Close()
 			m.store = nil
 		}
+		m.mu.Unlock()
 
 		_ = m.archiver.Archive(event.StreamID, m.lifecycle.GetStartedAt())
 	}
@@ -189,8 +208,19 @@ func (m *MemPalace) IsAddressed(msg string) (bool, float32) {
 	return m.addressDetect.IsAddressed(msg)
 }
 
+// GetQueryTool returns the chat history tool for the current session. It is backed by the
+// session's store and the ontology index, and fails when no session is recording.
 func (m *MemPalace) GetQueryTool() *tools.QueryChatHistoryTool {
-	return tools.NewQueryChatHistoryTool(m.store, nil, nil)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return tools.NewQueryChatHistoryTool(m.store, m.index, m.classes)
+}
+
+// HasSession reports whether a stream session is recording chat that can be queried
+func (m *MemPalace) HasSession() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.store != nil
 }
 
 func (m *MemPalace) Stop() error {
@@ -209,6 +239,11 @@ func (m *MemPalace) Stop() error {
 	return nil
 }
 
+// UpdateToken passes a refreshed Twitch access token to the lifecycle controller
+func (m *MemPalace) UpdateToken(accessToken string) {
+	m.lifecycle.UpdateToken(accessToken)
+}
+
 func (m *MemPalace) IsActive() bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -312,3 +312,26 @@ func (i *Index) ClassLabels() []string {
 	}
 	return labels
 }
+
+// ClassLabel returns the preferred label of the class with term as its label or an alt
+// label, ignoring case. It returns "" when no class matches.
+func (i *Index) ClassLabel(term string) string {
+	return MatchClass(i.GetClasses(), term)
+}
+
+// MatchClass returns the preferred label of the class in classes with term as its label or
+// an alt label, ignoring case. It returns "" when no class matches.
+func MatchClass(classes []Class, term string) string {
+	term = strings.TrimSpace(term)
+	for _, c := range classes {
+		if strings.EqualFold(c.Label, term) {
+			return c.Label
+		}
+		for _, alt := range c.AltLabels {
+			if strings.EqualFold(alt, term) {
+				return c.Label
+			}
+		}
+	}
+	return ""
+}
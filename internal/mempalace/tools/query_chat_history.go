@@ -12,7 +12,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/Soypete/twitch-llm-bot/internal/mempalace/ontology"
@@ -21,6 +22,19 @@ import (
 	"github.com/tmc/langchaingo/llms"
 )
 
+// MaxResultLength keeps tool results short enough for Pedro to quote in a 500 character
+// chat reply
+const MaxResultLength = 500
+
+// maxMessageLength is how much of one chat message is shown
+const maxMessageLength = 120
+
+// maxLimit caps how many messages one call may ask for
+const maxLimit = 25
+
+// minTopicScore is the ontology similarity needed to search by an inferred topic
+const minTopicScore = 0.5
+
 type QueryChatHistoryTool struct {
 	store   *store.Store
 	index   *ontology.Index
@@ -45,39 +59,47 @@ Arguments:
 - topic_class: The topic category to search (e.g., "Go", "LLM Engineering", "DevOps"). If not sure, leave empty.
 - time_range: Time range for search, format as "start,end" in RFC3339 or relative like "15m", "1h". Leave empty for all time.
 - query_text: Specific text to search for in messages.
+- username: Only return messages from this chatter.
 - limit: Maximum number of messages to return (default 10).`
 }
 
 func (t *QueryChatHistoryTool) Call(ctx context.Context, input string) (string, error) {
+	if t.store == nil {
+		metrics.MempalaceToolCallsTotal.WithLabelValues("query_chat_history", "no_session").Add(1)
+		return "", fmt.Errorf("no stream session is recording chat right now")
+	}
+
 	args, err := parseQueryArgs(input)
 	if err != nil {
 		metrics.MempalaceToolCallsTotal.WithLabelValues("query_chat_history", "parse_error").Add(1)
 		return "", err
 	}
 
-	topic := args.TopicClass
-	if topic == "" && args.QueryText != "" && t.index != nil {
-		results, err := t.index.Search(ctx, args.QueryText, 1)
-		if err == nil && len(results) > 0 {
-			topic = results[0].Term
-		}
-	}
-
 	opts := store.QueryOpts{
-		Topic:     topic,
+		Topic:     t.resolveTopic(ctx, args.TopicClass),
 		QueryText: args.QueryText,
+		Username:  strings.TrimPrefix(args.Username, "@"),
 		Limit:     args.Limit,
 	}
-
 	if args.TimeRange != "" {
-		start, end, err := parseTimeRange(args.TimeRange)
-		if err == nil {
-			opts.TimeStart = &start
-			opts.TimeEnd = &end
+		start, end, err := parseTimeRange(args.TimeRange, time.Now())
+		if err != nil {
+			metrics.MempalaceToolCallsTotal.WithLabelValues("query_chat_history", "parse_error").Add(1)
+			return "", err
 		}
+		opts.TimeStart = &start
+		opts.TimeEnd = &end
 	}
 
 	messages, err := t.store.Query(ctx, opts)
+	if err == nil && len(messages) == 0 && opts.Topic == "" && opts.QueryText != "" {
+		// Nothing said those exact words, so look for messages on the closest topic instead
+		if topic := t.inferTopic(ctx, opts.QueryText); topic != "" {
+			opts.Topic = topic
+			opts.QueryText = ""
+			messages, err = t.store.Query(ctx, opts)
+		}
+	}
 	if err != nil {
 		metrics.MempalaceToolCallsTotal.WithLabelValues("query_chat_history", "error").Add(1)
 		return "", fmt.Errorf("failed to query chat history: %w", err)
@@ -90,8 +112,39 @@ func (t *QueryChatHistoryTool) Call(ctx context.Context, input string) (string,
 
 	metrics.MempalaceToolCallsTotal.WithLabelValues("query_chat_history", "success").Add(1)
 
-	result := formatMessages(messages)
-	return result, nil
+	return formatMessages(messages, opts.Topic), nil
+}
+
+// resolveTopic maps a topic the LLM asked for onto an ontology class label. Names that are
+// not a class label or alt label are matched with the ontology index.
+func (t *QueryChatHistoryTool) resolveTopic(ctx context.Context, topic string) string {
+	topic = strings.TrimSpace(topic)
+	if topic == "" {
+		return ""
+	}
+	if label := ontology.MatchClass(t.classes, topic); label != "" {
+		return label
+	}
+	if inferred := t.inferTopic(ctx, topic); inferred != "" {
+		return inferred
+	}
+	return topic
+}
+
+// inferTopic returns the class label closest to text in the ontology index, or "" when
+// nothing is close enough
+func (t *QueryChatHistoryTool) inferTopic(ctx context.Context, text string) string {
+	if t.index == nil {
+		return ""
+	}
+	results, err := t.index.Search(ctx, text, 1)
+	if err != nil || len(results) == 0 || results[0].Score < minTopicScore {
+		return ""
+	}
+	if label := t.index.ClassLabel(results[0].Term); label != "" {
+		return label
+	}
+	return results[0].Term
 }
 
 func GetQueryChatHistoryToolDefinition() llms.Tool {
@@ -99,7 +152,7 @@ func GetQueryChatHistoryToolDefinition() llms.Tool {
 		Type: "function",
 		Function: &llms.FunctionDefinition{
 			Name:        "query_chat_history",
-			Description: "Query chat history from the Mem Palace session for relevant past discussions",
+			Description: "Search this stream's chat history. Use it when chatters ask what was said or discussed earlier in the stream, e.g. what someone said about a topic an hour ago",
 			Parameters: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
@@ -115,6 +168,10 @@ func GetQueryChatHistoryToolDefinition() llms.Tool {
 						"type":        "string",
 						"description": "Specific text to search for in messages",
 					},
+					"username": map[string]any{
+						"type":        "string",
+						"description": "Only return messages from this chatter",
+					},
 					"limit": map[string]any{
 						"type":        "integer",
 						"description": "Maximum number of messages to return",
@@ -130,6 +187,7 @@ type queryArgs struct {
 	TopicClass string `json:"topic_class"`
 	TimeRange  string `json:"time_range"`
 	QueryText  string `json:"query_text"`
+	Username   string `json:"username"`
 	Limit      int    `json:"limit"`
 }
 
@@ -138,39 +196,65 @@ func parseQueryArgs(input string) (*queryArgs, error) {
 	if err := json.Unmarshal([]byte(input), &args); err != nil {
 		return nil, fmt.Errorf("failed to parse query args: %w", err)
 	}
-	if args.Limit == 0 {
+	if args.Limit <= 0 {
 		args.Limit = 10
 	}
+	if args.Limit > maxLimit {
+		args.Limit = maxLimit
+	}
 	return &args, nil
 }
 
-func parseTimeRange(tr string) (time.Time, time.Time, error) {
-	now := time.Now()
-
-	switch tr {
-	case "5m":
-		return now.Add(-5 * time.Minute), now, nil
-	case "15m":
-		return now.Add(-15 * time.Minute), now, nil
-	case "30m":
-		return now.Add(-30 * time.Minute), now, nil
-	case "1h":
-		return now.Add(-1 * time.Hour), now, nil
-	case "2h":
-		return now.Add(-2 * time.Hour), now, nil
-	default:
-		return now, now, fmt.Errorf("unsupported time range format")
+// parseTimeRange reads a relative range like "15m" or "2h" ending now, or "start,end" in RFC3339
+func parseTimeRange(tr string, now time.Time) (time.Time, time.Time, error) {
+	tr = strings.TrimSpace(tr)
+	if startStr, endStr, ok := strings.Cut(tr, ","); ok {
+		start, err := time.Parse(time.RFC3339, strings.TrimSpace(startStr))
+		if err != nil {
+			return now, now, fmt.Errorf("invalid time range start: %w", err)
+		}
+		end, err := time.Parse(time.RFC3339, strings.TrimSpace(endStr))
+		if err != nil {
+			return now, now, fmt.Errorf("invalid time range end: %w", err)
+		}
+		return start, end, nil
+	}
+
+	d, err := time.ParseDuration(tr)
+	if err != nil || d <= 0 {
+		return now, now, fmt.Errorf("unsupported time range format %q, use a duration like 15m or start,end in RFC3339", tr)
 	}
+	return now.Add(-d), now, nil
 }
 
-func formatMessages(messages []store.Message) string {
-	result := "Relevant chat messages:\n"
+// formatMessages lists messages oldest first, cut to fit in MaxResultLength. Messages are
+// newest first from the store, so the oldest are dropped when they do not fit.
+func formatMessages(messages []store.Message, topic string) string {
+	header := "Relevant chat messages:"
+	if topic != "" {
+		header = fmt.Sprintf("Relevant chat messages about %s:", topic)
+	}
+
+	var lines []string
+	length := len(header)
 	for _, msg := range messages {
-		result += fmt.Sprintf("[%s] %s: %s\n",
-			msg.Timestamp.Format("15:04"),
-			msg.Username,
-			msg.Message,
-		)
+		text := msg.Message
+		if runes := []rune(text); len(runes) > maxMessageLength {
+			text = string(runes[:maxMessageLength-3]) + "..."
+		}
+		line := fmt.Sprintf("[%s] %s: %s", msg.Timestamp.Format("15:04"), msg.Username, text)
+		// leave room for the "older messages" note
+		if length+1+len(line) > MaxResultLength-30 && len(lines) > 0 {
+			break
+		}
+		lines = append(lines, line)
+		length += 1 + len(line)
+	}
+	slices.Reverse(lines)
+
+	result := header + "\n" + strings.Join(lines, "\n")
+	if dropped := len(messages) - len(lines); dropped > 0 {
+		result += fmt.Sprintf("\n(%d older messages not shown)", dropped)
 	}
 	return result
 }
@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/internal/mempalace/ontology"
+	"github.com/Soypete/twitch-llm-bot/internal/mempalace/store"
+)
+
+// keywordEmbedder puts Go terms and Python terms on their own axes. Ontology terms that are
+// neither share a third axis and queries that are neither embed to zero.
+type keywordEmbedder struct{}
+
+func (keywordEmbedder) vector(text string, fallback []float32) []float32 {
+	text = strings.ToLower(text)
+	switch {
+	case text == "go" || text == "golang" || text == "gopher" || strings.Contains(text, "generic"):
+		return []float32{1, 0, 0}
+	case strings.Contains(text, "python"):
+		return []float32{0, 1, 0}
+	default:
+		return fallback
+	}
+}
+
+func (e keywordEmbedder) Generate(_ context.Context, text string) ([]float32, error) {
+	return e.vector(text, []float32{0, 0, 0}), nil
+}
+
+func (e keywordEmbedder) GenerateBatch(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.vector(text, []float32{0, 0, 1})
+	}
+	return vectors, nil
+}
+
+func newTestTool(t *testing.T, messages []store.Message) *QueryChatHistoryTool {
+	t.Helper()
+	_ = os.Setenv("MEMPALACE_DATA_DIR", t.TempDir())
+	t.Cleanup(func() { _ = os.Unsetenv("MEMPALACE_DATA_DIR") })
+
+	ttl := filepath.Join("..", "ontology", "testdata", "twitch_topics.ttl")
+	index, err := ontology.NewIndex(keywordEmbedder{})
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	if err := index.LoadTTL(context.Background(), ttl); err != nil {
+		t.Fatalf("failed to load ontology: %v", err)
+	}
+
+	s := store.NewStore()
+	if err := s.Init("test-stream", index.GetClasses()); err != nil {
+		t.Fatalf("failed to init store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	for _, msg := range messages {
+		if err := s.WriteMessage(context.Background(), msg); err != nil {
+			t.Fatalf("failed to write message: %v", err)
+		}
+	}
+	return NewQueryChatHistoryTool(s, index, index.GetClasses())
+}
+
+func TestQueryChatHistoryTool_Call(t *testing.T) {
+	now := time.Now()
+	messages := []store.Message{
+		{ID: "1", StreamID: "test-stream", Username: "soypete", Message: "type parameters make this so much nicer", Timestamp: now.Add(-70 * time.Minute), Topic: "Go"},
+		{ID: "2", StreamID: "test-stream", Username: "chatter", Message: "is python faster?", Timestamp: now.Add(-65 * time.Minute), Topic: "Python"},
+		{ID: "3", StreamID: "test-stream", Username: "soypete", Message: "generics landed in Go 1.18", Timestamp: now.Add(-5 * time.Minute), Topic: "Go"},
+	}
+	tool := newTestTool(t, messages)
+
+	tests := []struct {
+		name     string
+		input    string
+		want     []string
+		dontWant []string
+	}{
+		{
+			name:     "text match",
+			input:    `{"query_text":"generics"}`,
+			want:     []string{"soypete: generics landed in Go 1.18"},
+			dontWant: []string{"python"},
+		},
+		{
+			name:     "topic from an alt label",
+			input:    `{"topic_class":"golang"}`,
+			want:     []string{"about Go", "type parameters", "generics landed"},
+			dontWant: []string{"python"},
+		},
+		{
+			name:     "text falls back to the closest topic",
+			input:    `{"query_text":"what about generic code"}`,
+			want:     []string{"about Go", "type parameters"},
+			dontWant: []string{"python"},
+		},
+		{
+			name:     "username and time range",
+			input:    `{"username":"@soypete","time_range":"2h"}`,
+			want:     []string{"type parameters", "generics landed"},
+			dontWant: []string{"chatter"},
+		},
+		{
+			name:  "nothing found",
+			input: `{"query_text":"kubernetes"}`,
+			want:  []string{"No messages found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tool.Call(context.Background(), tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in result:\n%s", want, got)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(got, dontWant) {
+					t.Errorf("did not expect %q in result:\n%s", dontWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryChatHistoryTool_NoSession(t *testing.T) {
+	tool := NewQueryChatHistoryTool(nil, nil, nil)
+	if _, err := tool.Call(context.Background(), `{"query_text":"go"}`); err == nil {
+		t.Fatal("expected an error without a session")
+	}
+}
+
+func TestFormatMessages_FitsReplyLimit(t *testing.T) {
+	now := time.Now()
+	var messages []store.Message
+	for i := 0; i < 20; i++ {
+		messages = append(messages, store.Message{
+			Username:  fmt.Sprintf("user%d", i),
+			Message:   strings.Repeat("long message ", 20),
+			Timestamp: now.Add(-time.Duration(i) * time.Minute),
+		})
+	}
+
+	got := formatMessages(messages, "Go")
+	if len(got) > MaxResultLength {
+		t.Errorf("expected at most %d characters, got %d:\n%s", MaxResultLength, len(got), got)
+	}
+	if !strings.Contains(got, "user0:") {
+		t.Errorf("expected the newest message to be kept:\n%s", got)
+	}
+	if !strings.Contains(got, "older messages not shown") {
+		t.Errorf("expected a note about dropped messages:\n%s", got)
+	}
+	// oldest shown first
+	lines := strings.Split(got, "\n")
+	if !strings.Contains(lines[len(lines)-2], "user0:") {
+		t.Errorf("expected the newest message last:\n%s", got)
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in        string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{in: "45m", wantStart: now.Add(-45 * time.Minute), wantEnd: now},
+		{in: "3h", wantStart: now.Add(-3 * time.Hour), wantEnd: now},
+		{in: "2026-03-01T10:00:00Z,2026-03-01T11:00:00Z", wantStart: now.Add(-2 * time.Hour), wantEnd: now.Add(-time.Hour)},
+		{in: "yesterday", wantErr: true},
+		{in: "-5m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			start, end, err := parseTimeRange(tt.in, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("got %s to %s, want %s to %s", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -119,6 +119,232 @@ var (
 		},
 		[]string{"reason"},
 	)
+
+	// EventSub metrics
+	EventSubNotificationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "eventsub_notifications_total",
+			Help: "Total number of EventSub notifications received by subscription type",
+		},
+		[]string{"type"},
+	)
+
+	EventSubReconnectsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "eventsub_reconnects_total",
+			Help: "Total number of EventSub WebSocket reconnects by reason",
+		},
+		[]string{"reason"},
+	)
+
+	// Outbound chat queue metrics
+	ChatMessagesSentTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_messages_sent_total",
+			Help: "Total number of chat messages sent by source and priority",
+		},
+		[]string{"source", "priority"},
+	)
+
+	TwitchIRCConnectionState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "twitch_irc_connection_state",
+			Help: "Twitch IRC connection state, 1 for the current state and 0 for the others",
+		},
+		[]string{"state"},
+	)
+
+	TwitchIRCReconnectsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "twitch_irc_reconnects_total",
+			Help: "Total number of Twitch IRC reconnects by reason",
+		},
+		[]string{"reason"},
+	)
+
+	ChatTriggerDecisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_trigger_decisions_total",
+			Help: "Total number of chat messages run through the reply trigger pipeline by reason",
+		},
+		[]string{"reason"},
+	)
+
+	ChatMessagesDroppedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_messages_dropped_total",
+			Help: "Total number of chat messages dropped before sending by reason",
+		},
+		[]string{"reason"},
+	)
+
+	ChatQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "chat_queue_depth",
+			Help: "Number of chat messages waiting to be sent by priority",
+		},
+		[]string{"priority"},
+	)
+
+	ChatQueueWaitDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "chat_queue_wait_duration_seconds",
+			Help:    "Time chat messages spend in the outbound queue before sending",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	// Agent tool loop metrics
+	AgentToolCallsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_tool_calls_total",
+			Help: "Total number of tool calls run by the agent loop by tool and status",
+		},
+		[]string{"tool", "status"},
+	)
+
+	AgentStepBudgetExhaustedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "agent_step_budget_exhausted_total",
+			Help: "Total number of agent loops that ran out of steps before a final answer",
+		},
+	)
+
+	// Orchestrator metrics
+	OrchestratorStepsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "orchestrator_steps_total",
+			Help: "Total number of orchestrator plan steps by action and final status",
+		},
+		[]string{"action", "status"},
+	)
+
+	OrchestratorPlansRejectedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "orchestrator_plans_rejected_total",
+			Help: "Total number of LLM plans that failed validation",
+		},
+	)
+
+	// LLM provider metrics
+	LLMBackendCallsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_backend_calls_total",
+			Help: "Total number of LLM calls per backend by kind (chat, embedding) and status (success, error, skipped, cancelled)",
+		},
+		[]string{"backend", "kind", "status"},
+	)
+
+	LLMBackendCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "llm_backend_call_duration_seconds",
+			Help:    "Duration of LLM calls per backend",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+		},
+		[]string{"backend", "kind"},
+	)
+
+	LLMBackendUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "llm_backend_up",
+			Help: "Whether the last health check of an LLM backend passed (1) or failed (0)",
+		},
+		[]string{"backend"},
+	)
+
+	LLMBackendCircuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "llm_backend_circuit_state",
+			Help: "Circuit breaker state of an LLM backend (0 closed, 1 half open, 2 open)",
+		},
+		[]string{"backend"},
+	)
+
+	LLMAllBackendsFailedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_all_backends_failed_total",
+			Help: "Total number of LLM calls that no backend could serve",
+		},
+		[]string{"kind"},
+	)
+
+	// Web search metrics
+	WebSearchRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "web_search_requests_total",
+			Help: "Total number of web searches per provider by status (success, empty, error)",
+		},
+		[]string{"provider", "status"},
+	)
+	WebSearchAttemptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "web_search_attempts_total",
+			Help: "Total number of web search attempts, retries included, by status (success, empty, error, rewrite_failed)",
+		},
+		[]string{"status"},
+	)
+	WebSearchCacheLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "web_search_cache_lookups_total",
+			Help: "Total number of web search cache lookups by result (memory_hit, postgres_hit, semantic_hit, miss)",
+		},
+		[]string{"result"},
+	)
+	FetchPageRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fetch_page_requests_total",
+			Help: "Total number of pages fetched by the fetch_page tool by status (success, blocked, error)",
+		},
+		[]string{"status"},
+	)
+
+	// Output safety metrics
+	OutputSafetyChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "output_safety_checks_total",
+			Help: "Total number of outgoing messages checked by the output safety pipeline by source and result",
+		},
+		[]string{"source", "result"},
+	)
+	OutputSafetyActionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "output_safety_actions_total",
+			Help: "Total number of output safety stage actions by stage and action (modified, blocked, error)",
+		},
+		[]string{"stage", "action"},
+	)
+
+	// Twenty Questions metrics
+	TwentyQuestionsGamesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "twenty_questions_games_total",
+			Help: "Total number of Twenty Questions games by result (started, won, ended, abandoned)",
+		},
+		[]string{"result"},
+	)
+	TwentyQuestionsQuestionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "twenty_questions_questions_total",
+			Help: "Total number of Twenty Questions questions and guesses",
+		},
+		[]string{"kind"},
+	)
+
+	// Chat memory metrics
+	ChatEmbeddingsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_embeddings_total",
+			Help: "Total number of chat message embeddings by status (stored, failed, dropped)",
+		},
+		[]string{"status"},
+	)
+	ChatMemoryRecallsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_memory_recalls_total",
+			Help: "Total number of chat memory recalls by result (hit, miss, error)",
+		},
+		[]string{"result"},
+	)
 )
 
 type Server struct {
@@ -190,6 +416,45 @@ func SetupServer() *Server {
 		MempalaceToolCallsTotal,
 		MempalacePedroAddressScore,
 		MempalaceArchiveFailuresTotal,
+		// Register EventSub metrics
+		EventSubNotificationsTotal,
+		EventSubReconnectsTotal,
+		// Register outbound chat queue metrics
+		ChatMessagesSentTotal,
+		ChatMessagesDroppedTotal,
+		ChatQueueDepth,
+		ChatQueueWaitDuration,
+		// Register reply trigger metrics
+		ChatTriggerDecisionsTotal,
+		// Register IRC connection metrics
+		TwitchIRCConnectionState,
+		TwitchIRCReconnectsTotal,
+		// Register agent tool loop metrics
+		AgentToolCallsTotal,
+		AgentStepBudgetExhaustedTotal,
+		// Register orchestrator metrics
+		OrchestratorStepsTotal,
+		OrchestratorPlansRejectedTotal,
+		// Register LLM provider metrics
+		LLMBackendCallsTotal,
+		LLMBackendCallDuration,
+		LLMBackendUp,
+		LLMBackendCircuitState,
+		LLMAllBackendsFailedTotal,
+		// Register web search metrics
+		WebSearchRequestsTotal,
+		WebSearchAttemptsTotal,
+		WebSearchCacheLookupsTotal,
+		FetchPageRequestsTotal,
+		// Register output safety metrics
+		OutputSafetyChecksTotal,
+		OutputSafetyActionsTotal,
+		// Register Twenty Questions metrics
+		TwentyQuestionsGamesTotal,
+		TwentyQuestionsQuestionsTotal,
+		// Register chat memory metrics
+		ChatEmbeddingsTotal,
+		ChatMemoryRecallsTotal,
 	)
 
 	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
@@ -202,6 +467,16 @@ func (s *Server) RegisterAuthHealthHandler(handler http.HandlerFunc) {
 	http.HandleFunc("/healthz/auth", handler)
 }
 
+// RegisterIRCHealthHandler registers the IRC connection state endpoint
+func (s *Server) RegisterIRCHealthHandler(handler http.HandlerFunc) {
+	http.HandleFunc("/healthz/irc", handler)
+}
+
+// RegisterSourcesHandler registers the endpoint with the sources behind Pedro's answers
+func (s *Server) RegisterSourcesHandler(handler http.HandlerFunc) {
+	http.HandleFunc("/sources", handler)
+}
+
 // healthzHandler returns a simple health check response
 func healthzHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -4,11 +4,13 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/Soypete/twitch-llm-bot/twitch/token"
 	"github.com/bwmarrin/discordgo"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/twitch"
@@ -87,24 +89,20 @@ func (irc *IRC) parseAuthCode(w http.ResponseWriter, req *http.Request) {
 	irc.authCode = req.FormValue("code")
 }
 
-// tryRefreshToken uses the stored refresh token to silently obtain a new access token.
-func (irc *IRC) tryRefreshToken(ctx context.Context) error {
-	if irc.tok == nil || irc.tok.RefreshToken == "" || irc.oauthConf == nil {
-		return fmt.Errorf("no refresh token available")
-	}
-	ts := irc.oauthConf.TokenSource(ctx, irc.tok)
-	newTok, err := ts.Token()
+// tryRefreshToken uses tok's refresh token to silently obtain a new access token.
+func (irc *IRC) tryRefreshToken(ctx context.Context, tok *oauth2.Token) error {
+	newTok, err := token.RefreshToken(ctx, irc.oauthConf, tok)
 	if err != nil {
-		return fmt.Errorf("refresh failed: %w", err)
+		return err
 	}
-	irc.tok = newTok
-	irc.tokenRefreshTime = time.Now()
+	irc.setToken(ctx, newTok)
 	irc.logger.Info("token refreshed silently")
 	return nil
 }
 
 // AuthTwitch uses oauth2 protocol to retrieve oauth2 token for twitch IRC.
-// Priority: TWITCH_REFRESH_TOKEN (silent refresh) → TWITCH_TOKEN (direct) → full OAuth flow.
+// Priority: saved token → TWITCH_REFRESH_TOKEN (silent refresh) → TWITCH_TOKEN (direct) → full OAuth flow.
+// Every token it obtains is persisted and refreshed before expiry by the token manager.
 // Safe to call multiple times; the HTTP handler is registered only once.
 func (irc *IRC) AuthTwitch(ctx context.Context) error {
 	// Build OAuth config — needed for refresh and/or full OAuth flow.
@@ -116,36 +114,43 @@ func (irc *IRC) AuthTwitch(ctx context.Context) error {
 	conf := &oauth2.Config{
 		ClientID:     os.Getenv("TWITCH_ID"),
 		ClientSecret: os.Getenv("TWITCH_SECRET"),
-		Scopes:       []string{"chat:read", "chat:edit", "channel:moderate"},
+		Scopes:       []string{"chat:read", "chat:edit", "channel:moderate", "user:write:chat"},
 		RedirectURL:  fmt.Sprintf("https://%s/oauth/redirect", redirectHost),
 		Endpoint:     twitch.Endpoint,
 	}
 	if irc.oauthConf == nil {
 		irc.oauthConf = conf
 	}
+	if irc.tokens != nil {
+		irc.tokens.SetConfig(irc.oauthConf)
+	}
 
 	tokenStr := os.Getenv("TWITCH_TOKEN")
 	refreshStr := os.Getenv("TWITCH_REFRESH_TOKEN")
 
 	// If we already have a token, this is a re-auth call (e.g. after "login authentication failed").
-	// Skip env var paths — they'd just load the same expired token again.
+	// Skip saved and env var tokens — they'd just load the same expired token again.
 	// Try silent refresh first; if that fails, fall through to interactive OAuth.
-	if irc.tok != nil {
+	if current := irc.currentToken(); current != nil {
 		irc.logger.Info("re-auth: existing token invalid, attempting silent refresh")
-		if err := irc.tryRefreshToken(ctx); err == nil {
+		if err := irc.tryRefreshToken(ctx, current); err == nil {
 			return nil
 		}
 		irc.logger.Warn("silent refresh failed, falling through to interactive OAuth")
 	} else {
-		// First-time auth: try env vars.
+		// First-time auth: try the token saved by a previous run.
+		if irc.loadSavedToken(ctx) {
+			return nil
+		}
+
 		// If a refresh token is available, try silent refresh first.
 		if refreshStr != "" {
 			irc.logger.Info("attempting silent token refresh using TWITCH_REFRESH_TOKEN")
-			irc.tok = &oauth2.Token{
+			envTok := &oauth2.Token{
 				AccessToken:  tokenStr,
 				RefreshToken: refreshStr,
 			}
-			if err := irc.tryRefreshToken(ctx); err == nil {
+			if err := irc.tryRefreshToken(ctx, envTok); err == nil {
 				return nil
 			}
 			irc.logger.Warn("silent refresh failed, falling back to other auth methods")
@@ -154,15 +159,14 @@ func (irc *IRC) AuthTwitch(ctx context.Context) error {
 		// Fall back to direct access token from environment.
 		if tokenStr != "" && tokenStr != "null" && tokenStr != "placeholder" {
 			irc.logger.Info("using TWITCH_TOKEN from environment")
-			irc.tok = &oauth2.Token{
+			irc.setToken(ctx, &oauth2.Token{
 				AccessToken: tokenStr,
-			}
-			irc.tokenRefreshTime = time.Now()
+			})
 			return nil
 		}
 	}
 
-	// No env-based token available — run interactive OAuth flow.
+	// No saved or env-based token available — run interactive OAuth flow.
 	irc.logger.Info("initiating interactive OAuth flow", "redirect_host", redirectHost)
 
 	// Register the redirect handler exactly once across all AuthTwitch calls.
@@ -190,11 +194,39 @@ func (irc *IRC) AuthTwitch(ctx context.Context) error {
 	}
 
 	irc.logger.Info("auth code received, exchanging for token")
-	irc.tok, err = irc.oauthConf.Exchange(ctx, irc.authCode)
+	tok, err := irc.oauthConf.Exchange(ctx, irc.authCode)
 	if err != nil {
 		return fmt.Errorf("failed to get token with auth code: %w", err)
 	}
-	irc.tokenRefreshTime = time.Now()
+	irc.setToken(ctx, tok)
 	irc.logger.Info("token received successfully")
 	return nil
 }
+
+// loadSavedToken uses the token persisted by a previous run, refreshing it first if it
+// has expired. It reports whether a usable token was found.
+func (irc *IRC) loadSavedToken(ctx context.Context) bool {
+	if irc.tokens == nil {
+		return false
+	}
+	saved, err := irc.tokens.Load(ctx)
+	if errors.Is(err, token.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		irc.logger.Warn("failed to load saved twitch token", "error", err.Error())
+		return false
+	}
+
+	if saved.Valid() {
+		irc.logger.Info("using saved twitch token", "expiry", saved.Expiry)
+		irc.setToken(ctx, saved)
+		return true
+	}
+	irc.logger.Info("saved twitch token expired, refreshing")
+	if err := irc.tryRefreshToken(ctx, saved); err != nil {
+		irc.logger.Warn("failed to refresh saved twitch token", "error", err.Error())
+		return false
+	}
+	return true
+}
@@ -19,14 +19,21 @@ const tokenExpiryDuration = 12 * time.Hour
 
 // GetAuthHealth returns the current auth token health status
 func (irc *IRC) GetAuthHealth() AuthHealthResponse {
-	hasToken := irc.tok != nil && irc.tok.AccessToken != ""
-	expirationTime := irc.tokenRefreshTime.Add(tokenExpiryDuration)
+	irc.tokMu.RLock()
+	tok, refreshTime := irc.tok, irc.tokenRefreshTime
+	irc.tokMu.RUnlock()
+
+	hasToken := tok != nil && tok.AccessToken != ""
+	expirationTime := refreshTime.Add(tokenExpiryDuration)
+	if hasToken && !tok.Expiry.IsZero() {
+		expirationTime = tok.Expiry
+	}
 	hoursUntilExpiry := time.Until(expirationTime).Hours()
 	isExpired := time.Now().After(expirationTime)
 
 	return AuthHealthResponse{
 		HasToken:         hasToken,
-		LastRefreshTime:  irc.tokenRefreshTime,
+		LastRefreshTime:  refreshTime,
 		ExpirationTime:   expirationTime,
 		IsExpired:        isExpired,
 		HoursUntilExpiry: hoursUntilExpiry,
@@ -0,0 +1,170 @@
+package twitchirc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/twitchchat"
+	"github.com/Soypete/twitch-llm-bot/twitch/helix"
+	"github.com/Soypete/twitch-llm-bot/twitch/moderation"
+	"gopkg.in/yaml.v3"
+)
+
+// ChannelsConfig is the file format for running Pedro in more than one channel.
+type ChannelsConfig struct {
+	Channels []ChannelConfig `yaml:"channels"`
+}
+
+// ChannelConfig holds the per-channel settings. Empty paths fall back to the
+// process wide flags so a single channel setup keeps working unchanged.
+type ChannelConfig struct {
+	// Name is the twitch login of the channel to join (e.g. soypetetech)
+	Name string `yaml:"name"`
+
+	// StreamConfig is the path to a stream context config for this channel
+	StreamConfig string `yaml:"stream_config,omitempty"`
+
+	// ModerationConfig is the path to a moderation config for this channel
+	ModerationConfig string `yaml:"moderation_config,omitempty"`
+
+	// FAQCategories limits FAQ matches to these categories. Empty means all FAQs.
+	FAQCategories []string `yaml:"faq_categories,omitempty"`
+
+	// Greeting is sent when Pedro joins the channel. Empty uses the default greeting.
+	Greeting string `yaml:"greeting,omitempty"`
+
+	// Persona is the prompt persona Pedro uses in this channel. Empty uses -persona.
+	Persona string `yaml:"persona,omitempty"`
+
+	// Emotes replaces the persona's emote list, for channels with their own emotes
+	Emotes []string `yaml:"emotes,omitempty"`
+
+	// Conversation controls how much chat history Pedro keeps. Nil uses the defaults.
+	Conversation *twitchchat.ConversationConfig `yaml:"conversation,omitempty"`
+}
+
+// LoadChannelsConfig loads the multi-channel configuration from a YAML file
+func LoadChannelsConfig(path string) (*ChannelsConfig, error) {
+	if path == "" {
+		return nil, fmt.Errorf("config path cannot be empty")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channels config file %s: %w", path, err)
+	}
+
+	var config ChannelsConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse channels config YAML: %w", err)
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid channels config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// validate normalizes channel names and rejects empty or duplicate channels
+func (c *ChannelsConfig) validate() error {
+	if len(c.Channels) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+
+	seen := make(map[string]bool)
+	for i := range c.Channels {
+		name := normalizeChannel(c.Channels[i].Name)
+		if name == "" {
+			return fmt.Errorf("channels[%d].name is required", i)
+		}
+		if seen[name] {
+			return fmt.Errorf("channel %q is listed more than once", name)
+		}
+		seen[name] = true
+		c.Channels[i].Name = name
+	}
+	return nil
+}
+
+// normalizeChannel lower cases a channel name and strips a leading #
+func normalizeChannel(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+}
+
+// Channel is the runtime state Pedro keeps for each joined channel.
+type Channel struct {
+	Name        string
+	Config      ChannelConfig
+	Greeting    string
+	ModeratorID string
+
+	// broadcasterID is looked up on first use, by goroutines that can run at once
+	mu            sync.RWMutex
+	broadcasterID string
+
+	modConfig    *ai.ModerationConfig
+	modMonitor   *moderation.Monitor
+	helixClient  *helix.Client
+	faqProcessor *FAQProcessor
+
+	// replies holds a slot for each LLM reply being written for the channel
+	replies chan struct{}
+}
+
+const defaultGreeting = "Hello, my name is Pedro_el_asistente I am here to help you."
+
+// maxChannelReplies is how many LLM replies a channel can have in progress at once
+const maxChannelReplies = 2
+
+func newChannel(config ChannelConfig, modConfig *ai.ModerationConfig) *Channel {
+	greeting := config.Greeting
+	if greeting == "" {
+		greeting = defaultGreeting
+	}
+	return &Channel{
+		Name:      config.Name,
+		Config:    config,
+		Greeting:  greeting,
+		modConfig: modConfig,
+		replies:   make(chan struct{}, maxChannelReplies),
+	}
+}
+
+// startReply takes a reply slot, or returns false when the channel already has
+// maxChannelReplies in progress. Call finishReply when the reply is done.
+func (ch *Channel) startReply() bool {
+	select {
+	case ch.replies <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// finishReply frees a slot taken by startReply
+func (ch *Channel) finishReply() {
+	<-ch.replies
+}
+
+// BroadcasterID returns the channel owner's Twitch user ID, or "" before it is looked up
+func (ch *Channel) BroadcasterID() string {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.broadcasterID
+}
+
+// setBroadcasterID records the channel owner's Twitch user ID
+func (ch *Channel) setBroadcasterID(id string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.broadcasterID = id
+}
+
+// moderationEnabled reports whether this channel should run a moderation monitor
+func (ch *Channel) moderationEnabled() bool {
+	return ch.modConfig != nil && ch.modConfig.Enabled
+}
@@ -0,0 +1,139 @@
+package twitchirc
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadChannelsConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		yaml      string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name: "multiple channels",
+			yaml: `channels:
+  - name: soypetetech
+    stream_config: configs/streams/golang.yaml
+  - name: "#ForgeUtah"
+    faq_categories: [events]
+`,
+			wantNames: []string{"soypetetech", "forgeutah"},
+		},
+		{
+			name:    "no channels",
+			yaml:    "channels: []\n",
+			wantErr: true,
+		},
+		{
+			name: "missing name",
+			yaml: `channels:
+  - stream_config: configs/streams/golang.yaml
+`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate channel",
+			yaml: `channels:
+  - name: soypetetech
+  - name: SoyPeteTech
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "channels.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			got, err := LoadChannelsConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadChannelsConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.Channels) != len(tt.wantNames) {
+				t.Fatalf("LoadChannelsConfig() got %d channels, want %d", len(got.Channels), len(tt.wantNames))
+			}
+			for i, name := range tt.wantNames {
+				if got.Channels[i].Name != name {
+					t.Errorf("channels[%d].Name = %q, want %q", i, got.Channels[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadChannelsConfig_Conversation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "channels.yaml")
+	data := `channels:
+  - name: soypetetech
+  - name: forgeutah
+    conversation:
+      max_turns: 20
+      idle_timeout: 1h
+      scope: channel
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	got, err := LoadChannelsConfig(path)
+	if err != nil {
+		t.Fatalf("LoadChannelsConfig() error = %v", err)
+	}
+	if got.Channels[0].Conversation != nil {
+		t.Errorf("channel without conversation config = %+v, want nil", got.Channels[0].Conversation)
+	}
+	conv := got.Channels[1].Conversation
+	if conv == nil || conv.MaxTurns != 20 || conv.IdleTimeout != time.Hour || conv.Scope != "channel" {
+		t.Errorf("conversation config = %+v", conv)
+	}
+}
+
+func TestNewChannelGreeting(t *testing.T) {
+	ch := newChannel(ChannelConfig{Name: "soypetetech"}, nil)
+	if ch.Greeting != defaultGreeting {
+		t.Errorf("Greeting = %q, want default greeting", ch.Greeting)
+	}
+	if ch.moderationEnabled() {
+		t.Error("moderation should be disabled without a config")
+	}
+
+	ch = newChannel(ChannelConfig{Name: "forgeutah", Greeting: "hola"}, nil)
+	if ch.Greeting != "hola" {
+		t.Errorf("Greeting = %q, want %q", ch.Greeting, "hola")
+	}
+}
+
+func TestChannel_BroadcasterID(t *testing.T) {
+	ch := newChannel(ChannelConfig{Name: "soypetetech"}, nil)
+
+	// The reply sender and eventsub setup look the ID up at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ch.setBroadcasterID("12345")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = ch.BroadcasterID()
+		}()
+	}
+	wg.Wait()
+
+	if got := ch.BroadcasterID(); got != "12345" {
+		t.Errorf("BroadcasterID() = %q, want %q", got, "12345")
+	}
+}
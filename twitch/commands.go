@@ -0,0 +1,537 @@
+package twitchirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/types"
+	v2 "github.com/gempir/go-twitch-irc/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrCommandExists is returned when a command name or alias is already registered
+var ErrCommandExists = errors.New("command name is already registered")
+
+// BadgeLevel is the minimum chat role needed to run a command
+type BadgeLevel int
+
+const (
+	BadgeEveryone BadgeLevel = iota
+	BadgeSubscriber
+	BadgeVIP
+	BadgeModerator
+	BadgeBroadcaster
+)
+
+var badgeNames = map[string]BadgeLevel{
+	"":            BadgeEveryone,
+	"everyone":    BadgeEveryone,
+	"subscriber":  BadgeSubscriber,
+	"vip":         BadgeVIP,
+	"moderator":   BadgeModerator,
+	"broadcaster": BadgeBroadcaster,
+}
+
+// ParseBadgeLevel converts a badge name from config into a BadgeLevel
+func ParseBadgeLevel(name string) (BadgeLevel, error) {
+	level, ok := badgeNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return BadgeEveryone, fmt.Errorf("unknown badge level %q", name)
+	}
+	return level, nil
+}
+
+// userBadgeLevel returns the highest badge level a chatter has
+func userBadgeLevel(user v2.User) BadgeLevel {
+	switch {
+	case user.Badges["broadcaster"] > 0:
+		return BadgeBroadcaster
+	case user.Badges["moderator"] > 0:
+		return BadgeModerator
+	case user.Badges["vip"] > 0:
+		return BadgeVIP
+	case user.Badges["subscriber"] > 0, user.Badges["founder"] > 0:
+		return BadgeSubscriber
+	default:
+		return BadgeEveryone
+	}
+}
+
+// CommandArg is a declared command argument
+type CommandArg struct {
+	Name     string
+	Required bool
+	// Rest takes the remainder of the message, so it must be the last argument
+	Rest bool
+}
+
+// ParseArgSpec parses an argument spec like "<user> [reason...]"
+func ParseArgSpec(spec string) ([]CommandArg, error) {
+	var args []CommandArg
+	fields := strings.Fields(spec)
+	for i, field := range fields {
+		var arg CommandArg
+		switch {
+		case strings.HasPrefix(field, "<") && strings.HasSuffix(field, ">"):
+			arg.Required = true
+		case strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]"):
+		default:
+			return nil, fmt.Errorf("argument %q must be written as <name> or [name]", field)
+		}
+		name := field[1 : len(field)-1]
+		if strings.HasSuffix(name, "...") {
+			if i != len(fields)-1 {
+				return nil, fmt.Errorf("argument %q takes the rest of the message and must be last", field)
+			}
+			arg.Rest = true
+			name = strings.TrimSuffix(name, "...")
+		}
+		if name == "" {
+			return nil, fmt.Errorf("argument %q has no name", field)
+		}
+		if arg.Required && len(args) > 0 && !args[len(args)-1].Required {
+			return nil, fmt.Errorf("required argument %q cannot follow an optional one", field)
+		}
+		arg.Name = name
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// CommandInvocation is a single use of a command in chat
+type CommandInvocation struct {
+	Command  *Command
+	Name     string // the name or alias that was typed
+	Channel  string
+	Username string
+	Badge    BadgeLevel
+	Args     map[string]string
+	RawArgs  string
+	Message  types.TwitchMessage
+}
+
+// CommandHandler produces the reply for a command. An empty reply sends nothing.
+type CommandHandler func(ctx context.Context, inv CommandInvocation) (string, error)
+
+// Command is a registered `!` command
+type Command struct {
+	Name         string
+	Aliases      []string
+	Description  string
+	Args         []CommandArg
+	Cooldown     time.Duration
+	UserCooldown time.Duration
+	MinBadge     BadgeLevel
+	Channels     []string
+	Handler      CommandHandler
+
+	// builtin commands are registered in code and replaced by a configured command
+	// with the same name
+	builtin bool
+}
+
+// Usage returns how the command is typed, e.g. "!so <user>"
+func (c *Command) Usage() string {
+	parts := []string{"!" + c.Name}
+	for _, arg := range c.Args {
+		name := arg.Name
+		if arg.Rest {
+			name += "..."
+		}
+		if arg.Required {
+			parts = append(parts, "<"+name+">")
+		} else {
+			parts = append(parts, "["+name+"]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseArgs matches the text after the command name to the declared arguments.
+// Quoted strings count as a single argument.
+func (c *Command) parseArgs(raw string) (map[string]string, error) {
+	tokens := splitArgs(raw)
+	args := make(map[string]string)
+	for i, arg := range c.Args {
+		if i >= len(tokens) {
+			if arg.Required {
+				return nil, fmt.Errorf("missing %s", arg.Name)
+			}
+			break
+		}
+		if arg.Rest {
+			args[arg.Name] = strings.Join(tokens[i:], " ")
+			break
+		}
+		args[arg.Name] = tokens[i]
+	}
+	return args, nil
+}
+
+// splitArgs splits on whitespace, keeping "quoted text" together
+func splitArgs(raw string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && (r == ' ' || r == '\t'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// CommandLLM answers LLM backed commands. *twitchchat.Client satisfies this interface.
+type CommandLLM interface {
+	PromptResponse(ctx context.Context, channel string, prompt string) (string, error)
+}
+
+// CommandRegistry holds the chat commands and tracks their cooldowns
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]*Command // keyed by name and every alias
+	handlers map[string]CommandHandler
+	llm      CommandLLM
+	lastUsed map[string]time.Time
+	now      func() time.Time
+	logger   *logging.Logger
+}
+
+// NewCommandRegistry creates a command registry with the built-in Go handlers and the
+// built-in `!commands` command registered
+func NewCommandRegistry(logger *logging.Logger) *CommandRegistry {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	r := &CommandRegistry{
+		commands: make(map[string]*Command),
+		handlers: make(map[string]CommandHandler),
+		lastUsed: make(map[string]time.Time),
+		now:      time.Now,
+		logger:   logger,
+	}
+	r.RegisterHandler("commands", r.listCommandsHandler)
+	r.registerBuiltin(&Command{
+		Name:        "commands",
+		Aliases:     []string{"help"},
+		Description: "List the commands you can use",
+		Cooldown:    30 * time.Second,
+		Handler:     r.listCommandsHandler,
+	})
+	return r
+}
+
+// SetLLM sets the LLM used by commands defined with an llm_prompt
+func (r *CommandRegistry) SetLLM(llm CommandLLM) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.llm = llm
+}
+
+// RegisterHandler makes a Go handler available to commands that reference it by name
+func (r *CommandRegistry) RegisterHandler(name string, handler CommandHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[strings.ToLower(name)] = handler
+}
+
+// Register adds a command. It fails if the name or an alias is already taken.
+func (r *CommandRegistry) Register(cmd *Command) error {
+	if cmd.Handler == nil {
+		return fmt.Errorf("command %s has no handler", cmd.Name)
+	}
+	cmd.Name = strings.ToLower(strings.TrimPrefix(cmd.Name, "!"))
+	if cmd.Name == "" {
+		return fmt.Errorf("command name is required")
+	}
+	for i, alias := range cmd.Aliases {
+		cmd.Aliases[i] = strings.ToLower(strings.TrimPrefix(alias, "!"))
+	}
+	for i, ch := range cmd.Channels {
+		cmd.Channels[i] = normalizeChannel(ch)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, name := range names {
+		if existing, exists := r.commands[name]; exists && (cmd.builtin || !existing.builtin) {
+			return fmt.Errorf("%w: %q", ErrCommandExists, name)
+		}
+	}
+	for _, name := range names {
+		if existing, exists := r.commands[name]; exists {
+			r.logger.Info("configured command replaces built-in command", "command", cmd.Name, "builtin", existing.Name)
+			r.removeLocked(existing)
+		}
+		r.commands[name] = cmd
+	}
+	return nil
+}
+
+// registerBuiltin adds a command that works without any configuration. A configured
+// command with the same name or alias replaces it.
+func (r *CommandRegistry) registerBuiltin(cmd *Command) {
+	cmd.builtin = true
+	if err := r.Register(cmd); err != nil {
+		r.logger.Error("failed to register built-in command", "command", cmd.Name, "error", err.Error())
+	}
+}
+
+// removeLocked drops a command under its name and every alias. r.mu must be held.
+func (r *CommandRegistry) removeLocked(cmd *Command) {
+	for name, registered := range r.commands {
+		if registered == cmd {
+			delete(r.commands, name)
+		}
+	}
+}
+
+// RegisterConfig builds a command from its definition and registers it
+func (r *CommandRegistry) RegisterConfig(config types.ChatCommand) error {
+	cmd, err := r.buildCommand(config)
+	if err != nil {
+		return fmt.Errorf("invalid command %s: %w", config.Name, err)
+	}
+	return r.Register(cmd)
+}
+
+func (r *CommandRegistry) buildCommand(config types.ChatCommand) (*Command, error) {
+	args, err := ParseArgSpec(config.Args)
+	if err != nil {
+		return nil, err
+	}
+	badge, err := ParseBadgeLevel(config.Badge)
+	if err != nil {
+		return nil, err
+	}
+
+	set := 0
+	for _, v := range []string{config.Response, config.LLMPrompt, config.Handler} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("exactly one of response, llm_prompt or handler must be set")
+	}
+
+	var handler CommandHandler
+	switch {
+	case config.Response != "":
+		response := config.Response
+		handler = func(_ context.Context, inv CommandInvocation) (string, error) {
+			return renderCommandText(response, inv), nil
+		}
+	case config.LLMPrompt != "":
+		prompt := config.LLMPrompt
+		handler = func(ctx context.Context, inv CommandInvocation) (string, error) {
+			r.mu.RLock()
+			llm := r.llm
+			r.mu.RUnlock()
+			if llm == nil {
+				return "", fmt.Errorf("no LLM configured for commands")
+			}
+			return llm.PromptResponse(ctx, inv.Channel, renderCommandText(prompt, inv))
+		}
+	default:
+		r.mu.RLock()
+		goHandler, ok := r.handlers[strings.ToLower(config.Handler)]
+		r.mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown handler %q", config.Handler)
+		}
+		handler = goHandler
+	}
+
+	return &Command{
+		Name:         config.Name,
+		Aliases:      slices.Clone(config.Aliases),
+		Description:  config.Description,
+		Args:         args,
+		Cooldown:     time.Duration(config.CooldownSeconds) * time.Second,
+		UserCooldown: time.Duration(config.UserCooldownSeconds) * time.Second,
+		MinBadge:     badge,
+		Channels:     slices.Clone(config.Channels),
+		Handler:      handler,
+	}, nil
+}
+
+// renderCommandText fills in {user}, {channel}, {args} and named argument placeholders
+func renderCommandText(text string, inv CommandInvocation) string {
+	pairs := []string{
+		"{user}", inv.Username,
+		"{channel}", inv.Channel,
+		"{args}", inv.RawArgs,
+	}
+	for name, value := range inv.Args {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Get returns the command registered under a name or alias
+func (r *CommandRegistry) Get(name string) *Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.commands[strings.ToLower(strings.TrimPrefix(name, "!"))]
+}
+
+// Commands returns every registered command sorted by name
+func (r *CommandRegistry) Commands() []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var cmds []*Command
+	for name, cmd := range r.commands {
+		if name == cmd.Name {
+			cmds = append(cmds, cmd)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// Dispatch runs the command in a chat message. handled is false when the message
+// is not a known command for this channel, so the caller can treat it as chat.
+func (r *CommandRegistry) Dispatch(ctx context.Context, msg v2.PrivateMessage, chat types.TwitchMessage) (reply string, handled bool) {
+	text := strings.TrimSpace(chat.Text)
+	if !strings.HasPrefix(text, "!") {
+		return "", false
+	}
+	name, rawArgs, _ := strings.Cut(text[1:], " ")
+	rawArgs = strings.TrimSpace(rawArgs)
+
+	cmd := r.Get(name)
+	if cmd == nil {
+		return "", false
+	}
+	channel := normalizeChannel(chat.Channel)
+	if len(cmd.Channels) > 0 && !slices.Contains(cmd.Channels, channel) {
+		return "", false
+	}
+
+	badge := userBadgeLevel(msg.User)
+	if badge < cmd.MinBadge {
+		r.logger.Debug("command badge level too low", "command", cmd.Name, "user", chat.Username, "channel", channel)
+		return "", true
+	}
+
+	// Mods and the broadcaster are not held to cooldowns
+	user := strings.ToLower(chat.Username)
+	if badge < BadgeModerator && !r.cooldownReady(cmd, channel, user) {
+		r.logger.Debug("command on cooldown", "command", cmd.Name, "user", chat.Username, "channel", channel)
+		return "", true
+	}
+
+	args, err := cmd.parseArgs(rawArgs)
+	if err != nil {
+		return fmt.Sprintf("@%s usage: %s", chat.Username, cmd.Usage()), true
+	}
+
+	inv := CommandInvocation{
+		Command:  cmd,
+		Name:     strings.ToLower(name),
+		Channel:  channel,
+		Username: chat.Username,
+		Badge:    badge,
+		Args:     args,
+		RawArgs:  rawArgs,
+		Message:  chat,
+	}
+	reply, err = cmd.Handler(ctx, inv)
+	if err != nil {
+		r.logger.Error("command failed", "command", cmd.Name, "channel", channel, "error", err.Error())
+		return "", true
+	}
+
+	r.markUsed(cmd, channel, user)
+	r.logger.Info("command executed", "command", cmd.Name, "alias", inv.Name, "channel", channel, "user", chat.Username)
+	return reply, true
+}
+
+func (r *CommandRegistry) cooldownReady(cmd *Command, channel, user string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	if cmd.Cooldown > 0 {
+		if last, ok := r.lastUsed[channel+"|"+cmd.Name]; ok && now.Sub(last) < cmd.Cooldown {
+			return false
+		}
+	}
+	if cmd.UserCooldown > 0 {
+		if last, ok := r.lastUsed[channel+"|"+cmd.Name+"|"+user]; ok && now.Sub(last) < cmd.UserCooldown {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *CommandRegistry) markUsed(cmd *Command, channel, user string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.lastUsed[channel+"|"+cmd.Name] = now
+	r.lastUsed[channel+"|"+cmd.Name+"|"+user] = now
+}
+
+// listCommandsHandler is the built-in "commands" handler
+func (r *CommandRegistry) listCommandsHandler(_ context.Context, inv CommandInvocation) (string, error) {
+	var names []string
+	for _, cmd := range r.Commands() {
+		if cmd.MinBadge > inv.Badge {
+			continue
+		}
+		if len(cmd.Channels) > 0 && !slices.Contains(cmd.Channels, inv.Channel) {
+			continue
+		}
+		names = append(names, "!"+cmd.Name)
+	}
+	return "Commands: " + strings.Join(names, " "), nil
+}
+
+// CommandsConfig is the file format for chat commands
+type CommandsConfig struct {
+	Commands []types.ChatCommand `yaml:"commands"`
+}
+
+// LoadCommandsConfig loads chat command definitions from a YAML file
+func LoadCommandsConfig(path string) (*CommandsConfig, error) {
+	if path == "" {
+		return nil, fmt.Errorf("config path cannot be empty")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commands config file %s: %w", path, err)
+	}
+
+	var config CommandsConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse commands config YAML: %w", err)
+	}
+
+	return &config, nil
+}
@@ -0,0 +1,265 @@
+package twitchirc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/types"
+	v2 "github.com/gempir/go-twitch-irc/v2"
+)
+
+type fakeCommandLLM struct {
+	prompt string
+}
+
+func (f *fakeCommandLLM) PromptResponse(_ context.Context, _ string, prompt string) (string, error) {
+	f.prompt = prompt
+	return "Goroutines are cheap threads soypet2Thinking", nil
+}
+
+func commandMessage(user, text string, badges map[string]int) (v2.PrivateMessage, types.TwitchMessage) {
+	msg := v2.PrivateMessage{
+		User:    v2.User{Name: user, DisplayName: user, Badges: badges},
+		Channel: "soypetetech",
+		Message: text,
+	}
+	return msg, cleanMessage(msg)
+}
+
+func TestParseArgSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []CommandArg
+		wantErr bool
+	}{
+		{name: "empty", spec: "", want: nil},
+		{
+			name: "required and rest",
+			spec: "<user> [reason...]",
+			want: []CommandArg{{Name: "user", Required: true}, {Name: "reason", Rest: true}},
+		},
+		{name: "rest not last", spec: "<topic...> <user>", wantErr: true},
+		{name: "required after optional", spec: "[user] <reason>", wantErr: true},
+		{name: "bare word", spec: "user", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseArgSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseArgSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseArgSpec() = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("arg %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCommandRegistry_Dispatch(t *testing.T) {
+	llm := &fakeCommandLLM{}
+	r := NewCommandRegistry(nil)
+	r.SetLLM(llm)
+
+	configs := []types.ChatCommand{
+		{Name: "discord", Aliases: []string{"disc"}, Response: "Join us {user}!"},
+		{Name: "so", Args: "<streamer>", Response: "Go follow @{streamer}", Badge: "moderator"},
+		{Name: "explain", Args: "<topic...>", LLMPrompt: "Explain {topic}"},
+		{Name: "commands", Handler: "commands"},
+		{Name: "meetup", Response: "Forge Utah meetup tonight", Channels: []string{"forgeutah"}},
+	}
+	for _, cfg := range configs {
+		if err := r.RegisterConfig(cfg); err != nil {
+			t.Fatalf("RegisterConfig(%s) error = %v", cfg.Name, err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		user        string
+		text        string
+		badges      map[string]int
+		wantReply   string
+		wantHandled bool
+	}{
+		{name: "static response", user: "viewer", text: "!discord", wantReply: "Join us viewer!", wantHandled: true},
+		{name: "alias", user: "viewer", text: "!DISC", wantReply: "Join us viewer!", wantHandled: true},
+		{name: "unknown command", user: "viewer", text: "!lurk", wantHandled: false},
+		{name: "badge too low", user: "viewer", text: "!so forgeutah", wantHandled: true},
+		{name: "moderator", user: "modperson", text: "!so forgeutah", badges: map[string]int{"moderator": 1}, wantReply: "Go follow @forgeutah", wantHandled: true},
+		{name: "missing argument", user: "modperson", text: "!so", badges: map[string]int{"moderator": 1}, wantReply: "@modperson usage: !so <streamer>", wantHandled: true},
+		{name: "llm prompt", user: "viewer", text: "!explain go routines", wantReply: "Goroutines are cheap threads soypet2Thinking", wantHandled: true},
+		{name: "other channel only", user: "viewer", text: "!meetup", wantHandled: false},
+		{name: "go handler", user: "viewer", text: "!commands", wantReply: "Commands: !commands !discord !explain", wantHandled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, chat := commandMessage(tt.user, tt.text, tt.badges)
+			reply, handled := r.Dispatch(context.Background(), msg, chat)
+			if handled != tt.wantHandled {
+				t.Errorf("Dispatch() handled = %v, want %v", handled, tt.wantHandled)
+			}
+			if reply != tt.wantReply {
+				t.Errorf("Dispatch() reply = %q, want %q", reply, tt.wantReply)
+			}
+		})
+	}
+
+	if llm.prompt != "Explain go routines" {
+		t.Errorf("LLM prompt = %q, want %q", llm.prompt, "Explain go routines")
+	}
+}
+
+func TestCommandRegistry_Cooldowns(t *testing.T) {
+	now := time.Now()
+	r := NewCommandRegistry(nil)
+	r.now = func() time.Time { return now }
+
+	if err := r.RegisterConfig(types.ChatCommand{Name: "hydrate", Response: "drink water", CooldownSeconds: 30, UserCooldownSeconds: 120}); err != nil {
+		t.Fatalf("RegisterConfig() error = %v", err)
+	}
+
+	dispatch := func(user string, badges map[string]int) string {
+		msg, chat := commandMessage(user, "!hydrate", badges)
+		reply, _ := r.Dispatch(context.Background(), msg, chat)
+		return reply
+	}
+
+	if got := dispatch("viewer", nil); got != "drink water" {
+		t.Fatalf("first use = %q", got)
+	}
+	if got := dispatch("other", nil); got != "" {
+		t.Errorf("channel cooldown should block a second viewer, got %q", got)
+	}
+	if got := dispatch("modperson", map[string]int{"moderator": 1}); got != "drink water" {
+		t.Errorf("moderators should skip cooldowns, got %q", got)
+	}
+
+	now = now.Add(31 * time.Second)
+	if got := dispatch("viewer", nil); got != "" {
+		t.Errorf("user cooldown should still block the first viewer, got %q", got)
+	}
+	if got := dispatch("other", nil); got != "drink water" {
+		t.Errorf("channel cooldown should have expired, got %q", got)
+	}
+}
+
+func TestCommandRegistry_RegisterErrors(t *testing.T) {
+	r := NewCommandRegistry(nil)
+	if err := r.RegisterConfig(types.ChatCommand{Name: "discord", Response: "hi"}); err != nil {
+		t.Fatalf("RegisterConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cmd  types.ChatCommand
+	}{
+		{name: "duplicate name", cmd: types.ChatCommand{Name: "discord", Response: "hi"}},
+		{name: "alias collides", cmd: types.ChatCommand{Name: "chat", Aliases: []string{"discord"}, Response: "hi"}},
+		{name: "no reply", cmd: types.ChatCommand{Name: "empty"}},
+		{name: "two replies", cmd: types.ChatCommand{Name: "both", Response: "hi", LLMPrompt: "hi"}},
+		{name: "unknown handler", cmd: types.ChatCommand{Name: "uptime", Handler: "uptime"}},
+		{name: "bad badge", cmd: types.ChatCommand{Name: "vipcmd", Response: "hi", Badge: "admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.RegisterConfig(tt.cmd); err == nil {
+				t.Error("RegisterConfig() expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadCommandsConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.yaml")
+	data := `commands:
+  - name: explain
+    aliases: [eli5]
+    args: "<topic...>"
+    llm_prompt: "Explain {topic}"
+    cooldown_seconds: 30
+    badge: subscriber
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	config, err := LoadCommandsConfig(path)
+	if err != nil {
+		t.Fatalf("LoadCommandsConfig() error = %v", err)
+	}
+	if len(config.Commands) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(config.Commands))
+	}
+	cmd := config.Commands[0]
+	if cmd.Name != "explain" || len(cmd.Aliases) != 1 || cmd.Aliases[0] != "eli5" || cmd.CooldownSeconds != 30 || cmd.Badge != "subscriber" {
+		t.Errorf("unexpected command: %+v", cmd)
+	}
+
+	r := NewCommandRegistry(nil)
+	if err := r.RegisterConfig(cmd); err != nil {
+		t.Fatalf("RegisterConfig() error = %v", err)
+	}
+	if got := r.Get("!eli5"); got == nil || got.Usage() != "!explain <topic...>" {
+		t.Errorf("Get(eli5) usage = %v", got)
+	}
+}
+
+func TestIRC_LoadCommandsSkipsDuplicates(t *testing.T) {
+	irc := &IRC{commands: NewCommandRegistry(nil), logger: logging.Default()}
+	yamlCommands := []types.ChatCommand{{Name: "discord", Response: "yaml discord"}}
+	dbCommands := []types.ChatCommand{
+		{Name: "discord", Response: "db discord"},
+		{Name: "meetup", Response: "db meetup"},
+	}
+
+	if err := irc.LoadCommands(yamlCommands); err != nil {
+		t.Fatalf("LoadCommands(yaml) error = %v", err)
+	}
+	if err := irc.LoadCommands(dbCommands); err != nil {
+		t.Fatalf("LoadCommands(db) error = %v", err)
+	}
+
+	for text, want := range map[string]string{"!discord": "yaml discord", "!meetup": "db meetup"} {
+		msg, chat := commandMessage("viewer", text, nil)
+		if reply, _ := irc.commands.Dispatch(context.Background(), msg, chat); reply != want {
+			t.Errorf("Dispatch(%s) = %q, want %q", text, reply, want)
+		}
+	}
+}
+
+func TestCommandRegistry_Builtins(t *testing.T) {
+	r := NewCommandRegistry(nil)
+	msg, chat := commandMessage("viewer", "!help", nil)
+	if reply, handled := r.Dispatch(context.Background(), msg, chat); !handled || reply != "Commands: !commands" {
+		t.Errorf("Dispatch(!help) = %q, %v, want the built-in command list", reply, handled)
+	}
+
+	// A configured command replaces the built-in one, names and aliases included
+	if err := r.RegisterConfig(types.ChatCommand{Name: "commands", Response: "see the panels"}); err != nil {
+		t.Fatalf("RegisterConfig() error = %v", err)
+	}
+	msg, chat = commandMessage("viewer", "!commands", nil)
+	if reply, _ := r.Dispatch(context.Background(), msg, chat); reply != "see the panels" {
+		t.Errorf("Dispatch(!commands) = %q, want the configured reply", reply)
+	}
+	if r.Get("!help") != nil {
+		t.Error("the built-in alias should be gone")
+	}
+	if err := r.RegisterConfig(types.ChatCommand{Name: "commands", Response: "again"}); !errors.Is(err, ErrCommandExists) {
+		t.Errorf("RegisterConfig() error = %v, want ErrCommandExists", err)
+	}
+}
@@ -5,22 +5,35 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/ai/chatmemory"
+	"github.com/Soypete/twitch-llm-bot/ai/provider"
+	"github.com/Soypete/twitch-llm-bot/ai/twitchchat"
 	"github.com/Soypete/twitch-llm-bot/database"
+	"github.com/Soypete/twitch-llm-bot/faq"
 	"github.com/Soypete/twitch-llm-bot/internal/mempalace"
+	"github.com/Soypete/twitch-llm-bot/internal/mempalace/tools"
 	"github.com/Soypete/twitch-llm-bot/logging"
 	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/twitch/eventsub"
 	"github.com/Soypete/twitch-llm-bot/twitch/helix"
 	"github.com/Soypete/twitch-llm-bot/twitch/messagequeue"
 	"github.com/Soypete/twitch-llm-bot/twitch/moderation"
+	"github.com/Soypete/twitch-llm-bot/twitch/outbound"
+	"github.com/Soypete/twitch-llm-bot/twitch/token"
+	"github.com/Soypete/twitch-llm-bot/twitch/twentyquestions"
 	"github.com/Soypete/twitch-llm-bot/types"
 	v2 "github.com/gempir/go-twitch-irc/v2"
 	"github.com/pkg/errors"
+	"github.com/tmc/langchaingo/llms"
 	"golang.org/x/oauth2"
 )
 
+// peteTwitchChannel is the default channel when no channels config is provided
 const peteTwitchChannel = "soypetetech"
 
 // IRC Connection to the twitch IRC server.
@@ -30,6 +43,7 @@ type IRC struct {
 	modelName        string
 	wg               *sync.WaitGroup
 	Client           *v2.Client
+	tokMu            sync.RWMutex
 	tok              *oauth2.Token
 	tokenRefreshTime time.Time // Time when the token was last refreshed
 	llm              ai.Chatter
@@ -44,21 +58,57 @@ type IRC struct {
 	sessionRegistry *SessionRegistry
 	palaceDataDir   string
 
+	// Joined channels, keyed by normalized channel name
+	channels     map[string]*Channel
+	channelNames []string
+
 	// Moderation system
-	modMonitor    *moderation.Monitor
-	modConfig     *ai.ModerationConfig
-	helixClient   *helix.Client
-	broadcasterID string
-	moderatorID   string
+	helixClient *helix.Client
+	moderatorID string
 
 	// Message queue system
 	messageBroker *messagequeue.Broker
 
-	// FAQ processor
-	faqProcessor *FAQProcessor
+	// EventSub events (follows, subs, raids, cheers, redemptions)
+	eventBus *eventsub.Bus
+
+	// Chat `!` commands
+	commands *CommandRegistry
+
+	// Twenty Questions games, nil when the LLM cannot play
+	twentyQuestions *twentyquestions.Manager
+
+	// Sources behind Pedro's answers, nil when the database does not keep them
+	sources database.AnswerSourcesStore
+
+	// Decides which messages Pedro replies to
+	trigger *Trigger
+
+	// Outbound chat queue, every message to chat goes through it
+	sender *outbound.Sender
+
+	// LLM used by the moderation monitors
+	modLLM llms.Model
+
+	// subsystemsOnce starts the sender, monitors, broker and async handler on the first connect
+	subsystemsOnce sync.Once
+	// onConnect is called every time the IRC connection is established
+	onConnect func()
 
 	// Mem Palace for chat history
 	memPalace *mempalace.MemPalace
+
+	// Embeds persisted chat and recalls similar messages from earlier streams
+	chatMemory *chatmemory.Memory
+
+	// tokens persists the token and refreshes it before it expires. helixClients
+	// are given the new access token after every refresh.
+	tokens       *token.Manager
+	helixClients []*helix.Client
+
+	// Shutdown: intakeStopped drops new messages, inflight counts handlers still running
+	intakeStopped atomic.Bool
+	inflight      atomic.Int64
 }
 
 // SetupTwitchIRC sets up the IRC, configures oauth, and inits connection functions.
@@ -72,9 +122,73 @@ func SetupTwitchIRC(wg *sync.WaitGroup, llm ai.Chatter, modelName string, db dat
 
 // SetupTwitchIRCWithModeration sets up the IRC with optional moderation support
 func SetupTwitchIRCWithModeration(wg *sync.WaitGroup, llm ai.Chatter, modelName string, db database.ChatResponseWriter, modDB database.ModActionWriter, modConfig *ai.ModerationConfig, logger *logging.Logger, palaceDataDir string) (*IRC, error) {
+	channels := []ChannelConfig{{Name: peteTwitchChannel}}
+	return SetupTwitchIRCWithChannels(wg, llm, modelName, db, modDB, modConfig, channels, logger, palaceDataDir)
+}
+
+// SetupTwitchIRCWithChannels sets up the IRC for a list of channels. modConfig is the default
+// moderation config for channels that do not point at their own moderation config file.
+func SetupTwitchIRCWithChannels(wg *sync.WaitGroup, llm ai.Chatter, modelName string, db database.ChatResponseWriter, modDB database.ModActionWriter, modConfig *ai.ModerationConfig, channels []ChannelConfig, logger *logging.Logger, palaceDataDir string) (*IRC, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	irc, err := newIRC(wg, llm, modelName, db, modDB, modConfig, channels, logger, palaceDataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	irc.tokens = token.NewManager(newTokenStore(db, logger), logger)
+	irc.tokens.Subscribe(irc.applyToken)
+
+	// using a separate context here because it needs human interaction
+	ctx := context.Background()
+	err = irc.AuthTwitch(ctx)
+	if err != nil {
+		logger.Error("failed to authenticate with twitch", "error", err.Error())
+		return nil, errors.Wrap(err, "failed to authenticate with twitch")
+	}
+
+	logger.Info("authenticating with twitch IRC", "channels", irc.channelNames)
+
+	// Set up Helix clients for channels with moderation enabled
+	if irc.anyModerationEnabled() {
+		if err := irc.setupModeration(ctx); err != nil {
+			logger.Error("failed to setup moderation", "error", err.Error())
+			// Continue without moderation - don't fail the whole bot
+			irc.disableModeration()
+		}
+	}
+
+	return irc, nil
+}
+
+// NewOfflineIRC creates an IRC that never connects to Twitch, for replaying recorded
+// chat. Everything Pedro sends goes to transport without Twitch's rate limits, and there
+// are no palace sessions or moderation monitors. Start the sender with GetSender().Start.
+func NewOfflineIRC(llm ai.Chatter, modelName string, db database.ChatResponseWriter, channels []ChannelConfig, transport outbound.Transport, logger *logging.Logger) (*IRC, error) {
 	if logger == nil {
 		logger = logging.Default()
 	}
+	irc, err := newIRC(&sync.WaitGroup{}, llm, modelName, db, nil, nil, channels, logger, "")
+	if err != nil {
+		return nil, err
+	}
+
+	irc.sessionRegistry = nil
+	recorder, _ := db.(outbound.Recorder)
+	irc.sender = outbound.NewSender(transport, recorder, outbound.Config{
+		RateLimit:       1 << 20,
+		RateWindow:      time.Millisecond,
+		DuplicateWindow: time.Nanosecond,
+	}, logger)
+	return irc, nil
+}
+
+// newIRC builds an IRC and its channels, sender and commands without connecting to Twitch
+func newIRC(wg *sync.WaitGroup, llm ai.Chatter, modelName string, db database.ChatResponseWriter, modDB database.ModActionWriter, modConfig *ai.ModerationConfig, channels []ChannelConfig, logger *logging.Logger, palaceDataDir string) (*IRC, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("at least one channel is required")
+	}
 
 	irc := &IRC{
 		db:              db,
@@ -84,10 +198,57 @@ func SetupTwitchIRCWithModeration(wg *sync.WaitGroup, llm ai.Chatter, modelName
 		modelName:       modelName,
 		logger:          logger,
 		asyncResponseCh: make(chan types.TwitchMessage, 10),
-		modConfig:       modConfig,
 		palaceDataDir:   palaceDataDir,
+		channels:        make(map[string]*Channel),
 	}
 
+	for _, chConfig := range channels {
+		chConfig.Name = normalizeChannel(chConfig.Name)
+		chModConfig := modConfig
+		if chConfig.ModerationConfig != "" {
+			loaded, err := ai.LoadModerationConfig(chConfig.ModerationConfig)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to load moderation config for channel %s", chConfig.Name)
+			}
+			chModConfig = loaded
+		}
+		irc.channels[chConfig.Name] = newChannel(chConfig, chModConfig)
+		irc.channelNames = append(irc.channelNames, chConfig.Name)
+	}
+
+	// The database records every message sent when it supports it
+	recorder, _ := db.(outbound.Recorder)
+	irc.sender = outbound.NewSender(nil, recorder, outbound.DefaultConfig(), logger)
+	irc.sender.SetReplier(newChatReplier(irc))
+
+	irc.commands = NewCommandRegistry(logger)
+	if commandLLM, ok := llm.(CommandLLM); ok {
+		irc.commands.SetLLM(commandLLM)
+	}
+	irc.sources, _ = db.(database.AnswerSourcesStore)
+	irc.commands.RegisterHandler("sources", irc.sourcesHandler())
+	irc.commands.registerBuiltin(&Command{
+		Name:         "sources",
+		Description:  "List the sources behind Pedro's last answer to you",
+		UserCooldown: 30 * time.Second,
+		Handler:      irc.sourcesHandler(),
+	})
+	if chat, ok := llm.(*twitchchat.Client); ok {
+		irc.commands.RegisterHandler("plan", irc.planHandler(chat))
+		irc.commands.RegisterHandler("purgesearch", irc.purgeSearchHandler(chat))
+
+		// Games are kept in the database when it supports them
+		store, _ := db.(database.TwentyQuestionsStore)
+		irc.twentyQuestions = twentyquestions.NewManager(chat, store, twentyquestions.DefaultConfig(), logger)
+		irc.twentyQuestions.SetAnnouncer(func(ctx context.Context, channel, text string) {
+			irc.say(ctx, outbound.Message{Channel: channel, Text: text, Source: outbound.SourceCommand})
+		})
+		irc.commands.RegisterHandler("20q", irc.twentyQuestionsHandler(irc.twentyQuestions))
+		irc.commands.RegisterHandler("guess", irc.guessHandler(irc.twentyQuestions))
+	}
+
+	irc.trigger = NewTrigger(nil, DefaultAddressThreshold, nil, logger)
+
 	irc.sessionRegistry = NewSessionRegistry(palaceDataDir, logger)
 
 	absPath, absErr := filepath.Abs(palaceDataDir)
@@ -97,29 +258,27 @@ func SetupTwitchIRCWithModeration(wg *sync.WaitGroup, llm ai.Chatter, modelName
 		irc.palaceDataDir = palaceDataDir
 	}
 
-	// using a separate context here because it needs human interaction
-	ctx := context.Background()
-	err := irc.AuthTwitch(ctx)
-	if err != nil {
-		logger.Error("failed to authenticate with twitch", "error", err.Error())
-		return nil, errors.Wrap(err, "failed to authenticate with twitch")
-	}
-
-	logger.Info("authenticating with twitch IRC")
+	return irc, nil
+}
 
-	// Set up Helix client if moderation is enabled
-	if modConfig != nil && modConfig.Enabled {
-		if err := irc.setupModeration(ctx); err != nil {
-			logger.Error("failed to setup moderation", "error", err.Error())
-			// Continue without moderation - don't fail the whole bot
-			irc.modConfig = nil
+// anyModerationEnabled reports whether at least one channel has moderation turned on
+func (irc *IRC) anyModerationEnabled() bool {
+	for _, ch := range irc.channels {
+		if ch.moderationEnabled() {
+			return true
 		}
 	}
+	return false
+}
 
-	return irc, nil
+// disableModeration turns moderation off for every channel
+func (irc *IRC) disableModeration() {
+	for _, ch := range irc.channels {
+		ch.modConfig = nil
+	}
 }
 
-// setupModeration initializes the moderation system
+// setupModeration initializes the moderation system for every moderated channel
 func (irc *IRC) setupModeration(ctx context.Context) error {
 	clientID := os.Getenv("TWITCH_ID")
 	if clientID == "" {
@@ -127,124 +286,272 @@ func (irc *IRC) setupModeration(ctx context.Context) error {
 	}
 
 	// Get broadcaster and moderator IDs
-	irc.helixClient = helix.NewClient(clientID, irc.tok.AccessToken, "", "", irc.logger)
+	botClient := irc.newHelixClient(clientID, "", "")
 
 	// Get the bot's user ID (moderator ID)
-	botUserID, err := irc.helixClient.GetUserIDByLogin(ctx, "pedro_el_asistente")
+	botUserID, err := lookupBotUserID(ctx, botClient)
 	if err != nil {
-		// Try with soy_llm_bot as fallback
-		botUserID, err = irc.helixClient.GetUserIDByLogin(ctx, "soy_llm_bot")
+		return err
+	}
+	irc.moderatorID = botUserID
+
+	for _, name := range irc.channelNames {
+		ch := irc.channels[name]
+		if !ch.moderationEnabled() {
+			continue
+		}
+
+		// Get broadcaster ID
+		broadcasterID, err := botClient.GetUserIDByLogin(ctx, ch.Name)
 		if err != nil {
-			return errors.Wrap(err, "failed to get bot user ID")
+			irc.logger.Error("failed to get broadcaster ID, disabling moderation for channel", "channel", ch.Name, "error", err.Error())
+			ch.modConfig = nil
+			continue
+		}
+		ch.setBroadcasterID(broadcasterID)
+		ch.ModeratorID = botUserID
+
+		// Each channel gets a helix client scoped to its broadcaster
+		ch.helixClient = irc.newHelixClient(clientID, broadcasterID, botUserID)
+		if irc.helixClient == nil {
+			irc.helixClient = ch.helixClient
 		}
+
+		irc.logger.Info("moderation system initialized",
+			"channel", ch.Name,
+			"broadcasterID", broadcasterID,
+			"moderatorID", botUserID,
+			"dryRun", ch.modConfig.DryRun,
+		)
 	}
-	irc.moderatorID = botUserID
 
-	// Get broadcaster ID
-	broadcasterID, err := irc.helixClient.GetUserIDByLogin(ctx, peteTwitchChannel)
-	if err != nil {
-		return errors.Wrap(err, "failed to get broadcaster ID")
+	if irc.helixClient == nil {
+		return errors.New("no channel could be set up for moderation")
 	}
-	irc.broadcasterID = broadcasterID
 
-	// Update helix client with proper IDs
-	irc.helixClient = helix.NewClient(clientID, irc.tok.AccessToken, broadcasterID, botUserID, irc.logger)
+	return nil
+}
 
-	irc.logger.Info("moderation system initialized",
-		"broadcasterID", broadcasterID,
-		"moderatorID", botUserID,
-		"dryRun", irc.modConfig.DryRun,
-	)
+// botLogins are the Twitch accounts Pedro chats as
+var botLogins = []string{"pedro_el_asistente", "soy_llm_bot"}
 
-	return nil
+// lookupBotUserID returns the twitch user ID of the bot account
+func lookupBotUserID(ctx context.Context, client *helix.Client) (string, error) {
+	botUserID, err := client.GetUserIDByLogin(ctx, botLogins[0])
+	if err != nil {
+		// Try with soy_llm_bot as fallback
+		botUserID, err = client.GetUserIDByLogin(ctx, botLogins[1])
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get bot user ID")
+		}
+	}
+	return botUserID, nil
 }
 
-// SetFAQProcessor sets the FAQ processor for semantic FAQ matching
+// SetFAQProcessor sets the FAQ processor for semantic FAQ matching on every channel
 // The FAQ processor runs in parallel with the main chat processing
 func (irc *IRC) SetFAQProcessor(processor *FAQProcessor) {
-	irc.faqProcessor = processor
+	for _, ch := range irc.channels {
+		ch.faqProcessor = processor
+	}
 	irc.logger.Info("FAQ processor enabled")
 }
 
+// SetFAQService creates a FAQ processor for each channel, scoped to that channel's FAQ categories
+func (irc *IRC) SetFAQService(service *faq.Service) {
+	for _, name := range irc.channelNames {
+		ch := irc.channels[name]
+		ch.faqProcessor = NewChannelFAQProcessor(service, ch.Name, ch.Config.FAQCategories, irc.asyncResponseCh, irc.logger)
+		irc.logger.Info("FAQ processor enabled", "channel", ch.Name, "categories", ch.Config.FAQCategories)
+	}
+}
+
+// FAQCategories returns the FAQ categories of each channel that limits them
+func (irc *IRC) FAQCategories() map[string][]string {
+	categories := make(map[string][]string)
+	for _, name := range irc.channelNames {
+		if ch := irc.channels[name]; len(ch.Config.FAQCategories) > 0 {
+			categories[name] = ch.Config.FAQCategories
+		}
+	}
+	return categories
+}
+
 // GetAsyncResponseChannel returns the channel for sending async responses to chat
 // Used by the FAQ processor to send FAQ responses
 func (irc *IRC) GetAsyncResponseChannel() chan<- types.TwitchMessage {
 	return irc.asyncResponseCh
 }
 
-// connectIRC gets the auth and connects to the twitch IRC server for channel.
+// Channels returns the names of the joined channels in config order
+func (irc *IRC) Channels() []string {
+	return irc.channelNames
+}
+
+// GetChannel returns the runtime state for a channel, or nil if Pedro has not joined it
+func (irc *IRC) GetChannel(name string) *Channel {
+	return irc.channels[normalizeChannel(name)]
+}
+
+// primaryChannel is the first configured channel. It is used for messages that
+// do not carry a channel, such as responses from older code paths.
+func (irc *IRC) primaryChannel() string {
+	if len(irc.channelNames) == 0 {
+		return peteTwitchChannel
+	}
+	return irc.channelNames[0]
+}
+
+// responseChannel returns the channel a response should be sent to
+func (irc *IRC) responseChannel(msg types.TwitchMessage) string {
+	if msg.Channel != "" {
+		return msg.Channel
+	}
+	return irc.primaryChannel()
+}
+
+// ConnectIRC starts the chat subsystems the first time it is called and creates a new
+// IRC client for every configured channel. It does not open the connection; call
+// irc.Client.Connect or let a Supervisor do it. It is safe to call again to reconnect.
 func (irc *IRC) ConnectIRC(ctx context.Context, wg *sync.WaitGroup) error {
-	irc.logger.Info("connecting to twitch IRC")
-	c := v2.NewClient(peteTwitchChannel, "oauth:"+irc.tok.AccessToken)
-	c.Join(peteTwitchChannel)
+	tok := irc.currentToken()
+	if tok == nil {
+		return errors.New("not authenticated with twitch")
+	}
+	irc.logger.Info("creating twitch IRC client", "channels", irc.channelNames)
+
+	c := v2.NewClient(peteTwitchChannel, "oauth:"+tok.AccessToken)
+	c.Join(irc.channelNames...)
 	c.OnConnect(func() {
 		metrics.TwitchConnectionCount.Add(1)
 		irc.logger.Info("connection to twitch IRC established")
+		if irc.onConnect != nil {
+			irc.onConnect()
+		}
 	})
+	c.OnPrivateMessage(func(msg v2.PrivateMessage) {
+		irc.handlePrivateMessage(ctx, msg)
+	})
+
+	irc.Client = c
+	irc.sender.SetTransport(c)
+
+	irc.subsystemsOnce.Do(func() {
+		irc.startSubsystems(ctx, wg)
+	})
+	return nil
+}
+
+// startSubsystems starts the outbound sender, Twenty Questions games, moderation monitors,
+// message broker and async response handler. They outlive any one IRC connection, so
+// this runs once.
+func (irc *IRC) startSubsystems(ctx context.Context, wg *sync.WaitGroup) {
+	irc.sender.Start(ctx, wg)
+	if irc.tokens != nil {
+		irc.tokens.Start(ctx, wg)
+	}
+	if irc.twentyQuestions != nil {
+		irc.twentyQuestions.Start(ctx, wg)
+	}
 
-	// Start moderation monitor if enabled
-	if irc.modConfig != nil && irc.modConfig.Enabled && irc.helixClient != nil && irc.modDB != nil {
-		llmPath := os.Getenv("LLAMA_CPP_PATH")
+	// Start a moderation monitor for each moderated channel
+	for _, name := range irc.channelNames {
+		ch := irc.channels[name]
+		if !ch.moderationEnabled() || ch.helixClient == nil || irc.modDB == nil {
+			continue
+		}
+		modLLM, err := irc.moderationLLM()
+		if err != nil {
+			irc.logger.Error("failed to create moderation LLM", "error", err.Error())
+			break
+		}
 		monitor, err := moderation.NewMonitor(
-			irc.modConfig,
-			llmPath,
+			ch.modConfig,
+			modLLM,
 			irc.modelName,
-			irc.helixClient,
+			ch.helixClient,
 			irc.modDB,
-			irc.broadcasterID,
-			peteTwitchChannel,
+			ch.BroadcasterID(),
+			ch.Name,
 			irc.logger,
 		)
 		if err != nil {
-			irc.logger.Error("failed to create moderation monitor", "error", err.Error())
-		} else {
-			irc.modMonitor = monitor
-			monitor.SetIRCClient(c)
-			monitor.Start(ctx, wg)
-			irc.logger.Info("moderation monitor started")
+			irc.logger.Error("failed to create moderation monitor", "channel", ch.Name, "error", err.Error())
+			continue
 		}
+		ch.modMonitor = monitor
+		monitor.SetSender(irc.sender)
+		monitor.Start(ctx, wg)
+		irc.logger.Info("moderation monitor started", "channel", ch.Name)
 	}
 
 	// Initialize message broker for queue-based message distribution
 	irc.messageBroker = messagequeue.NewBroker(1000, irc.logger)
 
-	// Subscribe FAQ processor to message broker if configured
-	if irc.faqProcessor != nil {
-		irc.messageBroker.Subscribe(irc.faqProcessor)
-		irc.logger.Info("FAQ processor subscribed to message broker")
+	// Subscribe FAQ processors to message broker if configured
+	subscribed := make(map[*FAQProcessor]bool)
+	for _, name := range irc.channelNames {
+		processor := irc.channels[name].faqProcessor
+		if processor == nil || subscribed[processor] {
+			continue
+		}
+		irc.messageBroker.Subscribe(processor)
+		subscribed[processor] = true
+		irc.logger.Info("FAQ processor subscribed to message broker", "channel", name)
 	}
 
 	// Start the message broker
 	irc.messageBroker.Start(ctx, wg)
 
-	c.OnPrivateMessage(func(msg v2.PrivateMessage) {
-		metrics.TwitchMessageRecievedCount.Add(1)
-		irc.logger.Debug("received message", "user", msg.User.Name, "message", msg.Message)
-
-		// Send to moderation monitor (non-blocking)
-		if irc.modMonitor != nil {
-			select {
-			case irc.modMonitor.MessageChannel() <- msg:
-			default:
-				irc.logger.Debug("moderation channel full, skipping message")
-			}
-		}
+	// Start async response handler
+	go irc.handleAsyncResponses(ctx)
 
-		// Publish to message broker (distributes to all consumers)
-		irc.messageBroker.Publish(msg)
+	// Greet each channel once, not on every reconnect
+	for _, name := range irc.channelNames {
+		irc.say(ctx, outbound.Message{
+			Channel: name,
+			Text:    irc.channels[name].Greeting,
+			Source:  outbound.SourceGreeting,
+		})
+	}
+}
 
-		// Handle normal chat
+// handlePrivateMessage fans a chat message out to moderation, the broker and HandleChat
+func (irc *IRC) handlePrivateMessage(ctx context.Context, msg v2.PrivateMessage) {
+	if !irc.accepting() {
+		return
+	}
+	irc.inflight.Add(1)
+	defer irc.inflight.Add(-1)
 
-		irc.HandleChat(ctx, msg)
-	})
+	metrics.TwitchMessageRecievedCount.Add(1)
+	irc.logger.Debug("received message", "channel", msg.Channel, "user", msg.User.Name, "message", msg.Message)
 
-	c.Say(peteTwitchChannel, "Hello, my name is Pedro_el_asistente I am here to help you.")
+	// Replies sent through Helix come back over IRC, Pedro must not moderate or answer himself
+	if isBotMessage(msg) {
+		return
+	}
 
-	// Start async response handler
-	go irc.handleAsyncResponses(ctx)
+	ch := irc.GetChannel(msg.Channel)
+	if ch == nil {
+		irc.logger.Debug("ignoring message from unknown channel", "channel", msg.Channel)
+		return
+	}
 
-	irc.Client = c
-	return nil
+	// Send to the channel's moderation monitor (non-blocking)
+	if ch.modMonitor != nil {
+		select {
+		case ch.modMonitor.MessageChannel() <- msg:
+		default:
+			irc.logger.Debug("moderation channel full, skipping message", "channel", ch.Name)
+		}
+	}
+
+	// Publish to message broker (distributes to all consumers)
+	irc.messageBroker.Publish(msg)
+
+	// Handle normal chat
+	irc.HandleChat(ctx, msg)
 }
 
 // handleAsyncResponses listens for async responses (like web search results and FAQ matches) and sends them to chat
@@ -255,7 +562,8 @@ func (irc *IRC) handleAsyncResponses(ctx context.Context) {
 			irc.logger.Info("shutting down async response handler")
 			return
 		case response := <-irc.asyncResponseCh:
-			irc.logger.Debug("received async response", "messageID", response.UUID, "username", response.Username, "responseLength", len(response.Text))
+			irc.inflight.Add(1)
+			irc.logger.Debug("received async response", "messageID", response.UUID, "channel", response.Channel, "username", response.Username, "responseLength", len(response.Text))
 
 			// FAQ responses are already stored by the FAQ service, skip database insert
 			// FAQ responses have Username = "Pedro_FAQ"
@@ -272,20 +580,121 @@ func (irc *IRC) handleAsyncResponses(ctx context.Context) {
 			}
 
 			// Send the response to Twitch chat
-			irc.Client.Say(peteTwitchChannel, response.Text)
-			metrics.TwitchMessageSentCount.Add(1)
+			source := outbound.SourceWebSearch
+			if response.Username == "Pedro_FAQ" {
+				source = outbound.SourceFAQ
+			}
+			irc.say(ctx, outbound.Message{
+				Channel: irc.responseChannel(response),
+				Text:    response.Text,
+				Source:  source,
+				ChatID:  response.UUID,
+				ReplyTo: response.ReplyTo,
+			})
+			irc.inflight.Add(-1)
 		}
 	}
 }
 
+// LoadCommands registers chat command definitions from YAML or the database
+func (irc *IRC) LoadCommands(commands []types.ChatCommand) error {
+	loaded := 0
+	for _, cmd := range commands {
+		err := irc.commands.RegisterConfig(cmd)
+		// The first command with a name wins, so YAML commands win over database ones
+		if errors.Is(err, ErrCommandExists) {
+			irc.logger.Warn("skipping duplicate chat command", "command", cmd.Name, "error", err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		loaded++
+	}
+	irc.logger.Info("chat commands loaded", "count", loaded, "skipped", len(commands)-loaded)
+	return nil
+}
+
+// GetCommandRegistry returns the chat command registry so Go handlers can be registered
+func (irc *IRC) GetCommandRegistry() *CommandRegistry {
+	return irc.commands
+}
+
+// say queues a message on the outbound chat sender. ctx bounds the output filter's check.
+func (irc *IRC) say(ctx context.Context, msg outbound.Message) {
+	err := irc.sender.Send(ctx, msg)
+	switch {
+	case errors.Is(err, outbound.ErrFiltered):
+		// the filter already logged why
+		irc.logger.Debug("chat message not sent", "error", err.Error(), "channel", msg.Channel, "source", msg.Source)
+	case err != nil:
+		irc.logger.Error("failed to queue chat message", "error", err.Error(), "channel", msg.Channel, "source", msg.Source)
+	}
+}
+
+// GetSender returns the outbound chat sender
+func (irc *IRC) GetSender() *outbound.Sender {
+	return irc.sender
+}
+
 // GetSessionRegistry returns the palace session registry
 func (irc *IRC) GetSessionRegistry() *SessionRegistry {
 	return irc.sessionRegistry
 }
 
 // SetMemPalace sets the Mem Palace instance for chat history
+// and uses its address detector to decide when Pedro replies.
 func (irc *IRC) SetMemPalace(mp *mempalace.MemPalace) {
 	irc.memPalace = mp
+	if mp == nil {
+		return
+	}
+	irc.trigger.SetScorer(mp)
+
+	// Let Pedro search the stream's chat while a session is recording it
+	if twitchLLM, ok := irc.llm.(*twitchchat.Client); ok {
+		// The session's store changes every stream, so look the tool up on each call
+		queryTool := agent.NewFuncTool("query_chat_history", "Query chat history from the Mem Palace session",
+			func(ctx context.Context, input string) (string, error) {
+				return mp.GetQueryTool().Call(ctx, input)
+			})
+		err := twitchLLM.RegisterTool(agent.Tool{
+			Definition: tools.GetQueryChatHistoryToolDefinition(),
+			Impl:       queryTool,
+			Available:  mp.HasSession,
+		})
+		if err != nil {
+			irc.logger.Error("failed to register chat history tool", "error", err.Error())
+		}
+	}
+}
+
+// SetChatMemory sets the chat memory that embeds persisted messages and recalls similar
+// messages from earlier streams into Pedro's prompt
+func (irc *IRC) SetChatMemory(memory *chatmemory.Memory) {
+	irc.chatMemory = memory
+}
+
+// SetModerationLLM sets the LLM the moderation monitors use, usually the shared provider.
+// Without it they connect to LLAMA_CPP_PATH.
+func (irc *IRC) SetModerationLLM(llm llms.Model) {
+	irc.modLLM = llm
+}
+
+func (irc *IRC) moderationLLM() (llms.Model, error) {
+	if irc.modLLM == nil {
+		llm, err := provider.New(provider.SingleBackend(os.Getenv("LLAMA_CPP_PATH")), irc.logger)
+		if err != nil {
+			return nil, err
+		}
+		irc.modLLM = llm
+	}
+	return irc.modLLM, nil
+}
+
+// SetAddressThreshold sets the address score a message needs before Pedro replies
+func (irc *IRC) SetAddressThreshold(threshold float32) {
+	irc.trigger.SetThreshold(threshold)
 }
 
 // GetMemPalace returns the Mem Palace instance
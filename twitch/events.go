@@ -0,0 +1,118 @@
+package twitchirc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Soypete/twitch-llm-bot/twitch/eventsub"
+	"github.com/Soypete/twitch-llm-bot/twitch/outbound"
+	"github.com/pkg/errors"
+)
+
+// StartEventSub connects to Twitch EventSub for every joined channel and publishes
+// follows, subs, raids, cheers and redemptions on the event bus. The bus is shared
+// with the message broker so its consumers can react to channel events too.
+// It must be called after ConnectIRC.
+func (irc *IRC) StartEventSub(ctx context.Context, wg *sync.WaitGroup) error {
+	clientID := os.Getenv("TWITCH_ID")
+	if clientID == "" {
+		return errors.New("TWITCH_ID environment variable not set")
+	}
+
+	apiClient := irc.newHelixClient(clientID, "", "")
+
+	if irc.moderatorID == "" {
+		botUserID, err := lookupBotUserID(ctx, apiClient)
+		if err != nil {
+			return err
+		}
+		irc.moderatorID = botUserID
+	}
+
+	var subscriptions []eventsub.Subscription
+	for _, name := range irc.channelNames {
+		ch := irc.channels[name]
+		broadcasterID := ch.BroadcasterID()
+		if broadcasterID == "" {
+			id, err := apiClient.GetUserIDByLogin(ctx, ch.Name)
+			if err != nil {
+				irc.logger.Error("failed to get broadcaster ID, skipping eventsub for channel", "channel", ch.Name, "error", err.Error())
+				continue
+			}
+			ch.setBroadcasterID(id)
+			broadcasterID = id
+		}
+		subscriptions = append(subscriptions, eventsub.ChannelSubscriptions(broadcasterID, irc.moderatorID)...)
+	}
+	if len(subscriptions) == 0 {
+		return errors.New("no channels available for eventsub")
+	}
+
+	irc.eventBus = eventsub.NewBus(100, irc.logger)
+	irc.eventBus.SubscribeAll(irc.handleEvent)
+	if irc.messageBroker != nil {
+		irc.eventBus.SubscribeAll(irc.messageBroker.PublishEvent)
+	}
+	irc.eventBus.Start(ctx, wg)
+
+	client := eventsub.NewClient(apiClient, irc.eventBus, subscriptions, irc.logger)
+	client.Start(ctx, wg)
+
+	irc.logger.Info("eventsub started", "channels", irc.channelNames, "subscriptions", len(subscriptions))
+	return nil
+}
+
+// GetEventBus returns the EventSub event bus, or nil if EventSub is not running
+func (irc *IRC) GetEventBus() *eventsub.Bus {
+	return irc.eventBus
+}
+
+// handleEvent sends a short chat message reacting to a channel event
+func (irc *IRC) handleEvent(ctx context.Context, event eventsub.Event) {
+	if !irc.accepting() {
+		return
+	}
+	ch := irc.GetChannel(event.Channel())
+	if ch == nil {
+		irc.logger.Debug("ignoring event for unknown channel", "type", event.Type(), "channel", event.Channel())
+		return
+	}
+
+	text := eventMessage(event)
+	if text == "" {
+		return
+	}
+
+	irc.logger.Info("reacting to channel event", "type", event.Type(), "channel", ch.Name)
+	irc.say(ctx, outbound.Message{
+		Channel: ch.Name,
+		Text:    text,
+		Source:  outbound.SourceEvent,
+	})
+}
+
+// eventMessage returns what Pedro says in chat for an event. Empty means stay quiet.
+func eventMessage(event eventsub.Event) string {
+	switch e := event.(type) {
+	case *eventsub.RaidEvent:
+		return fmt.Sprintf("Welcome raiders! Thank you @%s for the raid with %d viewers!", e.FromBroadcasterUserName, e.Viewers)
+	case *eventsub.FollowEvent:
+		return fmt.Sprintf("Thanks for the follow @%s!", e.UserName)
+	case *eventsub.SubscribeEvent:
+		if e.IsGift {
+			return fmt.Sprintf("@%s just got a gifted sub! Welcome to the crew!", e.UserName)
+		}
+		return fmt.Sprintf("Thank you for subscribing @%s!", e.UserName)
+	case *eventsub.CheerEvent:
+		if e.IsAnonymous {
+			return fmt.Sprintf("Thank you anonymous cheerer for the %d bits!", e.Bits)
+		}
+		return fmt.Sprintf("Thank you @%s for the %d bits!", e.UserName, e.Bits)
+	case *eventsub.RedemptionEvent:
+		return fmt.Sprintf("@%s redeemed %s!", e.UserName, e.Reward.Title)
+	default:
+		return ""
+	}
+}
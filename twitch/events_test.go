@@ -0,0 +1,49 @@
+package twitchirc
+
+import (
+	"testing"
+
+	"github.com/Soypete/twitch-llm-bot/twitch/eventsub"
+)
+
+func TestEventMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		event eventsub.Event
+		want  string
+	}{
+		{
+			name:  "raid",
+			event: &eventsub.RaidEvent{FromBroadcasterUserName: "ForgeUtah", Viewers: 12},
+			want:  "Welcome raiders! Thank you @ForgeUtah for the raid with 12 viewers!",
+		},
+		{
+			name:  "follow",
+			event: &eventsub.FollowEvent{UserName: "viewer"},
+			want:  "Thanks for the follow @viewer!",
+		},
+		{
+			name:  "gift sub",
+			event: &eventsub.SubscribeEvent{UserName: "viewer", IsGift: true},
+			want:  "@viewer just got a gifted sub! Welcome to the crew!",
+		},
+		{
+			name:  "anonymous cheer",
+			event: &eventsub.CheerEvent{IsAnonymous: true, Bits: 100},
+			want:  "Thank you anonymous cheerer for the 100 bits!",
+		},
+		{
+			name:  "redemption",
+			event: &eventsub.RedemptionEvent{UserName: "viewer", Reward: eventsub.Reward{Title: "Hydrate"}},
+			want:  "@viewer redeemed Hydrate!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventMessage(tt.event); got != tt.want {
+				t.Errorf("eventMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
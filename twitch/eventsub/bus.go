@@ -0,0 +1,95 @@
+package eventsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+)
+
+// Handler receives events published on the bus
+type Handler func(ctx context.Context, event Event)
+
+// Bus distributes EventSub events to subscribed handlers
+type Bus struct {
+	handlers map[string][]Handler
+	all      []Handler
+	queue    chan Event
+	logger   *logging.Logger
+	mu       sync.RWMutex
+}
+
+// NewBus creates a new event bus
+func NewBus(queueSize int, logger *logging.Logger) *Bus {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+
+	return &Bus{
+		handlers: make(map[string][]Handler),
+		queue:    make(chan Event, queueSize),
+		logger:   logger,
+	}
+}
+
+// Subscribe registers a handler for a single event type
+func (b *Bus) Subscribe(eventType string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// SubscribeAll registers a handler for every event type
+func (b *Bus) SubscribeAll(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.all = append(b.all, handler)
+}
+
+// Publish queues an event for delivery (non-blocking)
+func (b *Bus) Publish(event Event) bool {
+	select {
+	case b.queue <- event:
+		return true
+	default:
+		b.logger.Warn("event bus queue full, dropping event", "type", event.Type(), "channel", event.Channel())
+		return false
+	}
+}
+
+// Start begins delivering events to handlers
+func (b *Bus) Start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b.logger.Info("eventsub bus started")
+
+		for {
+			select {
+			case <-ctx.Done():
+				b.logger.Info("eventsub bus shutting down")
+				return
+			case event := <-b.queue:
+				b.dispatch(ctx, event)
+			}
+		}
+	}()
+}
+
+// dispatch calls every handler interested in the event
+func (b *Bus) dispatch(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.all)+len(b.handlers[event.Type()]))
+	handlers = append(handlers, b.handlers[event.Type()]...)
+	handlers = append(handlers, b.all...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
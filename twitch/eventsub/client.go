@@ -0,0 +1,384 @@
+package eventsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/twitch/helix"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultURL is the Twitch EventSub WebSocket endpoint
+const DefaultURL = "wss://eventsub.wss.twitch.tv/ws"
+
+const (
+	welcomeTimeout = 10 * time.Second
+	minBackoff     = time.Second
+	maxBackoff     = 2 * time.Minute
+	maxSeenIDs     = 500
+)
+
+// Subscriber creates EventSub subscriptions for a WebSocket session.
+// *helix.Client satisfies this interface.
+type Subscriber interface {
+	CreateEventSubSubscription(ctx context.Context, req helix.EventSubSubscriptionRequest) ([]byte, error)
+}
+
+// Subscription is an EventSub topic the client subscribes to on every new session
+type Subscription struct {
+	Type      string
+	Version   string
+	Condition map[string]string
+}
+
+// ChannelSubscriptions returns the subscriptions Pedro uses for a single channel.
+// Follows require the bot to be a moderator; subs, cheers and redemptions require
+// the broadcaster to have granted the matching scopes.
+func ChannelSubscriptions(broadcasterID, moderatorID string) []Subscription {
+	broadcaster := map[string]string{"broadcaster_user_id": broadcasterID}
+	return []Subscription{
+		{Type: TypeFollow, Version: "2", Condition: map[string]string{"broadcaster_user_id": broadcasterID, "moderator_user_id": moderatorID}},
+		{Type: TypeSubscribe, Version: "1", Condition: broadcaster},
+		{Type: TypeRaid, Version: "1", Condition: map[string]string{"to_broadcaster_user_id": broadcasterID}},
+		{Type: TypeCheer, Version: "1", Condition: broadcaster},
+		{Type: TypeRedemption, Version: "1", Condition: broadcaster},
+	}
+}
+
+// Session is the EventSub WebSocket session sent in welcome and reconnect messages
+type Session struct {
+	ID                      string    `json:"id"`
+	Status                  string    `json:"status"`
+	KeepaliveTimeoutSeconds int       `json:"keepalive_timeout_seconds"`
+	ReconnectURL            string    `json:"reconnect_url"`
+	ConnectedAt             time.Time `json:"connected_at"`
+}
+
+type message struct {
+	Metadata metadata        `json:"metadata"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+type metadata struct {
+	MessageID           string    `json:"message_id"`
+	MessageType         string    `json:"message_type"`
+	MessageTimestamp    time.Time `json:"message_timestamp"`
+	SubscriptionType    string    `json:"subscription_type"`
+	SubscriptionVersion string    `json:"subscription_version"`
+}
+
+type sessionPayload struct {
+	Session Session `json:"session"`
+}
+
+type subscriptionInfo struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Status    string            `json:"status"`
+	Condition map[string]string `json:"condition"`
+}
+
+type notificationPayload struct {
+	Subscription subscriptionInfo `json:"subscription"`
+	Event        json.RawMessage  `json:"event"`
+}
+
+// Client is an EventSub WebSocket client that publishes typed events onto a Bus
+type Client struct {
+	url            string
+	subscriber     Subscriber
+	subscriptions  []Subscription
+	bus            *Bus
+	dialer         *websocket.Dialer
+	logger         *logging.Logger
+	keepaliveGrace time.Duration
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+
+	mu        sync.RWMutex
+	sessionID string
+	seen      map[string]bool
+	seenOrder []string
+}
+
+// NewClient creates a new EventSub WebSocket client
+func NewClient(subscriber Subscriber, bus *Bus, subscriptions []Subscription, logger *logging.Logger) *Client {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &Client{
+		url:            DefaultURL,
+		subscriber:     subscriber,
+		subscriptions:  subscriptions,
+		bus:            bus,
+		dialer:         websocket.DefaultDialer,
+		logger:         logger,
+		keepaliveGrace: 5 * time.Second,
+		minBackoff:     minBackoff,
+		maxBackoff:     maxBackoff,
+		seen:           make(map[string]bool),
+	}
+}
+
+// SetURL overrides the EventSub WebSocket URL (used for the Twitch CLI mock server and tests)
+func (c *Client) SetURL(url string) {
+	c.url = url
+}
+
+// SessionID returns the ID of the current WebSocket session
+func (c *Client) SessionID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sessionID
+}
+
+// Start runs the client in the background until ctx is cancelled
+func (c *Client) Start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := c.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			c.logger.Error("eventsub client stopped", "error", err.Error())
+		}
+	}()
+}
+
+// Run connects to EventSub and keeps the session alive, reconnecting with backoff
+// when the connection drops or keepalives stop arriving. It returns when ctx is cancelled.
+func (c *Client) Run(ctx context.Context) error {
+	backoff := c.minBackoff
+	for {
+		conn, session, err := c.connect(ctx, c.url)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			metrics.EventSubReconnectsTotal.WithLabelValues("connect_failed").Inc()
+			wait := jitter(backoff)
+			c.logger.Warn("failed to connect to eventsub, retrying", "error", err.Error(), "retryIn", wait)
+			if !sleep(ctx, wait) {
+				return ctx.Err()
+			}
+			backoff = min(backoff*2, c.maxBackoff)
+			continue
+		}
+		backoff = c.minBackoff
+
+		// A new session starts with no subscriptions
+		c.subscribe(ctx, session.ID)
+
+		reason, err := c.readLoop(ctx, conn, session)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		metrics.EventSubReconnectsTotal.WithLabelValues(reason).Inc()
+		c.logger.Warn("eventsub connection lost, reconnecting", "reason", reason, "error", err)
+	}
+}
+
+// connect dials the WebSocket and waits for the session_welcome message
+func (c *Client) connect(ctx context.Context, url string) (*websocket.Conn, *Session, error) {
+	conn, _, err := c.dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to dial eventsub: %w", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(welcomeTimeout)); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("failed to set read deadline: %w", err)
+	}
+
+	var msg message
+	if err := conn.ReadJSON(&msg); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("failed to read welcome message: %w", err)
+	}
+	if msg.Metadata.MessageType != "session_welcome" {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("expected session_welcome, got %s", msg.Metadata.MessageType)
+	}
+
+	var payload sessionPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("failed to parse welcome message: %w", err)
+	}
+
+	c.mu.Lock()
+	c.sessionID = payload.Session.ID
+	c.mu.Unlock()
+
+	c.logger.Info("eventsub session started",
+		"sessionID", payload.Session.ID,
+		"keepaliveTimeoutSeconds", payload.Session.KeepaliveTimeoutSeconds)
+
+	return conn, &payload.Session, nil
+}
+
+// subscribe creates every configured subscription for the session
+func (c *Client) subscribe(ctx context.Context, sessionID string) {
+	created := 0
+	for _, sub := range c.subscriptions {
+		req := helix.EventSubSubscriptionRequest{
+			Type:      sub.Type,
+			Version:   sub.Version,
+			Condition: sub.Condition,
+			Transport: helix.EventSubTransport{
+				Method:    "websocket",
+				SessionID: sessionID,
+			},
+		}
+		if _, err := c.subscriber.CreateEventSubSubscription(ctx, req); err != nil {
+			// Missing scopes are common (e.g. subs for a channel that has not authorized Pedro)
+			c.logger.Warn("failed to create eventsub subscription", "type", sub.Type, "condition", sub.Condition, "error", err.Error())
+			continue
+		}
+		created++
+	}
+
+	if created == 0 && len(c.subscriptions) > 0 {
+		c.logger.Error("no eventsub subscriptions were created", "sessionID", sessionID)
+		return
+	}
+	c.logger.Info("eventsub subscriptions created", "sessionID", sessionID, "created", created, "requested", len(c.subscriptions))
+}
+
+// readLoop reads messages until the connection fails. A session_reconnect swaps to the
+// new connection in place since Twitch carries subscriptions over to it.
+// It returns the reason the connection was lost.
+func (c *Client) readLoop(ctx context.Context, conn *websocket.Conn, session *Session) (string, error) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer func() {
+		stop()
+		_ = conn.Close()
+	}()
+
+	keepalive := time.Duration(session.KeepaliveTimeoutSeconds) * time.Second
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(keepalive + c.keepaliveGrace)); err != nil {
+			return "read_error", err
+		}
+
+		var msg message
+		if err := conn.ReadJSON(&msg); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return "keepalive_timeout", err
+			}
+			return "read_error", err
+		}
+
+		switch msg.Metadata.MessageType {
+		case "session_keepalive":
+			c.logger.Debug("eventsub keepalive")
+		case "notification":
+			c.handleNotification(msg)
+		case "session_reconnect":
+			var payload sessionPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				return "read_error", fmt.Errorf("failed to parse reconnect message: %w", err)
+			}
+			c.logger.Info("eventsub requested reconnect", "reconnectURL", payload.Session.ReconnectURL)
+
+			newConn, newSession, err := c.connect(ctx, payload.Session.ReconnectURL)
+			if err != nil {
+				return "reconnect_failed", err
+			}
+			metrics.EventSubReconnectsTotal.WithLabelValues("session_reconnect").Inc()
+
+			stop()
+			_ = conn.Close()
+			conn = newConn
+			stop = context.AfterFunc(ctx, func() { _ = newConn.Close() })
+			keepalive = time.Duration(newSession.KeepaliveTimeoutSeconds) * time.Second
+		case "revocation":
+			var payload notificationPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				c.logger.Warn("failed to parse revocation message", "error", err.Error())
+				continue
+			}
+			c.logger.Warn("eventsub subscription revoked",
+				"type", payload.Subscription.Type,
+				"status", payload.Subscription.Status,
+				"condition", payload.Subscription.Condition)
+		default:
+			c.logger.Debug("ignoring unknown eventsub message", "type", msg.Metadata.MessageType)
+		}
+	}
+}
+
+// handleNotification decodes a notification and publishes it on the bus
+func (c *Client) handleNotification(msg message) {
+	if c.isDuplicate(msg.Metadata.MessageID) {
+		c.logger.Debug("skipping duplicate eventsub notification", "messageID", msg.Metadata.MessageID)
+		return
+	}
+
+	var payload notificationPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.logger.Error("failed to parse notification", "error", err.Error())
+		return
+	}
+
+	event, err := decodeEvent(payload.Subscription.Type, payload.Event)
+	if err != nil {
+		c.logger.Warn("failed to decode eventsub event", "error", err.Error())
+		return
+	}
+
+	metrics.EventSubNotificationsTotal.WithLabelValues(event.Type()).Inc()
+	c.logger.Debug("eventsub notification received", "type", event.Type(), "channel", event.Channel())
+	c.bus.Publish(event)
+}
+
+// isDuplicate reports whether a message ID was already seen. Twitch may resend
+// notifications, so the most recent IDs are remembered.
+func (c *Client) isDuplicate(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seen[id] {
+		return true
+	}
+	c.seen[id] = true
+	c.seenOrder = append(c.seenOrder, id)
+	if len(c.seenOrder) > maxSeenIDs {
+		delete(c.seen, c.seenOrder[0])
+		c.seenOrder = c.seenOrder[1:]
+	}
+	return false
+}
+
+// jitter returns a random duration between d/2 and d
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half)
+}
+
+// sleep waits for d or until ctx is cancelled. It returns false if ctx was cancelled.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
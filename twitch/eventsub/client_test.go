@@ -0,0 +1,338 @@
+package eventsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/twitch/helix"
+	"github.com/gorilla/websocket"
+)
+
+// fakeSubscriber records subscription requests instead of calling Helix
+type fakeSubscriber struct {
+	mu       sync.Mutex
+	requests []helix.EventSubSubscriptionRequest
+}
+
+func (f *fakeSubscriber) CreateEventSubSubscription(_ context.Context, req helix.EventSubSubscriptionRequest) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	return []byte(`{}`), nil
+}
+
+func (f *fakeSubscriber) sessionIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]string, 0, len(f.requests))
+	for _, req := range f.requests {
+		ids = append(ids, req.Transport.SessionID)
+	}
+	return ids
+}
+
+// fakeServer is a local EventSub WebSocket server. Each connection is handed
+// to the script for its path so tests control exactly what Twitch "sends".
+type fakeServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	conns   int
+	scripts map[string]func(n int, conn *websocket.Conn)
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	fs := &fakeServer{scripts: make(map[string]func(int, *websocket.Conn))}
+	upgrader := websocket.Upgrader{}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		fs.mu.Lock()
+		fs.conns++
+		n := fs.conns
+		script := fs.scripts[r.URL.Path]
+		fs.mu.Unlock()
+
+		if script != nil {
+			script(n, conn)
+		}
+		// Hold the connection open until the client goes away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+func (fs *fakeServer) handle(path string, script func(n int, conn *websocket.Conn)) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.scripts[path] = script
+}
+
+func (fs *fakeServer) wsURL(path string) string {
+	return "ws" + strings.TrimPrefix(fs.URL, "http") + path
+}
+
+func (fs *fakeServer) connections() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.conns
+}
+
+func sendJSON(t *testing.T, conn *websocket.Conn, v map[string]interface{}) {
+	t.Helper()
+	if err := conn.WriteJSON(v); err != nil {
+		t.Errorf("failed to write message: %v", err)
+	}
+}
+
+func welcome(sessionID string, keepalive int) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"message_id":        "welcome-" + sessionID,
+			"message_type":      "session_welcome",
+			"message_timestamp": time.Now().Format(time.RFC3339Nano),
+		},
+		"payload": map[string]interface{}{
+			"session": map[string]interface{}{
+				"id":                        sessionID,
+				"status":                    "connected",
+				"keepalive_timeout_seconds": keepalive,
+			},
+		},
+	}
+}
+
+func notification(messageID, subType string, event map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"message_id":           messageID,
+			"message_type":         "notification",
+			"message_timestamp":    time.Now().Format(time.RFC3339Nano),
+			"subscription_type":    subType,
+			"subscription_version": "1",
+		},
+		"payload": map[string]interface{}{
+			"subscription": map[string]interface{}{
+				"id":      "sub-1",
+				"type":    subType,
+				"version": "1",
+				"status":  "enabled",
+			},
+			"event": event,
+		},
+	}
+}
+
+var raid = map[string]interface{}{
+	"from_broadcaster_user_id":    "1234",
+	"from_broadcaster_user_login": "forgeutah",
+	"from_broadcaster_user_name":  "ForgeUtah",
+	"to_broadcaster_user_id":      "5678",
+	"to_broadcaster_user_login":   "soypetetech",
+	"to_broadcaster_user_name":    "SoyPeteTech",
+	"viewers":                     42,
+}
+
+// startClient runs a client against the fake server and collects published events
+func startClient(t *testing.T, url string, sub *fakeSubscriber) (*Client, <-chan Event) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	events := make(chan Event, 10)
+	bus := NewBus(10, nil)
+	bus.SubscribeAll(func(_ context.Context, event Event) { events <- event })
+	bus.Start(ctx, wg)
+
+	client := NewClient(sub, bus, ChannelSubscriptions("5678", "9999"), nil)
+	client.SetURL(url)
+	client.keepaliveGrace = 100 * time.Millisecond
+	client.minBackoff = 10 * time.Millisecond
+	client.Start(ctx, wg)
+	return client, events
+}
+
+func waitForEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return nil
+	}
+}
+
+func TestClient_SubscribesAndPublishesTypedEvents(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.handle("/ws", func(_ int, conn *websocket.Conn) {
+		sendJSON(t, conn, welcome("session-1", 10))
+		sendJSON(t, conn, notification("msg-1", TypeRaid, raid))
+	})
+
+	sub := &fakeSubscriber{}
+	_, events := startClient(t, fs.wsURL("/ws"), sub)
+
+	event := waitForEvent(t, events)
+	raidEvent, ok := event.(*RaidEvent)
+	if !ok {
+		t.Fatalf("expected *RaidEvent, got %T", event)
+	}
+	if raidEvent.FromBroadcasterUserName != "ForgeUtah" || raidEvent.Viewers != 42 {
+		t.Errorf("unexpected raid event: %+v", raidEvent)
+	}
+	if raidEvent.Channel() != "soypetetech" {
+		t.Errorf("Channel() = %q, want soypetetech", raidEvent.Channel())
+	}
+
+	ids := sub.sessionIDs()
+	if len(ids) != len(ChannelSubscriptions("5678", "9999")) {
+		t.Fatalf("expected %d subscriptions, got %d", len(ChannelSubscriptions("5678", "9999")), len(ids))
+	}
+	for _, id := range ids {
+		if id != "session-1" {
+			t.Errorf("subscription created for session %q, want session-1", id)
+		}
+	}
+}
+
+func TestClient_SkipsDuplicateNotifications(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.handle("/ws", func(_ int, conn *websocket.Conn) {
+		sendJSON(t, conn, welcome("session-1", 10))
+		sendJSON(t, conn, notification("msg-1", TypeRaid, raid))
+		sendJSON(t, conn, notification("msg-1", TypeRaid, raid))
+		sendJSON(t, conn, notification("msg-2", TypeCheer, map[string]interface{}{
+			"user_name":              "chatter",
+			"broadcaster_user_login": "soypetetech",
+			"bits":                   100,
+		}))
+	})
+
+	_, events := startClient(t, fs.wsURL("/ws"), &fakeSubscriber{})
+
+	if _, ok := waitForEvent(t, events).(*RaidEvent); !ok {
+		t.Fatal("first event should be the raid")
+	}
+	cheer, ok := waitForEvent(t, events).(*CheerEvent)
+	if !ok {
+		t.Fatal("duplicate raid should have been skipped before the cheer")
+	}
+	if cheer.Bits != 100 {
+		t.Errorf("Bits = %d, want 100", cheer.Bits)
+	}
+}
+
+func TestClient_SessionReconnectKeepsSubscriptions(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.handle("/ws", func(_ int, conn *websocket.Conn) {
+		sendJSON(t, conn, welcome("session-1", 10))
+		sendJSON(t, conn, map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"message_id":   "reconnect-1",
+				"message_type": "session_reconnect",
+			},
+			"payload": map[string]interface{}{
+				"session": map[string]interface{}{
+					"id":            "session-1",
+					"status":        "reconnecting",
+					"reconnect_url": fs.wsURL("/reconnect"),
+				},
+			},
+		})
+	})
+	fs.handle("/reconnect", func(_ int, conn *websocket.Conn) {
+		sendJSON(t, conn, welcome("session-2", 10))
+		sendJSON(t, conn, notification("msg-1", TypeRaid, raid))
+	})
+
+	sub := &fakeSubscriber{}
+	client, events := startClient(t, fs.wsURL("/ws"), sub)
+
+	if _, ok := waitForEvent(t, events).(*RaidEvent); !ok {
+		t.Fatal("expected raid from the reconnected session")
+	}
+	if client.SessionID() != "session-2" {
+		t.Errorf("SessionID() = %q, want session-2", client.SessionID())
+	}
+	for _, id := range sub.sessionIDs() {
+		if id != "session-1" {
+			t.Errorf("subscriptions should not be recreated after session_reconnect, got session %q", id)
+		}
+	}
+}
+
+func TestClient_ReconnectsWhenKeepalivesStop(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.handle("/ws", func(n int, conn *websocket.Conn) {
+		sendJSON(t, conn, welcome(fmt.Sprintf("session-%d", n), 1))
+		if n > 1 {
+			sendJSON(t, conn, notification("msg-1", TypeRaid, raid))
+		}
+		// The first connection goes silent so the keepalive timer expires
+	})
+
+	sub := &fakeSubscriber{}
+	_, events := startClient(t, fs.wsURL("/ws"), sub)
+
+	if _, ok := waitForEvent(t, events).(*RaidEvent); !ok {
+		t.Fatal("expected raid after reconnecting")
+	}
+	if fs.connections() < 2 {
+		t.Errorf("expected a second connection, got %d", fs.connections())
+	}
+
+	// A fresh session needs its subscriptions created again
+	seen := map[string]bool{}
+	for _, id := range sub.sessionIDs() {
+		seen[id] = true
+	}
+	if !seen["session-1"] || !seen["session-2"] {
+		t.Errorf("expected subscriptions for both sessions, got %v", sub.sessionIDs())
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"user_name":              "viewer",
+		"broadcaster_user_login": "soypetetech",
+		"user_input":             "play lofi",
+		"reward":                 map[string]interface{}{"title": "Song request", "cost": 500},
+	})
+
+	event, err := decodeEvent(TypeRedemption, raw)
+	if err != nil {
+		t.Fatalf("decodeEvent() error = %v", err)
+	}
+	redemption, ok := event.(*RedemptionEvent)
+	if !ok {
+		t.Fatalf("expected *RedemptionEvent, got %T", event)
+	}
+	if redemption.Reward.Title != "Song request" || redemption.UserInput != "play lofi" {
+		t.Errorf("unexpected redemption: %+v", redemption)
+	}
+
+	if _, err := decodeEvent("channel.unknown", raw); err == nil {
+		t.Error("expected error for unsupported subscription type")
+	}
+}
@@ -0,0 +1,136 @@
+// Package eventsub provides a Twitch EventSub WebSocket client and an event bus
+// for channel events that do not come through IRC (follows, subs, raids, cheers
+// and channel point redemptions).
+package eventsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventSub subscription types handled by Pedro
+const (
+	TypeFollow     = "channel.follow"
+	TypeSubscribe  = "channel.subscribe"
+	TypeRaid       = "channel.raid"
+	TypeCheer      = "channel.cheer"
+	TypeRedemption = "channel.channel_points_custom_reward_redemption.add"
+)
+
+// Event is a typed EventSub notification
+type Event interface {
+	// Type is the EventSub subscription type (e.g. channel.raid)
+	Type() string
+	// Channel is the login of the channel the event happened in
+	Channel() string
+}
+
+// FollowEvent is sent when a user follows the channel
+type FollowEvent struct {
+	UserID               string    `json:"user_id"`
+	UserLogin            string    `json:"user_login"`
+	UserName             string    `json:"user_name"`
+	BroadcasterUserID    string    `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string    `json:"broadcaster_user_login"`
+	BroadcasterUserName  string    `json:"broadcaster_user_name"`
+	FollowedAt           time.Time `json:"followed_at"`
+}
+
+func (e *FollowEvent) Type() string    { return TypeFollow }
+func (e *FollowEvent) Channel() string { return e.BroadcasterUserLogin }
+
+// SubscribeEvent is sent when a user subscribes to the channel
+type SubscribeEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	Tier                 string `json:"tier"`
+	IsGift               bool   `json:"is_gift"`
+}
+
+func (e *SubscribeEvent) Type() string    { return TypeSubscribe }
+func (e *SubscribeEvent) Channel() string { return e.BroadcasterUserLogin }
+
+// RaidEvent is sent when another broadcaster raids the channel
+type RaidEvent struct {
+	FromBroadcasterUserID    string `json:"from_broadcaster_user_id"`
+	FromBroadcasterUserLogin string `json:"from_broadcaster_user_login"`
+	FromBroadcasterUserName  string `json:"from_broadcaster_user_name"`
+	ToBroadcasterUserID      string `json:"to_broadcaster_user_id"`
+	ToBroadcasterUserLogin   string `json:"to_broadcaster_user_login"`
+	ToBroadcasterUserName    string `json:"to_broadcaster_user_name"`
+	Viewers                  int    `json:"viewers"`
+}
+
+func (e *RaidEvent) Type() string    { return TypeRaid }
+func (e *RaidEvent) Channel() string { return e.ToBroadcasterUserLogin }
+
+// CheerEvent is sent when a user cheers bits in the channel
+type CheerEvent struct {
+	IsAnonymous          bool   `json:"is_anonymous"`
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	UserName             string `json:"user_name"`
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	Message              string `json:"message"`
+	Bits                 int    `json:"bits"`
+}
+
+func (e *CheerEvent) Type() string    { return TypeCheer }
+func (e *CheerEvent) Channel() string { return e.BroadcasterUserLogin }
+
+// Reward is the channel points reward that was redeemed
+type Reward struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Cost   int    `json:"cost"`
+	Prompt string `json:"prompt"`
+}
+
+// RedemptionEvent is sent when a viewer redeems a channel points reward
+type RedemptionEvent struct {
+	ID                   string    `json:"id"`
+	UserID               string    `json:"user_id"`
+	UserLogin            string    `json:"user_login"`
+	UserName             string    `json:"user_name"`
+	BroadcasterUserID    string    `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string    `json:"broadcaster_user_login"`
+	BroadcasterUserName  string    `json:"broadcaster_user_name"`
+	UserInput            string    `json:"user_input"`
+	Status               string    `json:"status"`
+	Reward               Reward    `json:"reward"`
+	RedeemedAt           time.Time `json:"redeemed_at"`
+}
+
+func (e *RedemptionEvent) Type() string    { return TypeRedemption }
+func (e *RedemptionEvent) Channel() string { return e.BroadcasterUserLogin }
+
+// decodeEvent turns a notification payload into a typed event
+func decodeEvent(subscriptionType string, raw json.RawMessage) (Event, error) {
+	var event Event
+	switch subscriptionType {
+	case TypeFollow:
+		event = &FollowEvent{}
+	case TypeSubscribe:
+		event = &SubscribeEvent{}
+	case TypeRaid:
+		event = &RaidEvent{}
+	case TypeCheer:
+		event = &CheerEvent{}
+	case TypeRedemption:
+		event = &RedemptionEvent{}
+	default:
+		return nil, fmt.Errorf("unsupported subscription type: %s", subscriptionType)
+	}
+
+	if err := json.Unmarshal(raw, event); err != nil {
+		return nil, fmt.Errorf("failed to parse %s event: %w", subscriptionType, err)
+	}
+	return event, nil
+}
@@ -16,6 +16,11 @@ type FAQProcessor struct {
 	service    *faq.Service
 	responseCh chan<- types.TwitchMessage
 	logger     *logging.Logger
+
+	// channel limits the processor to messages from one channel. Empty means all channels.
+	channel string
+	// categories limits FAQ matches to these categories. Empty means all categories.
+	categories []string
 }
 
 // NewFAQProcessor creates a new FAQ processor
@@ -31,17 +36,35 @@ func NewFAQProcessor(service *faq.Service, responseCh chan<- types.TwitchMessage
 	}
 }
 
+// NewChannelFAQProcessor creates a FAQ processor that only answers in one channel
+// and only matches FAQ entries in the given categories
+func NewChannelFAQProcessor(service *faq.Service, channel string, categories []string, responseCh chan<- types.TwitchMessage, logger *logging.Logger) *FAQProcessor {
+	p := NewFAQProcessor(service, responseCh, logger)
+	p.channel = normalizeChannel(channel)
+	p.categories = categories
+	return p
+}
+
 // Name returns the consumer name for the message broker
 func (p *FAQProcessor) Name() string {
+	if p.channel != "" {
+		return "FAQProcessor:" + p.channel
+	}
 	return "FAQProcessor"
 }
 
 // ProcessMessage implements the Consumer interface for message broker
 // Converts v2.PrivateMessage and processes it against FAQ entries
 func (p *FAQProcessor) ProcessMessage(ctx context.Context, msg v2.PrivateMessage) {
+	if p.channel != "" && normalizeChannel(msg.Channel) != p.channel {
+		return
+	}
+
 	twitchMsg := types.TwitchMessage{
 		Username: msg.User.DisplayName,
 		Text:     msg.Message,
+		Channel:  normalizeChannel(msg.Channel),
+		TwitchID: msg.ID,
 		Time:     time.Now(),
 	}
 	p.processInternal(ctx, twitchMsg)
@@ -60,7 +83,7 @@ func (p *FAQProcessor) processInternal(ctx context.Context, msg types.TwitchMess
 	)
 
 	// Check against FAQ entries
-	result, err := p.service.CheckMessage(ctx, msg.Text, msg.Username)
+	result, err := p.service.CheckMessageInCategories(ctx, msg.Text, msg.Username, p.categories)
 	if err != nil {
 		p.logger.Error("FAQ processing error",
 			"error", err.Error(),
@@ -86,6 +109,8 @@ func (p *FAQProcessor) processInternal(ctx context.Context, msg types.TwitchMess
 	response := types.TwitchMessage{
 		Username: "Pedro_FAQ",
 		Text:     result.GeneratedResponse,
+		Channel:  msg.Channel,
+		ReplyTo:  msg.TwitchID,
 		Time:     time.Now(),
 	}
 
@@ -2,21 +2,25 @@ package twitchirc
 
 import (
 	"context"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/Soypete/twitch-llm-bot/ai/twitchchat"
+	"github.com/Soypete/twitch-llm-bot/ai/chatmemory"
 	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/twitch/outbound"
 	types "github.com/Soypete/twitch-llm-bot/types"
 	v2 "github.com/gempir/go-twitch-irc/v2"
+	"github.com/google/uuid"
 )
 
 func cleanMessage(msg v2.PrivateMessage) types.TwitchMessage {
 	chat := types.TwitchMessage{
 		Username: msg.User.DisplayName,
 		Text:     msg.Message,
-		// TODO: add an embedding for the message
-		Time: time.Now(),
+		Channel:  normalizeChannel(msg.Channel),
+		TwitchID: msg.ID,
+		Time:     time.Now(),
 	}
 
 	if strings.HasPrefix(msg.Message, "!") {
@@ -33,21 +37,9 @@ func cleanMessage(msg v2.PrivateMessage) types.TwitchMessage {
 	return chat
 }
 
-func needsResponseChat(msg types.TwitchMessage) bool {
-	switch {
-	case strings.Contains(msg.Text, "pedro"):
-		return true
-	case strings.Contains(msg.Text, "Pedro"):
-		return true
-	case strings.Contains(msg.Text, "llm"):
-		return true
-	case strings.Contains(msg.Text, "LLM"):
-		return true
-	case strings.Contains(msg.Text, "bot"):
-		return true
-	default:
-		return false
-	}
+// isBotMessage reports whether a message was sent by Pedro's own account
+func isBotMessage(msg v2.PrivateMessage) bool {
+	return slices.Contains(botLogins, strings.ToLower(msg.User.Name))
 }
 
 func (irc *IRC) HandleChat(ctx context.Context, msg v2.PrivateMessage) {
@@ -59,88 +51,133 @@ func (irc *IRC) HandleChat(ctx context.Context, msg v2.PrivateMessage) {
 		return
 	}
 
-	// Fork message to FAQ processor (non-blocking, runs in parallel)
-	// This checks if the message matches any FAQ entries and responds automatically
-	if irc.faqProcessor != nil && ShouldProcessMessage(msg) {
-		metrics.FAQCheckCount.Add(1)
-		go irc.faqProcessor.ProcessMessageFromPrivate(ctx, msg)
+	// do not persist, embed or answer Pedro's own messages
+	if isBotMessage(msg) {
+		irc.logger.Debug("ignoring Pedro's own message", "channel", chat.Channel)
+		return
 	}
 
-	// Get or create palace session for this stream
-	session, err := irc.sessionRegistry.GetOrCreateSession(peteTwitchChannel)
-	if err != nil {
-		irc.logger.Error("failed to get palace session", "error", err.Error())
+	channel := irc.GetChannel(chat.Channel)
+	if channel == nil {
+		irc.logger.Debug("ignoring message from unknown channel", "channel", chat.Channel)
+		return
 	}
 
-	// TODO: replace nitbot commands with a classifier model that prompts the LLM
-	if strings.Contains(chat.Text, "Pedro") || strings.Contains(chat.Text, "pedro") || strings.Contains(chat.Text, "soy_llm_bot") {
-		irc.logger.Debug("processing message that mentions bot")
-
-		// Get relevant context from palace
-		var palaceContext string
-		if session != nil {
-			palaceContext, err = session.GetContext(chat.Text)
-			if err != nil {
-				irc.logger.Error("failed to get palace context", "error", err.Error())
+	// `!` commands are answered by the command registry and never reach the LLM
+	if chat.IsCommand {
+		if reply, handled := irc.commands.Dispatch(ctx, msg, chat); handled {
+			if reply != "" {
+				irc.say(ctx, outbound.Message{
+					Channel: channel.Name,
+					Text:    reply,
+					Source:  outbound.SourceCommand,
+					ReplyTo: chat.TwitchID,
+				})
 			}
+			return
 		}
+	}
 
-		// Inject palace context into chat message for LLM
-		if palaceContext != "" {
-			chat.PalaceContext = palaceContext
-		}
+	// Fork message to FAQ processor (non-blocking, runs in parallel)
+	// This checks if the message matches any FAQ entries and responds automatically
+	if channel.faqProcessor != nil && ShouldProcessMessage(msg) {
+		metrics.FAQCheckCount.Add(1)
+		irc.goTracked(func() { channel.faqProcessor.ProcessMessageFromPrivate(ctx, msg) })
+	}
 
-		messageID, err := irc.db.InsertMessage(ctx, chat)
-		if err != nil {
-			irc.logger.Error("failed to insert message into types", "error", err.Error())
-			return
+	// Every message is persisted and embedded in the background, so Pedro can recall it later
+	messageID, err := irc.db.InsertMessage(ctx, chat)
+	if err != nil {
+		irc.logger.Error("failed to insert message into types", "error", err.Error())
+	} else {
+		irc.logger.Debug("message inserted into types", "messageID", messageID)
+		chat.UUID = messageID
+		if irc.chatMemory != nil {
+			irc.chatMemory.Enqueue(chat)
 		}
+	}
 
-		irc.logger.Debug("message inserted into types", "messageID", messageID)
-		resp, err := irc.llm.SingleMessageResponse(ctx, chat, messageID)
+	// Get or create palace session for this stream. Offline replays have none.
+	var session *PalaceSession
+	if irc.sessionRegistry != nil {
+		session, err = irc.sessionRegistry.GetOrCreateSession(channel.Name)
 		if err != nil {
-			irc.logger.Error("failed to get response from LLM", "error", err.Error(), "messageID", messageID)
-			return
+			irc.logger.Error("failed to get palace session", "error", err.Error())
 		}
+	}
 
-		// Check if this is a web search request
-		if resp.WebSearch != nil {
-			irc.logger.Debug("web search requested", "query", resp.WebSearch.Query, "messageID", messageID)
-
-			// Send immediate response
-			err = irc.db.InsertResponse(ctx, resp, irc.modelName)
-			if err != nil {
-				irc.logger.Error("failed to insert immediate response into database", "error", err.Error(), "messageID", resp.UUID)
-			}
-			irc.Client.Say(peteTwitchChannel, resp.Text)
-			metrics.TwitchMessageSentCount.Add(1)
-
-			// Start async web search
-			if twitchLLM, ok := irc.llm.(*twitchchat.Client); ok {
-				go twitchLLM.ExecuteWebSearch(ctx, resp.WebSearch, irc.asyncResponseCh)
-			} else {
-				irc.logger.Error("LLM client does not support web search")
-			}
+	// Pedro only answers messages that were persisted
+	if decision := irc.trigger.Evaluate(msg, chat); decision.Reply && chat.UUID != uuid.Nil {
+		// The LLM and its tools can take many seconds, so the reply is written in the
+		// background and the IRC read loop keeps reading chat
+		if !channel.startReply() {
+			irc.logger.Warn("channel has too many replies in progress, skipping message", "channel", channel.Name, "messageID", chat.UUID)
 			return
 		}
-
-		err = irc.db.InsertResponse(ctx, resp, irc.modelName)
-		if err != nil {
-			irc.logger.Error("failed to insert response into types", "error", err.Error(), "messageID", resp.UUID)
-			// continue to send the response even if it fails to insert into the types
-		}
-		// Don't log the actual response content to protect privacy
-		irc.logger.Debug("sending response to Twitch", "messageID", resp.UUID, "responseLength", len(resp.Text))
-		irc.Client.Say(peteTwitchChannel, resp.Text)
-		metrics.TwitchMessageSentCount.Add(1)
+		irc.goTracked(func() {
+			defer channel.finishReply()
+			irc.reply(ctx, channel, session, chat)
+		})
 	} else {
 		// Non-trigger message: index to palace asynchronously
 		if session != nil {
-			go func() {
+			irc.goTracked(func() {
 				if err := session.IndexMessage(chat.Username, chat.Text); err != nil {
 					irc.logger.Error("failed to index message to palace", "error", err.Error())
 				}
-			}()
+			})
 		}
 	}
 }
+
+// reply answers a persisted chat message with the LLM, stores the answer and sends it
+func (irc *IRC) reply(ctx context.Context, channel *Channel, session *PalaceSession, chat types.TwitchMessage) {
+	messageID := chat.UUID
+
+	// Get relevant context from palace
+	var palaceContext string
+	var err error
+	if session != nil {
+		palaceContext, err = session.GetContext(chat.Text)
+		if err != nil {
+			irc.logger.Error("failed to get palace context", "error", err.Error())
+		}
+	}
+
+	// Inject palace context into chat message for LLM
+	if palaceContext != "" {
+		chat.PalaceContext = palaceContext
+	}
+
+	// Recall similar messages from earlier streams
+	if irc.chatMemory != nil {
+		memories, err := irc.chatMemory.Recall(ctx, chat)
+		if err != nil {
+			irc.logger.Error("failed to recall chat memories", "error", err.Error(), "messageID", messageID)
+		}
+		chat.MemoryContext = chatmemory.Format(memories)
+	}
+
+	resp, err := irc.llm.SingleMessageResponse(ctx, chat, messageID)
+	if err != nil {
+		irc.logger.Error("failed to get response from LLM", "error", err.Error(), "messageID", messageID)
+		return
+	}
+	// Responses always go back to the channel the message came from
+	resp.Channel = channel.Name
+
+	err = irc.db.InsertResponse(ctx, resp, irc.modelName)
+	if err != nil {
+		irc.logger.Error("failed to insert response into types", "error", err.Error(), "messageID", resp.UUID)
+		// continue to send the response even if it fails to insert into the types
+	}
+	// Don't log the actual response content to protect privacy
+	irc.logger.Debug("sending response to Twitch", "messageID", resp.UUID, "responseLength", len(resp.Text))
+	irc.say(ctx, outbound.Message{
+		Channel: channel.Name,
+		Text:    resp.Text,
+		Source:  outbound.SourceChat,
+		ChatID:  resp.UUID,
+		ReplyTo: chat.TwitchID,
+	})
+}
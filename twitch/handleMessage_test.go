@@ -1,11 +1,16 @@
 package twitchirc
 
 import (
+	"context"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/Soypete/twitch-llm-bot/logging"
 	types "github.com/Soypete/twitch-llm-bot/types"
 	v2 "github.com/gempir/go-twitch-irc/v2"
+	"github.com/google/uuid"
 )
 
 func Test_cleanMessage(t *testing.T) {
@@ -50,46 +55,79 @@ func Test_cleanMessage(t *testing.T) {
 	}
 }
 
-func Test_needsResponseChat(t *testing.T) {
+func TestIRC_HandleChatIgnoresBotMessages(t *testing.T) {
 	tests := []struct {
-		name string
-		msg  types.TwitchMessage
-		want bool
+		name   string
+		user   v2.User
+		wantOK bool
 	}{
-		{
-			name: "Pedro",
-			msg: types.TwitchMessage{
-				Text: "hey, Pedro tell me a joke",
-			},
-			want: true,
-		},
-		{
-			name: "llm",
-			msg: types.TwitchMessage{
-				Text: "hey, llm tell me a joke",
-			},
-			want: true,
-		},
-		{
-			name: "bot",
-			msg: types.TwitchMessage{
-				Text: "hey, bot tell me a joke",
-			},
-			want: true,
-		},
-		{
-			name: "no response",
-			msg: types.TwitchMessage{
-				Text: "hey, tell me a joke",
-			},
-			want: false,
-		},
+		{name: "pedro", user: v2.User{Name: "pedro_el_asistente", DisplayName: "Pedro_El_Asistente"}},
+		{name: "fallback account", user: v2.User{Name: "soy_llm_bot", DisplayName: "soy_llm_bot"}},
+		{name: "viewer", user: v2.User{Name: "scott", DisplayName: "Scott"}, wantOK: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := needsResponseChat(tt.msg); got != tt.want {
-				t.Errorf("needsResponseChat() = %v, want %v", got, tt.want)
+			msg := v2.PrivateMessage{User: tt.user, Channel: "soypetetech", Message: "pedro, how do channels work?"}
+			if got := isBotMessage(msg); got == tt.wantOK {
+				t.Errorf("isBotMessage() = %v, want %v", got, !tt.wantOK)
+			}
+
+			// Pedro's own messages never reach the database. The insert fails so the
+			// viewer's message stops before it reaches the LLM.
+			db := &fakeChatWriter{err: context.Canceled}
+			irc := &IRC{
+				db:       db,
+				logger:   logging.Default(),
+				channels: map[string]*Channel{"soypetetech": newChannel(ChannelConfig{Name: "soypetetech"}, nil)},
+				trigger:  NewTrigger(nil, 0, nil, nil),
+				commands: NewCommandRegistry(nil),
+			}
+			irc.HandleChat(context.Background(), msg)
+			if stored := len(db.messages) > 0; stored != tt.wantOK {
+				t.Errorf("message stored = %v, want %v", stored, tt.wantOK)
 			}
 		})
 	}
 }
+
+// blockingLLM answers once release is closed and counts the messages it was sent
+type blockingLLM struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (b *blockingLLM) SingleMessageResponse(_ context.Context, msg types.TwitchMessage, messageID uuid.UUID) (types.TwitchMessage, error) {
+	b.calls.Add(1)
+	<-b.release
+	return types.TwitchMessage{Text: "hola", UUID: messageID}, nil
+}
+
+func TestIRC_HandleChatRepliesInBackground(t *testing.T) {
+	llm := &blockingLLM{release: make(chan struct{})}
+	irc := newDrainTestIRC(nil)
+	irc.db = &fakeChatWriter{id: uuid.New()}
+	irc.llm = llm
+	irc.trigger = NewTrigger(nil, 0, nil, nil)
+	irc.commands = NewCommandRegistry(nil)
+
+	// HandleChat returns while the LLM is still writing, and a channel only has
+	// maxChannelReplies replies in progress
+	for i := 0; i < maxChannelReplies+1; i++ {
+		irc.HandleChat(context.Background(), v2.PrivateMessage{User: v2.User{Name: "scott", DisplayName: "Scott"}, Channel: "soypetetech", Message: "pedro, what is a goroutine?"})
+	}
+	deadline := time.Now().Add(time.Second)
+	for llm.calls.Load() < maxChannelReplies && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := llm.calls.Load(); got != maxChannelReplies {
+		t.Errorf("LLM calls = %d, want %d", got, maxChannelReplies)
+	}
+
+	close(llm.release)
+	for irc.inflight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := irc.inflight.Load(); got != 0 {
+		t.Errorf("replies still in progress = %d", got)
+	}
+}
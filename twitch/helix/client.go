@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/Soypete/twitch-llm-bot/logging"
@@ -22,6 +23,7 @@ const (
 type Client struct {
 	httpClient    *http.Client
 	clientID      string
+	tokenMu       sync.RWMutex
 	accessToken   string
 	broadcasterID string
 	moderatorID   string
@@ -47,9 +49,17 @@ func NewClient(clientID, accessToken, broadcasterID, moderatorID string, logger
 
 // UpdateToken updates the access token (for token refresh scenarios)
 func (c *Client) UpdateToken(accessToken string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
 	c.accessToken = accessToken
 }
 
+func (c *Client) token() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.accessToken
+}
+
 // doRequest performs an HTTP request to the Twitch API
 func (c *Client) doRequest(ctx context.Context, method, endpoint string, query url.Values, body interface{}) ([]byte, error) {
 	var bodyReader io.Reader
@@ -71,7 +81,7 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, query u
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Authorization", "Bearer "+c.token())
 	req.Header.Set("Client-Id", c.clientID)
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
@@ -438,3 +448,68 @@ func (c *Client) GetStreamStatus(ctx context.Context, userID string) (*StreamSta
 func (c *Client) GetBroadcasterStreamStatus(ctx context.Context) (*StreamStatus, error) {
 	return c.GetStreamStatus(ctx, c.broadcasterID)
 }
+
+// EventSubTransport describes where Twitch should deliver EventSub notifications
+type EventSubTransport struct {
+	Method    string `json:"method"` // websocket or webhook
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// EventSubSubscriptionRequest represents the request body for creating an EventSub subscription
+type EventSubSubscriptionRequest struct {
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Condition map[string]string `json:"condition"`
+	Transport EventSubTransport `json:"transport"`
+}
+
+// CreateEventSubSubscription subscribes to an EventSub topic
+func (c *Client) CreateEventSubSubscription(ctx context.Context, req EventSubSubscriptionRequest) ([]byte, error) {
+	return c.doRequest(ctx, http.MethodPost, "/eventsub/subscriptions", nil, req)
+}
+
+// SendChatMessageRequest represents the request body for sending a chat message
+type SendChatMessageRequest struct {
+	BroadcasterID        string `json:"broadcaster_id"`
+	SenderID             string `json:"sender_id"`
+	Message              string `json:"message"`
+	ReplyParentMessageID string `json:"reply_parent_message_id,omitempty"`
+}
+
+// SendChatMessageResponse represents the response from the Send Chat Message endpoint
+type SendChatMessageResponse struct {
+	Data []struct {
+		MessageID  string `json:"message_id"`
+		IsSent     bool   `json:"is_sent"`
+		DropReason *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"drop_reason"`
+	} `json:"data"`
+}
+
+// SendChatMessage sends a chat message as the sender, optionally as a reply to another
+// message. It needs the user:write:chat scope and returns an error if Twitch drops the message.
+func (c *Client) SendChatMessage(ctx context.Context, req SendChatMessageRequest) (string, error) {
+	respBody, err := c.doRequest(ctx, http.MethodPost, "/chat/messages", nil, req)
+	if err != nil {
+		return "", err
+	}
+
+	var resp SendChatMessageResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse chat message response: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return "", fmt.Errorf("empty chat message response")
+	}
+
+	sent := resp.Data[0]
+	if !sent.IsSent {
+		if sent.DropReason != nil {
+			return "", fmt.Errorf("chat message dropped: %s: %s", sent.DropReason.Code, sent.DropReason.Message)
+		}
+		return "", fmt.Errorf("chat message dropped")
+	}
+	return sent.MessageID, nil
+}
@@ -4,8 +4,10 @@ package messagequeue
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/twitch/eventsub"
 	v2 "github.com/gempir/go-twitch-irc/v2"
 )
 
@@ -15,12 +17,21 @@ type Consumer interface {
 	Name() string
 }
 
+// EventConsumer is implemented by consumers that also want EventSub events
+// (follows, subs, raids, cheers and channel point redemptions)
+type EventConsumer interface {
+	ProcessEvent(ctx context.Context, event eventsub.Event)
+}
+
 // Broker distributes messages to multiple consumers
 type Broker struct {
 	consumers []Consumer
 	msgQueue  chan v2.PrivateMessage
 	logger    *logging.Logger
 	mu        sync.RWMutex
+
+	// processing is the number of messages being fanned out right now
+	processing atomic.Int64
 }
 
 // NewBroker creates a new message broker
@@ -72,7 +83,9 @@ func (b *Broker) Start(ctx context.Context, wg *sync.WaitGroup) {
 				b.logger.Info("message broker shutting down")
 				return
 			case msg := <-b.msgQueue:
+				b.processing.Add(1)
 				b.fanout(ctx, msg)
+				b.processing.Add(-1)
 			}
 		}
 	}()
@@ -95,6 +108,33 @@ func (b *Broker) fanout(ctx context.Context, msg v2.PrivateMessage) {
 	wg.Wait()
 }
 
+// PublishEvent distributes an EventSub event to all consumers that implement EventConsumer.
+// Its signature matches eventsub.Handler so it can be subscribed directly to an eventsub.Bus.
+func (b *Broker) PublishEvent(ctx context.Context, event eventsub.Event) {
+	b.mu.RLock()
+	consumers := b.consumers
+	b.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, consumer := range consumers {
+		ec, ok := consumer.(EventConsumer)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(c EventConsumer) {
+			defer wg.Done()
+			c.ProcessEvent(ctx, event)
+		}(ec)
+	}
+	wg.Wait()
+}
+
+// Pending returns the number of messages queued or being processed
+func (b *Broker) Pending() int {
+	return len(b.msgQueue) + int(b.processing.Load())
+}
+
 // GetQueueLength returns the current queue depth
 func (b *Broker) GetQueueLength() int {
 	return len(b.msgQueue)
@@ -7,19 +7,21 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/prompts"
 	"github.com/Soypete/twitch-llm-bot/ai/twitchchat/agent"
 	"github.com/Soypete/twitch-llm-bot/database"
 	"github.com/Soypete/twitch-llm-bot/logging"
 	"github.com/Soypete/twitch-llm-bot/metrics"
 	"github.com/Soypete/twitch-llm-bot/twitch/helix"
+	"github.com/Soypete/twitch-llm-bot/twitch/outbound"
 	"github.com/Soypete/twitch-llm-bot/types"
 	v2 "github.com/gempir/go-twitch-irc/v2"
 	"github.com/google/uuid"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 // Monitor handles chat moderation in parallel to the main chat handler
@@ -36,7 +38,10 @@ type Monitor struct {
 	recentMsgs    []types.TwitchMessage
 	recentMsgsMu  sync.RWMutex
 	maxRecentMsgs int
-	ircClient     *v2.Client
+	sender        *outbound.Sender
+
+	// processing is 1 while a message is being evaluated
+	processing atomic.Int64
 
 	// Rate limiting
 	actionCount   int
@@ -47,7 +52,7 @@ type Monitor struct {
 // NewMonitor creates a new moderation monitor
 func NewMonitor(
 	config *ai.ModerationConfig,
-	llmPath string,
+	llm llms.Model,
 	modelName string,
 	helixClient *helix.Client,
 	db database.ModActionWriter,
@@ -59,18 +64,8 @@ func NewMonitor(
 		logger = logging.Default()
 	}
 
-	// Set up LLM client
-	if llmPath != "" && !strings.HasSuffix(llmPath, "/v1") {
-		llmPath = llmPath + "/v1"
-	}
-
-	opts := []openai.Option{
-		openai.WithBaseURL(llmPath),
-		openai.WithModel(modelName),
-	}
-	llm, err := openai.New(opts...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create moderation LLM: %w", err)
+	if llm == nil {
+		return nil, fmt.Errorf("moderation LLM is required")
 	}
 
 	return &Monitor{
@@ -89,9 +84,9 @@ func NewMonitor(
 	}, nil
 }
 
-// SetIRCClient sets the IRC client for sending warning messages
-func (m *Monitor) SetIRCClient(client *v2.Client) {
-	m.ircClient = client
+// SetSender sets the outbound chat sender used for warning messages
+func (m *Monitor) SetSender(sender *outbound.Sender) {
+	m.sender = sender
 }
 
 // MessageChannel returns the channel for sending messages to be moderated
@@ -99,6 +94,11 @@ func (m *Monitor) MessageChannel() chan<- v2.PrivateMessage {
 	return m.messageCh
 }
 
+// Pending returns the number of messages waiting for or under moderation
+func (m *Monitor) Pending() int {
+	return len(m.messageCh) + int(m.processing.Load())
+}
+
 // Start begins the moderation monitoring loop
 func (m *Monitor) Start(ctx context.Context, wg *sync.WaitGroup) {
 	wg.Add(1)
@@ -112,12 +112,22 @@ func (m *Monitor) Start(ctx context.Context, wg *sync.WaitGroup) {
 				m.logger.Info("moderation monitor shutting down")
 				return
 			case msg := <-m.messageCh:
+				m.processing.Add(1)
 				m.processMessage(ctx, msg)
+				m.processing.Add(-1)
 			}
 		}
 	}()
 }
 
+// Evaluate moderates one message right away instead of queueing it. Offline replays use
+// it so decisions line up with the messages that caused them.
+func (m *Monitor) Evaluate(ctx context.Context, msg v2.PrivateMessage) {
+	m.processing.Add(1)
+	defer m.processing.Add(-1)
+	m.processMessage(ctx, msg)
+}
+
 // processMessage evaluates a message for moderation
 func (m *Monitor) processMessage(ctx context.Context, msg v2.PrivateMessage) {
 	// Skip if moderation is disabled
@@ -200,6 +210,11 @@ func (m *Monitor) shouldSkipUser(username string) bool {
 		"soypetetech", // Don't moderate the streamer
 	}
 
+	// Don't moderate the broadcaster of the channel being monitored
+	if m.channelName != "" && strings.EqualFold(username, m.channelName) {
+		return true
+	}
+
 	for _, skip := range skipUsers {
 		if strings.EqualFold(username, skip) {
 			return true
@@ -295,38 +310,26 @@ func (m *Monitor) getRecentMessages() []types.TwitchMessage {
 
 // evaluateWithLLM uses the LLM to decide on moderation action
 func (m *Monitor) evaluateWithLLM(ctx context.Context, modContext types.ModerationContext) (*types.ModerationDecision, error) {
-	// Build the system prompt
-	rulesStr := strings.Join(modContext.ChannelRules, "\n- ")
-	if rulesStr != "" {
-		rulesStr = "- " + rulesStr
+	systemPrompt, err := prompts.Default().Render(prompts.ModerationSystem, prompts.ModerationData{
+		Rules:       modContext.ChannelRules,
+		Sensitivity: m.config.SensitivityLevel,
+	})
+	if err != nil {
+		return nil, err
 	}
-	systemPrompt := fmt.Sprintf(ai.ModerationPrompt, rulesStr, m.config.SensitivityLevel)
-
-	// Build the user message with context
-	var recentContext strings.Builder
-	recentContext.WriteString("Recent chat messages:\n")
-	for _, msg := range modContext.RecentMessages {
-		fmt.Fprintf(&recentContext, "[%s]: %s\n", msg.Username, msg.Text)
+	userMessage, err := prompts.Default().Render(prompts.ModerationMessage, prompts.ModerationMessageData{
+		Recent:    modContext.RecentMessages,
+		Message:   modContext.Message,
+		MessageID: modContext.MessageID,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	userMessage := fmt.Sprintf(`%s
-
-Message to evaluate:
-User: %s
-Message ID: %s
-Content: %s
-
-Analyze this message and decide if moderation action is needed. Call exactly one tool with your decision.`,
-		recentContext.String(),
-		modContext.Message.Username,
-		modContext.MessageID,
-		modContext.Message.Text,
-	)
-
 	// Build message history
 	messageHistory := []llms.MessageContent{
-		llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt),
-		llms.TextParts(llms.ChatMessageTypeHuman, userMessage),
+		llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt.Text),
+		llms.TextParts(llms.ChatMessageTypeHuman, userMessage.Text),
 	}
 
 	// Get available tools based on configuration
@@ -553,8 +556,8 @@ func (m *Monitor) logModAction(ctx context.Context, msg v2.PrivateMessage, decis
 // Action execution methods
 
 func (m *Monitor) executeWarnUser(ctx context.Context, msg v2.PrivateMessage, decision *types.ModerationDecision) error {
-	if m.ircClient == nil {
-		return fmt.Errorf("IRC client not set")
+	if m.sender == nil {
+		return fmt.Errorf("chat sender not set")
 	}
 
 	message := "Please follow the channel rules."
@@ -563,8 +566,14 @@ func (m *Monitor) executeWarnUser(ctx context.Context, msg v2.PrivateMessage, de
 	}
 
 	warningMsg := fmt.Sprintf("@%s %s soypet2Peace", msg.User.DisplayName, message)
-	m.ircClient.Say(m.channelName, warningMsg)
-	return nil
+	// Warnings jump ahead of queued chatter replies
+	return m.sender.Send(ctx, outbound.Message{
+		Channel:  m.channelName,
+		Text:     warningMsg,
+		Priority: outbound.PriorityHigh,
+		Source:   outbound.SourceModeration,
+		ReplyTo:  msg.ID,
+	})
 }
 
 func (m *Monitor) executeTimeoutUser(ctx context.Context, msg v2.PrivateMessage, decision *types.ModerationDecision) ([]byte, error) {
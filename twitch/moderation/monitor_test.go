@@ -1,10 +1,17 @@
 package moderation
 
 import (
+	"context"
+	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/twitchchat/agent"
+	"github.com/Soypete/twitch-llm-bot/internal/llmtest"
 	"github.com/Soypete/twitch-llm-bot/types"
+	v2 "github.com/gempir/go-twitch-irc/v2"
+	"github.com/google/uuid"
 )
 
 func TestNeedsEvaluation(t *testing.T) {
@@ -123,6 +130,17 @@ func TestShouldSkipUser(t *testing.T) {
 	}
 }
 
+func TestShouldSkipUser_ChannelBroadcaster(t *testing.T) {
+	m := &Monitor{channelName: "costreamer"}
+
+	if !m.shouldSkipUser("CoStreamer") {
+		t.Errorf("shouldSkipUser() should skip the broadcaster of the monitored channel")
+	}
+	if m.shouldSkipUser("regularuser123") {
+		t.Errorf("shouldSkipUser() should not skip regular users")
+	}
+}
+
 func TestHasRepeatedChars(t *testing.T) {
 	tests := []struct {
 		name string
@@ -312,3 +330,101 @@ func TestGetAvailableTools(t *testing.T) {
 		})
 	}
 }
+
+// recordingModDB keeps the logged moderation actions
+type recordingModDB struct {
+	mu      sync.Mutex
+	actions []types.ModAction
+}
+
+func (r *recordingModDB) InsertModAction(_ context.Context, action types.ModAction) (uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actions = append(r.actions, action)
+	return action.ID, nil
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		message    string
+		response   llmtest.Response
+		wantTool   string
+		wantTarget string
+		wantLLM    bool
+	}{
+		{
+			name:     "safe message skips the LLM",
+			message:  "Hello everyone!",
+			response: llmtest.Response{Content: "unused"},
+		},
+		{
+			name:    "link spam is timed out",
+			message: "cheap followers at https://spam.example",
+			response: llmtest.Response{ToolCalls: []llmtest.ToolCall{{
+				Name:      agent.ToolTimeoutUser,
+				Arguments: `{"username":"spammer","duration":600,"reason":"link spam"}`,
+			}}},
+			wantTool:   agent.ToolTimeoutUser,
+			wantTarget: "spammer",
+			wantLLM:    true,
+		},
+		{
+			name:       "answer without a tool call is no action",
+			message:    "CHECK OUT MY CHANNEL PLEASE EVERYONE",
+			response:   llmtest.Response{Content: "looks fine to me"},
+			wantTool:   agent.ToolNoAction,
+			wantTarget: "spammer",
+			wantLLM:    true,
+		},
+		{
+			name:     "backend error logs nothing",
+			message:  "cheap followers at https://spam.example",
+			response: llmtest.Response{Status: http.StatusInternalServerError},
+			wantLLM:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := llmtest.NewServer(t)
+			server.Default(tt.response)
+
+			config := ai.DefaultModerationConfig()
+			config.Enabled = true
+			config.DryRun = true
+			db := &recordingModDB{}
+			m, err := NewMonitor(config, server.Client(t, "test-model"), "test-model", nil, db, "", "soypetetech", nil)
+			if err != nil {
+				t.Fatalf("NewMonitor() error = %v", err)
+			}
+
+			m.Evaluate(context.Background(), v2.PrivateMessage{
+				ID:      "msg-1",
+				Channel: "soypetetech",
+				User:    v2.User{Name: "spammer", DisplayName: "spammer"},
+				Message: tt.message,
+			})
+
+			requests := server.Requests()
+			if (len(requests) > 0) != tt.wantLLM {
+				t.Fatalf("LLM called %d times, want called %v", len(requests), tt.wantLLM)
+			}
+			if tt.wantLLM && !requests[0].HasTool(agent.ToolTimeoutUser) {
+				t.Errorf("request tools = %v, want the allowed moderation tools", requests[0].Tools)
+			}
+			if tt.wantTool == "" {
+				if len(db.actions) != 0 {
+					t.Errorf("logged %d actions, want none", len(db.actions))
+				}
+				return
+			}
+			if len(db.actions) != 1 {
+				t.Fatalf("logged %d actions, want 1", len(db.actions))
+			}
+			action := db.actions[0]
+			if action.ToolCallName != tt.wantTool || action.TargetUsername != tt.wantTarget || action.TriggerMessageID != "msg-1" {
+				t.Errorf("action = %s of %s for %s, want %s of %s for msg-1", action.ToolCallName, action.TargetUsername, action.TriggerMessageID, tt.wantTool, tt.wantTarget)
+			}
+		})
+	}
+}
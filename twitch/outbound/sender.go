@@ -0,0 +1,447 @@
+// Package outbound provides the single queue every subsystem uses to send messages
+// to Twitch chat. It enforces Twitch's send rate limit, works around duplicate
+// message rejection, splits long messages and records every send.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+)
+
+// Priority controls the order messages leave the queue
+type Priority int
+
+const (
+	// PriorityNormal is used for chat replies, FAQ answers and greetings
+	PriorityNormal Priority = iota
+	// PriorityHigh is used for moderation warnings, which skip ahead of chatter replies
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	if p == PriorityHigh {
+		return "high"
+	}
+	return "normal"
+}
+
+// Message sources, used for metrics and the chat_sends table
+const (
+	SourceChat       = "chat"
+	SourceWebSearch  = "web_search"
+	SourceFAQ        = "faq"
+	SourceModeration = "moderation"
+	SourceGreeting   = "greeting"
+	SourceEvent      = "event"
+	SourceCommand    = "command"
+)
+
+// duplicateSuffix is appended to a message identical to the previous one so Twitch
+// does not silently drop it. It is an invisible tag character.
+const duplicateSuffix = " \U000E0000"
+
+// ErrQueueFull is returned when a message cannot be queued
+var ErrQueueFull = errors.New("outbound queue is full")
+
+// ErrFiltered is returned when the sender's filter drops a message
+var ErrFiltered = errors.New("message dropped by output filter")
+
+// Message is a chat message waiting to be sent
+type Message struct {
+	Channel  string
+	Text     string
+	Priority Priority
+	Source   string
+	// ChatID is the twitch_chat message this is a response to, if any
+	ChatID uuid.UUID
+	// ReplyTo is the Twitch message ID to thread this message under, if any
+	ReplyTo string
+}
+
+// Transport delivers a single chat line. *v2.Client satisfies this interface.
+type Transport interface {
+	Say(channel, text string)
+}
+
+// Replier sends a chat line as a threaded reply to another chat message
+type Replier interface {
+	Reply(ctx context.Context, channel, parentID, text string) error
+}
+
+// Filter checks and rewrites a message's text before it is queued. Returning an error
+// drops the message. *safety.Pipeline satisfies this interface.
+type Filter interface {
+	Check(ctx context.Context, channel, source, text string) (string, error)
+}
+
+// Recorder stores sent messages. *database.Postgres satisfies this interface.
+type Recorder interface {
+	InsertChatSend(ctx context.Context, send types.ChatSend) error
+}
+
+// Config holds the limits the sender enforces
+type Config struct {
+	// RateLimit is the number of messages allowed per RateWindow across all channels
+	RateLimit  int
+	RateWindow time.Duration
+	// MaxLength is the longest single chat message in characters
+	MaxLength int
+	// DuplicateWindow is how long Twitch rejects a repeated identical message
+	DuplicateWindow time.Duration
+	// QueueSize is the maximum number of queued parts per priority
+	QueueSize int
+}
+
+// DefaultConfig returns Twitch's limits for an account that is not a moderator
+// in every channel it talks in.
+func DefaultConfig() Config {
+	return Config{
+		RateLimit:       20,
+		RateWindow:      30 * time.Second,
+		MaxLength:       500,
+		DuplicateWindow: 30 * time.Second,
+		QueueSize:       100,
+	}
+}
+
+type queuedPart struct {
+	msg      Message
+	text     string
+	part     int
+	parts    int
+	queuedAt time.Time
+}
+
+type lastMessage struct {
+	text string
+	at   time.Time
+}
+
+// Sender is the outbound chat queue
+type Sender struct {
+	config    Config
+	transport Transport
+	replier   Replier
+	filter    Filter
+	recorder  Recorder
+	logger    *logging.Logger
+
+	mu       sync.Mutex
+	high     []queuedPart
+	normal   []queuedPart
+	sending  int
+	sent     []time.Time
+	lastSent map[string]lastMessage
+	notify   chan struct{}
+}
+
+// NewSender creates a new outbound sender. transport may be set later with SetTransport
+// and recorder may be nil.
+func NewSender(transport Transport, recorder Recorder, config Config, logger *logging.Logger) *Sender {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	defaults := DefaultConfig()
+	if config.RateLimit <= 0 {
+		config.RateLimit = defaults.RateLimit
+	}
+	if config.RateWindow <= 0 {
+		config.RateWindow = defaults.RateWindow
+	}
+	if config.MaxLength <= 0 {
+		config.MaxLength = defaults.MaxLength
+	}
+	if config.DuplicateWindow <= 0 {
+		config.DuplicateWindow = defaults.DuplicateWindow
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+
+	return &Sender{
+		config:    config,
+		transport: transport,
+		recorder:  recorder,
+		logger:    logger,
+		lastSent:  make(map[string]lastMessage),
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// SetTransport sets the connection messages are sent on (e.g. after an IRC reconnect)
+func (s *Sender) SetTransport(transport Transport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transport = transport
+}
+
+// SetReplier sets how messages with ReplyTo are threaded. Without a replier, or when
+// a reply fails, they are sent as regular chat lines.
+func (s *Sender) SetReplier(replier Replier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replier = replier
+}
+
+// SetFilter sets the filter every message passes before it is queued
+func (s *Sender) SetFilter(filter Filter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter = filter
+}
+
+// Send runs a message through the filter, splits it into parts that fit Twitch's length
+// limit and queues them. The filter runs on the caller's goroutine with ctx, so a slow
+// check never holds up other channels' messages, but Send does not block on the rate limit.
+func (s *Sender) Send(ctx context.Context, msg Message) error {
+	if msg.Channel == "" {
+		return fmt.Errorf("channel is required")
+	}
+	if msg.Source == "" {
+		msg.Source = SourceChat
+	}
+
+	s.mu.Lock()
+	filter := s.filter
+	s.mu.Unlock()
+	if filter != nil {
+		text, err := filter.Check(ctx, msg.Channel, msg.Source, msg.Text)
+		if err != nil {
+			metrics.ChatMessagesDroppedTotal.WithLabelValues("filtered").Inc()
+			return fmt.Errorf("%w: %w", ErrFiltered, err)
+		}
+		msg.Text = text
+	}
+
+	// Leave room for the duplicate suffix so a repeated part still fits
+	parts := SplitMessage(msg.Text, s.config.MaxLength-utf8.RuneCountInString(duplicateSuffix))
+	if len(parts) == 0 {
+		return fmt.Errorf("message is empty")
+	}
+
+	s.mu.Lock()
+	queue := &s.normal
+	if msg.Priority == PriorityHigh {
+		queue = &s.high
+	}
+	if len(*queue)+len(parts) > s.config.QueueSize {
+		s.mu.Unlock()
+		metrics.ChatMessagesDroppedTotal.WithLabelValues("queue_full").Inc()
+		s.logger.Warn("outbound queue full, dropping message", "channel", msg.Channel, "source", msg.Source, "priority", msg.Priority.String())
+		return ErrQueueFull
+	}
+
+	now := time.Now()
+	for i, text := range parts {
+		*queue = append(*queue, queuedPart{
+			msg:      msg,
+			text:     text,
+			part:     i + 1,
+			parts:    len(parts),
+			queuedAt: now,
+		})
+	}
+	s.updateDepthLocked()
+	s.mu.Unlock()
+
+	if len(parts) > 1 {
+		s.logger.Debug("split long message", "channel", msg.Channel, "source", msg.Source, "parts", len(parts))
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// QueueLength returns the number of parts waiting to be sent
+func (s *Sender) QueueLength() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.high) + len(s.normal)
+}
+
+// Pending returns the number of parts queued or being sent right now
+func (s *Sender) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.high) + len(s.normal) + s.sending
+}
+
+// Start sends queued messages until ctx is cancelled
+func (s *Sender) Start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.logger.Info("outbound chat sender started", "rateLimit", s.config.RateLimit, "rateWindow", s.config.RateWindow)
+		s.run(ctx)
+		s.logger.Info("outbound chat sender shutting down", "queued", s.QueueLength())
+	}()
+}
+
+func (s *Sender) run(ctx context.Context) {
+	for {
+		if s.QueueLength() == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.notify:
+				continue
+			}
+		}
+
+		if !s.waitForSlot(ctx) {
+			return
+		}
+
+		// Pick the message after waiting so a warning queued meanwhile goes first
+		part, ok := s.pop()
+		if !ok {
+			continue
+		}
+		s.send(ctx, part)
+
+		s.mu.Lock()
+		s.sending--
+		s.mu.Unlock()
+	}
+}
+
+// waitForSlot blocks until sending another message stays within the rate limit
+func (s *Sender) waitForSlot(ctx context.Context) bool {
+	for {
+		wait := s.rateLimitDelay(time.Now())
+		if wait <= 0 {
+			return true
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// rateLimitDelay returns how long to wait before the next send is allowed
+func (s *Sender) rateLimitDelay(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-s.config.RateWindow)
+	kept := s.sent[:0]
+	for _, t := range s.sent {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	s.sent = kept
+
+	if len(s.sent) < s.config.RateLimit {
+		return 0
+	}
+	return s.sent[0].Add(s.config.RateWindow).Sub(now)
+}
+
+// pop removes the next part, high priority first
+func (s *Sender) pop() (queuedPart, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var part queuedPart
+	switch {
+	case len(s.high) > 0:
+		part, s.high = s.high[0], s.high[1:]
+	case len(s.normal) > 0:
+		part, s.normal = s.normal[0], s.normal[1:]
+	default:
+		return part, false
+	}
+	s.sending++
+	s.updateDepthLocked()
+	return part, true
+}
+
+// send delivers one part and records it
+func (s *Sender) send(ctx context.Context, part queuedPart) {
+	now := time.Now()
+
+	s.mu.Lock()
+	transport := s.transport
+	replier := s.replier
+	text := part.text
+	if last, ok := s.lastSent[part.msg.Channel]; ok && last.text == text && now.Sub(last.at) < s.config.DuplicateWindow {
+		text += duplicateSuffix
+	}
+	if transport != nil {
+		s.sent = append(s.sent, now)
+		s.lastSent[part.msg.Channel] = lastMessage{text: text, at: now}
+	}
+	s.mu.Unlock()
+
+	if transport == nil {
+		metrics.ChatMessagesDroppedTotal.WithLabelValues("not_connected").Inc()
+		s.logger.Warn("no chat connection, dropping message", "channel", part.msg.Channel, "source", part.msg.Source)
+		return
+	}
+
+	replyTo := ""
+	if part.msg.ReplyTo != "" && replier != nil {
+		if err := replier.Reply(ctx, part.msg.Channel, part.msg.ReplyTo, text); err != nil {
+			s.logger.Warn("failed to send threaded reply, sending as a chat message", "error", err.Error(), "channel", part.msg.Channel, "source", part.msg.Source)
+			transport.Say(part.msg.Channel, text)
+		} else {
+			replyTo = part.msg.ReplyTo
+		}
+	} else {
+		transport.Say(part.msg.Channel, text)
+	}
+
+	metrics.TwitchMessageSentCount.Add(1)
+	metrics.ChatMessagesSentTotal.WithLabelValues(part.msg.Source, part.msg.Priority.String()).Inc()
+	metrics.ChatQueueWaitDuration.Observe(now.Sub(part.queuedAt).Seconds())
+	s.logger.Debug("sent chat message",
+		"channel", part.msg.Channel,
+		"source", part.msg.Source,
+		"priority", part.msg.Priority.String(),
+		"part", part.part,
+		"parts", part.parts,
+		"reply", replyTo != "",
+		"length", len(text))
+
+	if s.recorder == nil {
+		return
+	}
+	record := types.ChatSend{
+		ID:       uuid.New(),
+		Channel:  part.msg.Channel,
+		Message:  text,
+		Source:   part.msg.Source,
+		Priority: part.msg.Priority.String(),
+		Part:     part.part,
+		Parts:    part.parts,
+		ChatID:   uuid.NullUUID{UUID: part.msg.ChatID, Valid: part.msg.ChatID != uuid.Nil},
+		ReplyTo:  replyTo,
+		QueuedAt: part.queuedAt,
+		SentAt:   now,
+	}
+	if err := s.recorder.InsertChatSend(ctx, record); err != nil {
+		s.logger.Error("failed to record chat send", "error", err.Error(), "channel", part.msg.Channel)
+	}
+}
+
+func (s *Sender) updateDepthLocked() {
+	metrics.ChatQueueDepth.WithLabelValues(PriorityHigh.String()).Set(float64(len(s.high)))
+	metrics.ChatQueueDepth.WithLabelValues(PriorityNormal.String()).Set(float64(len(s.normal)))
+}
@@ -0,0 +1,414 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+)
+
+type fakeTransport struct {
+	mu    sync.Mutex
+	lines []string
+	times []time.Time
+	sent  chan string
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{sent: make(chan string, 100)}
+}
+
+func (f *fakeTransport) Say(channel, text string) {
+	f.mu.Lock()
+	f.lines = append(f.lines, channel+": "+text)
+	f.times = append(f.times, time.Now())
+	f.mu.Unlock()
+	f.sent <- text
+}
+
+func (f *fakeTransport) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		select {
+		case text := <-f.sent:
+			got = append(got, text)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out after %d of %d messages", len(got), n)
+		}
+	}
+	return got
+}
+
+type fakeRecorder struct {
+	mu    sync.Mutex
+	sends []types.ChatSend
+}
+
+func (f *fakeRecorder) InsertChatSend(_ context.Context, send types.ChatSend) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sends = append(f.sends, send)
+	return nil
+}
+
+func startSender(t *testing.T, sender *Sender) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	sender.Start(ctx, wg)
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+}
+
+func TestSender_HighPriorityGoesFirst(t *testing.T) {
+	transport := newFakeTransport()
+	sender := NewSender(transport, nil, DefaultConfig(), nil)
+
+	// Queue before starting so ordering is decided by priority alone
+	for _, text := range []string{"reply one", "reply two"} {
+		if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: text}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "@spammer please stop", Priority: PriorityHigh, Source: SourceModeration}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	startSender(t, sender)
+	got := transport.waitFor(t, 3)
+	want := []string{"@spammer please stop", "reply one", "reply two"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSender_RateLimit(t *testing.T) {
+	transport := newFakeTransport()
+	sender := NewSender(transport, nil, Config{RateLimit: 2, RateWindow: 300 * time.Millisecond}, nil)
+	startSender(t, sender)
+
+	start := time.Now()
+	for _, text := range []string{"one", "two", "three"} {
+		if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: text}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	transport.waitFor(t, 3)
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if elapsed := transport.times[2].Sub(start); elapsed < 300*time.Millisecond {
+		t.Errorf("third message sent after %v, expected it to wait for the rate window", elapsed)
+	}
+}
+
+func TestSender_DuplicateMessage(t *testing.T) {
+	transport := newFakeTransport()
+	sender := NewSender(transport, nil, DefaultConfig(), nil)
+	startSender(t, sender)
+
+	for i := 0; i < 3; i++ {
+		if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "same thing"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	got := transport.waitFor(t, 3)
+
+	if got[0] == got[1] || got[1] == got[2] {
+		t.Errorf("consecutive identical messages should differ on the wire: %q", got)
+	}
+	for _, text := range got {
+		if !strings.HasPrefix(text, "same thing") {
+			t.Errorf("unexpected message %q", text)
+		}
+	}
+}
+
+func TestSender_DuplicateFitsMaxLength(t *testing.T) {
+	transport := newFakeTransport()
+	sender := NewSender(transport, nil, Config{MaxLength: 10}, nil)
+	startSender(t, sender)
+
+	for i := 0; i < 2; i++ {
+		if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "abcdefghij"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	for _, text := range transport.waitFor(t, 4) {
+		if n := utf8.RuneCountInString(text); n > 10 {
+			t.Errorf("sent %q with %d characters, want at most 10", text, n)
+		}
+	}
+}
+
+func TestSender_SplitsAndRecords(t *testing.T) {
+	transport := newFakeTransport()
+	recorder := &fakeRecorder{}
+	sender := NewSender(transport, recorder, Config{MaxLength: 30}, nil)
+	startSender(t, sender)
+
+	chatID := uuid.New()
+	text := "Go is a great language. Channels make concurrency easy. Try it today!"
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: text, ChatID: chatID}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	got := transport.waitFor(t, 3)
+	want := []string{"Go is a great language.", "Channels make concurrency", "easy. Try it today!"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("part %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	// Recording happens right after the send
+	deadline := time.Now().Add(time.Second)
+	for {
+		recorder.mu.Lock()
+		n := len(recorder.sends)
+		recorder.mu.Unlock()
+		if n == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.sends) != 3 {
+		t.Fatalf("expected 3 recorded sends, got %d", len(recorder.sends))
+	}
+	for i, send := range recorder.sends {
+		if send.Part != i+1 || send.Parts != 3 {
+			t.Errorf("send %d part = %d/%d", i, send.Part, send.Parts)
+		}
+		if !send.ChatID.Valid || send.ChatID.UUID != chatID {
+			t.Errorf("send %d chat ID = %v, want %v", i, send.ChatID, chatID)
+		}
+		if send.Source != SourceChat {
+			t.Errorf("send %d source = %q, want %q", i, send.Source, SourceChat)
+		}
+	}
+}
+
+type fakeReplier struct {
+	mu      sync.Mutex
+	fail    bool
+	replies []string
+	sent    chan string
+}
+
+func (f *fakeReplier) Reply(_ context.Context, channel, parentID, text string) error {
+	if f.fail {
+		return errors.New("reply dropped")
+	}
+	f.mu.Lock()
+	f.replies = append(f.replies, parentID+": "+text)
+	f.mu.Unlock()
+	f.sent <- text
+	return nil
+}
+
+func TestSender_Replies(t *testing.T) {
+	transport := newFakeTransport()
+	replier := &fakeReplier{sent: make(chan string, 10)}
+	recorder := &fakeRecorder{}
+	sender := NewSender(transport, recorder, DefaultConfig(), nil)
+	sender.SetReplier(replier)
+	startSender(t, sender)
+
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "goroutines are cheap", ReplyTo: "abc-123"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	select {
+	case <-replier.sent:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for reply")
+	}
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "welcome to the stream"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := transport.waitFor(t, 1); got[0] != "welcome to the stream" {
+		t.Errorf("message without ReplyTo = %q, want a regular chat line", got[0])
+	}
+
+	replier.mu.Lock()
+	if len(replier.replies) != 1 || replier.replies[0] != "abc-123: goroutines are cheap" {
+		t.Errorf("replies = %q", replier.replies)
+	}
+	replier.mu.Unlock()
+
+	// A failed reply still reaches chat
+	replier.fail = true
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "deleted question", ReplyTo: "gone"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := transport.waitFor(t, 1); got[0] != "deleted question" {
+		t.Errorf("fallback message = %q", got[0])
+	}
+}
+
+func TestSender_QueueFull(t *testing.T) {
+	sender := NewSender(nil, nil, Config{QueueSize: 1}, nil)
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "first"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "second"}); err != ErrQueueFull {
+		t.Errorf("Send() error = %v, want ErrQueueFull", err)
+	}
+	// High priority has its own queue so warnings still get through
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "warning", Priority: PriorityHigh}); err != nil {
+		t.Errorf("Send() high priority error = %v", err)
+	}
+}
+
+type fakeFilter struct{}
+
+func (fakeFilter) Check(_ context.Context, _, source, text string) (string, error) {
+	if strings.Contains(text, "blocked") {
+		return "", errors.New("banned phrase")
+	}
+	return source + ": " + strings.ToUpper(text), nil
+}
+
+func TestSender_Filter(t *testing.T) {
+	transport := newFakeTransport()
+	sender := NewSender(transport, nil, DefaultConfig(), nil)
+	sender.SetFilter(fakeFilter{})
+	startSender(t, sender)
+
+	err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "this is blocked"})
+	if !errors.Is(err, ErrFiltered) {
+		t.Errorf("Send() error = %v, want ErrFiltered", err)
+	}
+	if err := sender.Send(context.Background(), Message{Channel: "soypetetech", Text: "hello", Source: SourceFAQ}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := transport.waitFor(t, 1); got[0] != "faq: HELLO" {
+		t.Errorf("sent %q, want the filtered text", got[0])
+	}
+}
+
+// slowFilter holds chat messages until their context is done and passes everything else
+type slowFilter struct{}
+
+func (slowFilter) Check(ctx context.Context, _, source, text string) (string, error) {
+	if source != SourceChat {
+		return text, nil
+	}
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestSender_SlowFilterDoesNotHoldQueue(t *testing.T) {
+	transport := newFakeTransport()
+	sender := NewSender(transport, nil, DefaultConfig(), nil)
+	sender.SetFilter(slowFilter{})
+	startSender(t, sender)
+
+	// The filter runs on the caller's goroutine with the caller's context
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- sender.Send(ctx, Message{Channel: "soypetetech", Text: "slow reply"})
+	}()
+
+	// A warning is sent while the reply is still being checked
+	if err := sender.Send(context.Background(), Message{Channel: "forgeutah", Text: "@spammer please stop", Priority: PriorityHigh, Source: SourceModeration}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := transport.waitFor(t, 1); got[0] != "@spammer please stop" {
+		t.Errorf("sent %q, want the warning", got[0])
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Send() error = %v, want the caller's context error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send() did not return when its context was cancelled")
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	longWord := strings.Repeat("a", 25)
+
+	tests := []struct {
+		name   string
+		text   string
+		maxLen int
+		want   []string
+	}{
+		{
+			name:   "short message",
+			text:   "hello chat",
+			maxLen: 500,
+			want:   []string{"hello chat"},
+		},
+		{
+			name:   "empty message",
+			text:   "   ",
+			maxLen: 500,
+			want:   nil,
+		},
+		{
+			name:   "sentence boundaries",
+			text:   "First sentence. Second sentence! Third?",
+			maxLen: 20,
+			want:   []string{"First sentence.", "Second sentence!", "Third?"},
+		},
+		{
+			name:   "packs sentences that fit together",
+			text:   "One. Two. Three. Four.",
+			maxLen: 10,
+			want:   []string{"One. Two.", "Three.", "Four."},
+		},
+		{
+			name:   "does not split on decimals",
+			text:   "Go 1.22 is out. It has range over ints.",
+			maxLen: 20,
+			want:   []string{"Go 1.22 is out.", "It has range over", "ints."},
+		},
+		{
+			name:   "word longer than the limit",
+			text:   longWord,
+			maxLen: 10,
+			want:   []string{"aaaaaaaaaa", "aaaaaaaaaa", "aaaaa"},
+		},
+		{
+			name:   "counts characters not bytes",
+			text:   "ñññññ ñññññ",
+			maxLen: 11,
+			want:   []string{"ñññññ ñññññ"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitMessage(tt.text, tt.maxLen)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SplitMessage() = %q, want %q", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("part %d = %q, want %q", i, got[i], tt.want[i])
+				}
+				if utf8.RuneCountInString(got[i]) > tt.maxLen {
+					t.Errorf("part %d is %d characters, limit %d", i, utf8.RuneCountInString(got[i]), tt.maxLen)
+				}
+			}
+		})
+	}
+}
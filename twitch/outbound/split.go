@@ -0,0 +1,106 @@
+package outbound
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SplitMessage splits text into parts of at most maxLen characters. It breaks on
+// sentence boundaries where it can, then on spaces, and only cuts words that are
+// longer than maxLen on their own.
+func SplitMessage(text string, maxLen int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxLen <= 0 || utf8.RuneCountInString(text) <= maxLen {
+		return []string{text}
+	}
+
+	var parts []string
+	current := ""
+	for _, sentence := range splitSentences(text) {
+		for _, piece := range packWords(sentence, maxLen) {
+			if current == "" {
+				current = piece
+				continue
+			}
+			if utf8.RuneCountInString(current)+1+utf8.RuneCountInString(piece) <= maxLen {
+				current += " " + piece
+				continue
+			}
+			parts = append(parts, current)
+			current = piece
+		}
+	}
+	if current != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// splitSentences breaks text after '.', '!' or '?' followed by whitespace, and at newlines
+func splitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i, r := range runes {
+		end := false
+		switch {
+		case r == '\n':
+			end = true
+		case r == '.' || r == '!' || r == '?':
+			end = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		}
+		if !end {
+			continue
+		}
+		if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = i + 1
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// packWords splits a sentence that is too long into chunks of whole words
+func packWords(sentence string, maxLen int) []string {
+	if utf8.RuneCountInString(sentence) <= maxLen {
+		return []string{sentence}
+	}
+
+	var chunks []string
+	current := ""
+	for _, word := range strings.Fields(sentence) {
+		for utf8.RuneCountInString(word) > maxLen {
+			if current != "" {
+				chunks = append(chunks, current)
+				current = ""
+			}
+			runes := []rune(word)
+			chunks = append(chunks, string(runes[:maxLen]))
+			word = string(runes[maxLen:])
+		}
+		if word == "" {
+			continue
+		}
+		if current == "" {
+			current = word
+			continue
+		}
+		if utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) <= maxLen {
+			current += " " + word
+			continue
+		}
+		chunks = append(chunks, current)
+		current = word
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
@@ -24,6 +24,7 @@ type PalaceSession struct {
 	messageBuf  []string
 	flushTicker *time.Ticker
 	stopCh      chan struct{}
+	doneCh      chan struct{}
 	httpClient  *http.Client
 	wrapperURL  string
 }
@@ -101,6 +102,7 @@ func (s *PalaceSession) init() error {
 	}
 
 	s.flushTicker = time.NewTicker(30 * time.Second)
+	s.doneCh = make(chan struct{})
 	go s.flushLoop()
 
 	return nil
@@ -244,6 +246,7 @@ func sanitizeForEntity(message string) string {
 }
 
 func (s *PalaceSession) flushLoop() {
+	defer close(s.doneCh)
 	for {
 		select {
 		case <-s.flushTicker.C:
@@ -412,6 +415,10 @@ func (s *PalaceSession) parseSearchOutput(output string) string {
 func (s *PalaceSession) End() error {
 	close(s.stopCh)
 	s.flushTicker.Stop()
+	// Wait for the final flush so buffered messages are written before cleanup
+	if s.doneCh != nil {
+		<-s.doneCh
+	}
 
 	if err := os.RemoveAll(s.PalacePath); err != nil {
 		s.logger.Error("failed to cleanup palace directory", "error", err.Error())
@@ -0,0 +1,121 @@
+package twitchirc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/agent"
+	"github.com/Soypete/twitch-llm-bot/ai/orchestrator"
+	"github.com/Soypete/twitch-llm-bot/ai/twitchchat"
+	"github.com/Soypete/twitch-llm-bot/database"
+	"github.com/Soypete/twitch-llm-bot/twitch/outbound"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+)
+
+// planHandler answers `!plan <question>` with the orchestrator. A plan can take minutes,
+// so it runs in the background and posts the plan and each step's status to chat as it
+// goes, then the answer.
+func (irc *IRC) planHandler(chat *twitchchat.Client) CommandHandler {
+	return func(ctx context.Context, inv CommandInvocation) (string, error) {
+		question := strings.TrimSpace(inv.RawArgs)
+		if question == "" {
+			return "", fmt.Errorf("no question to plan for")
+		}
+
+		o := chat.NewOrchestrator(inv.Channel, orchestrator.DefaultConfig())
+		if searcher, ok := irc.db.(database.AnswerSearcher); ok {
+			o.HandleDBQuery(searcher)
+		}
+		irc.goTracked(func() { irc.runPlan(ctx, o, inv, question) })
+		return "", nil
+	}
+}
+
+// runPlan runs a plan and posts its progress and answer to chat
+func (irc *IRC) runPlan(ctx context.Context, o *orchestrator.Orchestrator, inv CommandInvocation, question string) {
+	o.OnEvent(func(event orchestrator.Event) {
+		irc.logger.Debug("orchestrator event", "channel", inv.Channel, "type", event.Type, "step", event.Step, "action", event.Action, "attempt", event.Attempt, "error", event.Error)
+		if text := planEventMessage(inv.Username, event); text != "" {
+			irc.say(ctx, outbound.Message{Channel: inv.Channel, Text: text, Source: outbound.SourceCommand})
+		}
+	})
+
+	// Tools answer for this channel, e.g. faq_lookup only uses its FAQ categories
+	result, err := o.Run(agent.WithChannel(ctx, inv.Channel), question)
+	reply := planReply(inv.Username, result, err)
+	if err != nil {
+		irc.logger.Error("plan command failed", "channel", inv.Channel, "error", err.Error())
+	}
+
+	var chatID uuid.UUID
+	if err == nil {
+		chatID = irc.storePlanAnswer(ctx, inv, reply, result)
+	}
+	irc.say(ctx, outbound.Message{
+		Channel: inv.Channel,
+		Text:    reply,
+		Source:  outbound.SourceCommand,
+		ChatID:  chatID,
+		ReplyTo: inv.Message.TwitchID,
+	})
+}
+
+// storePlanAnswer stores the question and answer of a plan with its sources, so !sources
+// can show them. It returns the question's ID, or uuid.Nil when it was not stored.
+func (irc *IRC) storePlanAnswer(ctx context.Context, inv CommandInvocation, reply string, result *orchestrator.Result) uuid.UUID {
+	messageID, err := irc.db.InsertMessage(ctx, inv.Message)
+	if err != nil {
+		irc.logger.Error("failed to insert plan question", "channel", inv.Channel, "error", err.Error())
+		return uuid.Nil
+	}
+
+	resp := types.TwitchMessage{
+		Channel: inv.Channel,
+		Text:    reply,
+		UUID:    messageID,
+		Sources: result.Sources,
+	}
+	if err := irc.db.InsertResponse(ctx, resp, irc.modelName); err != nil {
+		irc.logger.Error("failed to insert plan answer", "channel", inv.Channel, "error", err.Error(), "messageID", messageID)
+	}
+	return messageID
+}
+
+// planEventMessage is the chat line for an orchestrator event, or "" for events chat does
+// not see. The final step is left out, the answer follows it.
+func planEventMessage(username string, event orchestrator.Event) string {
+	if event.Plan == nil {
+		return ""
+	}
+	switch event.Type {
+	case orchestrator.EventPlanCreated:
+		return fmt.Sprintf("@%s plan: %s", username, event.Plan.String())
+	case orchestrator.EventStepSucceeded, orchestrator.EventStepFailed:
+		if event.Action == orchestrator.ActionReturnResponse {
+			return ""
+		}
+		status := orchestrator.StatusSucceeded
+		if event.Type == orchestrator.EventStepFailed {
+			status = orchestrator.StatusFailed
+		}
+		return fmt.Sprintf("@%s step %d/%d %s %s", username, event.Step, len(event.Plan.Steps), status.Emoji(), event.Action)
+	default:
+		return ""
+	}
+}
+
+// planReply is the final chat reply of a plan. Failures still get a reply so chat can see
+// which step failed.
+func planReply(username string, result *orchestrator.Result, err error) string {
+	switch {
+	case err != nil && result == nil:
+		return fmt.Sprintf("@%s sorry, I couldn't work out how to answer that one", username)
+	case err != nil:
+		return fmt.Sprintf("@%s %s | sorry, I couldn't finish that one", username, result.Progress())
+	default:
+		return fmt.Sprintf("@%s %s | %s", username, result.Progress(), ai.CleanResponse(result.Answer))
+	}
+}
@@ -0,0 +1,135 @@
+package twitchirc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Soypete/twitch-llm-bot/ai/orchestrator"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+)
+
+func TestPlanEventMessage(t *testing.T) {
+	plan := &orchestrator.Plan{Steps: []orchestrator.Step{
+		{Action: orchestrator.ActionWebSearch},
+		{Action: orchestrator.ActionReferenceCheck},
+		{Action: orchestrator.ActionReturnResponse},
+	}}
+
+	tests := []struct {
+		name  string
+		event orchestrator.Event
+		want  string
+	}{
+		{name: "plan created", event: orchestrator.Event{Type: orchestrator.EventPlanCreated, Plan: plan}, want: "@scott plan: web_search → reference_check → return_response"},
+		{name: "step succeeded", event: orchestrator.Event{Type: orchestrator.EventStepSucceeded, Plan: plan, Step: 1, Action: orchestrator.ActionWebSearch}, want: "@scott step 1/3 ✔️ web_search"},
+		{name: "step failed", event: orchestrator.Event{Type: orchestrator.EventStepFailed, Plan: plan, Step: 2, Action: orchestrator.ActionReferenceCheck}, want: "@scott step 2/3 ❌ reference_check"},
+		{name: "final step is left to the answer", event: orchestrator.Event{Type: orchestrator.EventStepSucceeded, Plan: plan, Step: 3, Action: orchestrator.ActionReturnResponse}},
+		{name: "retries are not shown", event: orchestrator.Event{Type: orchestrator.EventStepRetrying, Plan: plan, Step: 1, Action: orchestrator.ActionWebSearch}},
+		{name: "no plan", event: orchestrator.Event{Type: orchestrator.EventFailed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planEventMessage("scott", tt.event); got != tt.want {
+				t.Errorf("planEventMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanReply(t *testing.T) {
+	result := &orchestrator.Result{
+		Steps: []orchestrator.StepResult{
+			{Step: orchestrator.Step{Action: orchestrator.ActionWebSearch}, Status: orchestrator.StatusSucceeded},
+			{Step: orchestrator.Step{Action: orchestrator.ActionReturnResponse}, Status: orchestrator.StatusSucceeded},
+		},
+		Answer: "Go 1.26 is out",
+	}
+
+	tests := []struct {
+		name   string
+		result *orchestrator.Result
+		err    error
+		want   string
+	}{
+		{name: "answer", result: result, want: "@scott 1 ✔️ web_search · 2 ✔️ return_response | Go 1.26 is out"},
+		{name: "failed run", result: result, err: errors.New("answer failed"), want: "@scott 1 ✔️ web_search · 2 ✔️ return_response | sorry, I couldn't finish that one"},
+		{name: "no plan", err: errors.New("no valid plan"), want: "@scott sorry, I couldn't work out how to answer that one"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planReply("scott", tt.result, tt.err); got != tt.want {
+				t.Errorf("planReply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeChatWriter records stored messages and responses
+type fakeChatWriter struct {
+	mu        sync.Mutex
+	id        uuid.UUID
+	err       error
+	messages  []types.TwitchMessage
+	responses []types.TwitchMessage
+}
+
+func (f *fakeChatWriter) InsertMessage(_ context.Context, msg types.TwitchMessage) (uuid.UUID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, msg)
+	return f.id, f.err
+}
+
+func (f *fakeChatWriter) InsertResponse(_ context.Context, resp types.TwitchMessage, _ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func TestStorePlanAnswer(t *testing.T) {
+	sources := []types.Source{{Title: "Go 1.26", URL: "https://go.dev/doc/go1.26", Tool: "web_search"}}
+	inv := CommandInvocation{Channel: "soypetetech", Username: "scott", Message: types.TwitchMessage{Channel: "soypetetech", Username: "scott", Text: "!plan is go 1.26 out?"}}
+
+	tests := []struct {
+		name          string
+		err           error
+		wantID        uuid.UUID
+		wantResponses int
+	}{
+		{name: "stored with sources", wantID: uuid.MustParse("6f1c0c1e-4a7b-4c1f-9d3a-2b1e8f0a9c11"), wantResponses: 1},
+		{name: "question not stored", err: errors.New("db down"), wantID: uuid.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeChatWriter{id: tt.wantID, err: tt.err}
+			irc := &IRC{db: db, logger: logging.Default(), modelName: "test-model"}
+
+			got := irc.storePlanAnswer(context.Background(), inv, "@scott Go 1.26 is out", &orchestrator.Result{Sources: sources})
+			if got != tt.wantID {
+				t.Errorf("storePlanAnswer() = %v, want %v", got, tt.wantID)
+			}
+			if len(db.messages) != 1 || db.messages[0].Text != inv.Message.Text {
+				t.Errorf("stored questions = %+v, want the !plan message", db.messages)
+			}
+			if len(db.responses) != tt.wantResponses {
+				t.Fatalf("stored %d answers, want %d", len(db.responses), tt.wantResponses)
+			}
+			if tt.wantResponses == 0 {
+				return
+			}
+			resp := db.responses[0]
+			if resp.UUID != tt.wantID || resp.Text != "@scott Go 1.26 is out" || resp.Channel != "soypetetech" {
+				t.Errorf("stored answer = %+v", resp)
+			}
+			if !reflect.DeepEqual(resp.Sources, sources) {
+				t.Errorf("stored sources = %+v, want %+v", resp.Sources, sources)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package replay
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// RecordingLLM wraps a model and records the tool calls it asks for
+type RecordingLLM struct {
+	llms.Model
+
+	mu    sync.Mutex
+	calls []ToolCall
+}
+
+// NewRecordingLLM wraps model so its tool calls can be reported
+func NewRecordingLLM(model llms.Model) *RecordingLLM {
+	return &RecordingLLM{Model: model}
+}
+
+// GenerateContent calls the wrapped model and records the tool calls in its response
+func (r *RecordingLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	resp, err := r.Model.GenerateContent(ctx, messages, options...)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, choice := range resp.Choices {
+		for _, call := range choice.ToolCalls {
+			if call.FunctionCall == nil {
+				continue
+			}
+			r.calls = append(r.calls, ToolCall{Name: call.FunctionCall.Name, Arguments: call.FunctionCall.Arguments})
+		}
+	}
+	return resp, nil
+}
+
+// Call implements llms.Model through GenerateContent so tool calls are recorded
+func (r *RecordingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, r, prompt, options...)
+}
+
+// take returns the tool calls recorded since the last take
+func (r *RecordingLLM) take() []ToolCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+// store stands in for Postgres. It hands out message IDs and records Pedro's responses
+// and moderation actions.
+type store struct {
+	mu        sync.Mutex
+	responses []types.TwitchMessage
+	actions   []types.ModAction
+}
+
+func (s *store) InsertMessage(_ context.Context, _ types.TwitchMessage) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (s *store) InsertResponse(_ context.Context, resp types.TwitchMessage, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func (s *store) InsertModAction(_ context.Context, action types.ModAction) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = append(s.actions, action)
+	return action.ID, nil
+}
+
+// take returns the responses and moderation actions recorded since the last take
+func (s *store) take() ([]types.TwitchMessage, []types.ModAction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	responses, actions := s.responses, s.actions
+	s.responses, s.actions = nil, nil
+	return responses, actions
+}
+
+// transport stands in for the Twitch IRC connection and records every chat line
+type transport struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (t *transport) Say(_, text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, text)
+}
+
+// take returns the chat lines sent since the last take
+func (t *transport) take() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := t.lines
+	t.lines = nil
+	return lines
+}
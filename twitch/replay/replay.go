@@ -0,0 +1,280 @@
+// Package replay feeds recorded chat through Pedro's chat pipeline with Twitch, Postgres
+// and the IRC connection replaced by stand-ins, and reports every reply, tool call and
+// moderation decision so prompt and model changes can be diffed before going live.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	twitchirc "github.com/Soypete/twitch-llm-bot/twitch"
+	"github.com/Soypete/twitch-llm-bot/twitch/moderation"
+	"github.com/Soypete/twitch-llm-bot/twitch/outbound"
+	"github.com/Soypete/twitch-llm-bot/types"
+	v2 "github.com/gempir/go-twitch-irc/v2"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// DefaultMessageTimeout is how long one message may take to be answered
+const DefaultMessageTimeout = 2 * time.Minute
+
+// Config holds configuration for a replay
+type Config struct {
+	// Channels Pedro is in. Messages without a channel go to the first one.
+	Channels []twitchirc.ChannelConfig
+
+	// ModelName is reported and passed to moderation
+	ModelName string
+
+	// ModConfig enables moderation. It always runs in dry run mode.
+	ModConfig *ai.ModerationConfig
+
+	// ModLLM is the model moderation uses
+	ModLLM llms.Model
+
+	// Commands are the `!` commands to load
+	Commands []types.ChatCommand
+
+	// Filter checks everything Pedro sends, usually the output safety pipeline
+	Filter outbound.Filter
+
+	// MessageTimeout is how long one message may take to be answered
+	MessageTimeout time.Duration
+}
+
+// ToolCall is a tool the chat model asked for
+type ToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// ModerationDecision is what moderation decided for a message. Actions are never
+// executed during a replay.
+type ModerationDecision struct {
+	Tool      string          `json:"tool"`
+	Target    string          `json:"target,omitempty"`
+	Reasoning string          `json:"reasoning,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+}
+
+// Entry is everything that happened because of one transcript message
+type Entry struct {
+	Index          int                  `json:"index"`
+	Channel        string               `json:"channel"`
+	Username       string               `json:"username"`
+	Text           string               `json:"text"`
+	Replies        []string             `json:"replies,omitempty"`
+	ToolCalls      []ToolCall           `json:"tool_calls,omitempty"`
+	Moderation     []ModerationDecision `json:"moderation,omitempty"`
+	PromptVersions []string             `json:"prompt_versions,omitempty"`
+}
+
+// Summary counts what happened over the whole replay
+type Summary struct {
+	Messages          int            `json:"messages"`
+	Answered          int            `json:"answered"`
+	Replies           int            `json:"replies"`
+	ToolCalls         map[string]int `json:"tool_calls,omitempty"`
+	ModerationActions map[string]int `json:"moderation_actions,omitempty"`
+}
+
+// Report is the result of a replay. It has no timestamps or durations so two runs over
+// the same transcript can be diffed.
+type Report struct {
+	Transcript string  `json:"transcript,omitempty"`
+	Model      string  `json:"model,omitempty"`
+	Messages   []Entry `json:"messages"`
+	Summary    Summary `json:"summary"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Runner replays transcripts through an offline IRC
+type Runner struct {
+	irc       *twitchirc.IRC
+	llm       *RecordingLLM
+	store     *store
+	transport *transport
+	monitors  map[string]*moderation.Monitor
+	config    Config
+	logger    *logging.Logger
+}
+
+// New creates a runner. chat must be built on top of llm so its tool calls are recorded.
+func New(chat ai.Chatter, llm *RecordingLLM, config Config, logger *logging.Logger) (*Runner, error) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if chat == nil || llm == nil {
+		return nil, errors.New("replay needs a chat client and its recording LLM")
+	}
+	if len(config.Channels) == 0 {
+		return nil, errors.New("at least one channel is required")
+	}
+	if config.MessageTimeout <= 0 {
+		config.MessageTimeout = DefaultMessageTimeout
+	}
+
+	r := &Runner{
+		llm:       llm,
+		store:     &store{},
+		transport: &transport{},
+		monitors:  make(map[string]*moderation.Monitor),
+		config:    config,
+		logger:    logger,
+	}
+
+	irc, err := twitchirc.NewOfflineIRC(chat, config.ModelName, r.store, config.Channels, r.transport, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offline IRC: %w", err)
+	}
+	if err := irc.LoadCommands(config.Commands); err != nil {
+		return nil, fmt.Errorf("failed to load commands: %w", err)
+	}
+	if config.Filter != nil {
+		irc.GetSender().SetFilter(config.Filter)
+	}
+	r.irc = irc
+
+	if config.ModConfig != nil && config.ModConfig.Enabled {
+		if config.ModLLM == nil {
+			return nil, errors.New("moderation needs a model")
+		}
+		modConfig := *config.ModConfig
+		modConfig.DryRun = true
+		for _, ch := range config.Channels {
+			name := strings.ToLower(strings.TrimPrefix(ch.Name, "#"))
+			monitor, err := moderation.NewMonitor(&modConfig, config.ModLLM, config.ModelName, nil, r.store, "", name, logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create moderation monitor for %s: %w", name, err)
+			}
+			r.monitors[name] = monitor
+		}
+	}
+	return r, nil
+}
+
+// Run replays messages in order, waiting for each to be fully answered before the next
+func (r *Runner) Run(ctx context.Context, messages []Message) (*Report, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	r.irc.GetSender().Start(ctx, &wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	report := &Report{
+		Model:    r.config.ModelName,
+		Messages: make([]Entry, 0, len(messages)),
+		Summary: Summary{
+			ToolCalls:         make(map[string]int),
+			ModerationActions: make(map[string]int),
+		},
+	}
+	defaultChannel := r.irc.Channels()[0]
+
+	for i, m := range messages {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if m.Channel == "" {
+			m.Channel = defaultChannel
+		}
+		m.Channel = strings.ToLower(strings.TrimPrefix(m.Channel, "#"))
+
+		entry := r.replay(ctx, i+1, m)
+		report.Messages = append(report.Messages, entry)
+
+		report.Summary.Messages++
+		report.Summary.Replies += len(entry.Replies)
+		if len(entry.Replies) > 0 {
+			report.Summary.Answered++
+		}
+		for _, call := range entry.ToolCalls {
+			report.Summary.ToolCalls[call.Name]++
+		}
+		for _, decision := range entry.Moderation {
+			report.Summary.ModerationActions[decision.Tool]++
+		}
+	}
+	return report, nil
+}
+
+// replay runs one message through moderation and the chat pipeline
+func (r *Runner) replay(ctx context.Context, index int, m Message) Entry {
+	ctx, cancel := context.WithTimeout(ctx, r.config.MessageTimeout)
+	defer cancel()
+
+	msg := v2.PrivateMessage{
+		User:    v2.User{Name: strings.ToLower(m.Username), DisplayName: m.Username, Badges: m.Badges},
+		Channel: m.Channel,
+		Message: m.Text,
+		ID:      fmt.Sprintf("replay-%d", index),
+		Time:    m.Time,
+	}
+
+	if monitor := r.monitors[m.Channel]; monitor != nil {
+		monitor.Evaluate(ctx, msg)
+	}
+	r.irc.HandleChat(ctx, msg)
+	if err := r.wait(ctx); err != nil {
+		r.logger.Warn("message was not fully answered", "index", index, "error", err.Error())
+	}
+
+	entry := Entry{
+		Index:     index,
+		Channel:   m.Channel,
+		Username:  m.Username,
+		Text:      m.Text,
+		Replies:   r.transport.take(),
+		ToolCalls: r.llm.take(),
+	}
+	responses, actions := r.store.take()
+	for _, resp := range responses {
+		if resp.PromptVersion != "" {
+			entry.PromptVersions = append(entry.PromptVersions, resp.PromptVersion)
+		}
+	}
+	for _, action := range actions {
+		entry.Moderation = append(entry.Moderation, ModerationDecision{
+			Tool:      action.ToolCallName,
+			Target:    action.TargetUsername,
+			Reasoning: action.LLMReasoning,
+			Params:    action.ToolCallParams,
+		})
+	}
+	return entry
+}
+
+// wait blocks until the pipeline has no work left. Work can hand off to other work, so
+// it must be idle twice in a row.
+func (r *Runner) wait(ctx context.Context) error {
+	idle := 0
+	for idle < 2 {
+		if r.irc.Pending() == 0 {
+			idle++
+		} else {
+			idle = 0
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Soypete/twitch-llm-bot/ai"
+	"github.com/Soypete/twitch-llm-bot/ai/twitchchat"
+	"github.com/Soypete/twitch-llm-bot/ai/websearch"
+	twitchirc "github.com/Soypete/twitch-llm-bot/twitch"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeChatModel searches the web when asked about the weather, answers with the search
+// result once it has one, and says hello otherwise
+type fakeChatModel struct{}
+
+func (f *fakeChatModel) GenerateContent(_ context.Context, messages []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	var text strings.Builder
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			switch p := part.(type) {
+			case llms.ToolCallResponse:
+				return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "the forecast says " + p.Content}}}, nil
+			case llms.TextContent:
+				text.WriteString(p.Text)
+			}
+		}
+	}
+	last := messages[len(messages)-1]
+	if strings.Contains(text.String(), "weather") && last.Role == llms.ChatMessageTypeHuman {
+		return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+			ToolCalls: []llms.ToolCall{{
+				ID:           "call-1",
+				Type:         "function",
+				FunctionCall: &llms.FunctionCall{Name: "web_search", Arguments: `{"query":"weather in Provo"}`},
+			}},
+		}}}, nil
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "hello from pedro"}}}, nil
+}
+
+func (f *fakeChatModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
+
+// fakeModModel times out anyone who posts a link
+type fakeModModel struct{}
+
+func (f *fakeModModel) GenerateContent(_ context.Context, messages []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	call := llms.FunctionCall{Name: "no_action", Arguments: `{"reason":"fine"}`}
+	for _, part := range messages[len(messages)-1].Parts {
+		if p, ok := part.(llms.TextContent); ok && strings.Contains(p.Text, "http") {
+			call = llms.FunctionCall{Name: "timeout_user", Arguments: `{"username":"spammer","duration":60,"reason":"link spam"}`}
+		}
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		ToolCalls: []llms.ToolCall{{ID: "mod-1", Type: "function", FunctionCall: &call}},
+	}}}, nil
+}
+
+func (f *fakeModModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
+
+func newTestRunner(t *testing.T) *Runner {
+	t.Helper()
+	llm := NewRecordingLLM(&fakeChatModel{})
+	chat, err := twitchchat.SetupWithLLM(llm, "test-model", "", nil)
+	if err != nil {
+		t.Fatalf("SetupWithLLM() error = %v", err)
+	}
+	if err := chat.SetSearchProvider(websearch.NewFixture("", map[string][]websearch.Result{
+		"Weather in provo": {{Title: "Provo weather", Snippet: "sunny and 20C"}},
+	})); err != nil {
+		t.Fatalf("SetSearchProvider() error = %v", err)
+	}
+
+	modConfig := ai.DefaultModerationConfig()
+	modConfig.Enabled = true
+	runner, err := New(chat, llm, Config{
+		Channels:  []twitchirc.ChannelConfig{{Name: "soypetetech"}},
+		ModelName: "test-model",
+		ModConfig: modConfig,
+		ModLLM:    &fakeModModel{},
+		Commands:  []types.ChatCommand{{Name: "discord", Response: "join the discord"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return runner
+}
+
+func TestRunner_Run(t *testing.T) {
+	transcript := []Message{
+		{Username: "alice", Text: "hey pedro how are you?"},
+		{Username: "bob", Text: "just chatting with friends"},
+		{Username: "carol", Text: "pedro what is the weather in Provo?"},
+		{Username: "spammer", Text: "cheap followers at https://spam.example"},
+		{Username: "dave", Text: "!discord"},
+	}
+
+	report, err := newTestRunner(t).Run(context.Background(), transcript)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(report.Messages) != len(transcript) {
+		t.Fatalf("report has %d messages, want %d", len(report.Messages), len(transcript))
+	}
+
+	tests := []struct {
+		name       string
+		entry      Entry
+		wantReply  string
+		wantTool   string
+		wantAction string
+	}{
+		{name: "mention is answered", entry: report.Messages[0], wantReply: "hello from pedro"},
+		{name: "unaddressed message is ignored", entry: report.Messages[1]},
+		{name: "tool call is recorded", entry: report.Messages[2], wantReply: "the forecast says - Provo weather: sunny and 20C", wantTool: "web_search"},
+		{name: "moderation decision is recorded", entry: report.Messages[3], wantAction: "timeout_user"},
+		{name: "command is answered", entry: report.Messages[4], wantReply: "join the discord"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.entry.Channel != "soypetetech" {
+				t.Errorf("channel = %q, want the first configured channel", tt.entry.Channel)
+			}
+			if tt.wantReply == "" && len(tt.entry.Replies) != 0 {
+				t.Errorf("replies = %q, want none", tt.entry.Replies)
+			}
+			if tt.wantReply != "" && (len(tt.entry.Replies) != 1 || !strings.Contains(tt.entry.Replies[0], tt.wantReply)) {
+				t.Errorf("replies = %q, want one containing %q", tt.entry.Replies, tt.wantReply)
+			}
+			if tt.wantTool != "" && (len(tt.entry.ToolCalls) != 1 || tt.entry.ToolCalls[0].Name != tt.wantTool) {
+				t.Errorf("tool calls = %v, want %s", tt.entry.ToolCalls, tt.wantTool)
+			}
+			if tt.wantAction != "" {
+				if len(tt.entry.Moderation) != 1 || tt.entry.Moderation[0].Tool != tt.wantAction || tt.entry.Moderation[0].Target != "spammer" {
+					t.Errorf("moderation = %+v, want %s of spammer", tt.entry.Moderation, tt.wantAction)
+				}
+			}
+		})
+	}
+
+	if report.Summary.Messages != 5 || report.Summary.Answered != 3 || report.Summary.ToolCalls["web_search"] != 1 || report.Summary.ModerationActions["timeout_user"] != 1 {
+		t.Errorf("summary = %+v", report.Summary)
+	}
+	if len(report.Messages[0].PromptVersions) != 1 {
+		t.Errorf("prompt versions = %v, want the version of the one response", report.Messages[0].PromptVersions)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if !strings.Contains(out.String(), `"tool": "timeout_user"`) {
+		t.Errorf("report JSON is missing the moderation decision:\n%s", out.String())
+	}
+}
+
+func TestParseTranscript(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) ([]Message, error)
+		input   string
+		want    []Message
+		wantErr bool
+	}{
+		{
+			name:  "jsonl",
+			parse: func(s string) ([]Message, error) { return ParseJSONL(strings.NewReader(s)) },
+			input: `{"username":"alice","text":"hi pedro","channel":"soypetetech"}` + "\n\n" +
+				`{"username":"bob","message":"exported row","badges":{"moderator":1}}`,
+			want: []Message{
+				{Channel: "soypetetech", Username: "alice", Text: "hi pedro"},
+				{Username: "bob", Text: "exported row", Badges: map[string]int{"moderator": 1}},
+			},
+		},
+		{
+			name:    "jsonl without text",
+			parse:   func(s string) ([]Message, error) { return ParseJSONL(strings.NewReader(s)) },
+			input:   `{"username":"alice"}`,
+			wantErr: true,
+		},
+		{
+			name:  "csv export",
+			parse: func(s string) ([]Message, error) { return ParseCSV(strings.NewReader(s)) },
+			input: "username,message,channel\nalice,\"hi, pedro\",soypetetech\nbob,,soypetetech\n",
+			want:  []Message{{Channel: "soypetetech", Username: "alice", Text: "hi, pedro"}},
+		},
+		{
+			name:    "csv without message column",
+			parse:   func(s string) ([]Message, error) { return ParseCSV(strings.NewReader(s)) },
+			input:   "username,channel\nalice,soypetetech\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d messages, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].Channel != tt.want[i].Channel || got[i].Username != tt.want[i].Username || got[i].Text != tt.want[i].Text || got[i].Badges["moderator"] != tt.want[i].Badges["moderator"] {
+					t.Errorf("message %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is one recorded chat message
+type Message struct {
+	Channel  string
+	Username string
+	Text     string
+	Time     time.Time
+	// Badges are the Twitch badges the viewer had, e.g. {"moderator": 1}
+	Badges map[string]int
+}
+
+// transcriptLine is a JSONL transcript line. Rows exported from twitch_chat use message
+// and created_at, hand written transcripts can use text and time.
+type transcriptLine struct {
+	Channel   string         `json:"channel"`
+	Username  string         `json:"username"`
+	Text      string         `json:"text"`
+	Message   string         `json:"message"`
+	Time      time.Time      `json:"time"`
+	CreatedAt time.Time      `json:"created_at"`
+	Badges    map[string]int `json:"badges"`
+}
+
+// LoadTranscript reads a transcript from a .jsonl file with one message per line, or a
+// .csv file with a header row, such as `\copy (SELECT username, message, channel,
+// created_at FROM twitch_chat ORDER BY created_at) TO 'chat.csv' CSV HEADER`.
+func LoadTranscript(path string) ([]Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer file.Close()
+
+	var messages []Message
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		messages, err = ParseCSV(file)
+	default:
+		messages, err = ParseJSONL(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transcript %s: %w", path, err)
+	}
+	return messages, nil
+}
+
+// ParseJSONL reads one JSON message per line. Blank lines are skipped.
+func ParseJSONL(r io.Reader) ([]Message, error) {
+	var messages []Message
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		var line transcriptLine
+		if err := json.Unmarshal([]byte(data), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		msg := Message{
+			Channel:  line.Channel,
+			Username: line.Username,
+			Text:     line.Text,
+			Time:     line.Time,
+			Badges:   line.Badges,
+		}
+		if msg.Text == "" {
+			msg.Text = line.Message
+		}
+		if msg.Time.IsZero() {
+			msg.Time = line.CreatedAt
+		}
+		if msg.Username == "" || msg.Text == "" {
+			return nil, fmt.Errorf("line %d: username and text are required", lineNumber)
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ParseCSV reads messages from CSV with a header row. The username and message (or text)
+// columns are required, channel and created_at (or time) are optional.
+func ParseCSV(r io.Reader) ([]Message, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(names ...string) int {
+		for _, name := range names {
+			if i, ok := columns[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	usernameCol, textCol := column("username"), column("message", "text")
+	channelCol, timeCol := column("channel"), column("created_at", "time")
+	if usernameCol < 0 || textCol < 0 {
+		return nil, fmt.Errorf("header needs username and message columns, got %v", header)
+	}
+
+	var messages []Message
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		msg := Message{Username: record[usernameCol], Text: record[textCol]}
+		if channelCol >= 0 {
+			msg.Channel = record[channelCol]
+		}
+		if timeCol >= 0 && record[timeCol] != "" {
+			msg.Time, err = parseTime(record[timeCol])
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row, err)
+			}
+		}
+		if msg.Username == "" || msg.Text == "" {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// parseTime accepts RFC 3339 and the timestamps Postgres writes to CSV
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999-07", "2006-01-02 15:04:05.999999-07:00", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
@@ -0,0 +1,90 @@
+package twitchirc
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/Soypete/twitch-llm-bot/twitch/helix"
+	"github.com/pkg/errors"
+)
+
+// chatReplier sends threaded replies through the Helix Send Chat Message API.
+// go-twitch-irc cannot attach the reply-parent-msg-id tag to a PRIVMSG, so replies
+// cannot go over IRC. It satisfies outbound.Replier.
+type chatReplier struct {
+	irc *IRC
+
+	mu       sync.Mutex
+	client   *helix.Client
+	senderID string
+}
+
+func newChatReplier(irc *IRC) *chatReplier {
+	return &chatReplier{irc: irc}
+}
+
+// Reply sends text to channel as a reply to the chat message parentID
+func (r *chatReplier) Reply(ctx context.Context, channel, parentID, text string) error {
+	ch := r.irc.GetChannel(channel)
+	if ch == nil {
+		return errors.Errorf("unknown channel %s", channel)
+	}
+
+	client, senderID, broadcasterID, err := r.resolve(ctx, ch)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.SendChatMessage(ctx, helix.SendChatMessageRequest{
+		BroadcasterID:        broadcasterID,
+		SenderID:             senderID,
+		Message:              text,
+		ReplyParentMessageID: parentID,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to send reply")
+	}
+	return nil
+}
+
+// resolve returns a helix client with the current token and the IDs needed to send a
+// message in ch, looking them up the first time they are needed
+func (r *chatReplier) resolve(ctx context.Context, ch *Channel) (*helix.Client, string, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.irc.currentToken() == nil {
+		return nil, "", "", errors.New("not authenticated with twitch")
+	}
+	if r.client == nil {
+		clientID := os.Getenv("TWITCH_ID")
+		if clientID == "" {
+			return nil, "", "", errors.New("TWITCH_ID environment variable not set")
+		}
+		r.client = r.irc.newHelixClient(clientID, "", "")
+	}
+
+	if r.senderID == "" {
+		r.senderID = r.irc.moderatorID
+	}
+	if r.senderID == "" {
+		senderID, err := lookupBotUserID(ctx, r.client)
+		if err != nil {
+			return nil, "", "", err
+		}
+		r.senderID = senderID
+	}
+
+	broadcasterID := ch.BroadcasterID()
+	if broadcasterID == "" {
+		id, err := r.client.GetUserIDByLogin(ctx, ch.Name)
+		if err != nil {
+			return nil, "", "", errors.Wrap(err, "failed to get broadcaster ID")
+		}
+		ch.setBroadcasterID(id)
+		broadcasterID = id
+	}
+
+	return r.client, r.senderID, broadcasterID, nil
+}
@@ -0,0 +1,38 @@
+package twitchirc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/ai/twitchchat"
+)
+
+// purgeSearchHandler handles `!purgesearch [query...]`, which forgets the cached web search
+// for a query, or every cached web search. Only moderators and the broadcaster can use it.
+func (irc *IRC) purgeSearchHandler(chat *twitchchat.Client) CommandHandler {
+	return func(ctx context.Context, inv CommandInvocation) (string, error) {
+		if inv.Badge < BadgeModerator {
+			return "", nil
+		}
+		cache := chat.SearchCache()
+		if cache == nil {
+			return fmt.Sprintf("@%s web searches are not cached", inv.Username), nil
+		}
+
+		query := strings.TrimSpace(inv.RawArgs)
+		purged, err := cache.Purge(ctx, query)
+		if err != nil {
+			return "", err
+		}
+		irc.logger.Info("search cache purged", "channel", inv.Channel, "user", inv.Username, "query", query, "purged", purged)
+		switch {
+		case query == "":
+			return fmt.Sprintf("@%s cleared %d cached web searches", inv.Username, purged), nil
+		case purged == 0:
+			return fmt.Sprintf("@%s nothing was cached for %q", inv.Username, query), nil
+		default:
+			return fmt.Sprintf("@%s cleared the cached web search for %q", inv.Username, query), nil
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package twitchirc
+
+import (
+	"context"
+	"time"
+)
+
+// drainPollInterval is how often Drain checks for pending work
+const drainPollInterval = 50 * time.Millisecond
+
+// StopIntake stops handling new chat messages and channel events. Work that is already
+// in flight, like LLM calls and web searches, keeps running so Drain can wait for it.
+func (irc *IRC) StopIntake() {
+	if irc.intakeStopped.Swap(true) {
+		return
+	}
+	irc.logger.Info("stopped accepting chat messages and events")
+}
+
+// accepting reports whether new chat messages and events should be handled
+func (irc *IRC) accepting() bool {
+	return !irc.intakeStopped.Load()
+}
+
+// goTracked runs fn in a goroutine that Drain waits for
+func (irc *IRC) goTracked(fn func()) {
+	irc.inflight.Add(1)
+	go func() {
+		defer irc.inflight.Add(-1)
+		fn()
+	}()
+}
+
+// pendingWork returns the amount of unfinished work in each stage of the pipeline,
+// in the order messages move through it
+func (irc *IRC) pendingWork() map[string]int {
+	pending := map[string]int{
+		"handlers":        int(irc.inflight.Load()),
+		"async_responses": len(irc.asyncResponseCh),
+		"outbound":        irc.sender.Pending(),
+	}
+	if irc.messageBroker != nil {
+		pending["broker"] = irc.messageBroker.Pending()
+	}
+	if irc.chatMemory != nil {
+		pending["embeddings"] = irc.chatMemory.Pending()
+	}
+	monitors := 0
+	for _, ch := range irc.channels {
+		if ch.modMonitor != nil {
+			monitors += ch.modMonitor.Pending()
+		}
+	}
+	pending["moderation"] = monitors
+	return pending
+}
+
+// Pending returns the amount of unfinished chat work across the whole pipeline
+func (irc *IRC) Pending() int {
+	total := 0
+	for _, n := range irc.pendingWork() {
+		total += n
+	}
+	return total
+}
+
+// Drain waits until in-flight chat handling, web searches, the message broker, the
+// moderation monitors, chat embeddings, async responses and the outbound chat queue are
+// all empty.
+// Call StopIntake first. It returns ctx.Err() if the deadline passes first.
+func (irc *IRC) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	// Work moves between stages (e.g. a web search result is handed to the async
+	// response handler), so require two empty checks in a row before calling it done.
+	emptyChecks := 0
+	for {
+		pending := irc.pendingWork()
+		total := 0
+		for _, n := range pending {
+			total += n
+		}
+		if total == 0 {
+			emptyChecks++
+			if emptyChecks == 2 {
+				irc.logger.Info("chat pipeline drained")
+				return nil
+			}
+		} else {
+			emptyChecks = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			irc.logger.Warn("shutdown deadline reached before chat pipeline drained", "pending", pending)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// EndPalaceSessions flushes buffered messages in every palace session and ends them
+func (irc *IRC) EndPalaceSessions() {
+	if irc.sessionRegistry == nil {
+		return
+	}
+	if err := irc.sessionRegistry.EndAll(); err != nil {
+		irc.logger.Error("error ending palace sessions", "error", err.Error())
+		return
+	}
+	irc.logger.Info("palace sessions flushed and ended")
+}
+
+// Disconnect closes the IRC connection
+func (irc *IRC) Disconnect() {
+	if irc.Client == nil {
+		return
+	}
+	if err := irc.Client.Disconnect(); err != nil {
+		irc.logger.Error("error disconnecting twitch client", "error", err.Error())
+		return
+	}
+	irc.logger.Info("disconnected from twitch IRC")
+}
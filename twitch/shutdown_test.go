@@ -0,0 +1,92 @@
+package twitchirc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/twitch/outbound"
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+type recordingTransport struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *recordingTransport) Say(_, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, text)
+}
+
+func newDrainTestIRC(transport outbound.Transport) *IRC {
+	logger := logging.Default()
+	return &IRC{
+		logger:          logger,
+		asyncResponseCh: make(chan types.TwitchMessage, 10),
+		channels:        map[string]*Channel{"soypetetech": newChannel(ChannelConfig{Name: "soypetetech"}, nil)},
+		channelNames:    []string{"soypetetech"},
+		sender:          outbound.NewSender(transport, nil, outbound.DefaultConfig(), logger),
+	}
+}
+
+func TestIRC_StopIntake(t *testing.T) {
+	irc := newDrainTestIRC(nil)
+	if !irc.accepting() {
+		t.Fatal("new IRC should accept messages")
+	}
+	irc.StopIntake()
+	irc.StopIntake()
+	if irc.accepting() {
+		t.Error("IRC should not accept messages after StopIntake")
+	}
+}
+
+func TestIRC_DrainWaitsForInFlightWork(t *testing.T) {
+	transport := &recordingTransport{}
+	irc := newDrainTestIRC(transport)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	irc.sender.Start(ctx, wg)
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	// A slow web search that answers after intake has stopped
+	irc.goTracked(func() {
+		time.Sleep(150 * time.Millisecond)
+		irc.say(context.Background(), outbound.Message{Channel: "soypetetech", Text: "here is what I found"})
+	})
+	irc.StopIntake()
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer drainCancel()
+	if err := irc.Drain(drainCtx); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.lines) != 1 || transport.lines[0] != "here is what I found" {
+		t.Errorf("sent = %q, want the web search answer", transport.lines)
+	}
+}
+
+func TestIRC_DrainDeadline(t *testing.T) {
+	irc := newDrainTestIRC(nil)
+
+	release := make(chan struct{})
+	irc.goTracked(func() { <-release })
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	if err := irc.Drain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Drain() error = %v, want context.DeadlineExceeded", err)
+	}
+}
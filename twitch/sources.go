@@ -0,0 +1,114 @@
+package twitchirc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxChatSources is how many sources `!sources` lists in chat
+const maxChatSources = 3
+
+// sourcesHandler handles `!sources`, which lists the sources behind Pedro's last answer to
+// the viewer. Links are not allowed in chat, so it gives the page titles and sites and the
+// full links are on the sources endpoint.
+func (irc *IRC) sourcesHandler() CommandHandler {
+	return func(ctx context.Context, inv CommandInvocation) (string, error) {
+		if irc.sources == nil {
+			return fmt.Sprintf("@%s sources are not kept right now", inv.Username), nil
+		}
+		answer, err := irc.sources.GetLastAnswerSources(ctx, inv.Channel, inv.Username)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case answer == nil:
+			return fmt.Sprintf("@%s I have not answered you yet", inv.Username), nil
+		case len(answer.Sources) == 0:
+			return fmt.Sprintf("@%s my last answer to you did not use any web sources", inv.Username), nil
+		}
+
+		var names []string
+		for _, source := range answer.Sources {
+			if len(names) == maxChatSources {
+				break
+			}
+			names = append(names, sourceName(source.Title, source.URL))
+		}
+		reply := fmt.Sprintf("@%s my last answer used: %s", inv.Username, strings.Join(names, "; "))
+		if more := len(answer.Sources) - len(names); more > 0 {
+			reply += fmt.Sprintf(" and %d more", more)
+		}
+		return reply, nil
+	}
+}
+
+// sourceName names a source without a link, which chat would strip: its title and site,
+// with the site's dots spelled out
+func sourceName(title, rawURL string) string {
+	site := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		site = strings.TrimPrefix(u.Hostname(), "www.")
+	}
+	site = strings.ReplaceAll(site, ".", " dot ")
+	if title = strings.TrimSpace(title); title == "" {
+		return site
+	}
+	return fmt.Sprintf("%s (%s)", title, site)
+}
+
+// SourcesHandler returns an HTTP handler with the sources behind Pedro's last answer to a
+// viewer, as JSON. The channel and user query parameters pick the viewer. It looks up any
+// viewer, so it only answers requests from the bot's own host.
+func (irc *IRC) SourcesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopback(r.RemoteAddr) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if irc.sources == nil {
+			http.Error(w, "Sources are not kept", http.StatusServiceUnavailable)
+			return
+		}
+		channel := normalizeChannel(r.URL.Query().Get("channel"))
+		user := strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("user")), "@")
+		if channel == "" || user == "" {
+			http.Error(w, "channel and user are required", http.StatusBadRequest)
+			return
+		}
+
+		answer, err := irc.sources.GetLastAnswerSources(r.Context(), channel, user)
+		if err != nil {
+			irc.logger.Error("failed to get answer sources", "channel", channel, "user", user, "error", err.Error())
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if answer == nil {
+			http.Error(w, "No answer found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(answer); err != nil {
+			irc.logger.Error("failed to encode answer sources", "error", err.Error())
+		}
+	}
+}
+
+// isLoopback reports whether a request's remote address is on this host
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
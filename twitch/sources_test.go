@@ -0,0 +1,115 @@
+package twitchirc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+// fakeSourcesStore returns canned answers keyed by channel and username
+type fakeSourcesStore map[string]*types.AnswerSources
+
+func (f fakeSourcesStore) GetLastAnswerSources(_ context.Context, channel, username string) (*types.AnswerSources, error) {
+	return f[channel+"/"+username], nil
+}
+
+func newSourcesIRC() *IRC {
+	return &IRC{
+		logger: logging.Default(),
+		sources: fakeSourcesStore{
+			"soypetetech/scott": {
+				Channel:  "soypetetech",
+				Username: "scott",
+				Response: "Go 1.26 is out",
+				Sources: []types.Source{
+					{Title: "Go 1.26 is released", URL: "https://go.dev/blog/go1.26", Tool: "web_search"},
+					{URL: "https://www.tip.golang.org/doc/go1.26", Tool: "fetch_page"},
+					{Title: "Release history", URL: "https://go.dev/doc/devel/release", Tool: "web_search"},
+					{Title: "Go 1.26 release notes", URL: "https://go.dev/doc/go1.26", Tool: "web_search"},
+				},
+			},
+			"soypetetech/ana": {Channel: "soypetetech", Username: "ana", Response: "hello @ana"},
+		},
+	}
+}
+
+func TestSourcesHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		irc      *IRC
+		username string
+		want     string
+	}{
+		{
+			name:     "sources of the last answer",
+			irc:      newSourcesIRC(),
+			username: "scott",
+			want:     "@scott my last answer used: Go 1.26 is released (go dot dev); tip dot golang dot org; Release history (go dot dev) and 1 more",
+		},
+		{name: "answer without sources", irc: newSourcesIRC(), username: "ana", want: "@ana my last answer to you did not use any web sources"},
+		{name: "no answer yet", irc: newSourcesIRC(), username: "bob", want: "@bob I have not answered you yet"},
+		{name: "sources not kept", irc: &IRC{logger: logging.Default()}, username: "scott", want: "@scott sources are not kept right now"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.irc.sourcesHandler()(context.Background(), CommandInvocation{Channel: "soypetetech", Username: tt.username})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourcesHTTPHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		irc         *IRC
+		method      string
+		query       string
+		remoteAddr  string
+		wantStatus  int
+		wantSources int
+	}{
+		{name: "last answer", irc: newSourcesIRC(), method: http.MethodGet, query: "?channel=%23SoyPeteTech&user=@scott", wantStatus: http.StatusOK, wantSources: 4},
+		{name: "answer without sources", irc: newSourcesIRC(), method: http.MethodGet, query: "?channel=soypetetech&user=ana", wantStatus: http.StatusOK},
+		{name: "no answer", irc: newSourcesIRC(), method: http.MethodGet, query: "?channel=soypetetech&user=bob", wantStatus: http.StatusNotFound},
+		{name: "missing user", irc: newSourcesIRC(), method: http.MethodGet, query: "?channel=soypetetech", wantStatus: http.StatusBadRequest},
+		{name: "wrong method", irc: newSourcesIRC(), method: http.MethodPost, query: "?channel=soypetetech&user=scott", wantStatus: http.StatusMethodNotAllowed},
+		{name: "sources not kept", irc: &IRC{logger: logging.Default()}, method: http.MethodGet, query: "?channel=soypetetech&user=scott", wantStatus: http.StatusServiceUnavailable},
+		{name: "ipv6 loopback", irc: newSourcesIRC(), method: http.MethodGet, query: "?channel=soypetetech&user=scott", remoteAddr: "[::1]:51000", wantStatus: http.StatusOK, wantSources: 4},
+		{name: "remote request", irc: newSourcesIRC(), method: http.MethodGet, query: "?channel=soypetetech&user=scott", remoteAddr: "203.0.113.9:51000", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/sources"+tt.query, nil)
+			req.RemoteAddr = "127.0.0.1:51000"
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			w := httptest.NewRecorder()
+			tt.irc.SourcesHandler()(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var answer types.AnswerSources
+			if err := json.NewDecoder(w.Body).Decode(&answer); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(answer.Sources) != tt.wantSources {
+				t.Errorf("got %d sources, want %d", len(answer.Sources), tt.wantSources)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package twitchirc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	v2 "github.com/gempir/go-twitch-irc/v2"
+)
+
+// ConnectionState is where the IRC supervisor is in the connection lifecycle
+type ConnectionState string
+
+const (
+	StateConnecting   ConnectionState = "connecting"
+	StateConnected    ConnectionState = "connected"
+	StateReconnecting ConnectionState = "reconnecting"
+	StateAuthFailed   ConnectionState = "auth_failed"
+	StateStopped      ConnectionState = "stopped"
+)
+
+var connectionStates = []ConnectionState{StateConnecting, StateConnected, StateReconnecting, StateAuthFailed, StateStopped}
+
+// ircConnection is the part of *v2.Client the supervisor drives
+type ircConnection interface {
+	Connect() error
+}
+
+// SupervisorConfig controls reconnect backoff
+type SupervisorConfig struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxAuthFailures is how many logins in a row may fail, re-authenticating between
+	// each, before the supervisor gives up
+	MaxAuthFailures int
+}
+
+// DefaultSupervisorConfig returns the reconnect settings used in production
+func DefaultSupervisorConfig() SupervisorConfig {
+	return SupervisorConfig{
+		MinBackoff:      time.Second,
+		MaxBackoff:      2 * time.Minute,
+		MaxAuthFailures: 3,
+	}
+}
+
+// Supervisor keeps the IRC connection up. It creates a client, connects, and on any
+// disconnect waits with jittered exponential backoff before trying again. Login
+// failures re-authenticate first. Chat subsystems are started once by ConnectIRC and
+// keep running across reconnects.
+type Supervisor struct {
+	irc    *IRC
+	wg     *sync.WaitGroup
+	config SupervisorConfig
+	logger *logging.Logger
+
+	mu        sync.RWMutex
+	state     ConnectionState
+	since     time.Time
+	lastError string
+
+	startedOnce sync.Once
+	onStarted   []func()
+
+	// connected is set once the current connection is established
+	connected atomic.Bool
+
+	// dial and reauth are replaced in tests
+	dial   func(ctx context.Context) (ircConnection, error)
+	reauth func(ctx context.Context) error
+}
+
+// NewSupervisor creates a supervisor for irc. Zero config values use the defaults.
+func NewSupervisor(irc *IRC, wg *sync.WaitGroup, config SupervisorConfig, logger *logging.Logger) *Supervisor {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	defaults := DefaultSupervisorConfig()
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaults.MinBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.MaxAuthFailures <= 0 {
+		config.MaxAuthFailures = defaults.MaxAuthFailures
+	}
+
+	s := &Supervisor{
+		irc:    irc,
+		wg:     wg,
+		config: config,
+		logger: logger,
+	}
+	s.dial = func(ctx context.Context) (ircConnection, error) {
+		if err := irc.ConnectIRC(ctx, wg); err != nil {
+			return nil, err
+		}
+		return irc.Client, nil
+	}
+	s.reauth = irc.AuthTwitch
+	irc.onConnect = s.markConnected
+	s.setState(StateConnecting, nil)
+	return s
+}
+
+// OnStarted registers fn to run once, after the chat subsystems have started.
+// Use it for subsystems that need them, like EventSub.
+func (s *Supervisor) OnStarted(fn func()) {
+	s.onStarted = append(s.onStarted, fn)
+}
+
+// State returns the current connection state
+func (s *Supervisor) State() ConnectionState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Run connects and reconnects until ctx is cancelled. It returns an error only when
+// it gives up, which happens when re-authentication fails.
+func (s *Supervisor) Run(ctx context.Context) error {
+	attempt := 0
+	authFailures := 0
+	for {
+		if ctx.Err() != nil {
+			s.setState(StateStopped, nil)
+			return nil
+		}
+
+		err := s.connectOnce(ctx)
+		if ctx.Err() != nil {
+			s.setState(StateStopped, nil)
+			return nil
+		}
+		if s.connected.Load() {
+			// The connection was up, so this is a fresh outage
+			attempt = 0
+			authFailures = 0
+		}
+		if err == nil {
+			err = fmt.Errorf("connection closed")
+		}
+
+		if isAuthFailure(err) {
+			authFailures++
+			s.setState(StateAuthFailed, err)
+			metrics.TwitchIRCReconnectsTotal.WithLabelValues("auth_failed").Inc()
+			if authFailures > s.config.MaxAuthFailures {
+				return fmt.Errorf("twitch IRC login failed %d times in a row: %w", authFailures, err)
+			}
+			s.logger.Warn("twitch IRC login failed, re-authenticating", "error", err.Error(), "failures", authFailures)
+			if authErr := s.reauth(ctx); authErr != nil {
+				return fmt.Errorf("re-authentication failed: %w", authErr)
+			}
+		} else {
+			metrics.TwitchIRCReconnectsTotal.WithLabelValues("disconnected").Inc()
+			s.logger.Warn("twitch IRC connection lost", "error", err.Error())
+		}
+
+		delay := s.backoff(attempt)
+		attempt++
+		s.setState(StateReconnecting, err)
+		s.logger.Info("reconnecting to twitch IRC", "attempt", attempt, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.setState(StateStopped, nil)
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// connectOnce creates a client and blocks while it is connected
+func (s *Supervisor) connectOnce(ctx context.Context) error {
+	s.connected.Store(false)
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	s.startedOnce.Do(func() {
+		for _, fn := range s.onStarted {
+			fn()
+		}
+	})
+	return conn.Connect()
+}
+
+// markConnected is called by the IRC client once the connection is established
+func (s *Supervisor) markConnected() {
+	s.connected.Store(true)
+	s.setState(StateConnected, nil)
+}
+
+// backoff returns the wait before reconnect attempt n: exponential from MinBackoff,
+// capped at MaxBackoff, with jitter so a fleet of bots does not reconnect in lockstep
+func (s *Supervisor) backoff(attempt int) time.Duration {
+	delay := s.config.MinBackoff
+	for i := 0; i < attempt && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.config.MaxBackoff {
+		delay = s.config.MaxBackoff
+	}
+	// Wait between half and all of the delay
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (s *Supervisor) setState(state ConnectionState, err error) {
+	s.mu.Lock()
+	if s.state != state {
+		s.since = time.Now()
+	}
+	s.state = state
+	if err != nil {
+		s.lastError = err.Error()
+	}
+	s.mu.Unlock()
+
+	for _, st := range connectionStates {
+		value := 0.0
+		if st == state {
+			value = 1
+		}
+		metrics.TwitchIRCConnectionState.WithLabelValues(string(st)).Set(value)
+	}
+}
+
+func isAuthFailure(err error) bool {
+	return err == v2.ErrLoginAuthenticationFailed || strings.Contains(err.Error(), "login authentication failed")
+}
+
+// IRCHealthResponse represents the JSON response for the IRC health check endpoint
+type IRCHealthResponse struct {
+	State     ConnectionState `json:"state"`
+	Since     time.Time       `json:"since"`
+	LastError string          `json:"last_error,omitempty"`
+}
+
+// HealthHandler returns an HTTP handler reporting the connection state. It responds
+// 503 while the bot is not connected.
+func (s *Supervisor) HealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		s.mu.RLock()
+		health := IRCHealthResponse{State: s.state, Since: s.since, LastError: s.lastError}
+		s.mu.RUnlock()
+
+		status := http.StatusOK
+		if health.State != StateConnected {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(health); err != nil {
+			s.logger.Error("failed to encode IRC health response", "error", err.Error())
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package twitchirc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	v2 "github.com/gempir/go-twitch-irc/v2"
+)
+
+// fakeConn connects (or not) and then returns err, like a dropped IRC connection
+type fakeConn struct {
+	s         *Supervisor
+	connected bool
+	err       error
+	block     chan struct{}
+}
+
+func (f *fakeConn) Connect() error {
+	if f.connected {
+		f.s.markConnected()
+	}
+	if f.block != nil {
+		<-f.block
+	}
+	return f.err
+}
+
+func newTestSupervisor(t *testing.T) *Supervisor {
+	t.Helper()
+	irc := newDrainTestIRC(nil)
+	return NewSupervisor(irc, &sync.WaitGroup{}, SupervisorConfig{MinBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, MaxAuthFailures: 2}, nil)
+}
+
+func TestSupervisor_ReconnectsAndStartsOnce(t *testing.T) {
+	s := newTestSupervisor(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	block := make(chan struct{})
+	conns := []*fakeConn{
+		{s: s, err: errors.New("dial tcp: connection refused")},
+		{s: s, connected: true, err: errors.New("EOF")},
+		{s: s, connected: true, block: block, err: v2.ErrClientDisconnected},
+	}
+	var mu sync.Mutex
+	dials := 0
+	s.dial = func(context.Context) (ircConnection, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		conn := conns[dials]
+		dials++
+		return conn, nil
+	}
+	started := 0
+	s.OnStarted(func() { started++ })
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := dials
+		mu.Unlock()
+		if n == 3 && s.State() == StateConnected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("supervisor did not reconnect, dials = %d state = %s", n, s.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	close(block)
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	if s.State() != StateStopped {
+		t.Errorf("State() = %s, want %s", s.State(), StateStopped)
+	}
+	if started != 1 {
+		t.Errorf("OnStarted hooks ran %d times, want 1", started)
+	}
+}
+
+func TestSupervisor_AuthFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		reauthErr error
+		wantErr   bool
+		wantAuths int
+	}{
+		{name: "re-auth fails", reauthErr: errors.New("refresh token revoked"), wantErr: true, wantAuths: 1},
+		{name: "login keeps failing", wantErr: true, wantAuths: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSupervisor(t)
+			s.dial = func(context.Context) (ircConnection, error) {
+				return &fakeConn{s: s, err: v2.ErrLoginAuthenticationFailed}, nil
+			}
+			auths := 0
+			s.reauth = func(context.Context) error {
+				auths++
+				return tt.reauthErr
+			}
+
+			err := s.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if auths != tt.wantAuths {
+				t.Errorf("re-authenticated %d times, want %d", auths, tt.wantAuths)
+			}
+			if s.State() != StateAuthFailed {
+				t.Errorf("State() = %s, want %s", s.State(), StateAuthFailed)
+			}
+		})
+	}
+}
+
+func TestSupervisor_Backoff(t *testing.T) {
+	s := newTestSupervisor(t)
+	s.config = SupervisorConfig{MinBackoff: time.Second, MaxBackoff: 8 * time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 0, max: time.Second},
+		{attempt: 1, max: 2 * time.Second},
+		{attempt: 3, max: 8 * time.Second},
+		{attempt: 10, max: 8 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := s.backoff(tt.attempt)
+			if got < tt.max/2 || got > tt.max {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestSupervisor_HealthHandler(t *testing.T) {
+	s := newTestSupervisor(t)
+
+	rec := httptest.NewRecorder()
+	s.HealthHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz/irc", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status while connecting = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	s.markConnected()
+	rec = httptest.NewRecorder()
+	s.HealthHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz/irc", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status while connected = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
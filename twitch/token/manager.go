@@ -0,0 +1,218 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultRefreshMargin is how long before expiry the token is refreshed
+	DefaultRefreshMargin = 10 * time.Minute
+	// defaultLifetime is assumed for tokens without an expiry, like TWITCH_TOKEN from the environment
+	defaultLifetime = 4 * time.Hour
+	// retryDelay is how long to wait after a failed refresh
+	retryDelay = time.Minute
+)
+
+// Listener is called with every new token
+type Listener func(tok *oauth2.Token)
+
+// Manager holds the current token, persists it and refreshes it before it expires.
+// Every listener is told about each new token.
+type Manager struct {
+	store  Store
+	logger *logging.Logger
+	margin time.Duration
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	config    *oauth2.Config
+	tok       *oauth2.Token
+	obtained  time.Time
+	listeners []Listener
+	changed   chan struct{}
+}
+
+// NewManager creates a token manager. store may be nil to keep the token in memory only.
+func NewManager(store Store, logger *logging.Logger) *Manager {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &Manager{
+		store:   store,
+		logger:  logger,
+		margin:  DefaultRefreshMargin,
+		now:     time.Now,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// SetConfig sets the OAuth config used to refresh the token
+func (m *Manager) SetConfig(config *oauth2.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+}
+
+// Subscribe registers a listener for new tokens
+func (m *Manager) Subscribe(listener Listener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// Token returns the current token, or nil before one is set
+func (m *Manager) Token() *oauth2.Token {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.tok
+}
+
+// Load reads the saved token from the store. It returns ErrNotFound when there is none.
+func (m *Manager) Load(ctx context.Context) (*oauth2.Token, error) {
+	if m.store == nil {
+		return nil, ErrNotFound
+	}
+	return m.store.Load(ctx)
+}
+
+// Set makes tok the current token, saves it and notifies every listener
+func (m *Manager) Set(ctx context.Context, tok *oauth2.Token) error {
+	if tok == nil || tok.AccessToken == "" {
+		return fmt.Errorf("token is empty")
+	}
+
+	m.mu.Lock()
+	m.tok = tok
+	m.obtained = m.now()
+	listeners := append([]Listener(nil), m.listeners...)
+	m.mu.Unlock()
+
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+
+	for _, listener := range listeners {
+		listener(tok)
+	}
+
+	if m.store == nil {
+		return nil
+	}
+	if err := m.store.Save(ctx, tok); err != nil {
+		return fmt.Errorf("failed to save twitch token: %w", err)
+	}
+	m.logger.Debug("twitch token saved")
+	return nil
+}
+
+// Refresh exchanges the refresh token for a new token and sets it
+func (m *Manager) Refresh(ctx context.Context) error {
+	m.mu.RLock()
+	config, tok := m.config, m.tok
+	m.mu.RUnlock()
+
+	newTok, err := RefreshToken(ctx, config, tok)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info("twitch token refreshed", "expiry", newTok.Expiry)
+	if err := m.Set(ctx, newTok); err != nil {
+		// The new token works even if it could not be saved
+		m.logger.Error("failed to persist refreshed token", "error", err.Error())
+	}
+	return nil
+}
+
+// RefreshToken exchanges tok's refresh token for a new token, even if tok has not expired
+func RefreshToken(ctx context.Context, config *oauth2.Config, tok *oauth2.Token) (*oauth2.Token, error) {
+	if tok == nil || tok.RefreshToken == "" {
+		return nil, fmt.Errorf("no refresh token available")
+	}
+	if config == nil {
+		return nil, fmt.Errorf("no oauth config to refresh with")
+	}
+
+	// Mark the token expired so the token source always asks Twitch for a new one
+	expired := *tok
+	expired.Expiry = time.Unix(1, 0)
+	newTok, err := config.TokenSource(ctx, &expired).Token()
+	if err != nil {
+		return nil, fmt.Errorf("refresh failed: %w", err)
+	}
+	if newTok.RefreshToken == "" {
+		newTok.RefreshToken = tok.RefreshToken
+	}
+	return newTok, nil
+}
+
+// untilRefresh returns how long until the token should be refreshed. ok is false when
+// the token cannot be refreshed.
+func (m *Manager) untilRefresh() (time.Duration, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.tok == nil || m.tok.RefreshToken == "" {
+		return 0, false
+	}
+	expiry := m.tok.Expiry
+	if expiry.IsZero() {
+		expiry = m.obtained.Add(defaultLifetime)
+	}
+	return expiry.Add(-m.margin).Sub(m.now()), true
+}
+
+// Start refreshes the token before it expires until ctx is cancelled
+func (m *Manager) Start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.logger.Info("twitch token refresher started", "margin", m.margin)
+		m.run(ctx)
+		m.logger.Info("twitch token refresher shutting down")
+	}()
+}
+
+func (m *Manager) run(ctx context.Context) {
+	for {
+		wait, ok := m.untilRefresh()
+		if ok && wait <= 0 {
+			if err := m.Refresh(ctx); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				m.logger.Error("proactive token refresh failed", "error", err.Error(), "retryIn", retryDelay)
+				wait = retryDelay
+			} else {
+				continue
+			}
+		}
+
+		// Without a refreshable token, wait until one is set
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if ok {
+			timer = time.NewTimer(wait)
+			fire = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-m.changed:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
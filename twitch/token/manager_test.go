@@ -0,0 +1,155 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type memoryStore struct {
+	mu  sync.Mutex
+	tok *oauth2.Token
+}
+
+func (s *memoryStore) Load(context.Context) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok == nil {
+		return nil, ErrNotFound
+	}
+	return s.tok, nil
+}
+
+func (s *memoryStore) Save(_ context.Context, tok *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tok = tok
+	return nil
+}
+
+func (s *memoryStore) saved() *oauth2.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tok
+}
+
+// newTokenServer returns an OAuth token endpoint that hands out access-1, access-2, ...
+func newTokenServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.FormValue("grant_type") != "refresh_token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","refresh_token":"refresh-%d","token_type":"bearer","expires_in":14400}`, n, n)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func testConfig(server *httptest.Server) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: server.URL, AuthStyle: oauth2.AuthStyleInParams},
+	}
+}
+
+func TestManager_SetNotifiesAndSaves(t *testing.T) {
+	store := &memoryStore{}
+	m := NewManager(store, nil)
+
+	var got []string
+	m.Subscribe(func(tok *oauth2.Token) { got = append(got, tok.AccessToken) })
+	m.Subscribe(func(tok *oauth2.Token) { got = append(got, "second:"+tok.AccessToken) })
+
+	if err := m.Set(context.Background(), &oauth2.Token{AccessToken: "abc"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != "abc" || got[1] != "second:abc" {
+		t.Errorf("listeners got %v, want both notified with abc", got)
+	}
+	if saved := store.saved(); saved == nil || saved.AccessToken != "abc" {
+		t.Errorf("saved token = %+v, want abc", saved)
+	}
+	if m.Token().AccessToken != "abc" {
+		t.Errorf("Token() = %q, want abc", m.Token().AccessToken)
+	}
+	if err := m.Set(context.Background(), &oauth2.Token{}); err == nil {
+		t.Error("Set() with an empty token should fail")
+	}
+}
+
+func TestManager_Refresh(t *testing.T) {
+	server, calls := newTokenServer(t)
+	store := &memoryStore{}
+	m := NewManager(store, nil)
+	m.SetConfig(testConfig(server))
+
+	ctx := context.Background()
+	if err := m.Refresh(ctx); err == nil {
+		t.Fatal("Refresh() without a token should fail")
+	}
+
+	// Not expired yet: Refresh still asks Twitch for a new token
+	if err := m.Set(ctx, &oauth2.Token{AccessToken: "old", RefreshToken: "refresh-0", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	var notified string
+	m.Subscribe(func(tok *oauth2.Token) { notified = tok.AccessToken })
+
+	if err := m.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("token endpoint called %d times, want 1", calls.Load())
+	}
+	if notified != "access-1" {
+		t.Errorf("listener got %q, want access-1", notified)
+	}
+	if saved := store.saved(); saved.RefreshToken != "refresh-1" {
+		t.Errorf("saved refresh token = %q, want refresh-1", saved.RefreshToken)
+	}
+}
+
+func TestManager_StartRefreshesBeforeExpiry(t *testing.T) {
+	server, calls := newTokenServer(t)
+	m := NewManager(nil, nil)
+	m.SetConfig(testConfig(server))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	m.Start(ctx, wg)
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	// Expires inside the refresh margin, so the refresher should replace it right away
+	if err := m.Set(ctx, &oauth2.Token{AccessToken: "old", RefreshToken: "refresh-0", Expiry: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for m.Token().AccessToken != "access-1" {
+		if time.Now().After(deadline) {
+			t.Fatalf("token = %q after 2s, want access-1", m.Token().AccessToken)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// The new token expires in 4h, so there is no second refresh
+	time.Sleep(50 * time.Millisecond)
+	if calls.Load() != 1 {
+		t.Errorf("token endpoint called %d times, want 1", calls.Load())
+	}
+}
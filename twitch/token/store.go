@@ -0,0 +1,162 @@
+// Package token persists Pedro's Twitch OAuth token and refreshes it before it expires.
+// Tokens are encrypted with AES-GCM before they are written to a file or Postgres.
+package token
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNotFound is returned by a Store that has no saved token
+var ErrNotFound = errors.New("no saved twitch token")
+
+// Store persists a token between restarts
+type Store interface {
+	Load(ctx context.Context) (*oauth2.Token, error)
+	Save(ctx context.Context, tok *oauth2.Token) error
+}
+
+// Cipher encrypts tokens at rest
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a secret. The secret is hashed into an AES-256 key,
+// so any string (e.g. from 1Password) works.
+func NewCipher(secret string) (*Cipher, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("token encryption key is empty")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts a token
+func (c *Cipher) Seal(tok *oauth2.Token) ([]byte, error) {
+	plain, err := json.Marshal(tok)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal token: %w", err)
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// Open decrypts a token sealed with the same secret
+func (c *Cipher) Open(data []byte) (*oauth2.Token, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("encrypted token is too short")
+	}
+	plain, err := c.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token (wrong key?): %w", err)
+	}
+	var tok oauth2.Token
+	if err := json.Unmarshal(plain, &tok); err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	return &tok, nil
+}
+
+// FileStore keeps the encrypted token in a file
+type FileStore struct {
+	path   string
+	cipher *Cipher
+}
+
+// NewFileStore creates a store that writes the encrypted token to path
+func NewFileStore(path string, cipher *Cipher) *FileStore {
+	return &FileStore{path: path, cipher: cipher}
+}
+
+// Load reads the token from disk
+func (s *FileStore) Load(_ context.Context) (*oauth2.Token, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file %s: %w", s.path, err)
+	}
+	return s.cipher.Open(data)
+}
+
+// Save writes the token to disk, replacing the file atomically
+func (s *FileStore) Save(_ context.Context, tok *oauth2.Token) error {
+	data, err := s.cipher.Seal(tok)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace token file: %w", err)
+	}
+	return nil
+}
+
+// DB stores encrypted tokens by name. *database.Postgres satisfies this interface.
+type DB interface {
+	GetTwitchToken(ctx context.Context, name string) ([]byte, error)
+	SaveTwitchToken(ctx context.Context, name string, data []byte) error
+}
+
+// PostgresStore keeps the encrypted token in the twitch_tokens table
+type PostgresStore struct {
+	db     DB
+	name   string
+	cipher *Cipher
+}
+
+// NewPostgresStore creates a store for the token called name (e.g. the bot login)
+func NewPostgresStore(db DB, name string, cipher *Cipher) *PostgresStore {
+	return &PostgresStore{db: db, name: name, cipher: cipher}
+}
+
+// Load reads the token from Postgres
+func (s *PostgresStore) Load(ctx context.Context) (*oauth2.Token, error) {
+	data, err := s.db.GetTwitchToken(ctx, s.name)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	return s.cipher.Open(data)
+}
+
+// Save writes the token to Postgres
+func (s *PostgresStore) Save(ctx context.Context, tok *oauth2.Token) error {
+	data, err := s.cipher.Seal(tok)
+	if err != nil {
+		return err
+	}
+	return s.db.SaveTwitchToken(ctx, s.name, data)
+}
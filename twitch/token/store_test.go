@@ -0,0 +1,109 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestFileStore_RoundTrip(t *testing.T) {
+	cipher, err := NewCipher("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "tokens", "twitch_token.enc")
+	store := NewFileStore(path, cipher)
+	ctx := context.Background()
+
+	if _, err := store.Load(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load() before Save error = %v, want ErrNotFound", err)
+	}
+
+	tok := &oauth2.Token{
+		AccessToken:  "access-abc123",
+		RefreshToken: "refresh-xyz789",
+		Expiry:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := store.Save(ctx, tok); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, secret := range []string{tok.AccessToken, tok.RefreshToken} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("token file contains %q in plain text", secret)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("token file mode = %o, want 600", perm)
+	}
+
+	got, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.AccessToken != tok.AccessToken || got.RefreshToken != tok.RefreshToken || !got.Expiry.Equal(tok.Expiry) {
+		t.Errorf("Load() = %+v, want %+v", got, tok)
+	}
+
+	wrong, err := NewCipher("wrong key")
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	if _, err := NewFileStore(path, wrong).Load(ctx); err == nil {
+		t.Error("Load() with the wrong key should fail")
+	}
+}
+
+type fakeDB struct {
+	rows map[string][]byte
+}
+
+func (f *fakeDB) GetTwitchToken(_ context.Context, name string) ([]byte, error) {
+	return f.rows[name], nil
+}
+
+func (f *fakeDB) SaveTwitchToken(_ context.Context, name string, data []byte) error {
+	f.rows[name] = data
+	return nil
+}
+
+func TestPostgresStore_RoundTrip(t *testing.T) {
+	cipher, err := NewCipher("secret")
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	db := &fakeDB{rows: map[string][]byte{}}
+	store := NewPostgresStore(db, "pedro", cipher)
+	ctx := context.Background()
+
+	if _, err := store.Load(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load() before Save error = %v, want ErrNotFound", err)
+	}
+	if err := store.Save(ctx, &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if bytes.Contains(db.rows["pedro"], []byte("refresh")) {
+		t.Error("stored row contains the refresh token in plain text")
+	}
+	got, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.RefreshToken != "refresh" {
+		t.Errorf("Load().RefreshToken = %q, want %q", got.RefreshToken, "refresh")
+	}
+}
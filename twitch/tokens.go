@@ -0,0 +1,105 @@
+package twitchirc
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/twitch/helix"
+	"github.com/Soypete/twitch-llm-bot/twitch/token"
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultTokenFile = "data/twitch_token.enc"
+	// tokenStoreName is the row the token is saved under in Postgres
+	tokenStoreName = "pedro"
+)
+
+// newTokenStore picks where the Twitch token is persisted from the environment.
+// TWITCH_TOKEN_KEY is required; without it the token only lives in memory.
+// TWITCH_TOKEN_STORE is "file" (default, TWITCH_TOKEN_FILE) or "postgres".
+func newTokenStore(db any, logger *logging.Logger) token.Store {
+	key := os.Getenv("TWITCH_TOKEN_KEY")
+	if key == "" {
+		logger.Warn("TWITCH_TOKEN_KEY not set, twitch token will not be persisted")
+		return nil
+	}
+	cipher, err := token.NewCipher(key)
+	if err != nil {
+		logger.Error("failed to create token cipher, twitch token will not be persisted", "error", err.Error())
+		return nil
+	}
+
+	switch kind := os.Getenv("TWITCH_TOKEN_STORE"); kind {
+	case "postgres":
+		tokenDB, ok := db.(token.DB)
+		if !ok {
+			logger.Error("TWITCH_TOKEN_STORE=postgres but the database cannot store tokens, twitch token will not be persisted")
+			return nil
+		}
+		logger.Info("persisting twitch token in postgres")
+		return token.NewPostgresStore(tokenDB, tokenStoreName, cipher)
+	case "", "file":
+		path := os.Getenv("TWITCH_TOKEN_FILE")
+		if path == "" {
+			path = defaultTokenFile
+		}
+		logger.Info("persisting twitch token in file", "path", path)
+		return token.NewFileStore(path, cipher)
+	default:
+		logger.Error("unknown TWITCH_TOKEN_STORE, twitch token will not be persisted", "store", kind)
+		return nil
+	}
+}
+
+// currentToken returns the token IRC and Helix calls use
+func (irc *IRC) currentToken() *oauth2.Token {
+	irc.tokMu.RLock()
+	defer irc.tokMu.RUnlock()
+	return irc.tok
+}
+
+// setToken makes tok the bot's token. It is saved to the token store and passed to
+// every consumer through applyToken.
+func (irc *IRC) setToken(ctx context.Context, tok *oauth2.Token) {
+	if irc.tokens == nil {
+		irc.applyToken(tok)
+		return
+	}
+	if err := irc.tokens.Set(ctx, tok); err != nil {
+		irc.logger.Error("failed to persist twitch token", "error", err.Error())
+	}
+}
+
+// applyToken is subscribed to the token manager. It updates the token used for new IRC
+// connections and every Helix client, including the Mem Palace lifecycle.
+func (irc *IRC) applyToken(tok *oauth2.Token) {
+	irc.tokMu.Lock()
+	irc.tok = tok
+	irc.tokenRefreshTime = time.Now()
+	clients := append([]*helix.Client(nil), irc.helixClients...)
+	irc.tokMu.Unlock()
+
+	for _, c := range clients {
+		c.UpdateToken(tok.AccessToken)
+	}
+	if irc.memPalace != nil {
+		irc.memPalace.UpdateToken(tok.AccessToken)
+	}
+}
+
+// newHelixClient creates a Helix client with the current token that is kept up to date
+// when the token is refreshed
+func (irc *IRC) newHelixClient(clientID, broadcasterID, moderatorID string) *helix.Client {
+	irc.tokMu.Lock()
+	defer irc.tokMu.Unlock()
+	var accessToken string
+	if irc.tok != nil {
+		accessToken = irc.tok.AccessToken
+	}
+	c := helix.NewClient(clientID, accessToken, broadcasterID, moderatorID, irc.logger)
+	irc.helixClients = append(irc.helixClients, c)
+	return c
+}
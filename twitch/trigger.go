@@ -0,0 +1,160 @@
+package twitchirc
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/types"
+	v2 "github.com/gempir/go-twitch-irc/v2"
+)
+
+// DefaultAddressThreshold is the address score a message needs before Pedro replies
+const DefaultAddressThreshold float32 = 0.6
+
+// defaultBotNames are the names chat uses to talk to Pedro
+var defaultBotNames = []string{"pedro_el_asistente", "soy_llm_bot", "pedro"}
+
+// Trigger reasons, used in logs and metrics
+const (
+	TriggerReasonMention      = "mention"
+	TriggerReasonReply        = "reply_to_pedro"
+	TriggerReasonAddressed    = "address_score"
+	TriggerReasonBelowScore   = "below_threshold"
+	TriggerReasonNameMention  = "name_mention"
+	TriggerReasonNotAddressed = "not_addressed"
+)
+
+// AddressScorer scores how likely a message is talking to Pedro.
+// *mempalace.MemPalace and *address.Detector satisfy this interface.
+type AddressScorer interface {
+	IsAddressed(msg string) (bool, float32)
+}
+
+// TriggerDecision is the result of running a message through the trigger pipeline
+type TriggerDecision struct {
+	Reply  bool
+	Reason string
+	// Score is the address score, only set when the scorer ran
+	Score float32
+}
+
+// Trigger decides whether Pedro should reply to a chat message. It checks, in order:
+// an @mention of Pedro, a reply to one of Pedro's messages, and the address score
+// of the message against a threshold. Without a scorer it falls back to Pedro's
+// name appearing as a word in the message.
+type Trigger struct {
+	names     []string
+	mentionRe *regexp.Regexp
+	nameRe    *regexp.Regexp
+	threshold float32
+	scorer    AddressScorer
+	logger    *logging.Logger
+}
+
+// NewTrigger creates a trigger pipeline. names defaults to Pedro's Twitch logins and
+// a threshold of 0 uses DefaultAddressThreshold. scorer may be nil.
+func NewTrigger(names []string, threshold float32, scorer AddressScorer, logger *logging.Logger) *Trigger {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if len(names) == 0 {
+		names = defaultBotNames
+	}
+	if threshold <= 0 {
+		threshold = DefaultAddressThreshold
+	}
+
+	quoted := make([]string, 0, len(names))
+	lower := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+		if name == "" {
+			continue
+		}
+		lower = append(lower, name)
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	alternatives := strings.Join(quoted, "|")
+
+	return &Trigger{
+		names:     lower,
+		mentionRe: regexp.MustCompile(`(?i)@(?:` + alternatives + `)\b`),
+		nameRe:    regexp.MustCompile(`(?i)\b(?:` + alternatives + `)\b`),
+		threshold: threshold,
+		scorer:    scorer,
+		logger:    logger,
+	}
+}
+
+// SetScorer sets the address scorer, e.g. once Mem Palace has started
+func (t *Trigger) SetScorer(scorer AddressScorer) {
+	t.scorer = scorer
+}
+
+// SetThreshold sets the address score needed for a reply
+func (t *Trigger) SetThreshold(threshold float32) {
+	if threshold > 0 {
+		t.threshold = threshold
+	}
+}
+
+// Evaluate runs the trigger pipeline and logs why the message did or did not trigger a reply
+func (t *Trigger) Evaluate(msg v2.PrivateMessage, chat types.TwitchMessage) TriggerDecision {
+	decision := t.evaluate(msg, chat)
+
+	metrics.ChatTriggerDecisionsTotal.WithLabelValues(decision.Reason).Inc()
+	if decision.Reply {
+		t.logger.Info("message triggered a reply",
+			"channel", chat.Channel,
+			"user", chat.Username,
+			"reason", decision.Reason,
+			"score", decision.Score)
+	} else {
+		t.logger.Debug("message did not trigger a reply",
+			"channel", chat.Channel,
+			"user", chat.Username,
+			"reason", decision.Reason,
+			"score", decision.Score,
+			"threshold", t.threshold)
+	}
+	return decision
+}
+
+func (t *Trigger) evaluate(msg v2.PrivateMessage, chat types.TwitchMessage) TriggerDecision {
+	if t.mentionRe.MatchString(chat.Text) {
+		return TriggerDecision{Reply: true, Reason: TriggerReasonMention}
+	}
+
+	if t.isBotName(msg.Tags["reply-parent-user-login"]) {
+		return TriggerDecision{Reply: true, Reason: TriggerReasonReply}
+	}
+
+	if t.scorer == nil {
+		if t.nameRe.MatchString(chat.Text) {
+			return TriggerDecision{Reply: true, Reason: TriggerReasonNameMention}
+		}
+		return TriggerDecision{Reason: TriggerReasonNotAddressed}
+	}
+
+	// The scorer's own threshold is ignored so the pipeline threshold is the only knob
+	_, score := t.scorer.IsAddressed(chat.Text)
+	if score >= t.threshold {
+		return TriggerDecision{Reply: true, Reason: TriggerReasonAddressed, Score: score}
+	}
+	return TriggerDecision{Reason: TriggerReasonBelowScore, Score: score}
+}
+
+func (t *Trigger) isBotName(login string) bool {
+	login = strings.ToLower(strings.TrimSpace(login))
+	if login == "" {
+		return false
+	}
+	for _, name := range t.names {
+		if login == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package twitchirc
+
+import (
+	"testing"
+
+	v2 "github.com/gempir/go-twitch-irc/v2"
+)
+
+type fakeScorer struct {
+	score float32
+	calls int
+}
+
+func (f *fakeScorer) IsAddressed(_ string) (bool, float32) {
+	f.calls++
+	return f.score >= 0.9, f.score
+}
+
+func TestTrigger_Evaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		tags       map[string]string
+		scorer     *fakeScorer
+		wantReply  bool
+		wantReason string
+		wantScored bool
+	}{
+		{name: "at mention", text: "@Pedro_El_Asistente what is a goroutine?", scorer: &fakeScorer{}, wantReply: true, wantReason: TriggerReasonMention},
+		{name: "at mention short name", text: "thanks @pedro!", scorer: &fakeScorer{}, wantReply: true, wantReason: TriggerReasonMention},
+		{
+			name:       "reply to pedro",
+			text:       "can you explain more?",
+			tags:       map[string]string{"reply-parent-user-login": "pedro_el_asistente"},
+			scorer:     &fakeScorer{},
+			wantReply:  true,
+			wantReason: TriggerReasonReply,
+		},
+		{
+			name:       "reply to someone else",
+			text:       "agreed",
+			tags:       map[string]string{"reply-parent-user-login": "soypetetech"},
+			scorer:     &fakeScorer{score: 0.2},
+			wantReason: TriggerReasonBelowScore,
+			wantScored: true,
+		},
+		{name: "score over threshold", text: "hey pedro what is go?", scorer: &fakeScorer{score: 0.72}, wantReply: true, wantReason: TriggerReasonAddressed, wantScored: true},
+		{name: "score under threshold", text: "pedro pascal was great", scorer: &fakeScorer{score: 0.41}, wantReason: TriggerReasonBelowScore, wantScored: true},
+		{name: "no scorer name mention", text: "hey Pedro tell me a joke", wantReply: true, wantReason: TriggerReasonNameMention},
+		{name: "no scorer part of a word", text: "my bot is called pedrobot", wantReason: TriggerReasonNotAddressed},
+		{name: "no scorer not addressed", text: "this bot uses an llm", wantReason: TriggerReasonNotAddressed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trigger *Trigger
+			if tt.scorer != nil {
+				trigger = NewTrigger(nil, 0.6, tt.scorer, nil)
+			} else {
+				trigger = NewTrigger(nil, 0.6, nil, nil)
+			}
+
+			msg := v2.PrivateMessage{
+				User:    v2.User{Name: "viewer", DisplayName: "viewer"},
+				Channel: "soypetetech",
+				Message: tt.text,
+				Tags:    tt.tags,
+			}
+			got := trigger.Evaluate(msg, cleanMessage(msg))
+
+			if got.Reply != tt.wantReply {
+				t.Errorf("Evaluate() reply = %v, want %v", got.Reply, tt.wantReply)
+			}
+			if got.Reason != tt.wantReason {
+				t.Errorf("Evaluate() reason = %q, want %q", got.Reason, tt.wantReason)
+			}
+			if tt.scorer != nil && (tt.scorer.calls > 0) != tt.wantScored {
+				t.Errorf("scorer calls = %d, want scored %v", tt.scorer.calls, tt.wantScored)
+			}
+		})
+	}
+}
+
+func TestTrigger_SetThreshold(t *testing.T) {
+	scorer := &fakeScorer{score: 0.7}
+	trigger := NewTrigger(nil, 0, scorer, nil)
+	msg := v2.PrivateMessage{Channel: "soypetetech", Message: "pedro what is go?"}
+
+	if got := trigger.Evaluate(msg, cleanMessage(msg)); !got.Reply {
+		t.Fatalf("default threshold should reply to a score of 0.7, got %+v", got)
+	}
+
+	trigger.SetThreshold(0.8)
+	if got := trigger.Evaluate(msg, cleanMessage(msg)); got.Reply || got.Score != 0.7 {
+		t.Errorf("raised threshold should not reply, got %+v", got)
+	}
+}
@@ -0,0 +1,100 @@
+package twitchirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Soypete/twitch-llm-bot/twitch/twentyquestions"
+)
+
+// leaderboardSize is how many viewers `!20q leaderboard` lists
+const leaderboardSize = 5
+
+// twentyQuestionsHandler handles `!20q start [category]`, `!20q ask <question>`,
+// `!20q end` and `!20q leaderboard`. Anything else is asked as a question.
+func (irc *IRC) twentyQuestionsHandler(games *twentyquestions.Manager) CommandHandler {
+	return func(ctx context.Context, inv CommandInvocation) (string, error) {
+		sub, rest, _ := strings.Cut(strings.TrimSpace(inv.RawArgs), " ")
+		rest = strings.TrimSpace(rest)
+
+		var reply string
+		var err error
+		switch strings.ToLower(sub) {
+		case "":
+			return irc.twentyQuestionsStatus(games, inv.Channel), nil
+		case "start", "new":
+			reply, err = games.StartGame(ctx, inv.Channel, inv.Username, rest)
+		case "ask":
+			if rest == "" {
+				return fmt.Sprintf("@%s ask a yes or no question, like !20q ask is it alive?", inv.Username), nil
+			}
+			reply, err = games.Ask(ctx, inv.Channel, inv.Username, rest)
+		case "end", "stop":
+			reply, err = games.End(ctx, inv.Channel, inv.Username, inv.Badge >= BadgeModerator)
+		case "leaderboard", "top":
+			return irc.twentyQuestionsLeaderboard(ctx, games, inv), nil
+		default:
+			reply, err = games.Ask(ctx, inv.Channel, inv.Username, strings.TrimSpace(inv.RawArgs))
+		}
+		return irc.twentyQuestionsReply(inv, reply, err), nil
+	}
+}
+
+// guessHandler handles `!guess <answer>` for the channel's Twenty Questions game
+func (irc *IRC) guessHandler(games *twentyquestions.Manager) CommandHandler {
+	return func(ctx context.Context, inv CommandInvocation) (string, error) {
+		guess := strings.TrimSpace(inv.RawArgs)
+		if guess == "" {
+			return fmt.Sprintf("@%s what's your guess?", inv.Username), nil
+		}
+		reply, err := games.Guess(ctx, inv.Channel, inv.Username, guess)
+		return irc.twentyQuestionsReply(inv, reply, err), nil
+	}
+}
+
+// twentyQuestionsReply tags the viewer and turns game errors into chat replies. Handler
+// errors discard the reply, so failures are answered here instead.
+func (irc *IRC) twentyQuestionsReply(inv CommandInvocation, reply string, err error) string {
+	switch {
+	case err == nil:
+		return fmt.Sprintf("@%s %s", inv.Username, reply)
+	case errors.Is(err, twentyquestions.ErrNoGame):
+		return fmt.Sprintf("@%s there's no game of Twenty Questions running, start one with !20q start", inv.Username)
+	case errors.Is(err, twentyquestions.ErrGameInProgress):
+		return fmt.Sprintf("@%s a game is already running, ask with !20q ask or guess with !guess", inv.Username)
+	case errors.Is(err, twentyquestions.ErrNotAllowed):
+		return fmt.Sprintf("@%s %s", inv.Username, err.Error())
+	default:
+		irc.logger.Error("twenty questions failed", "channel", inv.Channel, "command", inv.Name, "error", err.Error())
+		return fmt.Sprintf("@%s sorry, my brain glitched, try that again", inv.Username)
+	}
+}
+
+// twentyQuestionsStatus describes the channel's game
+func (irc *IRC) twentyQuestionsStatus(games *twentyquestions.Manager, channel string) string {
+	game, ok := games.Game(channel)
+	if !ok {
+		return "No game of Twenty Questions is running. Start one with !20q start [category]"
+	}
+	return fmt.Sprintf("Twenty Questions started by %s: %d questions asked. Ask with !20q ask and guess with !guess", game.Username, game.QuestionCount)
+}
+
+// twentyQuestionsLeaderboard lists the viewers with the most wins in the channel
+func (irc *IRC) twentyQuestionsLeaderboard(ctx context.Context, games *twentyquestions.Manager, inv CommandInvocation) string {
+	scores, err := games.Leaderboard(ctx, inv.Channel, leaderboardSize)
+	if err != nil {
+		irc.logger.Error("failed to load twenty questions leaderboard", "channel", inv.Channel, "error", err.Error())
+		return fmt.Sprintf("@%s I couldn't load the leaderboard", inv.Username)
+	}
+	if len(scores) == 0 {
+		return "Nobody has won Twenty Questions here yet, start a game with !20q start"
+	}
+
+	entries := make([]string, len(scores))
+	for i, score := range scores {
+		entries[i] = fmt.Sprintf("%d. %s (%d)", i+1, score.Username, score.Wins)
+	}
+	return "Twenty Questions leaderboard: " + strings.Join(entries, " ")
+}
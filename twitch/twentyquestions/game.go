@@ -0,0 +1,416 @@
+// Package twentyquestions runs games of Twenty Questions in Twitch chat. Pedro picks a
+// secret answer, any viewer can ask yes or no questions or guess, and every question and
+// guess counts towards the limit. Games that nobody plays are abandoned on a timer.
+package twentyquestions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/database"
+	"github.com/Soypete/twitch-llm-bot/logging"
+	"github.com/Soypete/twitch-llm-bot/metrics"
+	"github.com/Soypete/twitch-llm-bot/types"
+	"github.com/google/uuid"
+)
+
+// Platform is stored with every game so Twitch and Discord games share the tables
+const Platform = "twitch"
+
+var (
+	// ErrGameInProgress is returned when a channel already has a game
+	ErrGameInProgress = errors.New("a game is already in progress")
+	// ErrNoGame is returned when a channel has no game
+	ErrNoGame = errors.New("no game in progress")
+	// ErrNotAllowed is returned when a viewer tries to end someone else's game
+	ErrNotAllowed = errors.New("only the viewer who started the game or a moderator can end it")
+)
+
+// LLM picks answers, answers questions and judges guesses
+type LLM interface {
+	Start20Questions(ctx context.Context, channel, category string) (string, error)
+	Play20Questions(ctx context.Context, channel, answer, question string) (string, error)
+	Check20QuestionsGuess(ctx context.Context, answer, guess string) (bool, error)
+}
+
+// Config holds configuration for Twenty Questions games
+type Config struct {
+	// MaxQuestions is how many questions and guesses a game allows
+	MaxQuestions int
+
+	// IdleTimeout is how long a game can go without a question before it is abandoned
+	IdleTimeout time.Duration
+
+	// SweepInterval is how often idle games are checked
+	SweepInterval time.Duration
+}
+
+// DefaultConfig returns the default Twenty Questions configuration
+func DefaultConfig() Config {
+	return Config{
+		MaxQuestions:  20,
+		IdleTimeout:   10 * time.Minute,
+		SweepInterval: time.Minute,
+	}
+}
+
+// game is a channel's current game. mu serializes questions so they are counted in order.
+type game struct {
+	mu    sync.Mutex
+	state types.TwentyQuestionsGame
+}
+
+// Manager runs one game of Twenty Questions per channel
+type Manager struct {
+	llm      LLM
+	store    database.TwentyQuestionsStore
+	config   Config
+	logger   *logging.Logger
+	announce func(ctx context.Context, channel, text string)
+	now      func() time.Time
+
+	mu    sync.Mutex
+	games map[string]*game
+}
+
+// NewManager creates a Twenty Questions manager. The store may be nil, in which case
+// games are only kept in memory and there is no leaderboard.
+func NewManager(llm LLM, store database.TwentyQuestionsStore, config Config, logger *logging.Logger) *Manager {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	defaults := DefaultConfig()
+	if config.MaxQuestions <= 0 {
+		config.MaxQuestions = defaults.MaxQuestions
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaults.IdleTimeout
+	}
+	if config.SweepInterval <= 0 {
+		config.SweepInterval = defaults.SweepInterval
+	}
+	return &Manager{
+		llm:      llm,
+		store:    store,
+		config:   config,
+		logger:   logger,
+		announce: func(context.Context, string, string) {},
+		now:      time.Now,
+		games:    make(map[string]*game),
+	}
+}
+
+// SetAnnouncer sets the function used to tell a channel its game was abandoned
+func (m *Manager) SetAnnouncer(announce func(ctx context.Context, channel, text string)) {
+	m.announce = announce
+}
+
+// Start resumes the games that were running when Pedro stopped and abandons idle games
+// until ctx is done
+func (m *Manager) Start(ctx context.Context, wg *sync.WaitGroup) {
+	m.resume(ctx)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(m.config.SweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.sweep(ctx)
+			}
+		}
+	}()
+}
+
+// resume loads the games that have not ended from the store
+func (m *Manager) resume(ctx context.Context) {
+	if m.store == nil {
+		return
+	}
+	games, err := m.store.ListActiveTwentyQuestionsGames(ctx, Platform)
+	if err != nil {
+		m.logger.Error("failed to resume twenty questions games", "error", err.Error())
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, state := range games {
+		if _, ok := m.games[state.Channel]; ok || state.Channel == "" {
+			continue
+		}
+		m.games[state.Channel] = &game{state: state}
+		m.logger.Info("resumed twenty questions game", "channel", state.Channel, "gameID", state.ID, "questions", state.QuestionCount)
+	}
+}
+
+// sweep abandons games that have been idle for longer than the idle timeout
+func (m *Manager) sweep(ctx context.Context) {
+	m.mu.Lock()
+	games := make([]*game, 0, len(m.games))
+	for _, g := range m.games {
+		games = append(games, g)
+	}
+	m.mu.Unlock()
+
+	// Announcements go through the output filter, which can call the LLM, so they are
+	// sent after the games are unlocked
+	type announcement struct{ channel, text string }
+	var announcements []announcement
+	for _, g := range games {
+		g.mu.Lock()
+		if g.state.Status == types.GameStarted && m.now().Sub(g.state.LastActivityAt) >= m.config.IdleTimeout {
+			m.finish(ctx, g, types.GameAbandoned, "")
+			announcements = append(announcements, announcement{
+				channel: g.state.Channel,
+				text:    fmt.Sprintf("Nobody has asked a question in a while, so Twenty Questions is over. The answer was %s", g.state.Answer),
+			})
+		}
+		g.mu.Unlock()
+	}
+	for _, a := range announcements {
+		m.announce(ctx, a.channel, a.text)
+	}
+}
+
+// active returns the channel's game, or nil
+func (m *Manager) active(channel string) *game {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.games[channel]
+}
+
+// lockActive returns the channel's game locked, or ErrNoGame
+func (m *Manager) lockActive(channel string) (*game, error) {
+	g := m.active(channel)
+	if g == nil {
+		return nil, ErrNoGame
+	}
+	g.mu.Lock()
+	// The game may have ended while we waited for the lock
+	if g.state.Status != types.GameStarted {
+		g.mu.Unlock()
+		return nil, ErrNoGame
+	}
+	return g, nil
+}
+
+// Game returns the channel's current game
+func (m *Manager) Game(channel string) (types.TwentyQuestionsGame, bool) {
+	g := m.active(channel)
+	if g == nil {
+		return types.TwentyQuestionsGame{}, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state, g.state.Status == types.GameStarted
+}
+
+// StartGame starts a game in the channel. The category is optional.
+func (m *Manager) StartGame(ctx context.Context, channel, username, category string) (string, error) {
+	if m.active(channel) != nil {
+		return "", ErrGameInProgress
+	}
+
+	answer, err := m.llm.Start20Questions(ctx, channel, category)
+	if err != nil {
+		return "", fmt.Errorf("failed to pick an answer: %w", err)
+	}
+
+	now := m.now()
+	g := &game{state: types.TwentyQuestionsGame{
+		ID:             uuid.New(),
+		Platform:       Platform,
+		Channel:        channel,
+		Username:       username,
+		Answer:         answer,
+		Status:         types.GameStarted,
+		CreatedAt:      now,
+		LastActivityAt: now,
+	}}
+
+	// Questions wait for the game to be saved
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Someone else may have started a game while the answer was picked
+	m.mu.Lock()
+	if _, ok := m.games[channel]; ok {
+		m.mu.Unlock()
+		return "", ErrGameInProgress
+	}
+	m.games[channel] = g
+	m.mu.Unlock()
+
+	if m.store != nil {
+		if err := m.store.InsertTwentyQuestionsGame(ctx, g.state); err != nil {
+			m.logger.Error("failed to save twenty questions game", "channel", channel, "error", err.Error())
+		}
+	}
+	metrics.TwentyQuestionsGamesTotal.WithLabelValues("started").Inc()
+	m.logger.Info("started twenty questions game", "channel", channel, "gameID", g.state.ID, "username", username)
+
+	about := "something"
+	if category != "" {
+		about = fmt.Sprintf("something in the category %s", category)
+	}
+	return fmt.Sprintf("I'm thinking of %s. Ask yes or no questions with !20q ask and guess with !guess. You have %d questions, guesses count too!", about, m.config.MaxQuestions), nil
+}
+
+// Ask answers a yes or no question about the channel's game
+func (m *Manager) Ask(ctx context.Context, channel, username, question string) (string, error) {
+	g, err := m.lockActive(channel)
+	if err != nil {
+		return "", err
+	}
+	defer g.mu.Unlock()
+
+	response, err := m.llm.Play20Questions(ctx, channel, g.state.Answer, question)
+	if err != nil {
+		return "", fmt.Errorf("failed to answer question: %w", err)
+	}
+	// The answer must never be given away by a question
+	if strings.Contains(strings.ToLower(response), strings.ToLower(g.state.Answer)) {
+		m.logger.Warn("twenty questions response revealed the answer", "channel", channel, "gameID", g.state.ID)
+		response = verdictOnly(response)
+	}
+
+	m.record(ctx, g, username, question, response, false)
+	metrics.TwentyQuestionsQuestionsTotal.WithLabelValues("question").Inc()
+
+	reply := fmt.Sprintf("%s (%d/%d)", response, g.state.QuestionCount, m.config.MaxQuestions)
+	if g.state.QuestionCount >= m.config.MaxQuestions {
+		m.finish(ctx, g, types.GameEnded, "")
+		reply += fmt.Sprintf(" That was the last question! The answer was %s", g.state.Answer)
+	}
+	return reply, nil
+}
+
+// Guess checks a viewer's guess. A correct guess wins the game.
+func (m *Manager) Guess(ctx context.Context, channel, username, guess string) (string, error) {
+	g, err := m.lockActive(channel)
+	if err != nil {
+		return "", err
+	}
+	defer g.mu.Unlock()
+
+	correct, err := m.llm.Check20QuestionsGuess(ctx, g.state.Answer, guess)
+	if err != nil {
+		return "", fmt.Errorf("failed to check guess: %w", err)
+	}
+
+	response := "wrong"
+	if correct {
+		response = "correct"
+	}
+	m.record(ctx, g, username, guess, response, true)
+	metrics.TwentyQuestionsQuestionsTotal.WithLabelValues("guess").Inc()
+
+	if correct {
+		m.finish(ctx, g, types.GameEnded, username)
+		return fmt.Sprintf("got it! The answer was %s, solved in %d questions", g.state.Answer, g.state.QuestionCount), nil
+	}
+	if g.state.QuestionCount >= m.config.MaxQuestions {
+		m.finish(ctx, g, types.GameEnded, "")
+		return fmt.Sprintf("nope, and that was the last question! The answer was %s", g.state.Answer), nil
+	}
+	return fmt.Sprintf("nope, it's not %s (%d/%d)", guess, g.state.QuestionCount, m.config.MaxQuestions), nil
+}
+
+// End ends the channel's game and reveals the answer. Only the viewer who started the
+// game can end it unless force is set.
+func (m *Manager) End(ctx context.Context, channel, username string, force bool) (string, error) {
+	g, err := m.lockActive(channel)
+	if err != nil {
+		return "", err
+	}
+	defer g.mu.Unlock()
+
+	if !force && !strings.EqualFold(username, g.state.Username) {
+		return "", ErrNotAllowed
+	}
+	m.finish(ctx, g, types.GameEnded, "")
+	return fmt.Sprintf("Twenty Questions is over. The answer was %s", g.state.Answer), nil
+}
+
+// Leaderboard returns the viewers with the most wins in the channel
+func (m *Manager) Leaderboard(ctx context.Context, channel string, limit int) ([]types.TwentyQuestionsScore, error) {
+	if m.store == nil {
+		return nil, errors.New("no database to keep a leaderboard")
+	}
+	return m.store.TwentyQuestionsLeaderboard(ctx, Platform, channel, limit)
+}
+
+// record counts a question or guess and saves it. g must be locked.
+func (m *Manager) record(ctx context.Context, g *game, username, question, response string, isGuess bool) {
+	g.state.QuestionCount++
+	g.state.LastActivityAt = m.now()
+	if m.store == nil {
+		return
+	}
+
+	if err := m.store.InsertTwentyQuestion(ctx, types.TwentyQuestion{
+		GameID:   g.state.ID,
+		Username: username,
+		Question: question,
+		Response: response,
+		IsGuess:  isGuess,
+	}); err != nil {
+		m.logger.Error("failed to save twenty question", "gameID", g.state.ID, "error", err.Error())
+	}
+	if err := m.store.UpdateTwentyQuestionsGame(ctx, g.state); err != nil {
+		m.logger.Error("failed to update twenty questions game", "gameID", g.state.ID, "error", err.Error())
+	}
+}
+
+// finish ends a game and removes it from its channel. g must be locked.
+func (m *Manager) finish(ctx context.Context, g *game, status, winner string) {
+	now := m.now()
+	g.state.Status = status
+	g.state.Winner = sql.NullString{String: winner, Valid: winner != ""}
+	g.state.LastActivityAt = now
+	g.state.EndedAt = sql.NullTime{Time: now, Valid: true}
+
+	m.mu.Lock()
+	if m.games[g.state.Channel] == g {
+		delete(m.games, g.state.Channel)
+	}
+	m.mu.Unlock()
+
+	result := status
+	if winner != "" {
+		result = "won"
+	}
+	metrics.TwentyQuestionsGamesTotal.WithLabelValues(result).Inc()
+	m.logger.Info("twenty questions game over", "channel", g.state.Channel, "gameID", g.state.ID, "status", status, "winner", winner, "questions", g.state.QuestionCount)
+
+	if m.store == nil {
+		return
+	}
+	if err := m.store.UpdateTwentyQuestionsGame(ctx, g.state); err != nil {
+		m.logger.Error("failed to update twenty questions game", "gameID", g.state.ID, "error", err.Error())
+	}
+}
+
+// verdictOnly keeps the yes or no from a response that gave the answer away
+func verdictOnly(response string) string {
+	fields := strings.Fields(response)
+	if len(fields) > 0 {
+		first := strings.TrimRight(fields[0], ",.!")
+		for _, verdict := range []string{"Yes", "No", "Sometimes"} {
+			if strings.EqualFold(first, verdict) {
+				return verdict
+			}
+		}
+	}
+	return "I don't know"
+}
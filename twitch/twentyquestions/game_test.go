@@ -0,0 +1,298 @@
+package twentyquestions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Soypete/twitch-llm-bot/types"
+)
+
+// fakeLLM always picks the same answer, answers every question with response and
+// accepts guesses that equal the answer
+type fakeLLM struct {
+	answer   string
+	response string
+}
+
+func (f *fakeLLM) Start20Questions(_ context.Context, _, _ string) (string, error) {
+	return f.answer, nil
+}
+
+func (f *fakeLLM) Play20Questions(_ context.Context, _, _, _ string) (string, error) {
+	return f.response, nil
+}
+
+func (f *fakeLLM) Check20QuestionsGuess(_ context.Context, answer, guess string) (bool, error) {
+	return strings.EqualFold(answer, guess), nil
+}
+
+// fakeStore keeps games and questions in memory
+type fakeStore struct {
+	mu        sync.Mutex
+	games     map[string]types.TwentyQuestionsGame
+	questions []types.TwentyQuestion
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{games: make(map[string]types.TwentyQuestionsGame)}
+}
+
+func (s *fakeStore) InsertTwentyQuestionsGame(_ context.Context, game types.TwentyQuestionsGame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.games[game.ID.String()] = game
+	return nil
+}
+
+func (s *fakeStore) UpdateTwentyQuestionsGame(_ context.Context, game types.TwentyQuestionsGame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.games[game.ID.String()]; !ok {
+		return errors.New("game not found")
+	}
+	s.games[game.ID.String()] = game
+	return nil
+}
+
+func (s *fakeStore) InsertTwentyQuestion(_ context.Context, question types.TwentyQuestion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.questions = append(s.questions, question)
+	return nil
+}
+
+func (s *fakeStore) ListActiveTwentyQuestionsGames(_ context.Context, platform string) ([]types.TwentyQuestionsGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var games []types.TwentyQuestionsGame
+	for _, game := range s.games {
+		if game.Platform == platform && game.Status == types.GameStarted {
+			games = append(games, game)
+		}
+	}
+	return games, nil
+}
+
+func (s *fakeStore) TwentyQuestionsLeaderboard(_ context.Context, _, channel string, _ int) ([]types.TwentyQuestionsScore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wins := make(map[string]int)
+	for _, game := range s.games {
+		if game.Channel == channel && game.Winner.Valid {
+			wins[game.Winner.String]++
+		}
+	}
+	var scores []types.TwentyQuestionsScore
+	for username, n := range wins {
+		scores = append(scores, types.TwentyQuestionsScore{Username: username, Wins: n})
+	}
+	return scores, nil
+}
+
+// onlyGame returns the single game in the store
+func (s *fakeStore) onlyGame(t *testing.T) types.TwentyQuestionsGame {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.games) != 1 {
+		t.Fatalf("store has %d games, want 1", len(s.games))
+	}
+	for _, game := range s.games {
+		return game
+	}
+	return types.TwentyQuestionsGame{}
+}
+
+func TestManager_Play(t *testing.T) {
+	type play struct {
+		username string
+		text     string
+		guess    bool
+	}
+	tests := []struct {
+		name       string
+		response   string
+		plays      []play
+		wantReply  string
+		wantStatus string
+		wantWinner string
+		wantCount  int
+	}{
+		{
+			name:       "correct guess wins",
+			response:   "Yes!",
+			plays:      []play{{"alice", "is it an animal?", false}, {"bob", "Gopher", true}},
+			wantReply:  "got it! The answer was gopher, solved in 2 questions",
+			wantStatus: types.GameEnded,
+			wantWinner: "bob",
+			wantCount:  2,
+		},
+		{
+			name:       "wrong guess counts as a question",
+			response:   "No.",
+			plays:      []play{{"alice", "mole", true}},
+			wantReply:  "nope, it's not mole (1/3)",
+			wantStatus: types.GameStarted,
+			wantCount:  1,
+		},
+		{
+			name:       "last question reveals the answer",
+			response:   "No.",
+			plays:      []play{{"alice", "is it big?", false}, {"bob", "mole", true}, {"carol", "is it blue?", false}},
+			wantReply:  "No. (3/3) That was the last question! The answer was gopher",
+			wantStatus: types.GameEnded,
+			wantCount:  3,
+		},
+		{
+			name:       "responses never reveal the answer",
+			response:   "Yes, the gopher is a rodent.",
+			plays:      []play{{"alice", "is it a rodent?", false}},
+			wantReply:  "Yes (1/3)",
+			wantStatus: types.GameStarted,
+			wantCount:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			m := NewManager(&fakeLLM{answer: "gopher", response: tt.response}, store, Config{MaxQuestions: 3}, nil)
+			ctx := context.Background()
+
+			if _, err := m.StartGame(ctx, "soypetetech", "alice", "animals"); err != nil {
+				t.Fatalf("StartGame() error = %v", err)
+			}
+			var reply string
+			var err error
+			for _, p := range tt.plays {
+				if p.guess {
+					reply, err = m.Guess(ctx, "soypetetech", p.username, p.text)
+				} else {
+					reply, err = m.Ask(ctx, "soypetetech", p.username, p.text)
+				}
+				if err != nil {
+					t.Fatalf("play %q error = %v", p.text, err)
+				}
+			}
+			if reply != tt.wantReply {
+				t.Errorf("reply = %q, want %q", reply, tt.wantReply)
+			}
+
+			game := store.onlyGame(t)
+			if game.Status != tt.wantStatus || game.Winner.String != tt.wantWinner || game.QuestionCount != tt.wantCount {
+				t.Errorf("game = %s winner %q after %d questions, want %s winner %q after %d", game.Status, game.Winner.String, game.QuestionCount, tt.wantStatus, tt.wantWinner, tt.wantCount)
+			}
+			if len(store.questions) != len(tt.plays) {
+				t.Errorf("stored %d questions, want %d", len(store.questions), len(tt.plays))
+			}
+			if _, active := m.Game("soypetetech"); active != (tt.wantStatus == types.GameStarted) {
+				t.Errorf("Game() active = %v, want %v", active, tt.wantStatus == types.GameStarted)
+			}
+		})
+	}
+}
+
+func TestManager_Errors(t *testing.T) {
+	m := NewManager(&fakeLLM{answer: "gopher", response: "No"}, nil, DefaultConfig(), nil)
+	ctx := context.Background()
+
+	if _, err := m.Ask(ctx, "soypetetech", "alice", "is it big?"); !errors.Is(err, ErrNoGame) {
+		t.Errorf("Ask() without a game error = %v, want ErrNoGame", err)
+	}
+	if _, err := m.StartGame(ctx, "soypetetech", "alice", ""); err != nil {
+		t.Fatalf("StartGame() error = %v", err)
+	}
+	if _, err := m.StartGame(ctx, "soypetetech", "bob", ""); !errors.Is(err, ErrGameInProgress) {
+		t.Errorf("second StartGame() error = %v, want ErrGameInProgress", err)
+	}
+	if _, err := m.StartGame(ctx, "forgeutah", "bob", ""); err != nil {
+		t.Errorf("StartGame() in another channel error = %v", err)
+	}
+	if _, err := m.End(ctx, "soypetetech", "bob", false); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("End() by another viewer error = %v, want ErrNotAllowed", err)
+	}
+	if _, err := m.End(ctx, "soypetetech", "bob", true); err != nil {
+		t.Errorf("End() by a moderator error = %v", err)
+	}
+	if _, err := m.Guess(ctx, "soypetetech", "bob", "gopher"); !errors.Is(err, ErrNoGame) {
+		t.Errorf("Guess() after the game ended error = %v, want ErrNoGame", err)
+	}
+	if _, err := m.Leaderboard(ctx, "soypetetech", 5); err == nil {
+		t.Error("Leaderboard() without a store should fail")
+	}
+}
+
+func TestManager_AbandonsIdleGames(t *testing.T) {
+	store := newFakeStore()
+	m := NewManager(&fakeLLM{answer: "gopher", response: "No"}, store, Config{IdleTimeout: 10 * time.Minute}, nil)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	var announced []string
+	var g *game
+	m.SetAnnouncer(func(_ context.Context, channel, text string) {
+		// Announcing can be slow, so it must not hold up the game
+		if !g.mu.TryLock() {
+			t.Error("announced while holding the game lock")
+		} else {
+			g.mu.Unlock()
+		}
+		announced = append(announced, channel+": "+text)
+	})
+
+	ctx := context.Background()
+	if _, err := m.StartGame(ctx, "soypetetech", "alice", ""); err != nil {
+		t.Fatalf("StartGame() error = %v", err)
+	}
+	g = m.active("soypetetech")
+
+	now = now.Add(9 * time.Minute)
+	m.sweep(ctx)
+	if _, active := m.Game("soypetetech"); !active || len(announced) != 0 {
+		t.Fatal("game was abandoned before the idle timeout")
+	}
+
+	now = now.Add(time.Minute)
+	m.sweep(ctx)
+	if _, active := m.Game("soypetetech"); active {
+		t.Fatal("idle game is still running")
+	}
+	if game := store.onlyGame(t); game.Status != types.GameAbandoned {
+		t.Errorf("stored status = %s, want %s", game.Status, types.GameAbandoned)
+	}
+	want := "soypetetech: Nobody has asked a question in a while, so Twenty Questions is over. The answer was gopher"
+	if len(announced) != 1 || announced[0] != want {
+		t.Errorf("announced %q, want %q", announced, want)
+	}
+}
+
+func TestManager_Resume(t *testing.T) {
+	store := newFakeStore()
+	ctx := context.Background()
+	first := NewManager(&fakeLLM{answer: "gopher", response: "No"}, store, DefaultConfig(), nil)
+	if _, err := first.StartGame(ctx, "soypetetech", "alice", ""); err != nil {
+		t.Fatalf("StartGame() error = %v", err)
+	}
+	if _, err := first.Ask(ctx, "soypetetech", "alice", "is it big?"); err != nil {
+		t.Fatalf("Ask() error = %v", err)
+	}
+
+	// A restarted bot picks up where the game left off
+	second := NewManager(&fakeLLM{answer: "other", response: "No"}, store, DefaultConfig(), nil)
+	second.resume(ctx)
+	reply, err := second.Guess(ctx, "soypetetech", "bob", "gopher")
+	if err != nil {
+		t.Fatalf("Guess() error = %v", err)
+	}
+	if want := "got it! The answer was gopher, solved in 2 questions"; reply != want {
+		t.Errorf("Guess() = %q, want %q", reply, want)
+	}
+
+	scores, err := second.Leaderboard(ctx, "soypetetech", 5)
+	if err != nil || len(scores) != 1 || scores[0].Username != "bob" || scores[0].Wins != 1 {
+		t.Errorf("Leaderboard() = %v, %v, want bob with 1 win", scores, err)
+	}
+}
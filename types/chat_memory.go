@@ -0,0 +1,31 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatMemory is a past chat message that is similar to a new one, with Pedro's answer to it
+type ChatMemory struct {
+	MessageID  uuid.UUID `db:"uuid"`
+	Channel    string    `db:"channel"`
+	Username   string    `db:"username"`
+	Text       string    `db:"message"`
+	Response   string    `db:"response"` // Pedro's answer, empty if he did not answer
+	Time       time.Time `db:"created_at"`
+	Similarity float64   `db:"similarity"`
+}
+
+// ChatMemoryQuery finds chat messages similar to an embedding
+type ChatMemoryQuery struct {
+	Embedding []float32
+	// Model is the embedding model, only embeddings from the same model are compared
+	Model string
+	// Channel limits the search to one channel. Empty searches every channel.
+	Channel string
+	// Before excludes messages sent at or after it
+	Before        time.Time
+	Limit         int
+	MinSimilarity float64
+}
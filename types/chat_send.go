@@ -0,0 +1,23 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatSend is a record of a message Pedro sent to Twitch chat through the outbound queue.
+// Long messages are split, so one logical message can have several parts.
+type ChatSend struct {
+	ID       uuid.UUID     `db:"id"`
+	Channel  string        `db:"channel"`
+	Message  string        `db:"message"`
+	Source   string        `db:"source"`
+	Priority string        `db:"priority"`
+	Part     int           `db:"part"`
+	Parts    int           `db:"parts"`
+	ChatID   uuid.NullUUID `db:"chat_id"`  // the twitch_chat message this is a response to, if any
+	ReplyTo  string        `db:"reply_to"` // the Twitch message ID this was sent as a reply to, if any
+	QueuedAt time.Time     `db:"queued_at"`
+	SentAt   time.Time     `db:"sent_at"`
+}
@@ -0,0 +1,34 @@
+package types
+
+import "github.com/lib/pq"
+
+// ChatCommand is the definition of a `!` chat command, loaded from YAML or the
+// chat_commands table. Exactly one of Response, LLMPrompt or Handler is set.
+type ChatCommand struct {
+	// Name is the command without the leading ! (e.g. discord)
+	Name    string         `yaml:"name" db:"name"`
+	Aliases pq.StringArray `yaml:"aliases,omitempty" db:"aliases"`
+
+	// Description is shown by the !commands handler
+	Description string `yaml:"description,omitempty" db:"description"`
+
+	// Args declares the arguments, e.g. "<topic...>" or "<user> [reason...]".
+	// <name> is required, [name] is optional and a trailing ... takes the rest of the message.
+	Args string `yaml:"args,omitempty" db:"args"`
+
+	// Response is static reply text. {user}, {channel}, {args} and argument names are replaced.
+	Response string `yaml:"response,omitempty" db:"response"`
+	// LLMPrompt is sent to the LLM after the same replacements and the answer is the reply.
+	LLMPrompt string `yaml:"llm_prompt,omitempty" db:"llm_prompt"`
+	// Handler is the name of a Go handler registered in code.
+	Handler string `yaml:"handler,omitempty" db:"handler"`
+
+	CooldownSeconds     int `yaml:"cooldown_seconds,omitempty" db:"cooldown_seconds"`
+	UserCooldownSeconds int `yaml:"user_cooldown_seconds,omitempty" db:"user_cooldown_seconds"`
+
+	// Badge is the minimum badge needed: everyone, subscriber, vip, moderator or broadcaster
+	Badge string `yaml:"badge,omitempty" db:"badge"`
+
+	// Channels limits the command to these channels. Empty means every channel.
+	Channels pq.StringArray `yaml:"channels,omitempty" db:"channels"`
+}
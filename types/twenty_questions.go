@@ -0,0 +1,45 @@
+package types
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Twenty Questions game statuses, matching the game_status enum
+const (
+	GameStarted   = "started"
+	GameEnded     = "ended"
+	GameAbandoned = "abandoned"
+)
+
+// TwentyQuestionsGame is a game of Twenty Questions in a chat channel
+type TwentyQuestionsGame struct {
+	ID             uuid.UUID      `db:"game_id"`
+	Platform       string         `db:"platform"`
+	Channel        string         `db:"channel"`
+	Username       string         `db:"username"` // the viewer who started the game
+	Answer         string         `db:"answer"`
+	QuestionCount  int            `db:"question_count"`
+	Status         string         `db:"status"`
+	Winner         sql.NullString `db:"winner"`
+	CreatedAt      time.Time      `db:"created_at"`
+	LastActivityAt time.Time      `db:"last_activity_at"`
+	EndedAt        sql.NullTime   `db:"ended_at"`
+}
+
+// TwentyQuestion is one question or guess in a game of Twenty Questions
+type TwentyQuestion struct {
+	GameID   uuid.UUID `db:"game_id"`
+	Username string    `db:"username"`
+	Question string    `db:"question"`
+	Response string    `db:"response"`
+	IsGuess  bool      `db:"is_guess"`
+}
+
+// TwentyQuestionsScore is a viewer's number of Twenty Questions wins in a channel
+type TwentyQuestionsScore struct {
+	Username string `db:"username"`
+	Wins     int    `db:"wins"`
+}
@@ -4,25 +4,30 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/tmc/langchaingo/llms"
 )
 
 // TwitchMessage represents a message sent in Twitch chat. Contains all the Metadata
 // need to related information to context and the llm  calls.
 type TwitchMessage struct {
-	Username      string            `db:"username"`
-	Text          string            `db:"message"`
-	IsCommand     bool              `db:"isCommand"`
-	StopReason    string            `db:"stop_reason"`
-	Time          time.Time         `db:"created_at"`
-	UUID          uuid.UUID         `db:"uuid"`
-	WebSearch     *WebSearchRequest `db:"-"` // Not stored in database
-	PalaceContext string            `db:"-"` // Context from palace session (not stored)
+	Username      string    `db:"username"`
+	Text          string    `db:"message"`
+	Channel       string    `db:"channel"`
+	IsCommand     bool      `db:"isCommand"`
+	StopReason    string    `db:"stop_reason"`
+	Time          time.Time `db:"created_at"`
+	UUID          uuid.UUID `db:"uuid"`
+	PalaceContext string    `db:"-"`                 // Context from palace session (not stored)
+	MemoryContext string    `db:"-"`                 // Similar messages from past streams (not stored)
+	TwitchID      string    `db:"twitch_message_id"` // Twitch's ID for a chat message
+	ReplyTo       string    `db:"-"`                 // Twitch ID of the chat message a response answers
+	PromptVersion string    `db:"prompt_version"`    // prompt templates and persona that produced a response
+	Sources       []Source  `db:"-"`                 // pages a response was based on, stored as JSON
 }
 
-// WebSearchRequest contains information needed for async web search
-type WebSearchRequest struct {
-	Query       string
-	OriginalMsg TwitchMessage
-	ChatHistory []llms.MessageContent
+// PastAnswer is one of Pedro's earlier answers and the chat message it replied to
+type PastAnswer struct {
+	Username  string    `db:"username"`
+	Message   string    `db:"message"`
+	Response  string    `db:"response"`
+	CreatedAt time.Time `db:"created_at"`
 }
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchCacheEntry is a cached web search
+type SearchCacheEntry struct {
+	// Key is the normalized query
+	Key   string `db:"query_key"`
+	Query string `db:"query"`
+	// Results are the search results as JSON
+	Results   json.RawMessage `db:"results"`
+	CreatedAt time.Time       `db:"created_at"`
+
+	// Embedding of the query and the model that made it, empty when not embedded
+	Embedding      []float32 `db:"-"`
+	EmbeddingModel string    `db:"embedding_model"`
+	// Similarity to the looked up query, set by similarity lookups
+	Similarity float64 `db:"similarity"`
+}
+
+// SearchCacheQuery finds the cached search most similar to an embedding
+type SearchCacheQuery struct {
+	Embedding []float32
+	// Model is the embedding model, only embeddings from the same model are compared
+	Model string
+	// Since excludes entries cached before it
+	Since         time.Time
+	MinSimilarity float64
+}
+
+// Source is a page one of Pedro's answers was based on
+type Source struct {
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url"`
+	// Tool is the tool that found the page, such as web_search or fetch_page
+	Tool string `json:"tool,omitempty"`
+}
+
+// AnswerSources are the sources behind one of Pedro's answers
+type AnswerSources struct {
+	// ChatID is the chat message the answer replied to
+	ChatID    uuid.UUID `json:"chat_id"`
+	Channel   string    `json:"channel"`
+	Username  string    `json:"username"`
+	Response  string    `json:"response"`
+	Sources   []Source  `json:"sources"`
+	CreatedAt time.Time `json:"created_at"`
+}